@@ -38,6 +38,10 @@ func (c capabilitySet) Clear() {
 	}
 }
 
+// execCommandPrefix prefixes the command globs stored in a capabilitySet so
+// they are merged and denied like any other capability.
+const execCommandPrefix = "exec-command:"
+
 // ACL object is used to convert a set of policies into a structure that
 // can be efficiently evaluated to determine if an action is allowed.
 type ACL struct {
@@ -126,6 +130,9 @@ func NewACL(management bool, policies []*Policy) (*ACL, error) {
 				}
 				capabilities.Set(cap)
 			}
+			for _, cmd := range ns.ExecCommands {
+				capabilities.Set(execCommandPrefix + cmd)
+			}
 		}
 
 		// Take the maximum privilege for agent, node, and operator
@@ -171,6 +178,36 @@ func (a *ACL) AllowNamespaceOperation(ns string, op string) bool {
 	return capabilities.Check(op)
 }
 
+// AllowNsExec checks if the given command may be executed in the allocations
+// of a namespace. The alloc-exec capability allows any command, otherwise the
+// command must match one of the exec_commands globs of the namespace.
+func (a *ACL) AllowNsExec(ns string, command string) bool {
+	// Hot path management tokens
+	if a.management {
+		return true
+	}
+
+	// Check for a matching capability set
+	capabilities, ok := a.matchingCapabilitySet(ns)
+	if !ok {
+		return false
+	}
+
+	if capabilities.Check(NamespaceCapabilityAllocExec) {
+		return true
+	}
+
+	for cap := range capabilities {
+		if !strings.HasPrefix(cap, execCommandPrefix) {
+			continue
+		}
+		if glob.Glob(strings.TrimPrefix(cap, execCommandPrefix), command) {
+			return true
+		}
+	}
+	return false
+}
+
 // AllowNamespace checks if any operations are allowed for a namespace
 func (a *ACL) AllowNamespace(ns string) bool {
 	// Hot path management tokens
@@ -263,6 +263,75 @@ func TestAllowNamespace(t *testing.T) {
 	}
 }
 
+func TestAllowNsExec(t *testing.T) {
+	tests := []struct {
+		Policy  string
+		Command string
+		Allow   bool
+	}{
+		{
+			Policy:  `namespace "foo" { policy = "write" }`,
+			Command: "/bin/ls",
+			Allow:   false,
+		},
+		{
+			Policy:  `namespace "foo" { capabilities = ["alloc-exec"] }`,
+			Command: "/bin/ls",
+			Allow:   true,
+		},
+		{
+			Policy:  `namespace "foo" { exec_commands = ["/bin/ls*", "cat"] }`,
+			Command: "/bin/ls -la",
+			Allow:   true,
+		},
+		{
+			Policy:  `namespace "foo" { exec_commands = ["/bin/ls*", "cat"] }`,
+			Command: "cat",
+			Allow:   true,
+		},
+		{
+			Policy:  `namespace "foo" { exec_commands = ["/bin/ls*", "cat"] }`,
+			Command: "/bin/sh",
+			Allow:   false,
+		},
+		{
+			Policy:  `namespace "bar" { exec_commands = ["*"] }`,
+			Command: "/bin/ls",
+			Allow:   false,
+		},
+		{
+			Policy:  `namespace "f*" { exec_commands = ["/bin/*"] }`,
+			Command: "/bin/sh",
+			Allow:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Policy+" "+tc.Command, func(t *testing.T) {
+			assert := assert.New(t)
+
+			policy, err := Parse(tc.Policy)
+			assert.Nil(err)
+
+			acl, err := NewACL(false, []*Policy{policy})
+			assert.Nil(err)
+
+			assert.Equal(tc.Allow, acl.AllowNsExec("foo", tc.Command))
+		})
+	}
+
+	// Deny takes precedence over the allowed commands of other policies
+	p1, err := Parse(`namespace "foo" { exec_commands = ["*"] }`)
+	assert.Nil(t, err)
+	p2, err := Parse(`namespace "foo" { policy = "deny" }`)
+	assert.Nil(t, err)
+	acl, err := NewACL(false, []*Policy{p1, p2})
+	assert.Nil(t, err)
+	assert.False(t, acl.AllowNsExec("foo", "/bin/ls"))
+
+	assert.True(t, ManagementACL.AllowNsExec("foo", "/bin/ls"))
+}
+
 func TestWildcardNamespaceMatching(t *testing.T) {
 	tests := []struct {
 		Policy string
@@ -3,6 +3,7 @@ package acl
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/hashicorp/hcl"
 )
@@ -29,6 +30,10 @@ const (
 	NamespaceCapabilityReadLogs         = "read-logs"
 	NamespaceCapabilityReadFS           = "read-fs"
 	NamespaceCapabilitySentinelOverride = "sentinel-override"
+	NamespaceCapabilityAllocProfile     = "alloc-profile"
+	NamespaceCapabilityWriteLogs        = "write-logs"
+	NamespaceCapabilityAllocExec        = "alloc-exec"
+	NamespaceCapabilityAllocLifecycle   = "alloc-lifecycle"
 )
 
 var (
@@ -60,6 +65,11 @@ type NamespacePolicy struct {
 	Name         string `hcl:",key"`
 	Policy       string
 	Capabilities []string
+
+	// ExecCommands is a list of command globs that may be executed in the
+	// allocations of the namespace. It is ignored if the broader alloc-exec
+	// capability is granted.
+	ExecCommands []string `hcl:"exec_commands"`
 }
 
 type AgentPolicy struct {
@@ -93,7 +103,8 @@ func isNamespaceCapabilityValid(cap string) bool {
 	switch cap {
 	case NamespaceCapabilityDeny, NamespaceCapabilityListJobs, NamespaceCapabilityReadJob,
 		NamespaceCapabilitySubmitJob, NamespaceCapabilityDispatchJob, NamespaceCapabilityReadLogs,
-		NamespaceCapabilityReadFS:
+		NamespaceCapabilityReadFS, NamespaceCapabilityAllocProfile, NamespaceCapabilityWriteLogs,
+		NamespaceCapabilityAllocExec, NamespaceCapabilityAllocLifecycle:
 		return true
 	// Separate the enterprise-only capabilities
 	case NamespaceCapabilitySentinelOverride:
@@ -163,6 +174,11 @@ func Parse(rules string) (*Policy, error) {
 				return nil, fmt.Errorf("Invalid namespace capability '%s': %#v", cap, ns)
 			}
 		}
+		for _, cmd := range ns.ExecCommands {
+			if strings.TrimSpace(cmd) == "" {
+				return nil, fmt.Errorf("Invalid namespace exec command '%s': %#v", cmd, ns)
+			}
+		}
 
 		// Expand the short hand policy to the capabilities and
 		// add to any existing capabilities
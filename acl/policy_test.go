@@ -122,6 +122,35 @@ func TestParse(t *testing.T) {
 			"Invalid namespace capability",
 			nil,
 		},
+		{
+			`
+			namespace "default" {
+				exec_commands = ["/bin/ls*", ""]
+			}
+			`,
+			"Invalid namespace exec command",
+			nil,
+		},
+		{
+			`
+			namespace "default" {
+				capabilities = ["alloc-exec"]
+				exec_commands = ["/bin/ls*"]
+			}
+			`,
+			"",
+			&Policy{
+				Namespaces: []*NamespacePolicy{
+					{
+						Name: "default",
+						Capabilities: []string{
+							NamespaceCapabilityAllocExec,
+						},
+						ExecCommands: []string{"/bin/ls*"},
+					},
+				},
+			},
+		},
 		{
 			`
 			agent {
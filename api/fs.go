@@ -1,9 +1,12 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"strconv"
 	"sync"
@@ -33,11 +36,42 @@ type StreamFrame struct {
 	Data      []byte `json:",omitempty"`
 	File      string `json:",omitempty"`
 	FileEvent string `json:",omitempty"`
+	FileIndex int64  `json:",omitempty"`
+	FileSize  int64  `json:",omitempty"`
+	ModTime   int64  `json:",omitempty"`
+
+	// Compressed is true when Data is gzip compressed. Frames returned by
+	// AllocFS are already decompressed.
+	Compressed bool `json:",omitempty"`
 }
 
 // IsHeartbeat returns if the frame is a heartbeat frame
 func (s *StreamFrame) IsHeartbeat() bool {
-	return len(s.Data) == 0 && s.FileEvent == "" && s.File == "" && s.Offset == 0
+	return len(s.Data) == 0 && s.FileEvent == "" && s.File == "" && s.Offset == 0 && s.FileIndex == 0 &&
+		s.FileSize == 0 && s.ModTime == 0 && !s.Compressed
+}
+
+// Decompress inflates the data of a compressed frame in place. It is a no-op
+// for uncompressed frames.
+func (s *StreamFrame) Decompress() error {
+	if !s.Compressed {
+		return nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(s.Data))
+	if err != nil {
+		return fmt.Errorf("failed to decompress frame: %v", err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to decompress frame: %v", err)
+	}
+
+	s.Data = data
+	s.Compressed = false
+	return nil
 }
 
 // AllocFS is used to introspect an allocation directory on a Nomad client
@@ -236,6 +270,12 @@ func (a *AllocFS) Stream(alloc *Allocation, path, origin string, offset int64,
 				continue
 			}
 
+			if err := frame.Decompress(); err != nil {
+				errCh <- err
+				close(frames)
+				return
+			}
+
 			frames <- &frame
 		}
 	}()
@@ -333,6 +373,11 @@ func (a *AllocFS) Logs(alloc *Allocation, follow bool, task, logType, origin str
 				continue
 			}
 
+			if err := frame.Decompress(); err != nil {
+				errCh <- err
+				return
+			}
+
 			frames <- &frame
 		}
 	}()
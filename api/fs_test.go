@@ -2,6 +2,8 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -289,3 +291,40 @@ func TestFS_FrameReader_Error(t *testing.T) {
 		t.Fatalf("bad error: %v", err)
 	}
 }
+
+func TestFS_StreamFrame_Decompress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data := []byte(strings.Repeat("hello, world ", 100))
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	// Compressed frames decode from the agent's JSON encoding
+	encoded, err := json.Marshal(map[string]interface{}{
+		"File":       "foo",
+		"Data":       buf.Bytes(),
+		"Compressed": true,
+	})
+	require.NoError(err)
+
+	var frame StreamFrame
+	require.NoError(json.Unmarshal(encoded, &frame))
+	require.True(frame.Compressed)
+	require.False(frame.IsHeartbeat())
+
+	require.NoError(frame.Decompress())
+	require.False(frame.Compressed)
+	require.Equal(data, frame.Data)
+
+	// Uncompressed frames are left as is
+	require.NoError(frame.Decompress())
+	require.Equal(data, frame.Data)
+
+	// Corrupt data is an error
+	bad := &StreamFrame{Data: []byte("not gzip"), Compressed: true}
+	require.Error(bad.Decompress())
+}
@@ -601,6 +601,8 @@ type HostDiskStats struct {
 	Available         uint64
 	UsedPercent       float64
 	InodesUsedPercent float64
+	InodesUsed        uint64
+	InodesTotal       uint64
 }
 
 // DeviceGroupStats contains statistics for each device of a particular
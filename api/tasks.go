@@ -27,6 +27,7 @@ type MemoryStats struct {
 	MaxUsage       uint64
 	KernelUsage    uint64
 	KernelMaxUsage uint64
+	RawUsage       uint64
 	Measured       []string
 }
 
@@ -38,6 +39,9 @@ type CpuStats struct {
 	ThrottledPeriods uint64
 	ThrottledTime    uint64
 	Percent          float64
+	RawPercent       float64
+	WaitTime         uint64
+	StealTime        uint64
 	Measured         []string
 }
 
@@ -51,17 +55,40 @@ type ResourceUsage struct {
 // TaskResourceUsage holds aggregated resource usage of all processes in a Task
 // and the resource usage of the individual pids
 type TaskResourceUsage struct {
-	ResourceUsage *ResourceUsage
-	Timestamp     int64
-	Pids          map[string]*ResourceUsage
+	ResourceUsage          *ResourceUsage
+	Timestamp              int64
+	Pids                   map[string]*ResourceUsage
+	CounterEpoch           uint64
+	MemoryReserved         uint64
+	MemoryMaxLimit         uint64
+	DriverStatsUnavailable bool
+	Cumulative             *TaskCumulativeStats
+	ConnectionStats        *ConnectionStats
+}
+
+// TaskCumulativeStats are the totals consumed by a task since it started
+type TaskCumulativeStats struct {
+	CpuTime      uint64
+	BytesWritten uint64
+	Measured     []string
+}
+
+// ConnectionStats are the TCP sockets of a task's network namespace by state
+type ConnectionStats struct {
+	States    map[string]uint64
+	Truncated bool
 }
 
 // AllocResourceUsage holds the aggregated task resource usage of the
 // allocation.
 type AllocResourceUsage struct {
-	ResourceUsage *ResourceUsage
-	Tasks         map[string]*TaskResourceUsage
-	Timestamp     int64
+	ResourceUsage          *ResourceUsage
+	Tasks                  map[string]*TaskResourceUsage
+	Timestamp              int64
+	CounterEpoch           uint64
+	MemoryReserved         uint64
+	MemoryMaxLimit         uint64
+	DriverStatsUnavailable bool
 }
 
 // RestartPolicy defines how the Nomad client restarts
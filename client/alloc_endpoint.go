@@ -1,12 +1,29 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/consul-template/signals"
 	"github.com/hashicorp/nomad/acl"
+	"github.com/hashicorp/nomad/client/allocrunner/taskrunner"
+	"github.com/hashicorp/nomad/client/lib/cgutil"
+	"github.com/hashicorp/nomad/client/lib/execaudit"
+	"github.com/hashicorp/nomad/client/lib/procfd"
+	"github.com/hashicorp/nomad/client/lib/procmount"
+	"github.com/hashicorp/nomad/client/lib/procsnap"
+	"github.com/hashicorp/nomad/client/lib/profiler"
+	"github.com/hashicorp/nomad/client/lib/ulimit"
 	cstructs "github.com/hashicorp/nomad/client/structs"
+	"github.com/hashicorp/nomad/helper"
 	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/ugorji/go/codec"
 )
 
 // Allocations endpoint is used for interacting with client allocations
@@ -14,6 +31,65 @@ type Allocations struct {
 	c *Client
 }
 
+// checkStatusPollInterval is the interval at which the status of an
+// allocation's checks is looked up when streaming check results.
+var checkStatusPollInterval = 500 * time.Millisecond
+
+// fdPollInterval is the interval at which the open file descriptors of a task
+// are listed when streaming their changes.
+var fdPollInterval = time.Second
+
+// artifactProgressPollInterval is the interval at which the progress of a
+// task's artifact downloads is looked up when streaming it.
+var artifactProgressPollInterval = 500 * time.Millisecond
+
+const (
+	// defaultStatsDiffWindow is the window between the two stats samples of
+	// a stats diff if none is requested.
+	defaultStatsDiffWindow = time.Second
+
+	// maxStatsDiffWindow is the largest window that can be requested for a
+	// stats diff.
+	maxStatsDiffWindow = time.Minute
+
+	// defaultStatsStreamInterval is the interval at which stats are sent
+	// when streaming the stats of an allocation if none is requested.
+	defaultStatsStreamInterval = time.Second
+
+	// minStatsStreamInterval is the smallest interval at which the stats of
+	// an allocation are streamed.
+	minStatsStreamInterval = 100 * time.Millisecond
+
+	// defaultExecOnceTimeout is the maximum runtime of a command run with
+	// ExecOnce if none is requested.
+	defaultExecOnceTimeout = 30 * time.Second
+
+	// maxExecOnceTimeout is the largest maximum runtime that can be
+	// requested for a command run with ExecOnce.
+	maxExecOnceTimeout = 5 * time.Minute
+
+	// defaultExecOnceOutputBytes is the number of bytes of each output
+	// stream returned by ExecOnce if no limit is requested.
+	defaultExecOnceOutputBytes = 64 * 1024
+
+	// maxExecOnceOutputBytes is the largest output limit that can be
+	// requested for ExecOnce.
+	maxExecOnceOutputBytes = 4 * 1024 * 1024
+)
+
+func NewAllocationsEndpoint(c *Client) *Allocations {
+	a := &Allocations{c}
+	a.c.streamingRpcs.Register("Allocations.Checks", a.checks)
+	a.c.streamingRpcs.Register("Allocations.Profile", a.profile)
+	a.c.streamingRpcs.Register("Allocations.StreamFDs", a.streamFDs)
+	a.c.streamingRpcs.Register("Allocations.GarbageCollect", a.garbageCollect)
+	a.c.streamingRpcs.Register("Allocations.LifecycleEvents", a.lifecycleEvents)
+	a.c.streamingRpcs.Register("Allocations.Decisions", a.decisions)
+	a.c.streamingRpcs.Register("Allocations.ArtifactProgress", a.artifactProgress)
+	a.c.streamingRpcs.Register("Allocations.StatsStream", a.statsStream)
+	return a
+}
+
 // GarbageCollectAll is used to garbage collect all allocations on a client.
 func (a *Allocations) GarbageCollectAll(args *nstructs.NodeSpecificRequest, reply *nstructs.GenericResponse) error {
 	defer metrics.MeasureSince([]string{"client", "allocations", "garbage_collect_all"}, time.Now())
@@ -29,8 +105,10 @@ func (a *Allocations) GarbageCollectAll(args *nstructs.NodeSpecificRequest, repl
 	return nil
 }
 
-// GarbageCollect is used to garbage collect an allocation on a client.
-func (a *Allocations) GarbageCollect(args *nstructs.AllocSpecificRequest, reply *nstructs.GenericResponse) error {
+// GarbageCollect is used to garbage collect an allocation on a client. A
+// single dead task of the allocation may be collected instead and a dry run
+// reports what would be reclaimed.
+func (a *Allocations) GarbageCollect(args *cstructs.AllocGarbageCollectRequest, reply *cstructs.AllocGarbageCollectResponse) error {
 	defer metrics.MeasureSince([]string{"client", "allocations", "garbage_collect"}, time.Now())
 
 	// Check submit job permissions
@@ -40,6 +118,15 @@ func (a *Allocations) GarbageCollect(args *nstructs.AllocSpecificRequest, reply
 		return nstructs.ErrPermissionDenied
 	}
 
+	if args.Task != "" || args.DryRun {
+		return a.garbageCollectPartial(args, reply)
+	}
+
+	// Fail fast if the allocations can't be accessed in time
+	if _, err := a.c.lookupAllocRunner(args.AllocID); err == allocLookupTimeoutErr {
+		return err
+	}
+
 	if !a.c.CollectAllocation(args.AllocID) {
 		// Could not find alloc
 		return nstructs.NewErrUnknownAllocation(args.AllocID)
@@ -48,6 +135,271 @@ func (a *Allocations) GarbageCollect(args *nstructs.AllocSpecificRequest, reply
 	return nil
 }
 
+// garbageCollectPartial garbage collects a single task of an allocation or
+// reports what garbage collecting the allocation or task would reclaim.
+func (a *Allocations) garbageCollectPartial(args *cstructs.AllocGarbageCollectRequest, reply *cstructs.AllocGarbageCollectResponse) error {
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	if args.Task != "" && ar.Alloc().LookupTask(args.Task) == nil {
+		return fmt.Errorf("unknown task name %q", args.Task)
+	}
+
+	if !args.DryRun {
+		var freed int64
+		if err := ar.CollectTask(context.Background(), args.Task, func(f int64) { freed = f }); err != nil {
+			return err
+		}
+
+		reply.Terminal = true
+		reply.Reclaimed = freed
+		return nil
+	}
+
+	if args.Task == "" {
+		reply.Terminal = a.c.AllocMarkedForCollection(args.AllocID)
+	} else if ts := ar.AllocState().TaskStates[args.Task]; ts != nil {
+		reply.Terminal = ts.State == nstructs.TaskStateDead
+	}
+
+	reply.Reclaimed, err = ar.GetAllocDir().DiskUsage(args.Task)
+	return err
+}
+
+// GarbageCollectMany is used to garbage collect multiple allocations on a
+// client, returning the result of each collection. Submit job permissions are
+// checked against the namespace of each allocation, and unknown allocations
+// are reported as denied to tokens other than management ones.
+func (a *Allocations) GarbageCollectMany(args *cstructs.AllocGarbageCollectManyRequest, reply *cstructs.AllocGarbageCollectManyResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "garbage_collect_many"}, time.Now())
+
+	aclObj, err := a.c.ResolveToken(args.AuthToken)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string]*cstructs.AllocGCResult, len(args.AllocIDs))
+	for _, allocID := range args.AllocIDs {
+		if _, ok := results[allocID]; ok {
+			continue
+		}
+
+		ar, err := a.c.lookupAllocRunner(allocID)
+		if err != nil {
+			// Unknown allocs are denied like the ones the token can't
+			// access so the results don't reveal which allocs exist
+			if aclObj != nil && !aclObj.IsManagement() && nstructs.IsErrUnknownAllocation(err) {
+				err = nstructs.ErrPermissionDenied
+			}
+			results[allocID] = &cstructs.AllocGCResult{
+				Result: cstructs.AllocGCResultError,
+				Error:  err.Error(),
+			}
+			continue
+		}
+
+		// Check submit job permissions in the alloc's namespace
+		if aclObj != nil && !aclObj.AllowNsOp(ar.Alloc().Namespace, acl.NamespaceCapabilitySubmitJob) {
+			results[allocID] = &cstructs.AllocGCResult{
+				Result: cstructs.AllocGCResultError,
+				Error:  nstructs.ErrPermissionDenied.Error(),
+			}
+			continue
+		}
+
+		// Only allocs marked for collection can be collected
+		if !a.c.CollectAllocation(allocID) {
+			results[allocID] = &cstructs.AllocGCResult{Result: cstructs.AllocGCResultSkipped}
+			continue
+		}
+
+		results[allocID] = &cstructs.AllocGCResult{Result: cstructs.AllocGCResultCollected}
+	}
+
+	reply.Results = results
+	return nil
+}
+
+// WouldGC is used to evaluate which allocations of a client would be garbage
+// collected under the given thresholds. Nothing is collected and the client's
+// configuration is not modified.
+func (a *Allocations) WouldGC(args *cstructs.AllocWouldGCRequest, reply *cstructs.AllocWouldGCResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "would_gc"}, time.Now())
+
+	// Check node read permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNodeRead() {
+		return nstructs.ErrPermissionDenied
+	}
+
+	collected, err := a.c.WouldCollectAllocs(func(config *GCConfig) {
+		if args.DiskUsageThreshold != nil {
+			config.DiskUsageThreshold = *args.DiskUsageThreshold
+		}
+		if args.InodeUsageThreshold != nil {
+			config.InodeUsageThreshold = *args.InodeUsageThreshold
+		}
+		if args.MaxAllocs != nil {
+			config.MaxAllocs = *args.MaxAllocs
+		}
+		if args.MinAllocRetention != nil {
+			config.MinAllocRetention = *args.MinAllocRetention
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	allocs := a.c.getAllocRunners()
+	reply.Allocs = make(map[string]*cstructs.AllocWouldGCResult, len(allocs))
+	for allocID := range allocs {
+		reason, eligible := collected[allocID]
+		reply.Allocs[allocID] = &cstructs.AllocWouldGCResult{
+			Eligible:  eligible,
+			Collected: reason != "",
+			Reason:    reason,
+		}
+	}
+	return nil
+}
+
+// garbageCollect is used to garbage collect an allocation on a client while
+// streaming the number of bytes freed. Closing the stream cancels the
+// collection, leaving the allocation marked for collection.
+func (a *Allocations) garbageCollect(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "garbage_collect_stream"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req nstructs.AllocSpecificRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check submit job permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilitySubmitJob) {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, "", req.QueryOptions.AuthToken)
+
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Only keep the latest progress if the stream is behind
+	progressCh := make(chan int64, 1)
+	progress := func(freed int64) {
+		select {
+		case <-progressCh:
+		default:
+		}
+		progressCh <- freed
+	}
+
+	type result struct {
+		found bool
+		err   error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		found, err := a.c.CollectAllocationContext(ctx, req.AllocID, progress)
+		resultCh <- result{found, err}
+	}()
+
+	// Create a goroutine to detect the remote side closing
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	buf := new(bytes.Buffer)
+	progressCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+	send := func(p *cstructs.AllocGCProgress) error {
+		if err := progressCodec.Encode(p); err != nil {
+			return err
+		}
+		progressCodec.Reset(buf)
+
+		resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+		err := encoder.Encode(resp)
+		buf.Reset()
+		if err != nil {
+			return err
+		}
+		encoder.Reset(conn)
+		return nil
+	}
+
+	var freed int64
+	for {
+		select {
+		case err := <-errCh:
+			cancel()
+			handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+			return
+		case freed = <-progressCh:
+			if err := send(&cstructs.AllocGCProgress{BytesFreed: freed}); err != nil {
+				cancel()
+				return
+			}
+		case res := <-resultCh:
+			if !res.found {
+				handleStreamResultError(nstructs.NewErrUnknownAllocation(req.AllocID), helper.Int64ToPtr(404), encoder)
+				return
+			}
+			if res.err != nil {
+				handleStreamResultError(res.err, helper.Int64ToPtr(500), encoder)
+				return
+			}
+
+			// Report the final progress
+			select {
+			case freed = <-progressCh:
+			default:
+			}
+			send(&cstructs.AllocGCProgress{BytesFreed: freed, Done: true})
+			return
+		}
+	}
+}
+
 // Stats is used to collect allocation statistics
 func (a *Allocations) Stats(args *cstructs.AllocStatsRequest, reply *cstructs.AllocStatsResponse) error {
 	defer metrics.MeasureSince([]string{"client", "allocations", "stats"}, time.Now())
@@ -59,17 +411,1786 @@ func (a *Allocations) Stats(args *cstructs.AllocStatsRequest, reply *cstructs.Al
 		return nstructs.ErrPermissionDenied
 	}
 
+	stats, err := a.allocStats(args)
+	if err != nil {
+		return err
+	}
+
+	reply.Stats = stats
+	return nil
+}
+
+// allocStats returns the latest stats of the allocation selected by the
+// request.
+func (a *Allocations) allocStats(args *cstructs.AllocStatsRequest) (*cstructs.AllocResourceUsage, error) {
 	clientStats := a.c.StatsReporter()
 	aStats, err := clientStats.GetAllocStats(args.AllocID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// The stats of some namespaces aren't collected
+	if ar, err := a.c.lookupAllocRunner(args.AllocID); err == nil {
+		if ns := ar.Alloc().Namespace; a.c.config.StatsDisabled(ns) {
+			return nil, fmt.Errorf("%s %q", cstructs.StatsDisabledErrPrefix, ns)
+		}
 	}
 
 	stats, err := aStats.LatestAllocStats(args.Task)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only return the cumulative totals when requested
+	if !args.Cumulative {
+		stripCumulative(stats)
+	}
+	return stats, nil
+}
+
+// statsStream streams the stats of an allocation on an interval until the
+// allocation stops running or the remote side closes the stream.
+func (a *Allocations) statsStream(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "stats_stream"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req cstructs.AllocStatsRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityReadJob) {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, req.Task, req.QueryOptions.AuthToken)
+
+	// Validate the arguments
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	interval := req.Interval
+	if interval < 0 {
+		handleStreamResultError(errors.New("interval must not be negative"), helper.Int64ToPtr(400), encoder)
+		return
+	} else if interval == 0 {
+		interval = defaultStatsStreamInterval
+	} else if interval < minStatsStreamInterval {
+		interval = minStatsStreamInterval
+	}
+
+	ar, err := a.c.lookupAllocRunner(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var streamErr error
+	buf := new(bytes.Buffer)
+	statsCodec := codec.NewEncoder(buf, nstructs.MsgpackHandle)
+OUTER:
+	for {
+		select {
+		case <-ar.WaitCh():
+			streamErr = fmt.Errorf("allocation %q is no longer running", req.AllocID)
+			break OUTER
+		default:
+		}
+
+		stats, err := a.allocStats(&req)
+		if err != nil {
+			streamErr = err
+			break OUTER
+		}
+
+		if err := statsCodec.Encode(&cstructs.AllocStatsResponse{Stats: stats}); err != nil {
+			streamErr = err
+			break OUTER
+		}
+		statsCodec.Reset(buf)
+
+		resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+		err = encoder.Encode(resp)
+		buf.Reset()
+		if err != nil {
+			streamErr = err
+			break OUTER
+		}
+		encoder.Reset(conn)
+
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case <-ctx.Done():
+			break OUTER
+		case <-a.c.shutdownCh:
+			break OUTER
+		case <-ar.WaitCh():
+			streamErr = fmt.Errorf("allocation %q is no longer running", req.AllocID)
+			break OUTER
+		case <-ticker.C:
+		}
+	}
+
+	if streamErr != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(streamErr) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(streamErr, code, encoder)
+		return
+	}
+}
+
+// stripCumulative removes the cumulative totals from the task usages of the
+// stats. The task usages are shared with the task runners so they are copied
+// before being modified.
+func stripCumulative(stats *cstructs.AllocResourceUsage) {
+	for name, usage := range stats.Tasks {
+		if usage.Cumulative == nil {
+			continue
+		}
+		c := *usage
+		c.Cumulative = nil
+		stats.Tasks[name] = &c
+	}
+}
+
+// StatsDebug is used to force a stats collection of the tasks of an
+// allocation and return the time spent in each stage of the collection. It
+// requires a management token.
+func (a *Allocations) StatsDebug(args *cstructs.AllocStatsDebugRequest, reply *cstructs.AllocStatsDebugResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "stats_debug"}, time.Now())
+
+	// Check management permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.IsManagement() {
+		return nstructs.ErrPermissionDenied
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
 	if err != nil {
 		return err
 	}
 
-	reply.Stats = stats
+	tasks, err := allocTaskNames(ar.Alloc(), args.Task)
+	if err != nil {
+		return err
+	}
+
+	reply.Tasks = make(map[string]*cstructs.TaskStatsDebug, len(tasks))
+	for _, task := range tasks {
+		debug, err := ar.TaskStatsDebug(context.Background(), task)
+		if err != nil {
+			// Skip tasks that aren't running unless explicitly requested
+			if args.Task == "" && err == taskrunner.ErrTaskNotRunning {
+				continue
+			}
+			return err
+		}
+
+		reply.Tasks[task] = debug
+	}
+
+	return nil
+}
+
+// StatsDiff is used to return the change in the stats of the tasks of an
+// allocation over a short window.
+func (a *Allocations) StatsDiff(args *cstructs.AllocStatsDiffRequest, reply *cstructs.AllocStatsDiffResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "stats_diff"}, time.Now())
+
+	window := args.Window
+	if window == 0 {
+		window = defaultStatsDiffWindow
+	} else if window < 0 || window > maxStatsDiffWindow {
+		return fmt.Errorf("window must be between 0 and %v", maxStatsDiffWindow)
+	}
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := allocTaskNames(ar.Alloc(), args.Task)
+	if err != nil {
+		return err
+	}
+
+	reply.Tasks = make(map[string]*cstructs.TaskStatsDiff, len(tasks))
+	for _, task := range tasks {
+		diff, err := ar.TaskStatsDiff(context.Background(), task, window)
+		if err != nil {
+			// Skip tasks that aren't running unless explicitly requested
+			if args.Task == "" && err == taskrunner.ErrTaskNotRunning {
+				continue
+			}
+			return err
+		}
+
+		reply.Tasks[task] = diff
+	}
+
+	return nil
+}
+
+// CgroupConfig is used to return the cgroup limits the client applied to the
+// tasks of an allocation.
+func (a *Allocations) CgroupConfig(args *cstructs.AllocCgroupConfigRequest, reply *cstructs.AllocCgroupConfigResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "cgroup_config"}, time.Now())
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := allocTaskNames(ar.Alloc(), args.Task)
+	if err != nil {
+		return err
+	}
+
+	reply.Tasks = make(map[string]*cstructs.TaskCgroupConfig, len(tasks))
+	for _, task := range tasks {
+		pid, err := ar.TaskPID(task)
+		if err != nil {
+			// Skip tasks that can't be inspected unless explicitly requested
+			if args.Task == "" && (err == taskrunner.ErrTaskNotRunning || err == taskrunner.ErrPIDUnavailable) {
+				continue
+			}
+			return err
+		}
+
+		conf, err := cgutil.ReadConfig(pid)
+		if err != nil {
+			return fmt.Errorf("failed to read cgroup config of task %q: %v", task, err)
+		}
+
+		reply.Tasks[task] = &cstructs.TaskCgroupConfig{
+			Version: conf.Version,
+			Paths:   conf.Paths,
+			Limits:  conf.Limits,
+		}
+	}
+
+	return nil
+}
+
+// Ulimits is used to return the resource limits applied to the tasks of an
+// allocation.
+func (a *Allocations) Ulimits(args *cstructs.AllocUlimitsRequest, reply *cstructs.AllocUlimitsResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "ulimits"}, time.Now())
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := allocTaskNames(ar.Alloc(), args.Task)
+	if err != nil {
+		return err
+	}
+
+	reply.Tasks = make(map[string]map[string]*cstructs.TaskUlimit, len(tasks))
+	for _, task := range tasks {
+		pid, err := ar.TaskPID(task)
+		if err != nil {
+			// Skip tasks that can't be inspected unless explicitly requested
+			if args.Task == "" && (err == taskrunner.ErrTaskNotRunning || err == taskrunner.ErrPIDUnavailable) {
+				continue
+			}
+			return err
+		}
+
+		limits, err := ulimit.Read(pid)
+		if err != nil {
+			return fmt.Errorf("failed to read resource limits of task %q: %v", task, err)
+		}
+
+		taskLimits := make(map[string]*cstructs.TaskUlimit, len(limits))
+		for name, l := range limits {
+			taskLimits[name] = &cstructs.TaskUlimit{
+				Soft:  l.Soft,
+				Hard:  l.Hard,
+				Units: l.Units,
+			}
+		}
+		reply.Tasks[task] = taskLimits
+	}
+
 	return nil
 }
+
+// SetLogRotation is used to update the log rotation settings of a running
+// task without restarting it.
+func (a *Allocations) SetLogRotation(args *cstructs.AllocSetLogRotationRequest, reply *nstructs.GenericResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "set_log_rotation"}, time.Now())
+
+	// Check submit job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilitySubmitJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	rotation := &nstructs.LogConfig{
+		MaxFiles:      args.MaxFiles,
+		MaxFileSizeMB: args.MaxFileSizeMB,
+	}
+	if err := rotation.Validate(); err != nil {
+		return err
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	// Logs must still fit in the allocation's ephemeral disk
+	alloc := ar.Alloc()
+	if tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup); tg != nil && tg.EphemeralDisk != nil {
+		logUsage := rotation.MaxFiles * rotation.MaxFileSizeMB
+		if tg.EphemeralDisk.SizeMB <= logUsage {
+			return fmt.Errorf("log storage (%d MB) must be less than requested disk capacity (%d MB)",
+				logUsage, tg.EphemeralDisk.SizeMB)
+		}
+	}
+
+	return ar.SetTaskLogRotation(args.Task, rotation)
+}
+
+// CancelArtifactDownload is used to abort the artifact download of a starting
+// task. The partially downloaded files are removed and the task fails.
+func (a *Allocations) CancelArtifactDownload(args *cstructs.AllocCancelArtifactRequest, reply *nstructs.GenericResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "cancel_artifact_download"}, time.Now())
+
+	// Check alloc lifecycle permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityAllocLifecycle) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	return ar.CancelTaskArtifactDownload(args.Task)
+}
+
+// RotateLogs is used to force the rotation of a running task's stdout or
+// stderr log so the output written so far is a complete file.
+func (a *Allocations) RotateLogs(args *cstructs.AllocRotateLogsRequest, reply *cstructs.AllocRotateLogsResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "rotate_logs"}, time.Now())
+
+	// Check write logs permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityWriteLogs) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+	switch args.LogType {
+	case "stdout", "stderr":
+	default:
+		return logTypeNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	path, err := ar.RotateTaskLogs(args.Task, args.LogType)
+	if err != nil {
+		return err
+	}
+
+	reply.Path = path
+	return nil
+}
+
+// RenderedTemplate is used to read the current content of a task's rendered
+// template. Templates reading Vault secrets are redacted unless a management
+// token is used.
+func (a *Allocations) RenderedTemplate(args *cstructs.AllocRenderedTemplateRequest, reply *cstructs.AllocRenderedTemplateResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "rendered_template"}, time.Now())
+
+	// Check read job permissions
+	aclObj, err := a.c.ResolveToken(args.AuthToken)
+	if err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+	if args.Path == "" {
+		return pathNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	content, secrets, err := ar.TaskRenderedTemplate(args.Task, args.Path)
+	if err != nil {
+		return err
+	}
+
+	if secrets && aclObj != nil && !aclObj.IsManagement() {
+		reply.Redacted = true
+		return nil
+	}
+
+	reply.Content = content
+	return nil
+}
+
+// checks is used to stream the status transitions of the checks registered
+// for an allocation's services. The stream is closed once the allocation
+// stops.
+func (a *Allocations) checks(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "checks"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req cstructs.AllocChecksRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityReadJob) {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, "", req.QueryOptions.AuthToken)
+
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	ar, err := a.c.lookupAllocRunner(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	statuses := make(chan *cstructs.CheckStatus, streamFramesBuffer)
+	errCh := make(chan error)
+
+	// Start polling the checks
+	go func() {
+		if err := a.checksImpl(ctx, req.AllocID, ar.WaitCh(), statuses); err != nil {
+			select {
+			case errCh <- err:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	var streamErr error
+	buf := new(bytes.Buffer)
+	statusCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+OUTER:
+	for {
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case status, ok := <-statuses:
+			if !ok {
+				// The allocation stopped
+				break OUTER
+			}
+
+			if err := statusCodec.Encode(status); err != nil {
+				streamErr = err
+				break OUTER
+			}
+			statusCodec.Reset(buf)
+
+			resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+			err := encoder.Encode(resp)
+			buf.Reset()
+			if err != nil {
+				streamErr = err
+				break OUTER
+			}
+			encoder.Reset(conn)
+		case <-ctx.Done():
+			break OUTER
+		}
+	}
+
+	if streamErr != nil {
+		handleStreamResultError(streamErr, helper.Int64ToPtr(500), encoder)
+		return
+	}
+}
+
+// checksImpl polls the status of the checks registered for an allocation and
+// sends it on the passed channel whenever a check is first seen or its status
+// changes. The channel is closed when waitCh is closed; the method otherwise
+// returns when the context is cancelled or on an error.
+func (a *Allocations) checksImpl(ctx context.Context, allocID string, waitCh <-chan struct{},
+	statuses chan<- *cstructs.CheckStatus) error {
+
+	last := make(map[string]string)
+	ticker := time.NewTicker(checkStatusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		reg, err := a.c.consulService.AllocRegistrations(allocID)
+		if err != nil {
+			return fmt.Errorf("failed to lookup checks: %v", err)
+		}
+
+		now := time.Now().UnixNano()
+		if reg != nil {
+			for task, treg := range reg.Tasks {
+				for serviceID, sreg := range treg.Services {
+					for _, check := range sreg.Checks {
+						if last[check.CheckID] == check.Status {
+							continue
+						}
+						last[check.CheckID] = check.Status
+
+						status := &cstructs.CheckStatus{
+							Task:      task,
+							ServiceID: serviceID,
+							CheckID:   check.CheckID,
+							CheckName: check.Name,
+							Status:    check.Status,
+							Output:    check.Output,
+							Timestamp: now,
+						}
+						if sreg.Service != nil {
+							status.ServiceName = sreg.Service.Service
+						}
+
+						select {
+						case statuses <- status:
+						case <-ctx.Done():
+							return nil
+						}
+					}
+				}
+			}
+		}
+
+		select {
+		case <-waitCh:
+			close(statuses)
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// profile is used to sample the stacks of a running task and stream back the
+// collapsed output.
+func (a *Allocations) profile(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "profile"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req cstructs.AllocProfileRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check profile permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityAllocProfile) {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, req.Task, req.QueryOptions.AuthToken)
+
+	// Validate the arguments
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+	if req.Task == "" {
+		handleStreamResultError(taskNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+	if err := profiler.Validate(req.Type, req.Duration); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	ar, err := a.c.lookupAllocRunner(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	pid, err := ar.TaskPID(req.Task)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		out []byte
+		err error
+	}
+	resultCh := make(chan result, 1)
+	errCh := make(chan error)
+
+	// Run the profile
+	go func() {
+		out, err := profiler.Run(ctx, pid, req.Type, req.Duration)
+		resultCh <- result{out, err}
+	}()
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	var res result
+	select {
+	case err := <-errCh:
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	case <-ctx.Done():
+		return
+	case res = <-resultCh:
+	}
+
+	if res.err != nil {
+		code := helper.Int64ToPtr(500)
+		if res.err == profiler.ErrPerfNotFound || res.err == profiler.ErrUnsupported {
+			code = helper.Int64ToPtr(501)
+		}
+		handleStreamResultError(res.err, code, encoder)
+		return
+	}
+
+	// Send the collapsed stacks in frames
+	for out := res.out; len(out) > 0; {
+		n := len(out)
+		if n > streamFrameSize {
+			n = streamFrameSize
+		}
+
+		if err := encoder.Encode(cstructs.StreamErrWrapper{Payload: out[:n]}); err != nil {
+			handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+			return
+		}
+		encoder.Reset(conn)
+		out = out[n:]
+	}
+}
+
+// lifecycleEvents is used to stream the hook runs and state transitions of an
+// alloc runner and its task runners until the allocation is destroyed. It
+// requires a management token.
+func (a *Allocations) lifecycleEvents(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "lifecycle_events"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req nstructs.AllocSpecificRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check management permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.IsManagement() {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, "", req.QueryOptions.AuthToken)
+
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	ar, err := a.c.lookupAllocRunner(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	events, stop := ar.LifecycleEvents().Listen()
+	defer stop()
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	var streamErr error
+	buf := new(bytes.Buffer)
+	eventCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+OUTER:
+	for {
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case <-ctx.Done():
+			break OUTER
+		case <-ar.DestroyCh():
+			break OUTER
+		case event := <-events:
+			if err := eventCodec.Encode(event); err != nil {
+				streamErr = err
+				break OUTER
+			}
+			eventCodec.Reset(buf)
+
+			resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+			err := encoder.Encode(resp)
+			buf.Reset()
+			if err != nil {
+				streamErr = err
+				break OUTER
+			}
+			encoder.Reset(conn)
+		}
+	}
+
+	if streamErr != nil {
+		handleStreamResultError(streamErr, helper.Int64ToPtr(500), encoder)
+		return
+	}
+}
+
+// decisions is used to stream the decisions the client makes while reconciling
+// an allocation with the servers, such as stopping or migrating it, along with
+// the server updates that triggered them. The allocation doesn't need to be
+// running on the client so its placement and removal can be observed. It
+// requires a management token.
+func (a *Allocations) decisions(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "decisions"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req nstructs.AllocSpecificRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check management permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.IsManagement() {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, "", req.QueryOptions.AuthToken)
+
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	decisions, stop := a.c.allocDecisions.Listen(req.AllocID)
+	defer stop()
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	var streamErr error
+	buf := new(bytes.Buffer)
+	decisionCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+OUTER:
+	for {
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case <-ctx.Done():
+			break OUTER
+		case <-a.c.shutdownCh:
+			break OUTER
+		case decision := <-decisions:
+			if err := decisionCodec.Encode(decision); err != nil {
+				streamErr = err
+				break OUTER
+			}
+			decisionCodec.Reset(buf)
+
+			resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+			err := encoder.Encode(resp)
+			buf.Reset()
+			if err != nil {
+				streamErr = err
+				break OUTER
+			}
+			encoder.Reset(conn)
+		}
+	}
+
+	if streamErr != nil {
+		handleStreamResultError(streamErr, helper.Int64ToPtr(500), encoder)
+		return
+	}
+}
+
+// ListFDs is used to list the open file descriptors of a task's main process.
+func (a *Allocations) ListFDs(args *cstructs.AllocFDsRequest, reply *cstructs.AllocFDsResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "list_fds"}, time.Now())
+
+	// Check profile permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityAllocProfile) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	pid, err := ar.TaskPID(args.Task)
+	if err != nil {
+		return err
+	}
+
+	fds, err := procfd.List(pid)
+	if err != nil {
+		return fmt.Errorf("failed to list file descriptors of task %q: %v", args.Task, err)
+	}
+
+	reply.FDs = make([]*cstructs.FileDescriptor, 0, len(fds))
+	for _, fd := range fds {
+		reply.FDs = append(reply.FDs, toFileDescriptor(fd))
+	}
+	return nil
+}
+
+// DropCaches is used to reclaim the page cache of a task's cgroup so that
+// subsequent reads are cold. It requires a management token given its impact
+// on the performance of the task.
+func (a *Allocations) DropCaches(args *cstructs.AllocDropCachesRequest, reply *cstructs.AllocDropCachesResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "drop_caches"}, time.Now())
+
+	// Check management permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.IsManagement() {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	pid, err := ar.TaskPID(args.Task)
+	if err != nil {
+		return err
+	}
+
+	cgroup, err := cgutil.ReadConfig(pid)
+	if err != nil {
+		return fmt.Errorf("failed to read the cgroup of task %q: %v", args.Task, err)
+	}
+
+	reclaimed, err := cgroup.ReclaimMemory()
+	if err != nil {
+		return fmt.Errorf("failed to drop the caches of task %q: %v", args.Task, err)
+	}
+
+	reply.Reclaimed = reclaimed
+	return nil
+}
+
+// Mounts is used to list the mounts of a task's mount namespace, as seen by
+// its main process.
+func (a *Allocations) Mounts(args *cstructs.AllocMountsRequest, reply *cstructs.AllocMountsResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "mounts"}, time.Now())
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	pid, err := ar.TaskPID(args.Task)
+	if err != nil {
+		return err
+	}
+
+	mounts, err := procmount.List(pid)
+	if err != nil {
+		return fmt.Errorf("failed to list mounts of task %q: %v", args.Task, err)
+	}
+
+	reply.Mounts = make([]*cstructs.MountEntry, 0, len(mounts))
+	for _, m := range mounts {
+		reply.Mounts = append(reply.Mounts, &cstructs.MountEntry{
+			Source:       m.Source,
+			Target:       m.Target,
+			Root:         m.Root,
+			FSType:       m.FSType,
+			Options:      m.Options,
+			SuperOptions: m.SuperOptions,
+		})
+	}
+	return nil
+}
+
+// Signal is used to send a signal to a running task of an allocation or to
+// all of its running tasks.
+func (a *Allocations) Signal(args *cstructs.AllocSignalRequest, reply *nstructs.GenericResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "signal"}, time.Now())
+
+	// Check alloc lifecycle permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityAllocLifecycle) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	signal := strings.ToUpper(args.Signal)
+	if signal != "" && !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+	if _, ok := signals.SignalLookup[signal]; !ok {
+		return fmt.Errorf("invalid signal %q", args.Signal)
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	return ar.SignalTask(args.Task, signal)
+}
+
+// TaskDiagnose is used to explain why a task is or is not running, from its
+// state, the state of its hooks and its recent events.
+func (a *Allocations) TaskDiagnose(args *cstructs.AllocTaskDiagnoseRequest, reply *cstructs.AllocTaskDiagnoseResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "task_diagnose"}, time.Now())
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	diagnosis, err := ar.TaskDiagnose(args.Task)
+	if err != nil {
+		return err
+	}
+
+	reply.Diagnosis = diagnosis
+	return nil
+}
+
+// ExecOnce is used to run a command in a task and return its captured output
+// and exit code once it exits.
+func (a *Allocations) ExecOnce(args *cstructs.AllocExecOnceRequest, reply *cstructs.AllocExecOnceResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "exec_once"}, time.Now())
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+	if len(args.Cmd) == 0 || args.Cmd[0] == "" {
+		return fmt.Errorf("must specify a command")
+	}
+
+	// Check the command may be executed
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsExec(args.Namespace, args.Cmd[0]) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	timeout := args.Timeout
+	if timeout == 0 {
+		timeout = defaultExecOnceTimeout
+	} else if timeout < 0 || timeout > maxExecOnceTimeout {
+		return fmt.Errorf("timeout must be between 0 and %v", maxExecOnceTimeout)
+	}
+
+	limit := args.MaxOutputBytes
+	if limit == 0 {
+		limit = defaultExecOnceOutputBytes
+	} else if limit < 0 || limit > maxExecOnceOutputBytes {
+		return fmt.Errorf("max output bytes must be between 0 and %d", maxExecOnceOutputBytes)
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	// Record the command in the exec audit log once it exits
+	entry := &execaudit.Entry{
+		AccessorID: a.c.tokenAccessor(args.AuthToken),
+		AllocID:    args.AllocID,
+		Namespace:  ar.Alloc().Namespace,
+		Task:       args.Task,
+		Command:    args.Cmd,
+		StartTime:  time.Now(),
+	}
+	defer a.auditExec(entry)
+
+	res, err := ar.TaskExec(args.Task, timeout, args.Cmd)
+	entry.EndTime = time.Now()
+	if err != nil {
+		entry.Error = err.Error()
+		return err
+	}
+	reply.Duration = entry.EndTime.Sub(entry.StartTime)
+
+	reply.Stdout, reply.StdoutTruncated = truncateOutput(res.Stdout, limit)
+	reply.Stderr, reply.StderrTruncated = truncateOutput(res.Stderr, limit)
+	if res.ExitResult != nil {
+		if res.ExitResult.Err != nil {
+			entry.Error = res.ExitResult.Err.Error()
+			return fmt.Errorf("failed to run command: %v", res.ExitResult.Err)
+		}
+		reply.ExitCode = res.ExitResult.ExitCode
+		reply.Signal = res.ExitResult.Signal
+		entry.ExitCode = reply.ExitCode
+		entry.Signal = reply.Signal
+	}
+	return nil
+}
+
+// auditExec appends the entry to the exec audit log. Failures are logged as
+// they shouldn't fail a command that already ran.
+func (a *Allocations) auditExec(entry *execaudit.Entry) {
+	if err := a.c.execAudit.Append(entry); err != nil {
+		a.c.logger.Error("failed to record command in exec audit log",
+			"alloc_id", entry.AllocID, "task", entry.Task, "error", err)
+	}
+}
+
+// truncateOutput truncates the output to the limit, returning whether it was
+// truncated.
+func truncateOutput(output []byte, limit int) ([]byte, bool) {
+	if len(output) > limit {
+		return output[:limit], true
+	}
+	return output, false
+}
+
+// ProcSnapshot is used to read the procfs metrics of a task's main process
+// and optionally of its descendants.
+func (a *Allocations) ProcSnapshot(args *cstructs.AllocProcSnapshotRequest, reply *cstructs.AllocProcSnapshotResponse) error {
+	defer metrics.MeasureSince([]string{"client", "allocations", "proc_snapshot"}, time.Now())
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.AllowNsOp(args.Namespace, acl.NamespaceCapabilityReadJob) {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Task == "" {
+		return taskNotPresentErr
+	}
+
+	ar, err := a.c.lookupAllocRunner(args.AllocID)
+	if err != nil {
+		return err
+	}
+
+	pid, err := ar.TaskPID(args.Task)
+	if err != nil {
+		return err
+	}
+
+	pids := []int{pid}
+	if args.AllPIDs {
+		descendants, err := procsnap.Descendants(pid)
+		if err != nil {
+			return fmt.Errorf("failed to list processes of task %q: %v", args.Task, err)
+		}
+		pids = append(pids, descendants...)
+	}
+
+	reply.Processes = make([]*cstructs.ProcSnapshot, 0, len(pids))
+	for _, p := range pids {
+		snap, err := procsnap.Read(p)
+		if err == procsnap.ErrProcessGone && p != pid {
+			// The descendant exited after being listed
+			continue
+		} else if err == procsnap.ErrProcessGone {
+			return fmt.Errorf("main process %d of task %q exited", pid, args.Task)
+		} else if err != nil {
+			return fmt.Errorf("failed to snapshot process %d of task %q: %v", p, args.Task, err)
+		}
+		reply.Processes = append(reply.Processes, toProcSnapshot(snap))
+	}
+	return nil
+}
+
+// streamFDs is used to stream the file descriptors opened and closed by a
+// task's main process.
+func (a *Allocations) streamFDs(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "stream_fds"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req cstructs.AllocFDsRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check profile permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityAllocProfile) {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, req.Task, req.QueryOptions.AuthToken)
+
+	// Validate the arguments
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+	if req.Task == "" {
+		handleStreamResultError(taskNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	ar, err := a.c.lookupAllocRunner(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	// Fail early if the task can't be inspected
+	listFn := func() ([]*procfd.FD, error) {
+		pid, err := ar.TaskPID(req.Task)
+		if err != nil {
+			return nil, err
+		}
+		return procfd.List(pid)
+	}
+	initial, err := listFn()
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan *cstructs.FDEvent, streamFramesBuffer)
+	errCh := make(chan error)
+
+	// Start polling the file descriptors
+	go a.fdsImpl(ctx, initial, listFn, ar.WaitCh(), events)
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	var streamErr error
+	buf := new(bytes.Buffer)
+	eventCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+OUTER:
+	for {
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case event, ok := <-events:
+			if !ok {
+				// The allocation stopped
+				break OUTER
+			}
+
+			if err := eventCodec.Encode(event); err != nil {
+				streamErr = err
+				break OUTER
+			}
+			eventCodec.Reset(buf)
+
+			resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+			err := encoder.Encode(resp)
+			buf.Reset()
+			if err != nil {
+				streamErr = err
+				break OUTER
+			}
+			encoder.Reset(conn)
+		case <-ctx.Done():
+			break OUTER
+		}
+	}
+
+	if streamErr != nil {
+		handleStreamResultError(streamErr, helper.Int64ToPtr(500), encoder)
+		return
+	}
+}
+
+// fdsImpl sends an open event for each of the initial file descriptors and
+// then polls listFn, sending an event for each descriptor opened or closed.
+// Failing to list the descriptors, eg while the task restarts, is treated as
+// having none open. The channel is closed when waitCh is closed; the method
+// otherwise returns when the context is cancelled.
+func (a *Allocations) fdsImpl(ctx context.Context, initial []*procfd.FD, listFn func() ([]*procfd.FD, error),
+	waitCh <-chan struct{}, events chan<- *cstructs.FDEvent) {
+
+	send := func(op string, fds []*procfd.FD) bool {
+		now := time.Now().UnixNano()
+		for _, fd := range fds {
+			select {
+			case events <- &cstructs.FDEvent{Op: op, FD: toFileDescriptor(fd), Timestamp: now}:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	if !send("open", initial) {
+		return
+	}
+
+	ticker := time.NewTicker(fdPollInterval)
+	defer ticker.Stop()
+
+	last := initial
+	for {
+		select {
+		case <-waitCh:
+			close(events)
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cur, _ := listFn()
+		opened, closed := procfd.Diff(last, cur)
+		if !send("close", closed) || !send("open", opened) {
+			return
+		}
+		last = cur
+	}
+}
+
+// toFileDescriptor converts a listed file descriptor to its RPC representation
+func toFileDescriptor(fd *procfd.FD) *cstructs.FileDescriptor {
+	return &cstructs.FileDescriptor{
+		FD:    fd.Num,
+		Path:  fd.Path,
+		Type:  fd.Type,
+		Flags: fd.Flags,
+	}
+}
+
+// toProcSnapshot converts a process snapshot to its RPC representation
+func toProcSnapshot(snap *procsnap.Snapshot) *cstructs.ProcSnapshot {
+	ps := &cstructs.ProcSnapshot{
+		PID:                      snap.PID,
+		PPid:                     snap.Status.PPid,
+		Name:                     snap.Status.Name,
+		State:                    snap.Status.State,
+		Threads:                  snap.Status.Threads,
+		VmPeak:                   snap.Status.VmPeak,
+		VmSize:                   snap.Status.VmSize,
+		VmHWM:                    snap.Status.VmHWM,
+		VmRSS:                    snap.Status.VmRSS,
+		VmSwap:                   snap.Status.VmSwap,
+		VoluntaryCtxtSwitches:    snap.Status.VoluntaryCtxtSwitches,
+		NonvoluntaryCtxtSwitches: snap.Status.NonvoluntaryCtxtSwitches,
+		MinorFaults:              snap.Stat.MinorFaults,
+		MajorFaults:              snap.Stat.MajorFaults,
+		UserTime:                 snap.Stat.UserTime,
+		SystemTime:               snap.Stat.SystemTime,
+		StartTime:                snap.Stat.StartTime,
+	}
+
+	if snap.IO != nil {
+		ps.IO = &cstructs.ProcIO{
+			ReadChars:           snap.IO.ReadChars,
+			WriteChars:          snap.IO.WriteChars,
+			ReadSyscalls:        snap.IO.ReadSyscalls,
+			WriteSyscalls:       snap.IO.WriteSyscalls,
+			ReadBytes:           snap.IO.ReadBytes,
+			WriteBytes:          snap.IO.WriteBytes,
+			CancelledWriteBytes: snap.IO.CancelledWriteBytes,
+		}
+	}
+
+	ps.Limits = make([]*cstructs.ProcLimit, 0, len(snap.Limits))
+	for _, l := range snap.Limits {
+		ps.Limits = append(ps.Limits, &cstructs.ProcLimit{
+			Name:  l.Name,
+			Soft:  l.Soft,
+			Hard:  l.Hard,
+			Units: l.Units,
+		})
+	}
+	return ps
+}
+
+// allocTaskNames returns the names of the tasks in the allocation's task
+// group. If taskFilter is set, only that task is returned if it exists.
+func allocTaskNames(alloc *nstructs.Allocation, taskFilter string) ([]string, error) {
+	tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup)
+	if tg == nil {
+		return nil, fmt.Errorf("failed to lookup task group %q", alloc.TaskGroup)
+	}
+
+	var names []string
+	for _, task := range tg.Tasks {
+		if taskFilter != "" && taskFilter != task.Name {
+			continue
+		}
+		names = append(names, task.Name)
+	}
+
+	if taskFilter != "" && len(names) == 0 {
+		return nil, fmt.Errorf("unknown task name %q", taskFilter)
+	}
+
+	return names, nil
+}
+
+// artifactProgress streams the progress of the artifact downloads of a task.
+// The progress is sent whenever it changes and the stream is closed once the
+// downloads finish or fail.
+func (a *Allocations) artifactProgress(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "allocations", "artifact_progress"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req cstructs.AllocArtifactProgressRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check read job permissions
+	if aclObj, err := a.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityReadJob) {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	a.c.setStreamTarget(conn, req.AllocID, req.Task, req.QueryOptions.AuthToken)
+
+	// Validate the arguments
+	if req.AllocID == "" {
+		handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+	if req.Task == "" {
+		handleStreamResultError(taskNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	ar, err := a.c.lookupAllocRunner(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if nstructs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	// Fail early if the task is unknown
+	progress, err := ar.TaskArtifactProgress(req.Task)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	// Wait for a stream slot
+	release, err := a.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(artifactProgressPollInterval)
+	defer ticker.Stop()
+
+	var streamErr error
+	var last *cstructs.TaskArtifactProgress
+	buf := new(bytes.Buffer)
+	progressCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+OUTER:
+	for {
+		if last == nil || *progress != *last {
+			if err := progressCodec.Encode(progress); err != nil {
+				streamErr = err
+				break OUTER
+			}
+			progressCodec.Reset(buf)
+
+			resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+			err := encoder.Encode(resp)
+			buf.Reset()
+			if err != nil {
+				streamErr = err
+				break OUTER
+			}
+			encoder.Reset(conn)
+			last = progress
+		}
+
+		if progress.Done {
+			break OUTER
+		}
+
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case <-ctx.Done():
+			break OUTER
+		case <-ar.WaitCh():
+			break OUTER
+		case <-ticker.C:
+		}
+
+		if progress, err = ar.TaskArtifactProgress(req.Task); err != nil {
+			streamErr = err
+			break OUTER
+		}
+	}
+
+	if streamErr != nil {
+		handleStreamResultError(streamErr, helper.Int64ToPtr(500), encoder)
+		return
+	}
+}
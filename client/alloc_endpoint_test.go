@@ -1,16 +1,35 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/nomad/acl"
 	"github.com/hashicorp/nomad/client/config"
+	consulApi "github.com/hashicorp/nomad/client/consul"
+	"github.com/hashicorp/nomad/client/lib/cgutil"
 	cstructs "github.com/hashicorp/nomad/client/structs"
+	"github.com/hashicorp/nomad/command/agent/consul"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/hashicorp/nomad/helper/uuid"
 	"github.com/hashicorp/nomad/nomad/mock"
 	nstructs "github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/testutil"
 	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
 )
 
 func TestAllocations_GarbageCollectAll(t *testing.T) {
@@ -96,8 +115,8 @@ func TestAllocations_GarbageCollect(t *testing.T) {
 	require.Nil(client.addAlloc(a, ""))
 
 	// Try with bad alloc
-	req := &nstructs.AllocSpecificRequest{}
-	var resp nstructs.GenericResponse
+	req := &cstructs.AllocGarbageCollectRequest{}
+	var resp cstructs.AllocGarbageCollectResponse
 	err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
 	require.NotNil(err)
 
@@ -109,7 +128,7 @@ func TestAllocations_GarbageCollect(t *testing.T) {
 			return true, nil
 		}
 
-		var resp2 nstructs.GenericResponse
+		var resp2 cstructs.AllocGarbageCollectResponse
 		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp2)
 		return err == nil, err
 	}, func(err error) {
@@ -117,7 +136,290 @@ func TestAllocations_GarbageCollect(t *testing.T) {
 	})
 }
 
-func TestAllocations_GarbageCollect_ACL(t *testing.T) {
+func TestAllocations_GarbageCollect_Task(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Run a task that completes right away next to a long running one
+	a := mock.BatchAlloc()
+	task := a.Job.TaskGroups[0].Tasks[0]
+	task.Driver = "mock_driver"
+	task.Config = map[string]interface{}{
+		"run_for": "10ms",
+	}
+	sidecar := task.Copy()
+	sidecar.Name = "sidecar"
+	sidecar.Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	a.Job.TaskGroups[0].Tasks = append(a.Job.TaskGroups[0].Tasks, sidecar)
+	a.AllocatedResources.Tasks[sidecar.Name] = a.AllocatedResources.Tasks[task.Name].Copy()
+	require.Nil(client.addAlloc(a, ""))
+
+	var ar AllocRunner
+	testutil.WaitForResult(func() (bool, error) {
+		var err error
+		if ar, err = client.getAllocRunner(a.ID); err != nil {
+			return false, err
+		}
+		state := ar.AllocState()
+		if ts := state.TaskStates[task.Name]; ts == nil || ts.State != nstructs.TaskStateDead {
+			return false, fmt.Errorf("expected %q to be dead: %v", task.Name, ts)
+		}
+		if ts := state.TaskStates[sidecar.Name]; ts == nil || ts.State != nstructs.TaskStateRunning {
+			return false, fmt.Errorf("expected %q to be running: %v", sidecar.Name, ts)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Fill the dead task's dir
+	taskDir := ar.GetAllocDir().TaskDirs[task.Name]
+	require.NoError(ioutil.WriteFile(filepath.Join(taskDir.LocalDir, "data"), make([]byte, 4096), 0644))
+
+	// Try with an unknown task
+	req := &cstructs.AllocGarbageCollectRequest{AllocID: a.ID, Task: "foo", DryRun: true}
+	var resp cstructs.AllocGarbageCollectResponse
+	err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// The running task can't be collected
+	req.Task = sidecar.Name
+	req.DryRun = false
+	err = client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "is running")
+
+	// A dry run of the running alloc collects nothing
+	req.Task = ""
+	req.DryRun = true
+	var dryAlloc cstructs.AllocGarbageCollectResponse
+	require.NoError(client.ClientRPC("Allocations.GarbageCollect", &req, &dryAlloc))
+	require.False(dryAlloc.Terminal)
+	require.True(dryAlloc.Reclaimed >= 4096)
+
+	// A dry run of the dead task collects nothing
+	req.Task = task.Name
+	var dryTask cstructs.AllocGarbageCollectResponse
+	require.NoError(client.ClientRPC("Allocations.GarbageCollect", &req, &dryTask))
+	require.True(dryTask.Terminal)
+	require.True(dryTask.Reclaimed >= 4096)
+	require.DirExists(taskDir.Dir)
+
+	// Collect the dead task only
+	req.DryRun = false
+	var collected cstructs.AllocGarbageCollectResponse
+	require.NoError(client.ClientRPC("Allocations.GarbageCollect", &req, &collected))
+	require.True(collected.Terminal)
+	require.Equal(dryTask.Reclaimed, collected.Reclaimed)
+
+	_, err = os.Stat(taskDir.Dir)
+	require.True(os.IsNotExist(err))
+	require.DirExists(ar.GetAllocDir().TaskDirs[sidecar.Name].Dir)
+	require.False(ar.IsDestroyed())
+}
+
+func TestAllocations_GarbageCollectMany(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.GCDiskUsageThreshold = 100.0
+	})
+	defer cleanup()
+
+	// An alloc that stops quickly
+	stopped := mock.Alloc()
+	stopped.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	stopped.Job.TaskGroups[0].RestartPolicy = &nstructs.RestartPolicy{
+		Attempts: 0,
+		Mode:     nstructs.RestartPolicyModeFail,
+	}
+	stopped.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "10ms",
+	}
+	require.Nil(client.addAlloc(stopped, ""))
+
+	// An alloc that is still running
+	running := mock.Alloc()
+	running.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	running.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(running, ""))
+
+	unknown := uuid.Generate()
+	req := &cstructs.AllocGarbageCollectManyRequest{
+		AllocIDs: []string{stopped.ID, running.ID, unknown},
+	}
+
+	var resp cstructs.AllocGarbageCollectManyResponse
+	testutil.WaitForResult(func() (bool, error) {
+		resp = cstructs.AllocGarbageCollectManyResponse{}
+		if err := client.ClientRPC("Allocations.GarbageCollectMany", req, &resp); err != nil {
+			return false, err
+		}
+
+		r := resp.Results[stopped.ID]
+		return r.Result == cstructs.AllocGCResultCollected, fmt.Errorf("stopped alloc result %#v", r)
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	require.Len(resp.Results, 3)
+	require.Equal(cstructs.AllocGCResultSkipped, resp.Results[running.ID].Result)
+	require.Equal(cstructs.AllocGCResultError, resp.Results[unknown].Result)
+	require.Contains(resp.Results[unknown].Error, nstructs.ErrUnknownAllocationPrefix)
+
+	ar, err := client.getAllocRunner(stopped.ID)
+	require.NoError(err)
+	require.True(ar.IsDestroyed())
+}
+
+func TestAllocations_GarbageCollectMany_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Register a running alloc with the server so the client keeps it
+	waitTilNodeReady(client, t)
+	a := mock.Alloc()
+	a.NodeID = client.NodeID()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	state := server.State()
+	require.NoError(state.UpsertJob(1001, a.Job))
+	require.NoError(state.UpsertJobSummary(1002, mock.JobSummary(a.JobID)))
+	require.NoError(state.UpsertAllocs(1003, []*nstructs.Allocation{a}))
+
+	testutil.WaitForResult(func() (bool, error) {
+		_, err := client.getAllocRunner(a.ID)
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Try request with an invalid token and expect failure
+	{
+		req := &cstructs.AllocGarbageCollectManyRequest{AllocIDs: []string{a.ID}}
+		req.AuthToken = uuid.Generate()
+		var resp cstructs.AllocGarbageCollectManyResponse
+		err := client.ClientRPC("Allocations.GarbageCollectMany", req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrTokenNotFound.Error())
+	}
+
+	// Try request without a token and expect the alloc to fail the same way
+	// an unknown alloc does
+	unknown := uuid.Generate()
+	{
+		req := &cstructs.AllocGarbageCollectManyRequest{AllocIDs: []string{a.ID, unknown}}
+		var resp cstructs.AllocGarbageCollectManyResponse
+		require.NoError(client.ClientRPC("Allocations.GarbageCollectMany", req, &resp))
+		require.Equal(cstructs.AllocGCResultError, resp.Results[a.ID].Result)
+		require.Equal(nstructs.ErrPermissionDenied.Error(), resp.Results[a.ID].Error)
+		require.Equal(resp.Results[a.ID], resp.Results[unknown])
+	}
+
+	// Try request with a token for another namespace and expect the alloc to
+	// fail
+	{
+		policyHCL := mock.NamespacePolicy("other", "", []string{acl.NamespaceCapabilitySubmitJob})
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "other", policyHCL)
+		req := &cstructs.AllocGarbageCollectManyRequest{AllocIDs: []string{a.ID}}
+		req.AuthToken = token.SecretID
+		var resp cstructs.AllocGarbageCollectManyResponse
+		require.NoError(client.ClientRPC("Allocations.GarbageCollectMany", req, &resp))
+		require.Equal(cstructs.AllocGCResultError, resp.Results[a.ID].Result)
+		require.Equal(nstructs.ErrPermissionDenied.Error(), resp.Results[a.ID].Error)
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocGarbageCollectManyRequest{AllocIDs: []string{a.ID, unknown}}
+		req.AuthToken = root.SecretID
+		var resp cstructs.AllocGarbageCollectManyResponse
+		require.NoError(client.ClientRPC("Allocations.GarbageCollectMany", req, &resp))
+		require.Equal(cstructs.AllocGCResultSkipped, resp.Results[a.ID].Result)
+		require.Contains(resp.Results[unknown].Error, nstructs.ErrUnknownAllocationPrefix)
+	}
+}
+
+func TestAllocations_WouldGC(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.GCDiskUsageThreshold = 100.0
+	})
+	defer cleanup()
+
+	// An alloc that stops quickly
+	stopped := mock.Alloc()
+	stopped.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	stopped.Job.TaskGroups[0].RestartPolicy = &nstructs.RestartPolicy{
+		Attempts: 0,
+		Mode:     nstructs.RestartPolicyModeFail,
+	}
+	stopped.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "10ms",
+	}
+	require.Nil(client.addAlloc(stopped, ""))
+
+	// An alloc that is still running
+	running := mock.Alloc()
+	running.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	running.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(running, ""))
+
+	// Wait for the stopped alloc to be marked for collection
+	req := &cstructs.AllocWouldGCRequest{}
+	testutil.WaitForResult(func() (bool, error) {
+		var resp cstructs.AllocWouldGCResponse
+		if err := client.ClientRPC("Allocations.WouldGC", req, &resp); err != nil {
+			return false, err
+		}
+		r := resp.Allocs[stopped.ID]
+		if r == nil || !r.Eligible {
+			return false, fmt.Errorf("stopped alloc not eligible: %#v", r)
+		}
+
+		// Usage is below the live threshold
+		return !r.Collected, fmt.Errorf("stopped alloc collected: %#v", r)
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// The stopped alloc is collected under a lower threshold
+	req.DiskUsageThreshold = helper.Float64ToPtr(0)
+	var resp cstructs.AllocWouldGCResponse
+	require.NoError(client.ClientRPC("Allocations.WouldGC", req, &resp))
+	require.Len(resp.Allocs, 2)
+	require.True(resp.Allocs[stopped.ID].Collected)
+	require.Contains(resp.Allocs[stopped.ID].Reason, "disk usage")
+	require.Equal(&cstructs.AllocWouldGCResult{}, resp.Allocs[running.ID])
+
+	// Nothing was collected
+	ar, err := client.getAllocRunner(stopped.ID)
+	require.NoError(err)
+	require.False(ar.IsDestroyed())
+	require.Equal(100.0, client.garbageCollector.Config().DiskUsageThreshold)
+}
+
+func TestAllocations_WouldGC_ACL(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
 	server, addr, root := testACLServer(t, nil)
@@ -131,9 +433,9 @@ func TestAllocations_GarbageCollect_ACL(t *testing.T) {
 
 	// Try request without a token and expect failure
 	{
-		req := &nstructs.AllocSpecificRequest{}
-		var resp nstructs.GenericResponse
-		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
+		req := &cstructs.AllocWouldGCRequest{}
+		var resp cstructs.AllocWouldGCResponse
+		err := client.ClientRPC("Allocations.WouldGC", req, &resp)
 		require.NotNil(err)
 		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
 	}
@@ -141,74 +443,108 @@ func TestAllocations_GarbageCollect_ACL(t *testing.T) {
 	// Try request with an invalid token and expect failure
 	{
 		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
-		req := &nstructs.AllocSpecificRequest{}
+		req := &cstructs.AllocWouldGCRequest{}
 		req.AuthToken = token.SecretID
-
-		var resp nstructs.GenericResponse
-		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
-
+		var resp cstructs.AllocWouldGCResponse
+		err := client.ClientRPC("Allocations.WouldGC", req, &resp)
 		require.NotNil(err)
 		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
 	}
 
 	// Try request with a valid token
 	{
-		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
-			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilitySubmitJob}))
-		req := &nstructs.AllocSpecificRequest{}
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "valid", mock.NodePolicy(acl.PolicyRead))
+		req := &cstructs.AllocWouldGCRequest{}
 		req.AuthToken = token.SecretID
-		req.Namespace = nstructs.DefaultNamespace
-
-		var resp nstructs.GenericResponse
-		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
-		require.True(nstructs.IsErrUnknownAllocation(err))
+		var resp cstructs.AllocWouldGCResponse
+		require.NoError(client.ClientRPC("Allocations.WouldGC", req, &resp))
 	}
 
 	// Try request with a management token
 	{
-		req := &nstructs.AllocSpecificRequest{}
+		req := &cstructs.AllocWouldGCRequest{}
 		req.AuthToken = root.SecretID
-
-		var resp nstructs.GenericResponse
-		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
-		require.True(nstructs.IsErrUnknownAllocation(err))
+		var resp cstructs.AllocWouldGCResponse
+		require.NoError(client.ClientRPC("Allocations.WouldGC", req, &resp))
 	}
 }
 
-func TestAllocations_Stats(t *testing.T) {
+func TestAllocations_GarbageCollect_Stream(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
-	client, cleanup := TestClient(t, nil)
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.GCDiskUsageThreshold = 100.0
+	})
 	defer cleanup()
 
 	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].RestartPolicy = &nstructs.RestartPolicy{
+		Attempts: 0,
+		Mode:     nstructs.RestartPolicyModeFail,
+	}
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "10ms",
+	}
 	require.Nil(client.addAlloc(a, ""))
 
+	// collect garbage collects the alloc through the streaming RPC and
+	// returns the last progress received
+	collect := func(allocID string) (*cstructs.AllocGCProgress, error) {
+		handler, err := client.StreamingRpcHandler("Allocations.GarbageCollect")
+		require.Nil(err)
+
+		p1, p2 := net.Pipe()
+		defer p1.Close()
+		defer p2.Close()
+		p1.SetDeadline(time.Now().Add(10 * time.Second))
+
+		go handler(p2)
+
+		req := &nstructs.AllocSpecificRequest{AllocID: allocID}
+		req.QueryOptions = nstructs.QueryOptions{Region: "global"}
+		encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+		require.Nil(encoder.Encode(req))
+
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				return nil, err
+			}
+			if msg.Error != nil {
+				return nil, msg.Error
+			}
+
+			var progress cstructs.AllocGCProgress
+			require.NoError(json.Unmarshal(msg.Payload, &progress))
+			if progress.Done {
+				return &progress, nil
+			}
+		}
+	}
+
 	// Try with bad alloc
-	req := &cstructs.AllocStatsRequest{}
-	var resp cstructs.AllocStatsResponse
-	err := client.ClientRPC("Allocations.Stats", &req, &resp)
-	require.NotNil(err)
+	_, err := collect(uuid.Generate())
+	require.Error(err)
+	require.True(nstructs.IsErrUnknownAllocation(err))
 
-	// Try with good alloc
-	req.AllocID = a.ID
+	// Try with good alloc once it is marked for collection
 	testutil.WaitForResult(func() (bool, error) {
-		var resp2 cstructs.AllocStatsResponse
-		err := client.ClientRPC("Allocations.Stats", &req, &resp2)
+		progress, err := collect(a.ID)
 		if err != nil {
 			return false, err
 		}
-		if resp2.Stats == nil {
-			return false, fmt.Errorf("invalid stats object")
-		}
-
-		return true, nil
+		return progress.Done, nil
 	}, func(err error) {
 		t.Fatalf("err: %v", err)
 	})
+
+	ar, ok := client.allocs[a.ID]
+	require.True(!ok || ar.IsDestroyed())
 }
 
-func TestAllocations_Stats_ACL(t *testing.T) {
+func TestAllocations_GarbageCollect_ACL(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
 	server, addr, root := testACLServer(t, nil)
@@ -222,9 +558,9 @@ func TestAllocations_Stats_ACL(t *testing.T) {
 
 	// Try request without a token and expect failure
 	{
-		req := &cstructs.AllocStatsRequest{}
-		var resp cstructs.AllocStatsResponse
-		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		req := &cstructs.AllocGarbageCollectRequest{}
+		var resp cstructs.AllocGarbageCollectResponse
+		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
 		require.NotNil(err)
 		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
 	}
@@ -232,11 +568,11 @@ func TestAllocations_Stats_ACL(t *testing.T) {
 	// Try request with an invalid token and expect failure
 	{
 		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
-		req := &cstructs.AllocStatsRequest{}
+		req := &cstructs.AllocGarbageCollectRequest{}
 		req.AuthToken = token.SecretID
 
-		var resp cstructs.AllocStatsResponse
-		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		var resp cstructs.AllocGarbageCollectResponse
+		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
 
 		require.NotNil(err)
 		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
@@ -245,23 +581,3459 @@ func TestAllocations_Stats_ACL(t *testing.T) {
 	// Try request with a valid token
 	{
 		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
-			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
-		req := &cstructs.AllocStatsRequest{}
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilitySubmitJob}))
+		req := &cstructs.AllocGarbageCollectRequest{}
 		req.AuthToken = token.SecretID
 		req.Namespace = nstructs.DefaultNamespace
 
-		var resp cstructs.AllocStatsResponse
-		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		var resp cstructs.AllocGarbageCollectResponse
+		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
 		require.True(nstructs.IsErrUnknownAllocation(err))
 	}
 
 	// Try request with a management token
 	{
-		req := &cstructs.AllocStatsRequest{}
+		req := &cstructs.AllocGarbageCollectRequest{}
 		req.AuthToken = root.SecretID
 
-		var resp cstructs.AllocStatsResponse
-		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		var resp cstructs.AllocGarbageCollectResponse
+		err := client.ClientRPC("Allocations.GarbageCollect", &req, &resp)
 		require.True(nstructs.IsErrUnknownAllocation(err))
 	}
 }
+
+func TestAllocations_Signal(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocSignalRequest{AllocID: uuid.Generate(), Signal: "SIGHUP"}
+	var resp nstructs.GenericResponse
+	err := client.ClientRPC("Allocations.Signal", &req, &resp)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+
+	// Try with a bad signal
+	req.AllocID = a.ID
+	req.Signal = "SIGFOO"
+	err = client.ClientRPC("Allocations.Signal", &req, &resp)
+	require.EqualError(err, `invalid signal "SIGFOO"`)
+
+	// Try with an unknown task
+	req.Signal = "hup"
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.Signal", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.Signal", &req, &resp2)
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Signal every task
+	req.Task = ""
+	require.NoError(client.ClientRPC("Allocations.Signal", &req, &resp))
+
+	ar, err := client.getAllocRunner(a.ID)
+	require.NoError(err)
+	testutil.WaitForResult(func() (bool, error) {
+		signals := 0
+		for _, e := range ar.AllocState().TaskStates["web"].Events {
+			if e.Type == nstructs.TaskSignaling && e.TaskSignal == "SIGHUP" {
+				signals++
+			}
+		}
+		if signals != 2 {
+			return false, fmt.Errorf("expected 2 signal events; got %d", signals)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_Signal_NotRunning(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.BatchAlloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "10ms",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	testutil.WaitForResult(func() (bool, error) {
+		ar, err := client.getAllocRunner(a.ID)
+		if err != nil {
+			return false, err
+		}
+		if ts := ar.AllocState().TaskStates["web"]; ts == nil || ts.State != nstructs.TaskStateDead {
+			return false, fmt.Errorf("expected task to be dead: %v", ts)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	req := &cstructs.AllocSignalRequest{AllocID: a.ID, Task: "web", Signal: "SIGHUP"}
+	var resp nstructs.GenericResponse
+	err := client.ClientRPC("Allocations.Signal", &req, &resp)
+	require.EqualError(err, `task "web" is not running`)
+
+	req.Task = ""
+	err = client.ClientRPC("Allocations.Signal", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "is running")
+}
+
+func TestAllocations_Signal_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request without a token and expect failure
+	{
+		req := &cstructs.AllocSignalRequest{Signal: "SIGHUP"}
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.Signal", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := &cstructs.AllocSignalRequest{Signal: "SIGHUP"}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.Signal", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityAllocLifecycle}))
+		req := &cstructs.AllocSignalRequest{Signal: "SIGHUP"}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.Signal", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocSignalRequest{Signal: "SIGHUP"}
+		req.AuthToken = root.SecretID
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.Signal", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_LookupTimeout(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.AllocLookupTimeout = 50 * time.Millisecond
+	})
+	defer cleanup()
+
+	allocID := uuid.Generate()
+
+	// Hold the allocations lock so lookups time out
+	client.allocLock.Lock()
+	_, err := client.lookupAllocRunner(allocID)
+	require.Equal(allocLookupTimeoutErr, err)
+
+	statsReq := &cstructs.AllocStatsRequest{AllocID: allocID}
+	var statsResp cstructs.AllocStatsResponse
+	err = client.ClientRPC("Allocations.Stats", statsReq, &statsResp)
+	require.EqualError(err, allocLookupTimeoutErr.Error())
+
+	gcReq := &cstructs.AllocGarbageCollectRequest{AllocID: allocID}
+	var gcResp cstructs.AllocGarbageCollectResponse
+	err = client.ClientRPC("Allocations.GarbageCollect", gcReq, &gcResp)
+	require.EqualError(err, allocLookupTimeoutErr.Error())
+
+	dryRunReq := &cstructs.AllocGarbageCollectRequest{AllocID: allocID, DryRun: true}
+	var dryRunResp cstructs.AllocGarbageCollectResponse
+	err = client.ClientRPC("Allocations.GarbageCollect", dryRunReq, &dryRunResp)
+	require.EqualError(err, allocLookupTimeoutErr.Error())
+
+	execReq := &cstructs.AllocExecOnceRequest{AllocID: allocID, Task: "web", Cmd: []string{"true"}}
+	var execResp cstructs.AllocExecOnceResponse
+	err = client.ClientRPC("Allocations.ExecOnce", execReq, &execResp)
+	require.EqualError(err, allocLookupTimeoutErr.Error())
+	client.allocLock.Unlock()
+
+	// Lookups succeed once the lock is released
+	_, err = client.lookupAllocRunner(allocID)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+}
+
+func TestAllocations_Stats(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocStatsRequest{}
+	var resp cstructs.AllocStatsResponse
+	err := client.ClientRPC("Allocations.Stats", &req, &resp)
+	require.NotNil(err)
+
+	// Try with good alloc
+	req.AllocID = a.ID
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocStatsResponse
+		err := client.ClientRPC("Allocations.Stats", &req, &resp2)
+		if err != nil {
+			return false, err
+		}
+		if resp2.Stats == nil {
+			return false, fmt.Errorf("invalid stats object")
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_Stats_Cumulative(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cumulative stats require cgroups")
+	}
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	req := &cstructs.AllocStatsRequest{AllocID: a.ID, Cumulative: true}
+	testutil.WaitForResult(func() (bool, error) {
+		var resp cstructs.AllocStatsResponse
+		if err := client.ClientRPC("Allocations.Stats", &req, &resp); err != nil {
+			return false, err
+		}
+		if resp.Stats == nil || resp.Stats.Tasks["web"] == nil {
+			return false, fmt.Errorf("no task stats")
+		}
+		if resp.Stats.Tasks["web"].Cumulative == nil {
+			return false, fmt.Errorf("no cumulative stats")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// The totals aren't returned unless requested
+	req.Cumulative = false
+	var resp cstructs.AllocStatsResponse
+	require.NoError(client.ClientRPC("Allocations.Stats", &req, &resp))
+	require.NotNil(resp.Stats.Tasks["web"])
+	require.Nil(resp.Stats.Tasks["web"].Cumulative)
+
+	// The task runner's sample must not have been modified
+	req.Cumulative = true
+	resp = cstructs.AllocStatsResponse{}
+	require.NoError(client.ClientRPC("Allocations.Stats", &req, &resp))
+	require.NotNil(resp.Stats.Tasks["web"].Cumulative)
+}
+
+func TestAllocations_Stats_Disabled(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.DisableStatsNamespaces = []string{nstructs.DefaultNamespace}
+	})
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	req := &cstructs.AllocStatsRequest{AllocID: a.ID}
+	testutil.WaitForResult(func() (bool, error) {
+		var resp cstructs.AllocStatsResponse
+		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		if err == nil {
+			return false, fmt.Errorf("expected an error")
+		}
+		if !strings.Contains(err.Error(), cstructs.StatsDisabledErrPrefix) {
+			return false, err
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_Stats_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request without a token and expect failure
+	{
+		req := &cstructs.AllocStatsRequest{}
+		var resp cstructs.AllocStatsResponse
+		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
+		req := &cstructs.AllocStatsRequest{}
+		req.AuthToken = token.SecretID
+
+		var resp cstructs.AllocStatsResponse
+		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := &cstructs.AllocStatsRequest{}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocStatsResponse
+		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocStatsRequest{}
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocStatsResponse
+		err := client.ClientRPC("Allocations.Stats", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_StatsStream(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	handler, err := client.StreamingRpcHandler("Allocations.StatsStream")
+	require.Nil(err)
+
+	// A negative interval is rejected
+	{
+		req := &cstructs.AllocStatsRequest{
+			AllocID:      a.ID,
+			Interval:     -time.Second,
+			QueryOptions: nstructs.QueryOptions{Region: "global"},
+		}
+
+		p1, p2 := net.Pipe()
+		defer p1.Close()
+		defer p2.Close()
+		p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+		go handler(p2)
+
+		encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+		require.Nil(encoder.Encode(req))
+
+		var msg cstructs.StreamErrWrapper
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		require.NoError(decoder.Decode(&msg))
+		require.NotNil(msg.Error)
+		require.EqualValues(400, *msg.Error.Code)
+	}
+
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+	p1.SetDeadline(time.Now().Add(10 * time.Second))
+
+	go handler(p2)
+
+	// Ask for an interval below the floor
+	req := &cstructs.AllocStatsRequest{
+		AllocID:      a.ID,
+		Interval:     time.Millisecond,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		var msg cstructs.StreamErrWrapper
+		require.NoError(decoder.Decode(&msg))
+		require.Nil(msg.Error)
+
+		var resp cstructs.AllocStatsResponse
+		require.NoError(codec.NewDecoderBytes(msg.Payload, nstructs.MsgpackHandle).Decode(&resp))
+		require.NotNil(resp.Stats)
+	}
+	require.True(time.Since(start) >= 2*minStatsStreamInterval)
+}
+
+func TestAllocations_StatsStream_AllocStopped(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.BatchAlloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "1s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	handler, err := client.StreamingRpcHandler("Allocations.StatsStream")
+	require.Nil(err)
+
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+	p1.SetDeadline(time.Now().Add(10 * time.Second))
+
+	go handler(p2)
+
+	req := &cstructs.AllocStatsRequest{
+		AllocID:      a.ID,
+		Interval:     100 * time.Millisecond,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	// Read stats until the alloc completes and the error is delivered
+	decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+	for {
+		var msg cstructs.StreamErrWrapper
+		require.NoError(decoder.Decode(&msg))
+		if msg.Error != nil {
+			require.Contains(msg.Error.Error(), "no longer running")
+			break
+		}
+	}
+
+	// The stream is closed
+	var msg cstructs.StreamErrWrapper
+	require.Equal(io.EOF, decoder.Decode(&msg))
+}
+
+func TestAllocations_StatsStream_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	policyBad := mock.NamespacePolicy("other", "", []string{acl.NamespaceCapabilityReadJob})
+	tokenBad := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", policyBad)
+
+	policyGood := mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob})
+	tokenGood := mock.CreatePolicyAndToken(t, server.State(), 1009, "valid2", policyGood)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         tokenBad.SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "good token",
+			Token:         tokenGood.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			req := &cstructs.AllocStatsRequest{
+				AllocID: uuid.Generate(),
+				QueryOptions: nstructs.QueryOptions{
+					Namespace: nstructs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			handler, err := client.StreamingRpcHandler("Allocations.StatsStream")
+			require.Nil(err)
+
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+			p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+			go handler(p2)
+
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			var msg cstructs.StreamErrWrapper
+			decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+			require.NoError(decoder.Decode(&msg))
+			require.NotNil(msg.Error)
+			require.Contains(msg.Error.Error(), c.ExpectedError)
+		})
+	}
+}
+
+func TestAllocations_StatsDebug(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocStatsDebugRequest{}
+	var resp cstructs.AllocStatsDebugResponse
+	err := client.ClientRPC("Allocations.StatsDebug", &req, &resp)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+
+	// Try with an unknown task
+	req.AllocID = a.ID
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.StatsDebug", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = ""
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocStatsDebugResponse
+		err := client.ClientRPC("Allocations.StatsDebug", &req, &resp2)
+		if err != nil {
+			return false, err
+		}
+		debug, ok := resp2.Tasks["web"]
+		if !ok {
+			return false, fmt.Errorf("missing stats for task web")
+		}
+		if debug.Sample == nil {
+			return false, fmt.Errorf("missing sample: %v", debug.Errors)
+		}
+		if len(debug.Stages) != 3 || debug.Stages[0].Name != "driver" {
+			return false, fmt.Errorf("unexpected stages: %v", debug.Stages)
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_StatsDebug_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request without a token and expect failure
+	{
+		req := &cstructs.AllocStatsDebugRequest{}
+		var resp cstructs.AllocStatsDebugResponse
+		err := client.ClientRPC("Allocations.StatsDebug", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a namespace token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, acl.PolicyWrite, nil))
+		req := &cstructs.AllocStatsDebugRequest{}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocStatsDebugResponse
+		err := client.ClientRPC("Allocations.StatsDebug", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocStatsDebugRequest{}
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocStatsDebugResponse
+		err := client.ClientRPC("Allocations.StatsDebug", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_CgroupConfig(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocCgroupConfigRequest{}
+	var resp cstructs.AllocCgroupConfigResponse
+	err := client.ClientRPC("Allocations.CgroupConfig", &req, &resp)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+
+	// Try with an unknown task
+	req.AllocID = a.ID
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.CgroupConfig", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = ""
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocCgroupConfigResponse
+		err := client.ClientRPC("Allocations.CgroupConfig", &req, &resp2)
+		if err != nil {
+			return false, err
+		}
+		conf, ok := resp2.Tasks["web"]
+		if !ok {
+			return false, fmt.Errorf("missing cgroup config for task web")
+		}
+		if len(conf.Paths) == 0 {
+			return false, fmt.Errorf("expected cgroup paths")
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_StatsDiff(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocStatsDiffRequest{}
+	var resp cstructs.AllocStatsDiffResponse
+	err := client.ClientRPC("Allocations.StatsDiff", &req, &resp)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+
+	// Try with a window that is too large
+	req.AllocID = a.ID
+	req.Window = 2 * maxStatsDiffWindow
+	err = client.ClientRPC("Allocations.StatsDiff", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "window must be")
+
+	// Try with good alloc
+	req.Window = 100 * time.Millisecond
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocStatsDiffResponse
+		err := client.ClientRPC("Allocations.StatsDiff", &req, &resp2)
+		if err != nil {
+			return false, err
+		}
+		diff, ok := resp2.Tasks["web"]
+		if !ok {
+			return false, fmt.Errorf("missing stats diff for task web")
+		}
+		if diff.Window <= 0 {
+			return false, fmt.Errorf("expected a positive window: %v", diff.Window)
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_StatsDiff_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request without a token and expect failure
+	{
+		req := &cstructs.AllocStatsDiffRequest{}
+		var resp cstructs.AllocStatsDiffResponse
+		err := client.ClientRPC("Allocations.StatsDiff", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
+		req := &cstructs.AllocStatsDiffRequest{}
+		req.AuthToken = token.SecretID
+
+		var resp cstructs.AllocStatsDiffResponse
+		err := client.ClientRPC("Allocations.StatsDiff", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := &cstructs.AllocStatsDiffRequest{}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocStatsDiffResponse
+		err := client.ClientRPC("Allocations.StatsDiff", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocStatsDiffRequest{}
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocStatsDiffResponse
+		err := client.ClientRPC("Allocations.StatsDiff", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_CgroupConfig_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request without a token and expect failure
+	{
+		req := &cstructs.AllocCgroupConfigRequest{}
+		var resp cstructs.AllocCgroupConfigResponse
+		err := client.ClientRPC("Allocations.CgroupConfig", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
+		req := &cstructs.AllocCgroupConfigRequest{}
+		req.AuthToken = token.SecretID
+
+		var resp cstructs.AllocCgroupConfigResponse
+		err := client.ClientRPC("Allocations.CgroupConfig", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := &cstructs.AllocCgroupConfigRequest{}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocCgroupConfigResponse
+		err := client.ClientRPC("Allocations.CgroupConfig", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocCgroupConfigRequest{}
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocCgroupConfigResponse
+		err := client.ClientRPC("Allocations.CgroupConfig", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_Ulimits(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocUlimitsRequest{}
+	var resp cstructs.AllocUlimitsResponse
+	err := client.ClientRPC("Allocations.Ulimits", &req, &resp)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+
+	// Try with an unknown task
+	req.AllocID = a.ID
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.Ulimits", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = ""
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocUlimitsResponse
+		err := client.ClientRPC("Allocations.Ulimits", &req, &resp2)
+		if err != nil {
+			return false, err
+		}
+		limits, ok := resp2.Tasks["web"]
+		if !ok {
+			return false, fmt.Errorf("missing resource limits for task web")
+		}
+		if l, ok := limits["nofile"]; !ok || l.Soft == "" || l.Hard == "" {
+			return false, fmt.Errorf("expected nofile limit: %#v", limits)
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_Ulimits_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request without a token and expect failure
+	{
+		req := &cstructs.AllocUlimitsRequest{}
+		var resp cstructs.AllocUlimitsResponse
+		err := client.ClientRPC("Allocations.Ulimits", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
+		req := &cstructs.AllocUlimitsRequest{}
+		req.AuthToken = token.SecretID
+
+		var resp cstructs.AllocUlimitsResponse
+		err := client.ClientRPC("Allocations.Ulimits", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := &cstructs.AllocUlimitsRequest{}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocUlimitsResponse
+		err := client.ClientRPC("Allocations.Ulimits", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := &cstructs.AllocUlimitsRequest{}
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocUlimitsResponse
+		err := client.ClientRPC("Allocations.Ulimits", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_SetLogRotation(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocSetLogRotationRequest{
+		AllocID:       a.ID,
+		MaxFiles:      3,
+		MaxFileSizeMB: 5,
+	}
+	var resp nstructs.GenericResponse
+	err := client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with invalid settings
+	req.Task = "web"
+	req.MaxFiles = 0
+	err = client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "minimum number of files")
+
+	// Try with settings exceeding the ephemeral disk
+	req.MaxFiles = 100
+	req.MaxFileSizeMB = 100
+	err = client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "must be less than requested disk capacity")
+
+	// Try with an unknown task
+	req.Task = "foo"
+	req.MaxFiles = 3
+	req.MaxFileSizeMB = 5
+	err = client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.SetLogRotation", &req, &resp2)
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_SetLogRotation_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocSetLogRotationRequest {
+		return &cstructs.AllocSetLogRotationRequest{
+			AllocID:       uuid.Generate(),
+			Task:          "web",
+			MaxFiles:      3,
+			MaxFileSizeMB: 5,
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilitySubmitJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.SetLogRotation", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_RotateLogs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "20s",
+		"stdout_string": "hello",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocRotateLogsRequest{
+		AllocID: a.ID,
+		LogType: "stdout",
+	}
+	var resp cstructs.AllocRotateLogsResponse
+	err := client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with a bad log type
+	req.Task = "web"
+	req.LogType = "foo"
+	err = client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+	require.EqualError(err, logTypeNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	req.LogType = "stdout"
+	err = client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocRotateLogsResponse
+		if err := client.ClientRPC("Allocations.RotateLogs", &req, &resp2); err != nil {
+			return false, err
+		}
+		if resp2.Path == "" {
+			return false, fmt.Errorf("nothing rotated yet")
+		}
+		resp = resp2
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+	require.Equal("alloc/logs/web.stdout.0", resp.Path)
+}
+
+func TestAllocations_RotateLogs_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocRotateLogsRequest {
+		return &cstructs.AllocRotateLogsRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+			LogType: "stdout",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocRotateLogsResponse
+		err := client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadLogs}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocRotateLogsResponse
+		err := client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityWriteLogs}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocRotateLogsResponse
+		err := client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocRotateLogsResponse
+		err := client.ClientRPC("Allocations.RotateLogs", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_Checks(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Flip the check status on every lookup
+	var lookups int32
+	mockConsul := client.consulService.(*consulApi.MockConsulServiceClient)
+	mockConsul.AllocRegistrationsFn = func(allocID string) (*consul.AllocRegistration, error) {
+		status := api.HealthPassing
+		if atomic.AddInt32(&lookups, 1)%2 == 0 {
+			status = api.HealthCritical
+		}
+		return &consul.AllocRegistration{
+			Tasks: map[string]*consul.TaskRegistration{
+				"web": {
+					Services: map[string]*consul.ServiceRegistration{
+						"web-service": {
+							Service: &api.AgentService{Service: "web"},
+							Checks: []*api.AgentCheck{
+								{CheckID: "check-1", Name: "alive", Status: status},
+							},
+						},
+					},
+				},
+			},
+		}, nil
+	}
+
+	// Use a batch alloc so it stops once its task completes
+	a := mock.Alloc()
+	a.Job.Type = nstructs.JobTypeBatch
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "2s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Get the handler
+	handler, err := client.StreamingRpcHandler("Allocations.Checks")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+	doneCh := make(chan struct{})
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					close(doneCh)
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+				return
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	req := &cstructs.AllocChecksRequest{
+		AllocID:      a.ID,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(10 * time.Second)
+	var statuses []*cstructs.CheckStatus
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout")
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var status cstructs.CheckStatus
+			require.NoError(json.Unmarshal(msg.Payload, &status))
+			statuses = append(statuses, &status)
+		case <-doneCh:
+			// The stream is closed once the alloc stops
+			break OUTER
+		}
+	}
+
+	require.True(len(statuses) >= 2, "expected at least 2 transitions, got %d", len(statuses))
+	require.Equal("web", statuses[0].Task)
+	require.Equal("web", statuses[0].ServiceName)
+	require.Equal("check-1", statuses[0].CheckID)
+	require.Equal(api.HealthPassing, statuses[0].Status)
+	require.Equal(api.HealthCritical, statuses[1].Status)
+}
+
+// testStreamDisconnect sends the request to the streaming RPC, waits for the
+// stream to go idle and disconnects. It asserts the stream's session ends,
+// which only happens once the handler returns and releases its stream slot.
+func testStreamDisconnect(t *testing.T, c *Client, method string, req interface{}) {
+	require := require.New(t)
+
+	handler, err := c.StreamingRpcHandler(method)
+	require.Nil(err)
+
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+	go handler(p2)
+
+	// Drain the stream
+	msgCh := make(chan *cstructs.StreamErrWrapper, 100)
+	go func() {
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+			msgCh <- &msg
+		}
+	}()
+
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	select {
+	case msg := <-msgCh:
+		require.Nil(msg.Error)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	// Wait for the stream to be idle so the handler isn't writing when the
+	// connection closes
+	for idle := false; !idle; {
+		select {
+		case msg := <-msgCh:
+			require.Nil(msg.Error)
+		case <-time.After(500 * time.Millisecond):
+			idle = true
+		}
+	}
+
+	listStreams := func() ([]*cstructs.StreamSession, error) {
+		var resp cstructs.ClientStreamsResponse
+		err := c.ClientRPC("ClientStats.ListStreams", &nstructs.NodeSpecificRequest{}, &resp)
+		return resp.Streams, err
+	}
+	streams, err := listStreams()
+	require.NoError(err)
+	require.Len(streams, 1)
+	require.Equal(method, streams[0].Method)
+
+	// Disconnecting ends the stream
+	require.NoError(p1.Close())
+	testutil.WaitForResult(func() (bool, error) {
+		streams, err := listStreams()
+		if err != nil {
+			return false, err
+		}
+		if len(streams) != 0 {
+			return false, fmt.Errorf("expected no streams, got %d", len(streams))
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_Checks_Disconnect(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// The check never changes so the stream is idle after the first status
+	mockConsul := client.consulService.(*consulApi.MockConsulServiceClient)
+	mockConsul.AllocRegistrationsFn = func(allocID string) (*consul.AllocRegistration, error) {
+		return &consul.AllocRegistration{
+			Tasks: map[string]*consul.TaskRegistration{
+				"web": {
+					Services: map[string]*consul.ServiceRegistration{
+						"web-service": {
+							Service: &api.AgentService{Service: "web"},
+							Checks: []*api.AgentCheck{
+								{CheckID: "check-1", Name: "alive", Status: api.HealthPassing},
+							},
+						},
+					},
+				},
+			},
+		}, nil
+	}
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	req := &cstructs.AllocChecksRequest{
+		AllocID:      a.ID,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	testStreamDisconnect(t, client, "Allocations.Checks", req)
+}
+
+func TestAllocations_Checks_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	policyBad := mock.NamespacePolicy("other", "", []string{acl.NamespaceCapabilityReadJob})
+	tokenBad := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", policyBad)
+
+	policyGood := mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob})
+	tokenGood := mock.CreatePolicyAndToken(t, server.State(), 1009, "valid2", policyGood)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         tokenBad.SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "good token",
+			Token:         tokenGood.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			// Make the request
+			req := &cstructs.AllocChecksRequest{
+				AllocID: uuid.Generate(),
+				QueryOptions: nstructs.QueryOptions{
+					Namespace: nstructs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			// Get the handler
+			handler, err := client.StreamingRpcHandler("Allocations.Checks")
+			require.Nil(err)
+
+			// Create a pipe
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+
+			errCh := make(chan error)
+			streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+			// Start the handler
+			go handler(p2)
+
+			// Start the decoder
+			go func() {
+				decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+				for {
+					var msg cstructs.StreamErrWrapper
+					if err := decoder.Decode(&msg); err != nil {
+						if err == io.EOF || strings.Contains(err.Error(), "closed") {
+							return
+						}
+						errCh <- fmt.Errorf("error decoding: %v", err)
+					}
+
+					streamMsg <- &msg
+				}
+			}()
+
+			// Send the request
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			timeout := time.After(5 * time.Second)
+
+		OUTER:
+			for {
+				select {
+				case <-timeout:
+					t.Fatal("timeout")
+				case err := <-errCh:
+					t.Fatal(err)
+				case msg := <-streamMsg:
+					if msg.Error == nil {
+						continue
+					}
+
+					require.Contains(msg.Error.Error(), c.ExpectedError)
+					break OUTER
+				}
+			}
+		})
+	}
+}
+
+func TestAllocations_Profile_Invalid(t *testing.T) {
+	t.Parallel()
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	cases := []struct {
+		Name          string
+		Req           *cstructs.AllocProfileRequest
+		ExpectedError string
+	}{
+		{
+			Name:          "no alloc",
+			Req:           &cstructs.AllocProfileRequest{Task: "web", Type: "cpu", Duration: time.Second},
+			ExpectedError: allocIDNotPresentErr.Error(),
+		},
+		{
+			Name:          "no task",
+			Req:           &cstructs.AllocProfileRequest{AllocID: uuid.Generate(), Type: "cpu", Duration: time.Second},
+			ExpectedError: taskNotPresentErr.Error(),
+		},
+		{
+			Name:          "bad type",
+			Req:           &cstructs.AllocProfileRequest{AllocID: uuid.Generate(), Task: "web", Type: "heap", Duration: time.Second},
+			ExpectedError: "unknown profile type",
+		},
+		{
+			Name:          "bad duration",
+			Req:           &cstructs.AllocProfileRequest{AllocID: uuid.Generate(), Task: "web", Type: "cpu", Duration: time.Hour},
+			ExpectedError: "profile duration",
+		},
+		{
+			Name:          "unknown alloc",
+			Req:           &cstructs.AllocProfileRequest{AllocID: uuid.Generate(), Task: "web", Type: "cpu", Duration: time.Second},
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			require := require.New(t)
+			c.Req.QueryOptions = nstructs.QueryOptions{Region: "global"}
+
+			// Get the handler
+			handler, err := client.StreamingRpcHandler("Allocations.Profile")
+			require.Nil(err)
+
+			// Create a pipe
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+
+			errCh := make(chan error)
+			streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+			// Start the handler
+			go handler(p2)
+
+			// Start the decoder
+			go func() {
+				decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+				for {
+					var msg cstructs.StreamErrWrapper
+					if err := decoder.Decode(&msg); err != nil {
+						if err == io.EOF || strings.Contains(err.Error(), "closed") {
+							return
+						}
+						errCh <- fmt.Errorf("error decoding: %v", err)
+					}
+
+					streamMsg <- &msg
+				}
+			}()
+
+			// Send the request
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(c.Req))
+
+			select {
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout")
+			case err := <-errCh:
+				t.Fatal(err)
+			case msg := <-streamMsg:
+				require.NotNil(msg.Error)
+				require.Contains(msg.Error.Error(), c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestAllocations_Profile_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	policyBad := mock.NamespacePolicy(nstructs.DefaultNamespace, "write", nil)
+	tokenBad := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", policyBad)
+
+	policyGood := mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityAllocProfile})
+	tokenGood := mock.CreatePolicyAndToken(t, server.State(), 1009, "valid2", policyGood)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         tokenBad.SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "good token",
+			Token:         tokenGood.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			// Make the request
+			req := &cstructs.AllocProfileRequest{
+				AllocID:  uuid.Generate(),
+				Task:     "web",
+				Type:     "cpu",
+				Duration: time.Second,
+				QueryOptions: nstructs.QueryOptions{
+					Namespace: nstructs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			// Get the handler
+			handler, err := client.StreamingRpcHandler("Allocations.Profile")
+			require.Nil(err)
+
+			// Create a pipe
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+
+			errCh := make(chan error)
+			streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+			// Start the handler
+			go handler(p2)
+
+			// Start the decoder
+			go func() {
+				decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+				for {
+					var msg cstructs.StreamErrWrapper
+					if err := decoder.Decode(&msg); err != nil {
+						if err == io.EOF || strings.Contains(err.Error(), "closed") {
+							return
+						}
+						errCh <- fmt.Errorf("error decoding: %v", err)
+					}
+
+					streamMsg <- &msg
+				}
+			}()
+
+			// Send the request
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			timeout := time.After(5 * time.Second)
+
+		OUTER:
+			for {
+				select {
+				case <-timeout:
+					t.Fatal("timeout")
+				case err := <-errCh:
+					t.Fatal(err)
+				case msg := <-streamMsg:
+					if msg.Error == nil {
+						continue
+					}
+
+					require.Contains(msg.Error.Error(), c.ExpectedError)
+					break OUTER
+				}
+			}
+		})
+	}
+}
+
+func TestAllocations_DropCaches(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocDropCachesRequest{AllocID: a.ID}
+	var resp cstructs.AllocDropCachesResponse
+	err := client.ClientRPC("Allocations.DropCaches", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.DropCaches", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc. Hosts without memory.reclaim return a clear error.
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocDropCachesResponse
+		err := client.ClientRPC("Allocations.DropCaches", &req, &resp2)
+		if err == nil {
+			return true, nil
+		}
+		for _, unsupported := range []error{cgutil.ErrReclaimUnsupported, cgutil.ErrCgroupsUnsupported} {
+			if strings.Contains(err.Error(), unsupported.Error()) {
+				return true, nil
+			}
+		}
+		return false, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_DropCaches_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocDropCachesRequest {
+		return &cstructs.AllocDropCachesRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocDropCachesResponse
+		err := client.ClientRPC("Allocations.DropCaches", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a namespace token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "write", nil))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocDropCachesResponse
+		err := client.ClientRPC("Allocations.DropCaches", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocDropCachesResponse
+		err := client.ClientRPC("Allocations.DropCaches", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_Mounts(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocMountsRequest{AllocID: a.ID}
+	var resp cstructs.AllocMountsResponse
+	err := client.ClientRPC("Allocations.Mounts", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.Mounts", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocMountsResponse
+		if err := client.ClientRPC("Allocations.Mounts", &req, &resp2); err != nil {
+			return false, err
+		}
+		for _, m := range resp2.Mounts {
+			if m.Target == "/" {
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("expected a root mount in %v", resp2.Mounts)
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_Mounts_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocMountsRequest {
+		return &cstructs.AllocMountsRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocMountsResponse
+		err := client.ClientRPC("Allocations.Mounts", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityListJobs}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocMountsResponse
+		err := client.ClientRPC("Allocations.Mounts", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocMountsResponse
+		err := client.ClientRPC("Allocations.Mounts", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocMountsResponse
+		err := client.ClientRPC("Allocations.Mounts", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_TaskDiagnose(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocTaskDiagnoseRequest{AllocID: a.ID}
+	var resp cstructs.AllocTaskDiagnoseResponse
+	err := client.ClientRPC("Allocations.TaskDiagnose", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.TaskDiagnose", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocTaskDiagnoseResponse
+		if err := client.ClientRPC("Allocations.TaskDiagnose", &req, &resp2); err != nil {
+			return false, err
+		}
+		d := resp2.Diagnosis
+		if d.Reason != cstructs.TaskDiagnosisRunning {
+			return false, fmt.Errorf("expected task to be running: %#v", d)
+		}
+		if len(d.Events) == 0 || d.Events[len(d.Events)-1].Type != nstructs.TaskStarted {
+			return false, fmt.Errorf("expected the started event last in %v", d.Events)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_TaskDiagnose_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocTaskDiagnoseRequest {
+		return &cstructs.AllocTaskDiagnoseRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocTaskDiagnoseResponse
+		err := client.ClientRPC("Allocations.TaskDiagnose", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityListJobs}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocTaskDiagnoseResponse
+		err := client.ClientRPC("Allocations.TaskDiagnose", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocTaskDiagnoseResponse
+		err := client.ClientRPC("Allocations.TaskDiagnose", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocTaskDiagnoseResponse
+		err := client.ClientRPC("Allocations.TaskDiagnose", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_ExecOnce(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocExecOnceRequest{AllocID: a.ID, Cmd: []string{"echo", "hi"}}
+	var resp cstructs.AllocExecOnceResponse
+	err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try without a command
+	req.Task = "web"
+	req.Cmd = nil
+	err = client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+	require.EqualError(err, "must specify a command")
+
+	// Try with a bad timeout and output limit
+	req.Cmd = []string{"echo", "hi"}
+	req.Timeout = time.Hour
+	err = client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "timeout must be between")
+
+	req.Timeout = 0
+	req.MaxOutputBytes = -1
+	err = client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "max output bytes must be between")
+
+	// Try with an unknown task
+	req.Task = "foo"
+	req.MaxOutputBytes = 0
+	err = client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocExecOnceResponse
+		if err := client.ClientRPC("Allocations.ExecOnce", &req, &resp2); err != nil {
+			return false, err
+		}
+		if expected := `Exec("web", ["echo" "hi"])`; string(resp2.Stdout) != expected {
+			return false, fmt.Errorf("expected stdout %q, got %q", expected, resp2.Stdout)
+		}
+		if resp2.StdoutTruncated || resp2.ExitCode != 0 {
+			return false, fmt.Errorf("unexpected response %#v", resp2)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Truncate the output
+	req.MaxOutputBytes = 4
+	var resp3 cstructs.AllocExecOnceResponse
+	require.NoError(client.ClientRPC("Allocations.ExecOnce", &req, &resp3))
+	require.Equal("Exec", string(resp3.Stdout))
+	require.True(resp3.StdoutTruncated)
+	require.Empty(resp3.Stderr)
+	require.False(resp3.StderrTruncated)
+}
+
+func TestAllocations_ExecOnce_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocExecOnceRequest {
+		return &cstructs.AllocExecOnceRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+			Cmd:     []string{"echo", "hi"},
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityAllocExec}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a token only allowed to run some commands
+	{
+		policy := `namespace "default" { exec_commands = ["/bin/ec*"] }`
+		token := mock.CreatePolicyAndToken(t, server.State(), 1009, "test-exec-commands", policy)
+
+		// A command matching the globs is allowed
+		req := newReq()
+		req.Cmd = []string{"/bin/echo", "hi"}
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+
+		// Other commands are denied
+		req.Cmd = []string{"/bin/sh", "-c", "echo hi"}
+		err = client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_ExecOnce_ExecCommands(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, _ := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Register a running alloc with the server so the client keeps it
+	waitTilNodeReady(client, t)
+	a := mock.Alloc()
+	a.NodeID = client.NodeID()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	state := server.State()
+	require.NoError(state.UpsertJob(1001, a.Job))
+	require.NoError(state.UpsertJobSummary(1002, mock.JobSummary(a.JobID)))
+	require.NoError(state.UpsertAllocs(1003, []*nstructs.Allocation{a}))
+
+	policy := `namespace "default" { exec_commands = ["ech*"] }`
+	token := mock.CreatePolicyAndToken(t, state, 1005, "exec-commands", policy)
+
+	// A command matching the policy's globs runs in the task
+	req := &cstructs.AllocExecOnceRequest{AllocID: a.ID, Task: "web", Cmd: []string{"echo", "hi"}}
+	req.AuthToken = token.SecretID
+	req.Namespace = nstructs.DefaultNamespace
+	testutil.WaitForResult(func() (bool, error) {
+		var resp cstructs.AllocExecOnceResponse
+		if err := client.ClientRPC("Allocations.ExecOnce", &req, &resp); err != nil {
+			return false, err
+		}
+		if expected := `Exec("web", ["echo" "hi"])`; string(resp.Stdout) != expected {
+			return false, fmt.Errorf("expected stdout %q, got %q", expected, resp.Stdout)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Other commands are denied
+	req.Cmd = []string{"cat", "/etc/passwd"}
+	var resp cstructs.AllocExecOnceResponse
+	err := client.ClientRPC("Allocations.ExecOnce", &req, &resp)
+	require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+}
+
+func TestAllocations_ProcSnapshot(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocProcSnapshotRequest{AllocID: a.ID}
+	var resp cstructs.AllocProcSnapshotResponse
+	err := client.ClientRPC("Allocations.ProcSnapshot", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.ProcSnapshot", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	req.AllPIDs = true
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocProcSnapshotResponse
+		if err := client.ClientRPC("Allocations.ProcSnapshot", &req, &resp2); err != nil {
+			return false, err
+		}
+		if len(resp2.Processes) == 0 {
+			return false, fmt.Errorf("expected processes")
+		}
+		if p := resp2.Processes[0]; p.VmPeak == 0 || len(p.Limits) == 0 {
+			return false, fmt.Errorf("expected the main process's metrics: %#v", p)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_ProcSnapshot_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocProcSnapshotRequest {
+		return &cstructs.AllocProcSnapshotRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocProcSnapshotResponse
+		err := client.ClientRPC("Allocations.ProcSnapshot", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityListJobs}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocProcSnapshotResponse
+		err := client.ClientRPC("Allocations.ProcSnapshot", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocProcSnapshotResponse
+		err := client.ClientRPC("Allocations.ProcSnapshot", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocProcSnapshotResponse
+		err := client.ClientRPC("Allocations.ProcSnapshot", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_ListFDs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocFDsRequest{AllocID: a.ID}
+	var resp cstructs.AllocFDsResponse
+	err := client.ClientRPC("Allocations.ListFDs", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.ListFDs", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Try with good alloc
+	req.Task = "web"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocFDsResponse
+		if err := client.ClientRPC("Allocations.ListFDs", &req, &resp2); err != nil {
+			return false, err
+		}
+		if len(resp2.FDs) == 0 {
+			return false, fmt.Errorf("expected open file descriptors")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_ListFDs_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocFDsRequest {
+		return &cstructs.AllocFDsRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocFDsResponse
+		err := client.ClientRPC("Allocations.ListFDs", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "write", nil))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocFDsResponse
+		err := client.ClientRPC("Allocations.ListFDs", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityAllocProfile}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocFDsResponse
+		err := client.ClientRPC("Allocations.ListFDs", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocFDsResponse
+		err := client.ClientRPC("Allocations.ListFDs", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_StreamFDs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Use a batch alloc so it stops once its task completes
+	a := mock.Alloc()
+	a.Job.Type = nstructs.JobTypeBatch
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "3s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Wait for the task to be inspectable
+	testutil.WaitForResult(func() (bool, error) {
+		ar, err := client.getAllocRunner(a.ID)
+		if err != nil {
+			return false, err
+		}
+		_, err = ar.TaskPID("web")
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Get the handler
+	handler, err := client.StreamingRpcHandler("Allocations.StreamFDs")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+	doneCh := make(chan struct{})
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					close(doneCh)
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+				return
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	req := &cstructs.AllocFDsRequest{
+		AllocID:      a.ID,
+		Task:         "web",
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(10 * time.Second)
+	var events []*cstructs.FDEvent
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout")
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var event cstructs.FDEvent
+			require.NoError(json.Unmarshal(msg.Payload, &event))
+			events = append(events, &event)
+		case <-doneCh:
+			// The stream is closed once the alloc stops
+			break OUTER
+		}
+	}
+
+	require.NotEmpty(events)
+	require.Equal("open", events[0].Op)
+	require.NotNil(events[0].FD)
+}
+
+// TestAllocations_StreamFDs_Disconnect isn't parallel as it stops the polling
+// of the descriptors so the stream is idle. The mock driver's task is the test
+// process, whose descriptors change all the time.
+func TestAllocations_StreamFDs_Disconnect(t *testing.T) {
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	defer func(interval time.Duration) {
+		fdPollInterval = interval
+	}(fdPollInterval)
+	fdPollInterval = time.Hour
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Wait for the task to be inspectable
+	testutil.WaitForResult(func() (bool, error) {
+		ar, err := client.getAllocRunner(a.ID)
+		if err != nil {
+			return false, err
+		}
+		_, err = ar.TaskPID("web")
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	req := &cstructs.AllocFDsRequest{
+		AllocID:      a.ID,
+		Task:         "web",
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	testStreamDisconnect(t, client, "Allocations.StreamFDs", req)
+}
+
+func TestAllocations_LifecycleEvents(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Use a batch alloc so its task completes while streaming
+	a := mock.Alloc()
+	a.Job.Type = nstructs.JobTypeBatch
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "3s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Get the handler
+	handler, err := client.StreamingRpcHandler("Allocations.LifecycleEvents")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+				return
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	req := &nstructs.AllocSpecificRequest{
+		AllocID:      a.ID,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	// Wait for the task to exit and its hooks to run
+	var hooks []string
+	timeout := time.After(10 * time.Second)
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout, received hooks %v", hooks)
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var event cstructs.AllocLifecycleEvent
+			require.NoError(json.Unmarshal(msg.Payload, &event))
+			switch event.Type {
+			case cstructs.LifecycleEventHook:
+				require.Equal("web", event.Task)
+				hooks = append(hooks, event.Stage+"/"+event.Hook)
+			case cstructs.LifecycleEventState:
+				if event.Task == "" && event.State == nstructs.AllocClientStatusComplete {
+					break OUTER
+				}
+			}
+		}
+	}
+
+	require.Contains(hooks, "exited/stats_hook")
+}
+
+func TestAllocations_LifecycleEvents_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	policy := mock.NamespacePolicy(nstructs.DefaultNamespace, acl.PolicyWrite, nil)
+	token := mock.CreatePolicyAndToken(t, server.State(), 1005, "valid", policy)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         "",
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "namespace token",
+			Token:         token.SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			req := &nstructs.AllocSpecificRequest{
+				AllocID: uuid.Generate(),
+				QueryOptions: nstructs.QueryOptions{
+					Namespace: nstructs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			handler, err := client.StreamingRpcHandler("Allocations.LifecycleEvents")
+			require.Nil(err)
+
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+			p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+			go handler(p2)
+
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			var msg cstructs.StreamErrWrapper
+			decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+			require.NoError(decoder.Decode(&msg))
+			require.NotNil(msg.Error)
+			require.Contains(msg.Error.Error(), c.ExpectedError)
+		})
+	}
+}
+
+func TestAllocations_Decisions(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+
+	// Get the handler
+	handler, err := client.StreamingRpcHandler("Allocations.Decisions")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+				return
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	req := &nstructs.AllocSpecificRequest{
+		AllocID:      a.ID,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	next := func() *cstructs.AllocDecision {
+		select {
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for decision")
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var decision cstructs.AllocDecision
+			require.NoError(json.Unmarshal(msg.Payload, &decision))
+			return &decision
+		}
+		return nil
+	}
+
+	// Wait for the stream to listen before placing the alloc
+	testutil.WaitForResult(func() (bool, error) {
+		client.allocDecisions.Send(&cstructs.AllocDecision{AllocID: a.ID, Decision: "probe"})
+		select {
+		case msg := <-streamMsg:
+			return msg.Error == nil, fmt.Errorf("unexpected error: %v", msg.Error)
+		case <-time.After(100 * time.Millisecond):
+			return false, fmt.Errorf("stream not listening")
+		}
+	}, func(err error) {
+		t.Fatal(err)
+	})
+
+	// Decisions about other allocs aren't streamed
+	other := mock.Alloc()
+	other.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	other.Job.TaskGroups[0].Tasks[0].Config = a.Job.TaskGroups[0].Tasks[0].Config
+	require.Nil(client.addAlloc(other, ""))
+
+	// Place the alloc
+	require.Nil(client.addAlloc(a, ""))
+	decision := next()
+	require.Equal(a.ID, decision.AllocID)
+	require.Equal(cstructs.AllocDecisionRun, decision.Decision)
+	require.NotNil(decision.Update)
+	require.Equal(a.AllocModifyIndex, decision.Update.AllocModifyIndex)
+
+	// Stop the alloc from the servers
+	update := a.Copy()
+	update.DesiredStatus = nstructs.AllocDesiredStatusStop
+	update.DesiredDescription = "alloc is being updated due to job update"
+	update.AllocModifyIndex++
+	client.updateAlloc(update)
+	decision = next()
+	require.Equal(cstructs.AllocDecisionStop, decision.Decision)
+	require.Equal("server marked desired=stop: alloc is being updated due to job update", decision.Reason)
+	require.Equal(update.AllocModifyIndex, decision.Update.AllocModifyIndex)
+	require.Equal(nstructs.AllocDesiredStatusStop, decision.Update.DesiredStatus)
+
+	// Remove the alloc from the servers
+	client.removeAlloc(a.ID)
+	decision = next()
+	require.Equal(cstructs.AllocDecisionRemove, decision.Decision)
+	require.Nil(decision.Update)
+}
+
+func TestAllocations_Decisions_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	policy := mock.NamespacePolicy(nstructs.DefaultNamespace, acl.PolicyWrite, nil)
+	token := mock.CreatePolicyAndToken(t, server.State(), 1005, "valid", policy)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         "",
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "namespace token",
+			Token:         token.SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:  "root token",
+			Token: root.SecretID,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			req := &nstructs.AllocSpecificRequest{
+				AllocID: uuid.Generate(),
+				QueryOptions: nstructs.QueryOptions{
+					Namespace: nstructs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			handler, err := client.StreamingRpcHandler("Allocations.Decisions")
+			require.Nil(err)
+
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+			p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+			go handler(p2)
+
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			if c.ExpectedError == "" {
+				// The stream listens even though the alloc is unknown
+				streamMsg := make(chan *cstructs.StreamErrWrapper, 1)
+				go func() {
+					var msg cstructs.StreamErrWrapper
+					decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+					if err := decoder.Decode(&msg); err == nil {
+						streamMsg <- &msg
+					}
+				}()
+
+				testutil.WaitForResult(func() (bool, error) {
+					client.allocDecisions.Send(&cstructs.AllocDecision{AllocID: req.AllocID, Decision: "probe"})
+					select {
+					case msg := <-streamMsg:
+						return msg.Error == nil, fmt.Errorf("unexpected error: %v", msg.Error)
+					case <-time.After(100 * time.Millisecond):
+						return false, fmt.Errorf("stream not listening")
+					}
+				}, func(err error) {
+					t.Fatal(err)
+				})
+				return
+			}
+
+			var msg cstructs.StreamErrWrapper
+			decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+			require.NoError(decoder.Decode(&msg))
+			require.NotNil(msg.Error)
+			require.Contains(msg.Error.Error(), c.ExpectedError)
+		})
+	}
+}
+
+func TestAllocations_RenderedTemplate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	task := a.Job.TaskGroups[0].Tasks[0]
+	task.Driver = "mock_driver"
+	task.Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	task.Templates = []*nstructs.Template{
+		{
+			EmbeddedTmpl: "hello {{ env \"NOMAD_TASK_NAME\" }}",
+			DestPath:     "local/hello.txt",
+			ChangeMode:   nstructs.TemplateChangeModeNoop,
+		},
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try with bad alloc
+	req := &cstructs.AllocRenderedTemplateRequest{
+		Task: "web",
+		Path: "local/hello.txt",
+	}
+	var resp cstructs.AllocRenderedTemplateResponse
+	err := client.ClientRPC("Allocations.RenderedTemplate", &req, &resp)
+	require.True(nstructs.IsErrUnknownAllocation(err))
+
+	// Try with a path that isn't a template destination
+	req.AllocID = a.ID
+	req.Path = "local/other.txt"
+	err = client.ClientRPC("Allocations.RenderedTemplate", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "is not a template destination")
+
+	// Try with good alloc
+	req.Path = "./local/hello.txt"
+	testutil.WaitForResult(func() (bool, error) {
+		var resp2 cstructs.AllocRenderedTemplateResponse
+		err := client.ClientRPC("Allocations.RenderedTemplate", &req, &resp2)
+		if err != nil {
+			return false, err
+		}
+		if string(resp2.Content) != "hello web" {
+			return false, fmt.Errorf("unexpected content %q", resp2.Content)
+		}
+		if resp2.Redacted {
+			return false, fmt.Errorf("unexpected redaction")
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_RenderedTemplate_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocRenderedTemplateRequest {
+		return &cstructs.AllocRenderedTemplateRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+			Path:    "local/hello.txt",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp cstructs.AllocRenderedTemplateResponse
+		err := client.ClientRPC("Allocations.RenderedTemplate", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny))
+		req := newReq()
+		req.AuthToken = token.SecretID
+
+		var resp cstructs.AllocRenderedTemplateResponse
+		err := client.ClientRPC("Allocations.RenderedTemplate", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp cstructs.AllocRenderedTemplateResponse
+		err := client.ClientRPC("Allocations.RenderedTemplate", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp cstructs.AllocRenderedTemplateResponse
+		err := client.ClientRPC("Allocations.RenderedTemplate", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_CancelArtifactDownload(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Serve part of the artifact and then stall
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	a := mock.Alloc()
+	task := a.Job.TaskGroups[0].Tasks[0]
+	task.Driver = "mock_driver"
+	task.Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	task.Artifacts = []*nstructs.TaskArtifact{
+		{
+			GetterSource: ts.URL + "/artifact.bin",
+			GetterMode:   nstructs.GetterModeFile,
+			RelativeDest: "local/artifact.bin",
+		},
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Try without a task
+	req := &cstructs.AllocCancelArtifactRequest{AllocID: a.ID}
+	var resp nstructs.GenericResponse
+	err := client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp)
+	require.EqualError(err, taskNotPresentErr.Error())
+
+	// Try with an unknown task
+	req.Task = "foo"
+	err = client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp)
+	require.Error(err)
+	require.Contains(err.Error(), "unknown task")
+
+	// Cancel the download once it started
+	req.Task = task.Name
+	testutil.WaitForResult(func() (bool, error) {
+		if err := client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp); err != nil {
+			return false, err
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// The task fails without restarting
+	testutil.WaitForResult(func() (bool, error) {
+		state, err := client.GetAllocState(a.ID)
+		if err != nil {
+			return false, err
+		}
+		ts := state.TaskStates[task.Name]
+		if ts == nil || ts.State != nstructs.TaskStateDead || !ts.Failed {
+			return false, fmt.Errorf("task not failed: %#v", ts)
+		}
+		for _, e := range ts.Events {
+			if e.Type == nstructs.TaskArtifactDownloadFailed && strings.Contains(e.DownloadError, "download canceled") {
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("no canceled download event: %#v", ts.Events)
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocations_CancelArtifactDownload_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	newReq := func() *cstructs.AllocCancelArtifactRequest {
+		return &cstructs.AllocCancelArtifactRequest{
+			AllocID: uuid.Generate(),
+			Task:    "web",
+		}
+	}
+
+	// Try request without a token and expect failure
+	{
+		req := newReq()
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with an invalid token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp)
+
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Try request with a valid token
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1007, "test-valid",
+			mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityAllocLifecycle}))
+		req := newReq()
+		req.AuthToken = token.SecretID
+		req.Namespace = nstructs.DefaultNamespace
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+
+	// Try request with a management token
+	{
+		req := newReq()
+		req.AuthToken = root.SecretID
+
+		var resp nstructs.GenericResponse
+		err := client.ClientRPC("Allocations.CancelArtifactDownload", &req, &resp)
+		require.True(nstructs.IsErrUnknownAllocation(err))
+	}
+}
+
+func TestAllocations_ArtifactProgress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Serve the artifact
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 4096))
+	}))
+	defer ts.Close()
+
+	a := mock.Alloc()
+	task := a.Job.TaskGroups[0].Tasks[0]
+	task.Driver = "mock_driver"
+	task.Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	task.Artifacts = []*nstructs.TaskArtifact{
+		{
+			GetterSource: ts.URL + "/artifact.bin",
+			GetterMode:   nstructs.GetterModeFile,
+			RelativeDest: "local/artifact.bin",
+		},
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	handler, err := client.StreamingRpcHandler("Allocations.ArtifactProgress")
+	require.Nil(err)
+
+	// An unknown task is rejected
+	{
+		req := &cstructs.AllocArtifactProgressRequest{
+			AllocID:      a.ID,
+			Task:         "unknown",
+			QueryOptions: nstructs.QueryOptions{Region: "global"},
+		}
+
+		p1, p2 := net.Pipe()
+		defer p1.Close()
+		defer p2.Close()
+		p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+		go handler(p2)
+
+		encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+		require.Nil(encoder.Encode(req))
+
+		var msg cstructs.StreamErrWrapper
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		require.NoError(decoder.Decode(&msg))
+		require.NotNil(msg.Error)
+		require.Contains(msg.Error.Error(), "unknown task name")
+	}
+
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+	p1.SetDeadline(time.Now().Add(10 * time.Second))
+
+	go handler(p2)
+
+	req := &cstructs.AllocArtifactProgressRequest{
+		AllocID:      a.ID,
+		Task:         task.Name,
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	// Read the progress until the download is done and the stream closes
+	var last cstructs.TaskArtifactProgress
+	decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+	for {
+		var msg cstructs.StreamErrWrapper
+		if err := decoder.Decode(&msg); err != nil {
+			require.Equal(io.EOF, err)
+			break
+		}
+		require.Nil(msg.Error)
+		require.NoError(json.Unmarshal(msg.Payload, &last))
+	}
+
+	require.True(last.Done)
+	require.Empty(last.Error)
+}
+
+func TestAllocations_ArtifactProgress_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	policyBad := mock.NamespacePolicy("other", "", []string{acl.NamespaceCapabilityReadJob})
+	tokenBad := mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", policyBad)
+
+	policyGood := mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityReadJob})
+	tokenGood := mock.CreatePolicyAndToken(t, server.State(), 1009, "valid2", policyGood)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         tokenBad.SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "good token",
+			Token:         tokenGood.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: nstructs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			req := &cstructs.AllocArtifactProgressRequest{
+				AllocID: uuid.Generate(),
+				Task:    "web",
+				QueryOptions: nstructs.QueryOptions{
+					Namespace: nstructs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			handler, err := client.StreamingRpcHandler("Allocations.ArtifactProgress")
+			require.Nil(err)
+
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+			p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+			go handler(p2)
+
+			encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			var msg cstructs.StreamErrWrapper
+			decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+			require.NoError(decoder.Decode(&msg))
+			require.NotNil(msg.Error)
+			require.Contains(msg.Error.Error(), c.ExpectedError)
+		})
+	}
+}
@@ -283,6 +283,79 @@ func (d *AllocDir) Destroy() error {
 	return mErr.ErrorOrNil()
 }
 
+// DestroyContext is like Destroy but removes the alloc dir one file at a time,
+// calling progress, if set, with the number of bytes freed so far. If the
+// context is canceled the remaining files are left in place, the context's
+// error is returned and the alloc dir can be destroyed again.
+func (d *AllocDir) DestroyContext(ctx context.Context, progress func(freed int64)) error {
+	var mErr multierror.Error
+	if err := d.UnmountAll(); err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+	}
+
+	if err := removeAllContext(ctx, d.AllocDir, progress); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Fallback to removing what is left at once
+		if err := os.RemoveAll(d.AllocDir); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("failed to remove alloc dir %q: %v", d.AllocDir, err))
+		}
+	}
+
+	// Unset built since the alloc dir has been destroyed.
+	d.mu.Lock()
+	d.built = false
+	d.mu.Unlock()
+	return mErr.ErrorOrNil()
+}
+
+// removeAllContext removes the directory and its content, children before
+// their parents, calling progress with the size of the files removed so far.
+// It stops when the context is canceled.
+func removeAllContext(ctx context.Context, dir string, progress func(freed int64)) error {
+	var paths []string
+	var sizes []int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		var size int64
+		if info.Mode().IsRegular() {
+			size = info.Size()
+		}
+		paths = append(paths, path)
+		sizes = append(sizes, size)
+		return ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	// Walk lists directories before their content
+	var freed int64
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := os.Remove(paths[i]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if sizes[i] > 0 && progress != nil {
+			freed += sizes[i]
+			progress(freed)
+		}
+	}
+
+	return nil
+}
+
 // UnmountAll linked/mounted directories in task dirs.
 func (d *AllocDir) UnmountAll() error {
 	d.mu.RLock()
@@ -290,31 +363,127 @@ func (d *AllocDir) UnmountAll() error {
 
 	var mErr multierror.Error
 	for _, dir := range d.TaskDirs {
-		// Check if the directory has the shared alloc mounted.
-		if pathExists(dir.SharedTaskDir) {
-			if err := unlinkDir(dir.SharedTaskDir); err != nil {
-				mErr.Errors = append(mErr.Errors,
-					fmt.Errorf("failed to unmount shared alloc dir %q: %v", dir.SharedTaskDir, err))
-			} else if err := os.RemoveAll(dir.SharedTaskDir); err != nil {
-				mErr.Errors = append(mErr.Errors,
-					fmt.Errorf("failed to delete shared alloc dir %q: %v", dir.SharedTaskDir, err))
-			}
+		dir.unmount(&mErr)
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+// unmount unlinks the shared alloc dir and removes the secrets dir and the
+// special dirs mounted in the task dir, appending errors to mErr.
+func (t *TaskDir) unmount(mErr *multierror.Error) {
+	// Check if the directory has the shared alloc mounted.
+	if pathExists(t.SharedTaskDir) {
+		if err := unlinkDir(t.SharedTaskDir); err != nil {
+			mErr.Errors = append(mErr.Errors,
+				fmt.Errorf("failed to unmount shared alloc dir %q: %v", t.SharedTaskDir, err))
+		} else if err := os.RemoveAll(t.SharedTaskDir); err != nil {
+			mErr.Errors = append(mErr.Errors,
+				fmt.Errorf("failed to delete shared alloc dir %q: %v", t.SharedTaskDir, err))
 		}
+	}
+
+	if pathExists(t.SecretsDir) {
+		if err := removeSecretDir(t.SecretsDir); err != nil {
+			mErr.Errors = append(mErr.Errors,
+				fmt.Errorf("failed to remove the secret dir %q: %v", t.SecretsDir, err))
+		}
+	}
+
+	// Unmount dev/ and proc/ have been mounted.
+	if err := t.unmountSpecialDirs(); err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+	}
+}
+
+// taskPaths returns the paths of the files of the task: its task dir and its
+// log files in the shared alloc dir.
+func (d *AllocDir) taskPaths(task string) ([]string, error) {
+	d.mu.RLock()
+	dir, ok := d.TaskDirs[task]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown task %q", task)
+	}
+
+	paths := []string{dir.Dir}
+	for _, logType := range []string{"stdout", "stderr"} {
+		logs, err := filepath.Glob(filepath.Join(dir.LogDir, fmt.Sprintf("%s.%s.*", task, logType)))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, logs...)
+	}
+	return paths, nil
+}
 
-		if pathExists(dir.SecretsDir) {
-			if err := removeSecretDir(dir.SecretsDir); err != nil {
-				mErr.Errors = append(mErr.Errors,
-					fmt.Errorf("failed to remove the secret dir %q: %v", dir.SecretsDir, err))
+// DestroyTaskDir unmounts and removes the task dir and the log files of the
+// task, one file at a time, calling progress, if set, with the number of bytes
+// freed so far. The rest of the alloc dir is left in place.
+func (d *AllocDir) DestroyTaskDir(ctx context.Context, task string, progress func(freed int64)) error {
+	paths, err := d.taskPaths(task)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	dir := d.TaskDirs[task]
+	d.mu.RUnlock()
+
+	var mErr multierror.Error
+	dir.unmount(&mErr)
+	if err := mErr.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	var freed int64
+	for _, path := range paths {
+		var pathFreed int64
+		err := removeAllContext(ctx, path, func(f int64) {
+			pathFreed = f
+			if progress != nil {
+				progress(freed + f)
 			}
+		})
+		if err != nil {
+			return err
 		}
+		freed += pathFreed
+	}
+	return nil
+}
 
-		// Unmount dev/ and proc/ have been mounted.
-		if err := dir.unmountSpecialDirs(); err != nil {
-			mErr.Errors = append(mErr.Errors, err)
+// DiskUsage returns the size of the files of the alloc dir or, if a task is
+// given, of the files DestroyTaskDir would remove for the task.
+func (d *AllocDir) DiskUsage(task string) (int64, error) {
+	paths := []string{d.AllocDir}
+	if task != "" {
+		var err error
+		if paths, err = d.taskPaths(task); err != nil {
+			return 0, err
 		}
 	}
 
-	return mErr.ErrorOrNil()
+	var size int64
+	for _, path := range paths {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if info.Mode().IsRegular() {
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
 }
 
 // Build the directory tree for an allocation.
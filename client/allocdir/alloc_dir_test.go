@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -472,3 +473,86 @@ func TestPathFuncs(t *testing.T) {
 		t.Errorf("%q is not empty. empty=%v error=%v", dir, empty, err)
 	}
 }
+
+func TestAllocDir_DestroyContext(t *testing.T) {
+	require := require.New(t)
+
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	require.NoError(err)
+	defer os.RemoveAll(tmp)
+
+	d := NewAllocDir(testlog.HCLogger(t), tmp)
+	d.NewTaskDir(t1.Name)
+	require.NoError(d.Build())
+
+	for i := 0; i < 3; i++ {
+		name := filepath.Join(d.SharedDir, fmt.Sprintf("file%d", i))
+		require.NoError(ioutil.WriteFile(name, make([]byte, 100), 0666))
+	}
+
+	// Cancel after the first file was removed
+	ctx, cancel := context.WithCancel(context.Background())
+	var freed []int64
+	err = d.DestroyContext(ctx, func(f int64) {
+		freed = append(freed, f)
+		cancel()
+	})
+	require.Equal(context.Canceled, err)
+	require.Equal([]int64{100}, freed)
+	_, err = os.Stat(d.AllocDir)
+	require.NoError(err)
+
+	// Destroying again removes the rest
+	freed = nil
+	require.NoError(d.DestroyContext(context.Background(), func(f int64) {
+		freed = append(freed, f)
+	}))
+	require.Equal([]int64{100, 200}, freed)
+	_, err = os.Stat(d.AllocDir)
+	require.True(os.IsNotExist(err))
+}
+
+func TestAllocDir_DestroyTaskDir(t *testing.T) {
+	require := require.New(t)
+
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	require.NoError(err)
+	defer os.RemoveAll(tmp)
+
+	d := NewAllocDir(testlog.HCLogger(t), tmp)
+	td1 := d.NewTaskDir(t1.Name)
+	td2 := d.NewTaskDir(t2.Name)
+	require.NoError(d.Build())
+	require.NoError(td1.Build(false, nil))
+	require.NoError(td2.Build(false, nil))
+
+	// Write task files and logs
+	require.NoError(ioutil.WriteFile(filepath.Join(td1.LocalDir, "data"), make([]byte, 100), 0666))
+	require.NoError(ioutil.WriteFile(filepath.Join(td1.LogDir, t1.Name+".stdout.0"), make([]byte, 10), 0666))
+	require.NoError(ioutil.WriteFile(filepath.Join(td2.LocalDir, "data"), make([]byte, 1000), 0666))
+	require.NoError(ioutil.WriteFile(filepath.Join(td2.LogDir, t2.Name+".stdout.0"), make([]byte, 1), 0666))
+
+	size, err := d.DiskUsage(t1.Name)
+	require.NoError(err)
+	require.EqualValues(110, size)
+
+	size, err = d.DiskUsage("")
+	require.NoError(err)
+	require.EqualValues(1111, size)
+
+	_, err = d.DiskUsage("unknown")
+	require.Error(err)
+
+	var freed int64
+	require.NoError(d.DestroyTaskDir(context.Background(), t1.Name, func(f int64) {
+		freed = f
+	}))
+	require.EqualValues(110, freed)
+
+	// Only the files of the task are removed
+	_, err = os.Stat(td1.Dir)
+	require.True(os.IsNotExist(err))
+	size, err = d.DiskUsage("")
+	require.NoError(err)
+	require.EqualValues(1001, size)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	log "github.com/hashicorp/go-hclog"
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
 	"github.com/hashicorp/nomad/client/allocrunner/state"
@@ -121,6 +122,14 @@ type allocRunner struct {
 	// allocBroadcaster sends client allocation updates to all listeners
 	allocBroadcaster *cstructs.AllocBroadcaster
 
+	// lifecycleEvents sends the hook runs and state transitions of the alloc
+	// runner and its task runners to all listeners
+	lifecycleEvents *cstructs.AllocLifecycleBroadcaster
+
+	// decisions sends the client's decisions about the allocation. It may be
+	// nil.
+	decisions *cstructs.AllocDecisionBroadcaster
+
 	// prevAllocWatcher allows waiting for any previous or preempted allocations
 	// to exit
 	prevAllocWatcher allocwatcher.PrevAllocWatcher
@@ -166,6 +175,8 @@ func NewAllocRunner(config *Config) (*allocRunner, error) {
 		prevAllocMigrator:        config.PrevAllocMigrator,
 		devicemanager:            config.DeviceManager,
 		driverManager:            config.DriverManager,
+		lifecycleEvents:          cstructs.NewAllocLifecycleBroadcaster(),
+		decisions:                config.Decisions,
 	}
 
 	// Create the logger based on the allocation ID
@@ -204,6 +215,7 @@ func (ar *allocRunner) initTaskRunners(tasks []*structs.Task) error {
 			DeviceStatsReporter: ar.deviceStatsReporter,
 			DeviceManager:       ar.devicemanager,
 			DriverManager:       ar.driverManager,
+			LifecycleEvents:     ar.lifecycleEvents,
 		}
 
 		// Create, but do not Run, the task runner
@@ -376,6 +388,7 @@ func (ar *allocRunner) TaskStateUpdated() {
 func (ar *allocRunner) handleTaskStateUpdates() {
 	defer close(ar.taskStateUpdateHandlerCh)
 
+	var lastStatus string
 	for done := false; !done; {
 		select {
 		case <-ar.taskStateUpdatedCh:
@@ -467,6 +480,14 @@ func (ar *allocRunner) handleTaskStateUpdates() {
 
 		// Broadcast client alloc to listeners
 		ar.allocBroadcaster.Send(calloc)
+
+		if calloc.ClientStatus != lastStatus {
+			lastStatus = calloc.ClientStatus
+			ar.lifecycleEvents.Send(&cstructs.AllocLifecycleEvent{
+				Type:  cstructs.LifecycleEventState,
+				State: calloc.ClientStatus,
+			})
+		}
 	}
 }
 
@@ -797,6 +818,33 @@ func (ar *allocRunner) Destroy() {
 	go ar.destroyImpl()
 }
 
+// DestroyAllocDir removes the allocation directory, calling progress with the
+// number of bytes freed so far, and returns the context's error if canceled.
+// It must only be called once the alloc runner has stopped. The alloc runner
+// itself is left untouched; Destroy removes whatever remains.
+func (ar *allocRunner) DestroyAllocDir(ctx context.Context, progress func(freed int64)) error {
+	return ar.allocDir.DestroyContext(ctx, progress)
+}
+
+// CollectTask garbage collects the task dir, log files and local state of the
+// named dead task, calling progress with the number of bytes freed so far. The
+// other tasks of the allocation are left in place.
+func (ar *allocRunner) CollectTask(ctx context.Context, taskName string, progress func(freed int64)) error {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	if state := tr.TaskState().State; state != structs.TaskStateDead {
+		return fmt.Errorf("task %q is %s and can't be garbage collected", taskName, state)
+	}
+
+	if err := ar.allocDir.DestroyTaskDir(ctx, taskName, progress); err != nil {
+		return err
+	}
+	return tr.ClearLocalState()
+}
+
 // IsDestroyed returns true if the alloc runner has been destroyed (stopped and
 // garbage collected).
 //
@@ -817,6 +865,12 @@ func (ar *allocRunner) IsWaiting() bool {
 	return ar.prevAllocWatcher.IsWaiting()
 }
 
+// LifecycleEvents returns the broadcaster of the hook runs and state
+// transitions of the alloc runner and its task runners.
+func (ar *allocRunner) LifecycleEvents() *cstructs.AllocLifecycleBroadcaster {
+	return ar.lifecycleEvents
+}
+
 // DestroyCh is a channel that is closed when an allocrunner is closed due to
 // an explicit call to Destroy().
 func (ar *allocRunner) DestroyCh() <-chan struct{} {
@@ -914,15 +968,179 @@ func (ar *allocRunner) LatestAllocStats(taskFilter string) (*cstructs.AllocResou
 		if usage := tr.LatestResourceUsage(); usage != nil {
 			astat.Tasks[name] = usage
 			astat.ResourceUsage.Add(usage.ResourceUsage)
+			astat.CounterEpoch += usage.CounterEpoch
+			astat.MemoryReserved += usage.MemoryReserved
+			astat.MemoryMaxLimit += usage.MemoryMaxLimit
+			astat.DriverStatsUnavailable = astat.DriverStatsUnavailable || usage.DriverStatsUnavailable
 			if usage.Timestamp > astat.Timestamp {
 				astat.Timestamp = usage.Timestamp
 			}
+			if usage.Monotonic > astat.Monotonic {
+				astat.Monotonic = usage.Monotonic
+			}
 		}
 	}
 
 	return astat, nil
 }
 
+// TaskPID returns the host PID of the named task's main process.
+func (ar *allocRunner) TaskPID(taskName string) (int, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return 0, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.PID()
+}
+
+// TaskArtifactProgress returns the progress of the artifact downloads of the
+// named task.
+func (ar *allocRunner) TaskArtifactProgress(taskName string) (*cstructs.TaskArtifactProgress, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return nil, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.ArtifactProgress(), nil
+}
+
+// SignalTask sends the signal to the named running task or, if no task is
+// named, to every running task of the allocation. An error is returned if the
+// named task, or every task, is not running.
+func (ar *allocRunner) SignalTask(taskName, signal string) error {
+	newEvent := func() *structs.TaskEvent {
+		event := structs.NewTaskEvent(structs.TaskSignaling).
+			SetTaskSignalReason("signal requested by operator")
+		event.TaskSignal = signal
+		event.Details["task_signal"] = signal
+		return event
+	}
+
+	if taskName != "" {
+		tr, ok := ar.tasks[taskName]
+		if !ok {
+			return fmt.Errorf("unknown task name %q", taskName)
+		}
+
+		if err := tr.Signal(newEvent(), signal); err != nil {
+			if err == taskrunner.ErrTaskNotRunning {
+				return fmt.Errorf("task %q is not running", taskName)
+			}
+			return fmt.Errorf("failed to signal task %q: %v", taskName, err)
+		}
+		return nil
+	}
+
+	var mErr multierror.Error
+	signaled := 0
+	for name, tr := range ar.tasks {
+		if err := tr.Signal(newEvent(), signal); err != nil {
+			if err == taskrunner.ErrTaskNotRunning {
+				continue
+			}
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("failed to signal task %q: %v", name, err))
+			continue
+		}
+		signaled++
+	}
+
+	if signaled == 0 && len(mErr.Errors) == 0 {
+		return fmt.Errorf("no task of allocation %q is running", ar.id)
+	}
+	return mErr.ErrorOrNil()
+}
+
+// TaskDiagnose explains the current state of the named task.
+func (ar *allocRunner) TaskDiagnose(taskName string) (*cstructs.TaskDiagnosis, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return nil, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.Diagnose(), nil
+}
+
+// TaskExec runs the command in the named task.
+func (ar *allocRunner) TaskExec(taskName string, timeout time.Duration, cmd []string) (*drivers.ExecTaskResult, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return nil, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.ExecTask(timeout, cmd)
+}
+
+// CancelTaskArtifactDownload aborts the artifact download of the named task.
+func (ar *allocRunner) CancelTaskArtifactDownload(taskName string) error {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.CancelArtifactDownload()
+}
+
+// SetTaskLogRotation updates the log rotation settings of the named task.
+func (ar *allocRunner) SetTaskLogRotation(taskName string, rotation *structs.LogConfig) error {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.SetLogRotation(rotation)
+}
+
+// RotateTaskLogs forces the rotation of the named task's stdout or stderr log
+// and returns the path of the rotated file relative to the allocation
+// directory.
+func (ar *allocRunner) RotateTaskLogs(taskName, logType string) (string, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return "", fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	path, err := tr.RotateLogs(logType)
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	return filepath.Rel(ar.allocDir.AllocDir, path)
+}
+
+// TaskStatsDebug forces a stats collection of the named task and returns the
+// time spent in each stage of the collection.
+func (ar *allocRunner) TaskStatsDebug(ctx context.Context, taskName string) (*cstructs.TaskStatsDebug, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return nil, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.DebugStats(ctx)
+}
+
+// TaskStatsDiff collects two stats samples of the named task, window apart,
+// and returns the change between them.
+func (ar *allocRunner) TaskStatsDiff(ctx context.Context, taskName string, window time.Duration) (*cstructs.TaskStatsDiff, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return nil, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.StatsDiff(ctx, window)
+}
+
+// TaskRenderedTemplate returns the rendered content of the named task's
+// template destination and whether the template reads Vault secrets.
+func (ar *allocRunner) TaskRenderedTemplate(taskName, dest string) ([]byte, bool, error) {
+	tr, ok := ar.tasks[taskName]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown task name %q", taskName)
+	}
+
+	return tr.RenderedTemplate(dest)
+}
+
 func (ar *allocRunner) GetTaskEventHandler(taskName string) drivermanager.EventHandler {
 	if tr, ok := ar.tasks[taskName]; ok {
 		return func(ev *drivers.TaskEvent) {
@@ -6,6 +6,7 @@ import (
 
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
+	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
 
@@ -48,6 +49,18 @@ func (a *allocHealthSetter) SetHealth(healthy, isDeploy bool, trackerTaskEvents
 	terminalDesiredState := a.ar.alloc.ServerTerminalStatus()
 	a.ar.stateLock.Unlock()
 
+	if !healthy {
+		reason := "migration health checks failed"
+		if isDeploy {
+			reason = "deployment health checks failed"
+		}
+		a.ar.decisions.Send(&cstructs.AllocDecision{
+			AllocID:  a.ar.id,
+			Decision: cstructs.AllocDecisionUnhealthy,
+			Reason:   reason,
+		})
+	}
+
 	// If deployment is unhealthy emit task events explaining why
 	if !healthy && isDeploy && !terminalDesiredState {
 		for task, event := range trackerTaskEvents {
@@ -110,13 +123,14 @@ func (ar *allocRunner) prerun() error {
 		}
 
 		name := pre.Name()
-		var start time.Time
+		start := time.Now()
 		if ar.logger.IsTrace() {
-			start = time.Now()
 			ar.logger.Trace("running pre-run hook", "name", name, "start", start)
 		}
 
-		if err := pre.Prerun(); err != nil {
+		err := pre.Prerun()
+		ar.lifecycleEvents.SendHook("", "prerun", name, start, err)
+		if err != nil {
 			return fmt.Errorf("pre-run hook %q failed: %v", name, err)
 		}
 
@@ -153,13 +167,14 @@ func (ar *allocRunner) update(update *structs.Allocation) error {
 		}
 
 		name := h.Name()
-		var start time.Time
+		start := time.Now()
 		if ar.logger.IsTrace() {
-			start = time.Now()
 			ar.logger.Trace("running pre-run hook", "name", name, "start", start)
 		}
 
-		if err := h.Update(req); err != nil {
+		err := h.Update(req)
+		ar.lifecycleEvents.SendHook("", "update", name, start, err)
+		if err != nil {
 			merr.Errors = append(merr.Errors, fmt.Errorf("update hook %q failed: %v", name, err))
 		}
 
@@ -190,13 +205,14 @@ func (ar *allocRunner) postrun() error {
 		}
 
 		name := post.Name()
-		var start time.Time
+		start := time.Now()
 		if ar.logger.IsTrace() {
-			start = time.Now()
 			ar.logger.Trace("running post-run hook", "name", name, "start", start)
 		}
 
-		if err := post.Postrun(); err != nil {
+		err := post.Postrun()
+		ar.lifecycleEvents.SendHook("", "postrun", name, start, err)
+		if err != nil {
 			return fmt.Errorf("hook %q failed: %v", name, err)
 		}
 
@@ -229,13 +245,14 @@ func (ar *allocRunner) destroy() error {
 		}
 
 		name := h.Name()
-		var start time.Time
+		start := time.Now()
 		if ar.logger.IsTrace() {
-			start = time.Now()
 			ar.logger.Trace("running destroy hook", "name", name, "start", start)
 		}
 
-		if err := h.Destroy(); err != nil {
+		err := h.Destroy()
+		ar.lifecycleEvents.SendHook("", "destroy", name, start, err)
+		if err != nil {
 			merr.Errors = append(merr.Errors, fmt.Errorf("destroy hook %q failed: %v", name, err))
 		}
 
@@ -257,13 +274,13 @@ func (ar *allocRunner) shutdownHooks() {
 		}
 
 		name := sh.Name()
-		var start time.Time
+		start := time.Now()
 		if ar.logger.IsTrace() {
-			start = time.Now()
 			ar.logger.Trace("running shutdown hook", "name", name, "start", start)
 		}
 
 		sh.Shutdown()
+		ar.lifecycleEvents.SendHook("", "shutdown", name, start, nil)
 
 		if ar.logger.IsTrace() {
 			end := time.Now()
@@ -3,32 +3,134 @@ package taskrunner
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
 	"github.com/hashicorp/nomad/client/allocrunner/taskrunner/getter"
 	ti "github.com/hashicorp/nomad/client/allocrunner/taskrunner/interfaces"
+	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
 
+const (
+	// HookNameArtifacts is the name of the artifacts hook
+	HookNameArtifacts = "artifacts"
+)
+
+// artifactProgress tracks the progress of the artifact downloads of a task.
+type artifactProgress struct {
+	progress cstructs.TaskArtifactProgress
+
+	// started is when the current artifact started downloading
+	started time.Time
+
+	mu sync.Mutex
+}
+
+// start marks the artifact at index as downloading
+func (p *artifactProgress) start(index, count int, artifact string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress = cstructs.TaskArtifactProgress{
+		Artifact: artifact,
+		Index:    index,
+		Count:    count,
+		Total:    -1,
+	}
+	p.started = time.Now()
+}
+
+// update is a getter.ProgressFunc updating the bytes downloaded
+func (p *artifactProgress) update(downloaded, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Downloaded = downloaded
+	p.progress.Total = total
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		p.progress.BytesPerSecond = float64(downloaded) / elapsed
+	}
+}
+
+// finish marks the downloads as done, failed if err is not nil
+func (p *artifactProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Done = true
+	if err != nil {
+		p.progress.Error = err.Error()
+	}
+}
+
+// get returns a copy of the progress
+func (p *artifactProgress) get() *cstructs.TaskArtifactProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	progress := p.progress
+	return &progress
+}
+
 // artifactHook downloads artifacts for a task.
 type artifactHook struct {
 	eventEmitter ti.EventEmitter
 	logger       log.Logger
+
+	// progress is the progress of the downloads
+	progress *artifactProgress
+
+	// cancel aborts the current download. It is nil when no download is in
+	// progress.
+	cancel   context.CancelFunc
+	cancelMu sync.Mutex
 }
 
 func newArtifactHook(e ti.EventEmitter, logger log.Logger) *artifactHook {
 	h := &artifactHook{
 		eventEmitter: e,
+		progress:     &artifactProgress{},
 	}
 	h.logger = logger.Named(h.Name())
 	return h
 }
 
+// cancelDownload aborts the artifact download in progress, failing the task.
+// ErrNoArtifactDownload is returned if no artifact is downloading.
+func (h *artifactHook) cancelDownload() error {
+	h.cancelMu.Lock()
+	defer h.cancelMu.Unlock()
+	if h.cancel == nil {
+		return ErrNoArtifactDownload
+	}
+
+	h.cancel()
+	return nil
+}
+
+// download downloads the artifact into the task dir while allowing it to be
+// cancelled with cancelDownload. It returns whether the download was
+// cancelled.
+func (h *artifactHook) download(ctx context.Context, req *interfaces.TaskPrestartRequest, artifact *structs.TaskArtifact) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	h.cancelMu.Lock()
+	h.cancel = cancel
+	h.cancelMu.Unlock()
+
+	defer func() {
+		h.cancelMu.Lock()
+		h.cancel = nil
+		h.cancelMu.Unlock()
+		cancel()
+	}()
+
+	err := getter.GetArtifactWithContext(ctx, req.TaskEnv, artifact, req.TaskDir.Dir, h.progress.update)
+	return ctx.Err() != nil, err
+}
+
 func (*artifactHook) Name() string {
 	// Copied in client/state when upgrading from <0.9 schemas, so if you
 	// change it here you also must change it there.
-	return "artifacts"
+	return HookNameArtifacts
 }
 
 func (h *artifactHook) Prestart(ctx context.Context, req *interfaces.TaskPrestartRequest, resp *interfaces.TaskPrestartResponse) error {
@@ -42,7 +144,7 @@ func (h *artifactHook) Prestart(ctx context.Context, req *interfaces.TaskPrestar
 
 	h.eventEmitter.EmitEvent(structs.NewTaskEvent(structs.TaskDownloadingArtifacts))
 
-	for _, artifact := range req.Task.Artifacts {
+	for i, artifact := range req.Task.Artifacts {
 		aid := artifact.Hash()
 		if req.PreviousState[aid] != "" {
 			h.logger.Trace("skipping already downloaded artifact", "artifact", artifact.GetterSource)
@@ -51,14 +153,16 @@ func (h *artifactHook) Prestart(ctx context.Context, req *interfaces.TaskPrestar
 		}
 
 		h.logger.Debug("downloading artifact", "artifact", artifact.GetterSource)
-		//XXX add ctx to GetArtifact to allow cancelling long downloads
-		if err := getter.GetArtifact(req.TaskEnv, artifact, req.TaskDir.Dir); err != nil {
+		h.progress.start(i, len(req.Task.Artifacts), artifact.GetterSource)
+		if canceled, err := h.download(ctx, req, artifact); err != nil {
+			// Cancelled downloads fail the task rather than being retried
 			wrapped := structs.NewRecoverableError(
 				fmt.Errorf("failed to download artifact %q: %v", artifact.GetterSource, err),
-				true,
+				!canceled,
 			)
 			herr := NewHookError(wrapped, structs.NewTaskEvent(structs.TaskArtifactDownloadFailed).SetDownloadError(wrapped))
 
+			h.progress.finish(wrapped)
 			return herr
 		}
 
@@ -68,6 +172,7 @@ func (h *artifactHook) Prestart(ctx context.Context, req *interfaces.TaskPrestar
 		resp.State[aid] = "1"
 	}
 
+	h.progress.finish(nil)
 	resp.Done = true
 	return nil
 }
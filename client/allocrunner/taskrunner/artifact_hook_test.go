@@ -2,6 +2,7 @@ package taskrunner
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
@@ -16,6 +18,7 @@ import (
 	"github.com/hashicorp/nomad/helper"
 	"github.com/hashicorp/nomad/helper/testlog"
 	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -158,3 +161,133 @@ func TestTaskRunner_ArtifactHook_PartialDone(t *testing.T) {
 	require.True(t, resp.Done)
 	require.Len(t, resp.State, 2)
 }
+
+// TestTaskRunner_ArtifactHook_Progress asserts the artifact hook tracks the
+// progress of its downloads.
+func TestTaskRunner_ArtifactHook_Progress(t *testing.T) {
+	t.Parallel()
+
+	me := &mockEmitter{}
+	artifactHook := newArtifactHook(me, testlog.HCLogger(t))
+
+	srcdir, err := ioutil.TempDir("", "nomadtest-src")
+	require.NoError(t, err)
+	defer os.RemoveAll(srcdir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srcdir, "foo.txt"), make([]byte, 1024), 0644))
+
+	// Test server to serve the artifacts
+	ts := httptest.NewServer(http.FileServer(http.Dir(srcdir)))
+	defer ts.Close()
+
+	destdir, err := ioutil.TempDir("", "nomadtest-dest")
+	require.NoError(t, err)
+	defer os.RemoveAll(destdir)
+
+	req := &interfaces.TaskPrestartRequest{
+		TaskEnv: taskenv.NewEmptyTaskEnv(),
+		TaskDir: &allocdir.TaskDir{Dir: destdir},
+		Task: &structs.Task{
+			Artifacts: []*structs.TaskArtifact{
+				{
+					GetterSource: ts.URL + "/foo.txt",
+					GetterMode:   structs.GetterModeAny,
+				},
+				{
+					GetterSource: ts.URL + "/bar.txt",
+					GetterMode:   structs.GetterModeAny,
+				},
+			},
+		},
+	}
+
+	// The first artifact downloads but the second one is missing
+	resp := interfaces.TaskPrestartResponse{}
+	require.Error(t, artifactHook.Prestart(context.Background(), req, &resp))
+
+	progress := artifactHook.progress.get()
+	require.True(t, progress.Done)
+	require.NotEmpty(t, progress.Error)
+	require.Equal(t, ts.URL+"/bar.txt", progress.Artifact)
+	require.Equal(t, 1, progress.Index)
+	require.Equal(t, 2, progress.Count)
+
+	// Only download the first artifact
+	req.Task.Artifacts = req.Task.Artifacts[:1]
+	resp = interfaces.TaskPrestartResponse{}
+	require.NoError(t, artifactHook.Prestart(context.Background(), req, &resp))
+
+	progress = artifactHook.progress.get()
+	require.True(t, progress.Done)
+	require.Empty(t, progress.Error)
+	require.Equal(t, 0, progress.Index)
+	require.EqualValues(t, 1024, progress.Downloaded)
+	require.EqualValues(t, 1024, progress.Total)
+}
+
+// TestTaskRunner_ArtifactHook_Cancel asserts that cancelling a download fails
+// the hook with an unrecoverable error and removes the partial download.
+func TestTaskRunner_ArtifactHook_Cancel(t *testing.T) {
+	t.Parallel()
+
+	me := &mockEmitter{}
+	artifactHook := newArtifactHook(me, testlog.HCLogger(t))
+
+	// Nothing to cancel before downloading
+	require.Equal(t, ErrNoArtifactDownload, artifactHook.cancelDownload())
+
+	// Test server sending part of the artifact and then stalling
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		w.Write(make([]byte, 512))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	destdir, err := ioutil.TempDir("", "nomadtest-dest")
+	require.NoError(t, err)
+	defer os.RemoveAll(destdir)
+
+	req := &interfaces.TaskPrestartRequest{
+		TaskEnv: taskenv.NewEmptyTaskEnv(),
+		TaskDir: &allocdir.TaskDir{Dir: destdir},
+		Task: &structs.Task{
+			Artifacts: []*structs.TaskArtifact{
+				{
+					GetterSource: ts.URL + "/foo.bin",
+					GetterMode:   structs.GetterModeAny,
+				},
+			},
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		resp := interfaces.TaskPrestartResponse{}
+		errCh <- artifactHook.Prestart(context.Background(), req, &resp)
+	}()
+
+	// Cancel once the download started
+	testutil.WaitForResult(func() (bool, error) {
+		if artifactHook.progress.get().Downloaded == 0 {
+			return false, fmt.Errorf("download not started")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+	require.NoError(t, artifactHook.cancelDownload())
+
+	select {
+	case err := <-errCh:
+		require.Error(t, err)
+		require.False(t, structs.IsRecoverable(err))
+		require.Contains(t, err.Error(), "download canceled")
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the hook to fail")
+	}
+
+	_, err = os.Stat(filepath.Join(destdir, "foo.bin"))
+	require.True(t, os.IsNotExist(err))
+	require.Equal(t, ErrNoArtifactDownload, artifactHook.cancelDownload())
+}
@@ -0,0 +1,126 @@
+package taskrunner
+
+import (
+	"fmt"
+	"time"
+
+	cstructs "github.com/hashicorp/nomad/client/structs"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// diagnoseEvents is the number of recent task events included in a
+// diagnosis.
+const diagnoseEvents = 5
+
+// Diagnose explains the current state of the task.
+func (tr *TaskRunner) Diagnose() *cstructs.TaskDiagnosis {
+	hook := tr.getPrestartHook()
+
+	var artifacts *cstructs.TaskArtifactProgress
+	if hook == HookNameArtifacts {
+		artifacts = tr.ArtifactProgress()
+	}
+
+	return diagnose(tr.TaskState(), hook, artifacts)
+}
+
+// diagnose explains the state of a task given the prestart hook it is waiting
+// on, if any, and the progress of its artifact downloads.
+func diagnose(ts *structs.TaskState, hook string, artifacts *cstructs.TaskArtifactProgress) *cstructs.TaskDiagnosis {
+	d := &cstructs.TaskDiagnosis{
+		State: ts.State,
+	}
+
+	events := ts.Events
+	if len(events) > diagnoseEvents {
+		events = events[len(events)-diagnoseEvents:]
+	}
+	d.Events = events
+
+	var last *structs.TaskEvent
+	if len(ts.Events) != 0 {
+		last = ts.Events[len(ts.Events)-1]
+	}
+
+	switch ts.State {
+	case structs.TaskStateRunning:
+		d.Reason = cstructs.TaskDiagnosisRunning
+		d.Explanation = fmt.Sprintf("task has been running since %s", ts.StartedAt.Format(time.RFC3339))
+
+	case structs.TaskStateDead:
+		terminated := lastEvent(ts.Events, structs.TaskTerminated)
+		switch {
+		case ts.Failed && terminated != nil && terminated.Details["oom_killed"] == "true":
+			d.Reason = cstructs.TaskDiagnosisOOMKilled
+			d.Explanation = fmt.Sprintf("task was killed for running out of memory (exit code %d)", terminated.ExitCode)
+		case ts.Failed:
+			d.Reason = cstructs.TaskDiagnosisFailed
+			if failure := lastFailure(ts.Events); failure != nil {
+				d.Explanation = fmt.Sprintf("task failed: %s", eventMessage(failure))
+			} else {
+				d.Explanation = "task failed"
+			}
+		case lastEvent(ts.Events, structs.TaskKilled) != nil:
+			d.Reason = cstructs.TaskDiagnosisKilled
+			d.Explanation = "task was killed"
+		default:
+			d.Reason = cstructs.TaskDiagnosisCompleted
+			d.Explanation = "task completed successfully"
+		}
+
+	default:
+		switch {
+		case last != nil && last.Type == structs.TaskRestarting:
+			d.Reason = cstructs.TaskDiagnosisRestarting
+			d.Explanation = fmt.Sprintf("task is restarting in %s: %s",
+				time.Duration(last.StartDelay), last.RestartReason)
+		case artifacts != nil && !artifacts.Done:
+			d.Reason = cstructs.TaskDiagnosisDownloadingArtifacts
+			d.Hook = hook
+			d.Artifacts = artifacts
+			d.Explanation = fmt.Sprintf("task is downloading artifact %d of %d: %s",
+				artifacts.Index+1, artifacts.Count, artifacts.Artifact)
+		case hook != "":
+			d.Reason = cstructs.TaskDiagnosisPrestartHook
+			d.Hook = hook
+			d.Explanation = fmt.Sprintf("task is waiting on prestart hook %q", hook)
+		default:
+			d.Reason = cstructs.TaskDiagnosisPending
+			d.Explanation = "task is waiting to be started"
+		}
+	}
+
+	return d
+}
+
+// lastEvent returns the most recent event of the given type.
+func lastEvent(events []*structs.TaskEvent, eventType string) *structs.TaskEvent {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == eventType {
+			return events[i]
+		}
+	}
+	return nil
+}
+
+// lastFailure returns the most recent event that failed the task or, lacking
+// one, the most recent termination.
+func lastFailure(events []*structs.TaskEvent) *structs.TaskEvent {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].FailsTask {
+			return events[i]
+		}
+	}
+	return lastEvent(events, structs.TaskTerminated)
+}
+
+// eventMessage returns the human readable message of the event.
+func eventMessage(e *structs.TaskEvent) string {
+	if e.DisplayMessage != "" {
+		return e.DisplayMessage
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Type
+}
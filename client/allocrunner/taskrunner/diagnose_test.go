@@ -0,0 +1,137 @@
+package taskrunner
+
+import (
+	"fmt"
+	"testing"
+
+	cstructs "github.com/hashicorp/nomad/client/structs"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskRunner_Diagnose(t *testing.T) {
+	t.Parallel()
+
+	errTest := fmt.Errorf("failed to pull image")
+	failed := structs.NewTaskEvent(structs.TaskDriverFailure).
+		SetDriverError(errTest).
+		SetFailsTask()
+	failed.PopulateEventDisplayMessage()
+
+	cases := []struct {
+		Name      string
+		State     *structs.TaskState
+		Hook      string
+		Artifacts *cstructs.TaskArtifactProgress
+		Reason    string
+		Contains  string
+	}{
+		{
+			Name:     "pending",
+			State:    &structs.TaskState{State: structs.TaskStatePending},
+			Reason:   cstructs.TaskDiagnosisPending,
+			Contains: "waiting to be started",
+		},
+		{
+			Name:     "prestart hook",
+			State:    &structs.TaskState{State: structs.TaskStatePending},
+			Hook:     "vault",
+			Reason:   cstructs.TaskDiagnosisPrestartHook,
+			Contains: `"vault"`,
+		},
+		{
+			Name:  "downloading artifacts",
+			State: &structs.TaskState{State: structs.TaskStatePending},
+			Hook:  HookNameArtifacts,
+			Artifacts: &cstructs.TaskArtifactProgress{
+				Artifact: "http://example.com/app.tgz",
+				Index:    1,
+				Count:    2,
+			},
+			Reason:   cstructs.TaskDiagnosisDownloadingArtifacts,
+			Contains: "artifact 2 of 2: http://example.com/app.tgz",
+		},
+		{
+			Name: "restarting",
+			State: &structs.TaskState{
+				State: structs.TaskStatePending,
+				Events: []*structs.TaskEvent{
+					structs.NewTaskEvent(structs.TaskRestarting).
+						SetRestartReason("Restart within policy"),
+				},
+			},
+			Reason:   cstructs.TaskDiagnosisRestarting,
+			Contains: "Restart within policy",
+		},
+		{
+			Name: "failed",
+			State: &structs.TaskState{
+				State:  structs.TaskStateDead,
+				Failed: true,
+				Events: []*structs.TaskEvent{
+					failed,
+					structs.NewTaskEvent(structs.TaskNotRestarting),
+				},
+			},
+			Reason:   cstructs.TaskDiagnosisFailed,
+			Contains: errTest.Error(),
+		},
+		{
+			Name: "oom killed",
+			State: &structs.TaskState{
+				State:  structs.TaskStateDead,
+				Failed: true,
+				Events: []*structs.TaskEvent{
+					structs.NewTaskEvent(structs.TaskTerminated).
+						SetExitCode(137).
+						SetOOMKilled(true),
+				},
+			},
+			Reason:   cstructs.TaskDiagnosisOOMKilled,
+			Contains: "out of memory",
+		},
+		{
+			Name: "killed",
+			State: &structs.TaskState{
+				State: structs.TaskStateDead,
+				Events: []*structs.TaskEvent{
+					structs.NewTaskEvent(structs.TaskKilled),
+				},
+			},
+			Reason:   cstructs.TaskDiagnosisKilled,
+			Contains: "killed",
+		},
+		{
+			Name: "completed",
+			State: &structs.TaskState{
+				State: structs.TaskStateDead,
+				Events: []*structs.TaskEvent{
+					structs.NewTaskEvent(structs.TaskTerminated),
+				},
+			},
+			Reason:   cstructs.TaskDiagnosisCompleted,
+			Contains: "completed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			d := diagnose(c.State, c.Hook, c.Artifacts)
+			require.Equal(t, c.State.State, d.State)
+			require.Equal(t, c.Reason, d.Reason)
+			require.Contains(t, d.Explanation, c.Contains)
+		})
+	}
+}
+
+func TestTaskRunner_Diagnose_Events(t *testing.T) {
+	t.Parallel()
+
+	state := &structs.TaskState{State: structs.TaskStatePending}
+	for i := 0; i < diagnoseEvents+3; i++ {
+		state.Events = append(state.Events, structs.NewTaskEvent(structs.TaskDriverMessage))
+	}
+
+	d := diagnose(state, "", nil)
+	require.Equal(t, state.Events[3:], d.Events)
+}
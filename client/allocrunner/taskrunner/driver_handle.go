@@ -2,6 +2,9 @@ package taskrunner
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	cstructs "github.com/hashicorp/nomad/client/structs"
@@ -26,6 +29,11 @@ type DriverHandle struct {
 	net    *drivers.DriverNetwork
 	task   *structs.Task
 	taskID string
+
+	// pid caches the PID of the task's main process once the driver
+	// returned it, as it doesn't change for the lifetime of the handle.
+	pid     int
+	pidLock sync.Mutex
 }
 
 func (h *DriverHandle) ID() string {
@@ -61,6 +69,41 @@ func (h *DriverHandle) Exec(timeout time.Duration, cmd string, args []string) ([
 	return res.Stdout, res.ExitResult.ExitCode, res.ExitResult.Err
 }
 
+// ExecTask runs the command in the task and returns its separate stdout and
+// stderr along with its exit result.
+func (h *DriverHandle) ExecTask(timeout time.Duration, cmd []string) (*drivers.ExecTaskResult, error) {
+	return h.driver.ExecTask(h.taskID, cmd, timeout)
+}
+
+// PID returns the host PID of the task's main process as reported by the
+// driver. An error is returned if the driver does not expose it. The driver
+// is only asked until it returns a PID.
+func (h *DriverHandle) PID() (int, error) {
+	h.pidLock.Lock()
+	defer h.pidLock.Unlock()
+	if h.pid != 0 {
+		return h.pid, nil
+	}
+
+	status, err := h.driver.InspectTask(h.taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, ok := status.DriverAttributes["pid"]
+	if !ok {
+		return 0, ErrPIDUnavailable
+	}
+
+	pid, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("driver returned invalid pid %q: %v", raw, err)
+	}
+
+	h.pid = pid
+	return pid, nil
+}
+
 func (h *DriverHandle) Network() *drivers.DriverNetwork {
 	return h.net
 }
@@ -0,0 +1,41 @@
+package taskrunner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/nomad/nomad/mock"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	dtu "github.com/hashicorp/nomad/plugins/drivers/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDriverHandle_PID asserts the driver is only asked for the PID until it
+// returns one.
+func TestDriverHandle_PID(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	inspected := 0
+	attrs := map[string]string{}
+	driver := &dtu.MockDriver{
+		InspectTaskF: func(string) (*drivers.TaskStatus, error) {
+			inspected++
+			return &drivers.TaskStatus{DriverAttributes: attrs}, nil
+		},
+	}
+
+	h := NewDriverHandle(driver, "id", mock.Job().TaskGroups[0].Tasks[0], nil)
+
+	// The driver doesn't expose the PID yet
+	_, err := h.PID()
+	require.Equal(ErrPIDUnavailable, err)
+	require.Equal(1, inspected)
+
+	attrs["pid"] = "42"
+	for i := 0; i < 3; i++ {
+		pid, err := h.PID()
+		require.NoError(err)
+		require.Equal(42, pid)
+	}
+	require.Equal(2, inspected)
+}
@@ -7,11 +7,15 @@ import (
 )
 
 const (
-	errTaskNotRunning = "Task not running"
+	errTaskNotRunning     = "Task not running"
+	errPIDUnavailable     = "Driver does not expose the task's pid"
+	errNoArtifactDownload = "No artifact download in progress"
 )
 
 var (
-	ErrTaskNotRunning = errors.New(errTaskNotRunning)
+	ErrTaskNotRunning     = errors.New(errTaskNotRunning)
+	ErrPIDUnavailable     = errors.New(errPIDUnavailable)
+	ErrNoArtifactDownload = errors.New(errNoArtifactDownload)
 )
 
 // NewHookError contains an underlying err and a pre-formatted task event.
@@ -1,12 +1,19 @@
 package getter
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
 	gg "github.com/hashicorp/go-getter"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
@@ -19,6 +26,10 @@ var (
 
 	// supported is the set of download schemes supported by Nomad
 	supported = []string{"http", "https", "s3", "hg", "git"}
+
+	// ErrDownloadCanceled is returned when the context of a download is
+	// cancelled
+	ErrDownloadCanceled = errors.New("download canceled")
 )
 
 const (
@@ -32,8 +43,15 @@ type EnvReplacer interface {
 	ReplaceEnv(string) string
 }
 
+// ProgressFunc is called while an artifact downloads with the number of bytes
+// downloaded so far and the total number of bytes, which is -1 when unknown.
+// Progress is only reported for downloads over HTTP.
+type ProgressFunc func(downloaded, total int64)
+
 // getClient returns a client that is suitable for Nomad downloading artifacts.
-func getClient(src string, mode gg.ClientMode, dst string) *gg.Client {
+// The progress of HTTP downloads is reported to progress if it isn't nil and
+// they are aborted when ctx is cancelled.
+func getClient(ctx context.Context, src string, mode gg.ClientMode, dst string, progress ProgressFunc) *gg.Client {
 	lock.Lock()
 	defer lock.Unlock()
 
@@ -47,12 +65,128 @@ func getClient(src string, mode gg.ClientMode, dst string) *gg.Client {
 		}
 	}
 
+	// Background contexts can't be cancelled
+	clientGetters := getters
+	if progress != nil || ctx.Done() != nil {
+		// Use HTTP getters tracking the bodies of the responses
+		clientGetters = make(map[string]gg.Getter, len(getters))
+		for scheme, impl := range getters {
+			clientGetters[scheme] = impl
+		}
+
+		transport := &downloadTransport{
+			transport: cleanhttp.DefaultTransport(),
+			ctx:       ctx,
+			progress:  progress,
+		}
+		httpGetter := &gg.HttpGetter{
+			Netrc:  true,
+			Client: &http.Client{Transport: transport},
+		}
+		clientGetters["http"] = httpGetter
+		clientGetters["https"] = httpGetter
+	}
+
 	return &gg.Client{
 		Src:     src,
 		Dst:     dst,
 		Mode:    mode,
-		Getters: getters,
+		Getters: clientGetters,
+	}
+}
+
+// downloadTransport is an http.RoundTripper binding the requests to a context
+// and reporting the progress of reading the bodies of the responses if
+// progress isn't nil.
+type downloadTransport struct {
+	transport http.RoundTripper
+	ctx       context.Context
+	progress  ProgressFunc
+}
+
+func (t *downloadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req.WithContext(t.ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if t.progress != nil {
+		resp.Body = &progressReader{
+			ReadCloser: resp.Body,
+			total:      resp.ContentLength,
+			progress:   t.progress,
+		}
+	}
+	return resp, nil
+}
+
+// progressReader reports the number of bytes read to a ProgressFunc
+type progressReader struct {
+	io.ReadCloser
+	read     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(r.read, r.total)
+	}
+	return n, err
+}
+
+// destSnapshot records what existed at the destination of a download so that
+// what a cancelled download wrote can be removed.
+type destSnapshot struct {
+	path    string
+	existed bool
+	entries map[string]struct{}
+}
+
+func snapshotDest(path string) *destSnapshot {
+	snap := &destSnapshot{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return snap
+	}
+
+	snap.existed = true
+	if info.IsDir() {
+		snap.entries = make(map[string]struct{})
+		if entries, err := ioutil.ReadDir(path); err == nil {
+			for _, e := range entries {
+				snap.entries[e.Name()] = struct{}{}
+			}
+		}
 	}
+	return snap
+}
+
+// cleanup removes the destination if it was created by the download, or the
+// entries it added to an existing directory.
+func (s *destSnapshot) cleanup() error {
+	if !s.existed {
+		return os.RemoveAll(s.path)
+	}
+	if s.entries == nil {
+		// The file was overwritten and can't be restored
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, ok := s.entries[e.Name()]; !ok {
+			if err := os.RemoveAll(filepath.Join(s.path, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // getGetterUrl returns the go-getter URL to download the artifact.
@@ -90,6 +224,21 @@ func getGetterUrl(taskEnv EnvReplacer, artifact *structs.TaskArtifact) (string,
 
 // GetArtifact downloads an artifact into the specified task directory.
 func GetArtifact(taskEnv EnvReplacer, artifact *structs.TaskArtifact, taskDir string) error {
+	return GetArtifactWithProgress(taskEnv, artifact, taskDir, nil)
+}
+
+// GetArtifactWithProgress downloads an artifact into the specified task
+// directory, reporting the progress of the download to progress.
+func GetArtifactWithProgress(taskEnv EnvReplacer, artifact *structs.TaskArtifact, taskDir string, progress ProgressFunc) error {
+	return GetArtifactWithContext(context.Background(), taskEnv, artifact, taskDir, progress)
+}
+
+// GetArtifactWithContext downloads an artifact into the specified task
+// directory, reporting the progress of the download to progress. HTTP
+// downloads are aborted when ctx is cancelled while other downloads are
+// discarded once they complete. The files written by a cancelled download are
+// removed and ErrDownloadCanceled is returned.
+func GetArtifactWithContext(ctx context.Context, taskEnv EnvReplacer, artifact *structs.TaskArtifact, taskDir string, progress ProgressFunc) error {
 	url, err := getGetterUrl(taskEnv, artifact)
 	if err != nil {
 		return newGetError(artifact.GetterSource, err, false)
@@ -107,7 +256,15 @@ func GetArtifact(taskEnv EnvReplacer, artifact *structs.TaskArtifact, taskDir st
 		mode = gg.ClientModeDir
 	}
 
-	if err := getClient(url, mode, dest).Get(); err != nil {
+	snap := snapshotDest(dest)
+	err = getClient(ctx, url, mode, dest, progress).Get()
+	if ctx.Err() != nil {
+		if err := snap.cleanup(); err != nil {
+			return newGetError(url, fmt.Errorf("%v and failed to remove partial download: %v", ErrDownloadCanceled, err), false)
+		}
+		return newGetError(url, ErrDownloadCanceled, false)
+	}
+	if err != nil {
 		return newGetError(url, err, true)
 	}
 
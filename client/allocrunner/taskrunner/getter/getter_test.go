@@ -1,6 +1,7 @@
 package getter
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -57,6 +58,96 @@ func TestGetArtifact_FileAndChecksum(t *testing.T) {
 	}
 }
 
+func TestGetArtifactWithProgress(t *testing.T) {
+	// Create the test server hosting the file to download
+	ts := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir("./test-fixtures/"))))
+	defer ts.Close()
+
+	// Create a temp directory to download into
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	file := "test.sh"
+	info, err := os.Stat(filepath.Join("./test-fixtures", file))
+	if err != nil {
+		t.Fatalf("failed to stat fixture: %v", err)
+	}
+	artifact := &structs.TaskArtifact{
+		GetterSource: fmt.Sprintf("%s/%s", ts.URL, file),
+	}
+
+	// Download the artifact, recording its progress
+	var downloaded, total int64
+	progress := func(d, t int64) {
+		downloaded, total = d, t
+	}
+	if err := GetArtifactWithProgress(taskEnv, artifact, taskDir, progress); err != nil {
+		t.Fatalf("GetArtifactWithProgress failed: %v", err)
+	}
+
+	if downloaded != info.Size() || total != info.Size() {
+		t.Fatalf("expected %d bytes downloaded of %d; got %d of %d", info.Size(), info.Size(), downloaded, total)
+	}
+}
+
+func TestGetArtifactWithContext_Cancel(t *testing.T) {
+	// Create a test server sending part of the file and then stalling
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	// Create a temp directory to download into holding a previous artifact
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	existing := filepath.Join(taskDir, "local", "existing")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatalf("failed to make local directory: %v", err)
+	}
+	if err := ioutil.WriteFile(existing, []byte("keep"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	artifact := &structs.TaskArtifact{
+		GetterSource: fmt.Sprintf("%s/huge.bin", ts.URL),
+		RelativeDest: "local/",
+	}
+
+	// Cancel the download once it started
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := func(d, t int64) {
+		cancel()
+	}
+
+	err = GetArtifactWithContext(ctx, taskEnv, artifact, taskDir, progress)
+	if err == nil {
+		t.Fatalf("expected the download to fail")
+	}
+	getErr, ok := err.(*GetError)
+	if !ok || getErr.Err != ErrDownloadCanceled || getErr.IsRecoverable() {
+		t.Fatalf("expected an unrecoverable canceled error; got %#v", err)
+	}
+
+	// The partial download was removed but not the previous files
+	if _, err := os.Stat(filepath.Join(taskDir, "local", "huge.bin")); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial download to be removed: %v", err)
+	}
+	if _, err := os.Stat(existing); err != nil {
+		t.Fatalf("expected the existing file to be kept: %v", err)
+	}
+}
+
 func TestGetArtifact_File_RelativeDest(t *testing.T) {
 	// Create the test server hosting the file to download
 	ts := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir("./test-fixtures/"))))
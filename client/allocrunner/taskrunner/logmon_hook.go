@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"sync"
 
 	hclog "github.com/hashicorp/go-hclog"
 	plugin "github.com/hashicorp/go-plugin"
@@ -30,6 +31,18 @@ type logmonHook struct {
 
 	config *logmonHookConfig
 
+	// logConfig is the configuration logmon was last started with. It is
+	// used to reconfigure log rotation while the task is running.
+	logConfig *logmon.LogConfig
+
+	// rotation overrides the task's log rotation settings when set. It is
+	// kept across task restarts.
+	rotation *structs.LogConfig
+
+	// lock guards logConfig, rotation and the logmon handle between the
+	// task runner and rotation updates
+	lock sync.Mutex
+
 	logger hclog.Logger
 }
 
@@ -95,6 +108,9 @@ func reattachConfigFromHookData(data map[string]string) (*plugin.ReattachConfig,
 func (h *logmonHook) Prestart(ctx context.Context,
 	req *interfaces.TaskPrestartRequest, resp *interfaces.TaskPrestartResponse) error {
 
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
 	// Create a logmon client by reattaching or launching a new instance
 	if h.logmonPluginClient == nil || h.logmonPluginClient.Exited() {
 		reattachConfig, err := reattachConfigFromHookData(req.PreviousState)
@@ -113,19 +129,26 @@ func (h *logmonHook) Prestart(ctx context.Context,
 
 	}
 
-	err := h.logmon.Start(&logmon.LogConfig{
+	rotation := req.Task.LogConfig
+	if h.rotation != nil {
+		rotation = h.rotation
+	}
+
+	logConfig := &logmon.LogConfig{
 		LogDir:        h.config.logDir,
 		StdoutLogFile: fmt.Sprintf("%s.stdout", req.Task.Name),
 		StderrLogFile: fmt.Sprintf("%s.stderr", req.Task.Name),
 		StdoutFifo:    h.config.stdoutFifo,
 		StderrFifo:    h.config.stderrFifo,
-		MaxFiles:      req.Task.LogConfig.MaxFiles,
-		MaxFileSizeMB: req.Task.LogConfig.MaxFileSizeMB,
-	})
+		MaxFiles:      rotation.MaxFiles,
+		MaxFileSizeMB: rotation.MaxFileSizeMB,
+	}
+	err := h.logmon.Start(logConfig)
 	if err != nil {
 		h.logger.Error("failed to start logmon", "error", err)
 		return err
 	}
+	h.logConfig = logConfig
 
 	rCfg := pstructs.ReattachConfigFromGoPlugin(h.logmonPluginClient.ReattachConfig())
 	jsonCfg, err := json.Marshal(rCfg)
@@ -136,7 +159,41 @@ func (h *logmonHook) Prestart(ctx context.Context,
 	return nil
 }
 
+// setLogRotation updates the log rotation settings of the running logmon. The
+// settings are retained and applied again if the task restarts.
+func (h *logmonHook) setLogRotation(rotation *structs.LogConfig) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.logmon != nil && h.logConfig != nil {
+		logConfig := *h.logConfig
+		logConfig.MaxFiles = rotation.MaxFiles
+		logConfig.MaxFileSizeMB = rotation.MaxFileSizeMB
+		if err := h.logmon.Start(&logConfig); err != nil {
+			return err
+		}
+		h.logConfig = &logConfig
+	}
+
+	h.rotation = rotation
+	return nil
+}
+
+// rotateLog forces the rotation of the task's stdout or stderr log and returns
+// the path of the rotated file.
+func (h *logmonHook) rotateLog(logType string) (string, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.logmon == nil {
+		return "", fmt.Errorf("logmon is not running")
+	}
+	return h.logmon.Rotate(logType)
+}
+
 func (h *logmonHook) Stop(_ context.Context, req *interfaces.TaskStopRequest, _ *interfaces.TaskStopResponse) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 
 	// It's possible that Stop was called without calling Prestart on agent
 	// restarts. Attempt to reattach to an existing logmon.
@@ -2,9 +2,11 @@ package taskrunner
 
 import (
 	"context"
+	"math"
 	"sync"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
 	cstructs "github.com/hashicorp/nomad/client/structs"
@@ -18,14 +20,98 @@ type StatsUpdater interface {
 	UpdateStats(*cstructs.TaskResourceUsage)
 }
 
+// minDriverStatsTimeout is the minimum time the stats hook waits for a sample
+// from the driver before abandoning the stats stream.
+const minDriverStatsTimeout = 5 * time.Second
+
+const (
+	// idleCpuPercent is the CPU percent under which a task is near idle
+	idleCpuPercent = 1.0
+
+	// stableCpuDelta and stableMemoryRatio are the changes of the CPU percent
+	// and of the relative memory usage between samples under which the usage
+	// of a task is stable
+	stableCpuDelta    = 0.5
+	stableMemoryRatio = 0.01
+
+	// sharpCpuDelta and sharpMemoryRatio are the changes between samples
+	// from which the usage of a task changed sharply
+	sharpCpuDelta    = 10.0
+	sharpMemoryRatio = 0.1
+)
+
+// adaptiveInterval backs off the stats collection interval of a task while its
+// usage is stable and near idle, and resets it when the usage changes sharply.
+type adaptiveInterval struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+
+	// cpu and memory are the usage of the last sample
+	cpu    float64
+	memory uint64
+	seen   bool
+}
+
+func newAdaptiveInterval(min, max time.Duration) *adaptiveInterval {
+	return &adaptiveInterval{
+		min:     min,
+		max:     max,
+		current: min,
+	}
+}
+
+// next returns the interval to collect the next sample at given the latest
+// sample.
+func (a *adaptiveInterval) next(ru *cstructs.TaskResourceUsage) time.Duration {
+	if ru == nil || ru.ResourceUsage == nil || ru.ResourceUsage.CpuStats == nil || ru.ResourceUsage.MemoryStats == nil {
+		return a.current
+	}
+
+	cpu := ru.ResourceUsage.CpuStats.Percent
+	memory := ru.ResourceUsage.MemoryStats.RSS
+	if !a.seen {
+		a.cpu, a.memory, a.seen = cpu, memory, true
+		return a.current
+	}
+
+	cpuDelta := math.Abs(cpu - a.cpu)
+	memoryRatio := math.Abs(float64(memory)-float64(a.memory)) / math.Max(float64(a.memory), 1)
+	a.cpu, a.memory = cpu, memory
+
+	switch {
+	case cpuDelta >= sharpCpuDelta || memoryRatio >= sharpMemoryRatio:
+		a.current = a.min
+	case cpu < idleCpuPercent && cpuDelta < stableCpuDelta && memoryRatio < stableMemoryRatio:
+		a.current *= 2
+		if a.current > a.max {
+			a.current = a.max
+		}
+	}
+	return a.current
+}
+
 // statsHook manages the task stats collection goroutine.
 type statsHook struct {
 	updater  StatsUpdater
 	interval time.Duration
 
+	// timeout is how long to wait for a sample from the driver before
+	// reporting the stats as unavailable and restarting the stats stream.
+	timeout time.Duration
+
 	// cancel is called by Exited
 	cancel context.CancelFunc
 
+	// disabled skips collecting stats, emitting a counter labeled with
+	// labels instead
+	disabled bool
+	labels   []metrics.Label
+
+	// maxInterval enables backing off the collection of idle tasks up to the
+	// interval. The current interval is emitted as a gauge with labels.
+	maxInterval time.Duration
+
 	mu sync.Mutex
 
 	logger hclog.Logger
@@ -35,16 +121,46 @@ func newStatsHook(su StatsUpdater, interval time.Duration, logger hclog.Logger)
 	h := &statsHook{
 		updater:  su,
 		interval: interval,
+		timeout:  statsTimeout(interval),
 	}
 	h.logger = logger.Named(h.Name())
 	return h
 }
 
+// statsTimeout returns how long to wait for a sample collected every interval
+func statsTimeout(interval time.Duration) time.Duration {
+	timeout := 3 * interval
+	if timeout < minDriverStatsTimeout {
+		timeout = minDriverStatsTimeout
+	}
+	return timeout
+}
+
 func (*statsHook) Name() string {
 	return "stats_hook"
 }
 
+// disable skips collecting the stats of the task. A counter with the given
+// labels is incremented once each time the task starts, not per interval.
+func (h *statsHook) disable(labels []metrics.Label) {
+	h.disabled = true
+	h.labels = labels
+}
+
+// adaptive backs off the collection of the task's stats up to maxInterval
+// while it is idle. The current interval is emitted as a gauge with the given
+// labels.
+func (h *statsHook) adaptive(maxInterval time.Duration, labels []metrics.Label) {
+	h.maxInterval = maxInterval
+	h.labels = labels
+}
+
 func (h *statsHook) Poststart(ctx context.Context, req *interfaces.TaskPoststartRequest, _ *interfaces.TaskPoststartResponse) error {
+	if h.disabled {
+		metrics.IncrCounterWithLabels([]string{"client", "allocs", "stats_collection_skipped"}, 1, h.labels)
+		return nil
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -87,8 +203,15 @@ func (h *statsHook) Exited(context.Context, *interfaces.TaskExitedRequest, *inte
 // collectResourceUsageStats starts collecting resource usage stats of a Task.
 // Collection ends when the passed channel is closed
 func (h *statsHook) collectResourceUsageStats(ctx context.Context, handle interfaces.DriverStats) {
+	var adaptive *adaptiveInterval
+	interval, timeoutDuration := h.interval, h.timeout
+	if h.maxInterval > h.interval {
+		adaptive = newAdaptiveInterval(h.interval, h.maxInterval)
+		h.emitInterval(interval)
+	}
 
-	ch, err := handle.Stats(ctx, h.interval)
+	ch, stopStream, err := h.startStream(ctx, handle, interval)
+	defer func() { stopStream() }()
 	if err != nil {
 		// Check if the driver doesn't implement stats
 		if err.Error() == cstructs.DriverStatsNotImplemented.Error() {
@@ -98,17 +221,40 @@ func (h *statsHook) collectResourceUsageStats(ctx context.Context, handle interf
 		h.logger.Error("failed to start stats collection for task", "error", err)
 	}
 
+	timeout := time.NewTimer(timeoutDuration)
+	defer timeout.Stop()
+
 	var backoff time.Duration
 	var retry int
 	limit := time.Second * 5
 	for {
 		time.Sleep(backoff)
 		select {
+		case <-timeout.C:
+			// The driver didn't return a sample in time. Report the stats
+			// that don't depend on the driver and start a new stream. The
+			// driver's memory and CPU stats are left unset so the last
+			// sample is kept rather than reported as zero.
+			h.logger.Warn("timed out waiting for stats from driver", "timeout", timeoutDuration)
+			h.updater.UpdateStats(&cstructs.TaskResourceUsage{
+				ResourceUsage:          &cstructs.ResourceUsage{},
+				Timestamp:              time.Now().UTC().UnixNano(),
+				DriverStatsUnavailable: true,
+			})
+
+			stopStream()
+			ch, stopStream, err = h.startStream(ctx, handle, interval)
+			if err != nil {
+				h.logger.Debug("error fetching stats of task", "error", err)
+			}
+			timeout.Reset(timeoutDuration)
+
 		case ru, ok := <-ch:
 			// Channel is closed
 			if !ok {
 				var re *structs.RecoverableError
-				ch, err = handle.Stats(ctx, h.interval)
+				stopStream()
+				ch, stopStream, err = h.startStream(ctx, handle, interval)
 				if err == nil {
 					goto RETRY
 				}
@@ -140,15 +286,56 @@ func (h *statsHook) collectResourceUsageStats(ctx context.Context, handle interf
 				continue
 			}
 
+			// Reset the timeout now that the driver returned a sample
+			if !timeout.Stop() {
+				select {
+				case <-timeout.C:
+				default:
+				}
+			}
+			timeout.Reset(timeoutDuration)
+
 			// Update stats on TaskRunner and emit them
 			h.updater.UpdateStats(ru)
 
+			// Restart the stream at the new interval when it adapted to the
+			// task's usage
+			if adaptive == nil {
+				continue
+			}
+			if next := adaptive.next(ru); next != interval {
+				h.logger.Trace("changing stats collection interval", "from", interval, "to", next)
+				interval, timeoutDuration = next, statsTimeout(next)
+				h.emitInterval(interval)
+
+				stopStream()
+				ch, stopStream, err = h.startStream(ctx, handle, interval)
+				if err != nil {
+					h.logger.Debug("error fetching stats of task", "error", err)
+				}
+				timeout.Reset(timeoutDuration)
+			}
+
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// startStream starts a stats stream with its own context so a hung stream can
+// be abandoned without stopping the collection. The returned function stops
+// the stream.
+func (h *statsHook) startStream(ctx context.Context, handle interfaces.DriverStats, interval time.Duration) (<-chan *cstructs.TaskResourceUsage, func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch, err := handle.Stats(ctx, interval)
+	return ch, cancel, err
+}
+
+// emitInterval emits the current collection interval in milliseconds
+func (h *statsHook) emitInterval(interval time.Duration) {
+	metrics.SetGaugeWithLabels([]string{"client", "allocs", "stats_interval"}, float32(interval.Seconds()*1000), h.labels)
+}
+
 func (h *statsHook) Shutdown() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -38,12 +38,18 @@ func (m *mockStatsUpdater) UpdateStats(ru *cstructs.TaskResourceUsage) {
 type mockDriverStats struct {
 	// err is returned by Stats if it is non-nil
 	err error
+
+	// hang makes the stats stream never return a sample
+	hang bool
 }
 
 func (m *mockDriverStats) Stats(ctx context.Context, interval time.Duration) (<-chan *cstructs.TaskResourceUsage, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	if m.hang {
+		return make(chan *cstructs.TaskResourceUsage), nil
+	}
 	ru := &cstructs.TaskResourceUsage{
 		ResourceUsage: &cstructs.ResourceUsage{
 			MemoryStats: &cstructs.MemoryStats{
@@ -186,3 +192,105 @@ func TestTaskRunner_StatsHook_NotImplemented(t *testing.T) {
 		// Ok! No update received because error was returned
 	}
 }
+
+// TestTaskRunner_StatsHook_DriverTimeout asserts the stats hook reports the
+// driver stats as unavailable when the driver hangs.
+func TestTaskRunner_StatsHook_DriverTimeout(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+	logger := testlog.HCLogger(t)
+	su := newMockStatsUpdater()
+	ds := &mockDriverStats{hang: true}
+
+	poststartReq := &interfaces.TaskPoststartRequest{DriverStats: ds}
+
+	h := newStatsHook(su, time.Minute, logger)
+	h.timeout = 100 * time.Millisecond
+	defer h.Exited(context.Background(), nil, nil)
+
+	// Run prestart
+	require.NoError(h.Poststart(context.Background(), poststartReq, nil))
+
+	// The hook should keep reporting partial stats while the driver hangs
+	for i := 0; i < 2; i++ {
+		select {
+		case ru := <-su.Ch:
+			require.True(ru.DriverStatsUnavailable)
+			require.NotZero(ru.Timestamp)
+			require.Nil(ru.ResourceUsage.MemoryStats)
+			require.Nil(ru.ResourceUsage.CpuStats)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout waiting for partial stats")
+		}
+	}
+
+	require.NoError(h.Exited(context.Background(), nil, nil))
+}
+
+// TestTaskRunner_StatsHook_Disabled asserts a disabled stats hook never
+// collects stats.
+func TestTaskRunner_StatsHook_Disabled(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+	logger := testlog.HCLogger(t)
+	su := newMockStatsUpdater()
+
+	ds := new(mockDriverStats)
+	poststartReq := &interfaces.TaskPoststartRequest{DriverStats: ds}
+
+	h := newStatsHook(su, 10*time.Millisecond, logger)
+	h.disable(nil)
+	defer h.Exited(context.Background(), nil, nil)
+
+	// Run prestart
+	require.NoError(h.Poststart(context.Background(), poststartReq, nil))
+
+	select {
+	case <-su.Ch:
+		t.Fatalf("unexpected stats collection")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(h.Exited(context.Background(), nil, nil))
+}
+
+// TestTaskRunner_StatsHook_AdaptiveInterval asserts the collection interval
+// backs off while the task is idle and resets on sharp usage changes.
+func TestTaskRunner_StatsHook_AdaptiveInterval(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+	sample := func(cpu float64, rss uint64) *cstructs.TaskResourceUsage {
+		return &cstructs.TaskResourceUsage{
+			ResourceUsage: &cstructs.ResourceUsage{
+				MemoryStats: &cstructs.MemoryStats{RSS: rss},
+				CpuStats:    &cstructs.CpuStats{Percent: cpu},
+			},
+		}
+	}
+
+	a := newAdaptiveInterval(time.Second, 5*time.Second)
+
+	// The first sample has nothing to compare to
+	require.Equal(time.Second, a.next(sample(0.1, 1000)))
+
+	// Stable and idle usage backs off up to the max
+	require.Equal(2*time.Second, a.next(sample(0.2, 1000)))
+	require.Equal(4*time.Second, a.next(sample(0.1, 1001)))
+	require.Equal(5*time.Second, a.next(sample(0.1, 1000)))
+	require.Equal(5*time.Second, a.next(sample(0.1, 1000)))
+
+	// Busy but stable usage keeps the interval
+	require.Equal(5*time.Second, a.next(sample(5, 1000)))
+	require.Equal(5*time.Second, a.next(sample(5.2, 1000)))
+
+	// A sharp change resets it
+	require.Equal(time.Second, a.next(sample(50, 1000)))
+	require.Equal(time.Second, a.next(sample(50, 1000)))
+	require.Equal(time.Second, a.next(sample(50, 2000)))
+
+	// Samples without usage are ignored
+	require.Equal(time.Second, a.next(&cstructs.TaskResourceUsage{}))
+}
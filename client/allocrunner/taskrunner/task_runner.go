@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -16,12 +20,17 @@ import (
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
 	"github.com/hashicorp/nomad/client/allocrunner/taskrunner/restarts"
 	"github.com/hashicorp/nomad/client/allocrunner/taskrunner/state"
+	"github.com/hashicorp/nomad/client/allocrunner/taskrunner/template"
 	"github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/consul"
 	"github.com/hashicorp/nomad/client/devicemanager"
 	cinterfaces "github.com/hashicorp/nomad/client/interfaces"
+	"github.com/hashicorp/nomad/client/lib/cgutil"
+	"github.com/hashicorp/nomad/client/lib/schedstat"
+	"github.com/hashicorp/nomad/client/lib/tcpconn"
 	"github.com/hashicorp/nomad/client/pluginmanager/drivermanager"
 	cstate "github.com/hashicorp/nomad/client/state"
+	"github.com/hashicorp/nomad/client/stats"
 	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/client/taskenv"
 	"github.com/hashicorp/nomad/client/vaultclient"
@@ -46,6 +55,14 @@ const (
 	// the task.
 	killBackoffLimit = 2 * time.Minute
 
+	// driverStatsSampleTimeout is how long a forced stats collection waits
+	// for the driver to return a sample.
+	driverStatsSampleTimeout = 10 * time.Second
+
+	// maxConnectionEntries is the maximum number of sockets counted per
+	// stats sample to bound the cost for tasks with huge connection tables.
+	maxConnectionEntries = 16384
+
 	// killFailureLimit is how many times we will attempt to kill a task before
 	// giving up and potentially leaking resources.
 	killFailureLimit = 5
@@ -179,6 +196,18 @@ type TaskRunner struct {
 	resourceUsage     *cstructs.TaskResourceUsage
 	resourceUsageLock sync.Mutex
 
+	// cumulativeCpuTime and cumulativeBytesWritten total the cgroup counters
+	// across restarts. Guarded by resourceUsageLock.
+	cumulativeCpuTime      cstructs.CumulativeCounter
+	cumulativeBytesWritten cstructs.CumulativeCounter
+
+	// statsCgroup is the cgroup configuration of the process statsCgroupPID.
+	// It is cached so stats samples only read the cgroup counters, and read
+	// again when the task restarts with a new PID.
+	statsCgroup     *cgutil.Config
+	statsCgroupPID  int
+	statsCgroupLock sync.Mutex
+
 	// deviceStatsReporter is used to lookup resource usage for alloc devices
 	deviceStatsReporter cinterfaces.DeviceStatsReporter
 
@@ -190,6 +219,17 @@ type TaskRunner struct {
 	// handlers
 	driverManager drivermanager.Manager
 
+	// artifactProgress is the progress of the artifact downloads
+	artifactProgress *artifactProgress
+
+	// prestartHook is the name of the prestart hook being run, if any. Must
+	// acquire stateLock to access.
+	prestartHook string
+
+	// lifecycleEvents broadcasts hook runs and state transitions. It may be
+	// nil.
+	lifecycleEvents *cstructs.AllocLifecycleBroadcaster
+
 	// runLaunched marks whether the Run goroutine has been started. It should
 	// be accessed via helpers
 	runLaunched     bool
@@ -227,6 +267,10 @@ type Config struct {
 	// DriverManager is used to dispense driver plugins and register event
 	// handlers
 	DriverManager drivermanager.Manager
+
+	// LifecycleEvents is used to broadcast hook runs and state transitions.
+	// It may be nil.
+	LifecycleEvents *cstructs.AllocLifecycleBroadcaster
 }
 
 func NewTaskRunner(config *Config) (*TaskRunner, error) {
@@ -261,6 +305,7 @@ func NewTaskRunner(config *Config) (*TaskRunner, error) {
 		envBuilder:          envBuilder,
 		consulClient:        config.Consul,
 		vaultClient:         config.Vault,
+		lifecycleEvents:     config.LifecycleEvents,
 		state:               tstate,
 		localState:          state.NewLocalState(),
 		stateDB:             config.StateDB,
@@ -322,12 +367,12 @@ func NewTaskRunner(config *Config) (*TaskRunner, error) {
 		return nil, err
 	}
 
-	// Initialize the runners hooks.
-	tr.initHooks()
-
 	// Initialize base labels
 	tr.initLabels()
 
+	// Initialize the runners hooks.
+	tr.initHooks()
+
 	// Initialize initial task received event
 	tr.appendEvent(structs.NewTaskEvent(structs.TaskReceived))
 
@@ -802,6 +847,17 @@ func (tr *TaskRunner) killTask(handle *DriverHandle) error {
 	return err
 }
 
+// ClearLocalState discards the hook states and driver handle of the task and
+// persists the emptied local state. It must only be called once the task is
+// dead.
+func (tr *TaskRunner) ClearLocalState() error {
+	tr.stateLock.Lock()
+	tr.localState = state.NewLocalState()
+	tr.stateLock.Unlock()
+
+	return tr.persistLocalState()
+}
+
 // persistLocalState persists local state to disk synchronously.
 func (tr *TaskRunner) persistLocalState() error {
 	tr.stateLock.RLock()
@@ -943,6 +999,14 @@ func (tr *TaskRunner) updateStateImpl(state string) error {
 	taskState := tr.state
 	taskState.State = state
 
+	if oldState != state {
+		tr.lifecycleEvents.Send(&cstructs.AllocLifecycleEvent{
+			Task:  tr.taskName,
+			Type:  cstructs.LifecycleEventState,
+			State: state,
+		})
+	}
+
 	// Handle the state transition.
 	switch state {
 	case structs.TaskStateRunning:
@@ -1129,9 +1193,185 @@ func (tr *TaskRunner) LatestResourceUsage() *cstructs.TaskResourceUsage {
 	return ru
 }
 
+// PID returns the host PID of the task's main process. ErrTaskNotRunning is
+// returned if the task has no driver handle.
+func (tr *TaskRunner) PID() (int, error) {
+	handle := tr.getDriverHandle()
+	if handle == nil {
+		return 0, ErrTaskNotRunning
+	}
+
+	return handle.PID()
+}
+
+// ExecTask runs the command in the running task. ErrTaskNotRunning is
+// returned if the task is not running.
+func (tr *TaskRunner) ExecTask(timeout time.Duration, cmd []string) (*drivers.ExecTaskResult, error) {
+	handle := tr.getDriverHandle()
+	if handle == nil {
+		return nil, ErrTaskNotRunning
+	}
+
+	return handle.ExecTask(timeout, cmd)
+}
+
+// ArtifactProgress returns the progress of the artifact downloads of the task.
+// The downloads are reported done if the task has no artifacts or is no
+// longer pending, eg when it was restored after downloading them.
+func (tr *TaskRunner) ArtifactProgress() *cstructs.TaskArtifactProgress {
+	progress := tr.artifactProgress.get()
+	if len(tr.Task().Artifacts) == 0 || tr.TaskState().State != structs.TaskStatePending {
+		progress.Done = true
+	}
+	return progress
+}
+
+// CancelArtifactDownload aborts the artifact download of the starting task,
+// which fails the task. ErrNoArtifactDownload is returned if no artifact is
+// downloading.
+func (tr *TaskRunner) CancelArtifactDownload() error {
+	for _, hook := range tr.runnerHooks {
+		if h, ok := hook.(*artifactHook); ok {
+			return h.cancelDownload()
+		}
+	}
+
+	return ErrNoArtifactDownload
+}
+
+// SetLogRotation updates the log rotation settings of the running task
+// without restarting it. The settings are kept if the task is restarted.
+func (tr *TaskRunner) SetLogRotation(rotation *structs.LogConfig) error {
+	if tr.getDriverHandle() == nil {
+		return ErrTaskNotRunning
+	}
+
+	for _, hook := range tr.runnerHooks {
+		if h, ok := hook.(*logmonHook); ok {
+			return h.setLogRotation(rotation)
+		}
+	}
+
+	return fmt.Errorf("task %q has no log monitor", tr.taskName)
+}
+
+// RotateLogs forces the rotation of the running task's stdout or stderr log
+// and returns the host path of the rotated file. The path is empty if there
+// was nothing to rotate.
+func (tr *TaskRunner) RotateLogs(logType string) (string, error) {
+	if tr.getDriverHandle() == nil {
+		return "", ErrTaskNotRunning
+	}
+
+	for _, hook := range tr.runnerHooks {
+		if h, ok := hook.(*logmonHook); ok {
+			return h.rotateLog(logType)
+		}
+	}
+
+	return "", fmt.Errorf("task %q has no log monitor", tr.taskName)
+}
+
+// RenderedTemplate returns the current content of the task's template
+// rendered to dest and whether the template reads Vault secrets.
+func (tr *TaskRunner) RenderedTemplate(dest string) ([]byte, bool, error) {
+	var tmpl *structs.Template
+	for _, t := range tr.Task().Templates {
+		if filepath.Clean(t.DestPath) == filepath.Clean(dest) {
+			tmpl = t
+			break
+		}
+	}
+	if tmpl == nil {
+		return nil, false, fmt.Errorf("%q is not a template destination of task %q", dest, tr.taskName)
+	}
+
+	taskEnv := tr.envBuilder.Build()
+	path := template.DestinationPath(tmpl, tr.taskDir.Dir, taskEnv)
+	if rel, err := filepath.Rel(tr.taskDir.AllocDir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, false, fmt.Errorf("template destination %q escapes the allocation directory", dest)
+	}
+
+	secrets, err := template.ReadsSecrets(tmpl, tr.taskDir.Dir, taskEnv)
+	if err != nil {
+		return nil, false, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("template %q has not been rendered", dest)
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return content, secrets, nil
+}
+
 // UpdateStats updates and emits the latest stats from the driver.
 func (tr *TaskRunner) UpdateStats(ru *cstructs.TaskResourceUsage) {
+	// The PID is resolved once for all the stats read from the host
+	pid := 0
+	var cgroup *cgutil.Config
+	if ru != nil {
+		if p, err := tr.PID(); err == nil {
+			pid = p
+			cgroup, _ = tr.cgroupConfig(pid)
+		}
+
+		if ru.DriverStatsUnavailable && !tr.clientConfig.DisableTaggedMetrics {
+			metrics.IncrCounterWithLabels([]string{"client", "allocs", "driver_stats_timeout"}, 1, tr.baseLabels)
+		}
+	}
+	tr.updateStats(ru, pid, cgroup)
+}
+
+// updateStats stores and emits a sample given the PID of the task's main
+// process and its cgroup configuration, which are zero and nil if they
+// couldn't be read.
+func (tr *TaskRunner) updateStats(ru *cstructs.TaskResourceUsage, pid int, cgroup *cgutil.Config) {
+	if ru != nil {
+		ru.Monotonic = stats.Monotonic(time.Now())
+
+		// Stamp the sample with the number of restarts so consumers can
+		// detect counter resets
+		tr.stateLock.RLock()
+		ru.CounterEpoch = tr.state.Restarts
+		tr.stateLock.RUnlock()
+
+		if ru.ResourceUsage != nil && ru.ResourceUsage.MemoryStats != nil {
+			ru.ResourceUsage.MemoryStats.SetUsageSemantics(tr.clientConfig.MemoryUsageSemantics)
+		}
+
+		// Report the memory limits usage can be compared against
+		if res := tr.Task().Resources; res != nil {
+			ru.MemoryReserved = uint64(res.MemoryMB) * 1024 * 1024
+		}
+		if cgroup != nil {
+			ru.MemoryMaxLimit, _ = cgroup.MemoryLimit()
+		}
+
+		if ru.ResourceUsage != nil && ru.ResourceUsage.CpuStats != nil {
+			ru.ResourceUsage.CpuStats.SetAccounting(tr.clientConfig.CpuAccounting, runtime.NumCPU())
+			tr.setCpuWaitStats(ru.ResourceUsage.CpuStats, pid)
+		}
+
+		if tr.clientConfig.CollectConnectionStats {
+			ru.ConnectionStats = connectionStats(pid)
+		}
+	}
+
+	var counters *cgutil.Counters
+	if ru != nil && cgroup != nil {
+		counters = cgroup.ReadCounters()
+	}
+
 	tr.resourceUsageLock.Lock()
+	if counters != nil {
+		ru.Cumulative = tr.cumulativeStats(ru.CounterEpoch, counters)
+	}
+	if ru != nil && ru.DriverStatsUnavailable {
+		tr.keepDriverStats(ru)
+	}
 	tr.resourceUsage = ru
 	tr.resourceUsageLock.Unlock()
 	if ru != nil {
@@ -1139,6 +1379,196 @@ func (tr *TaskRunner) UpdateStats(ru *cstructs.TaskResourceUsage) {
 	}
 }
 
+// keepDriverStats sets the memory and CPU stats missing from a sample taken
+// while the driver was unavailable to the ones of the previous sample, so
+// they are reported as stale rather than zero. Must be called with
+// resourceUsageLock held.
+func (tr *TaskRunner) keepDriverStats(ru *cstructs.TaskResourceUsage) {
+	prev := tr.resourceUsage
+	if prev == nil || prev.ResourceUsage == nil {
+		return
+	}
+	if ru.ResourceUsage == nil {
+		ru.ResourceUsage = &cstructs.ResourceUsage{}
+	}
+
+	if ru.ResourceUsage.MemoryStats == nil {
+		ru.ResourceUsage.MemoryStats = prev.ResourceUsage.MemoryStats
+	}
+	if ru.ResourceUsage.CpuStats == nil {
+		ru.ResourceUsage.CpuStats = prev.ResourceUsage.CpuStats
+	}
+	if ru.Pids == nil {
+		ru.Pids = prev.Pids
+	}
+}
+
+// setCpuWaitStats sets the time the task's main process waited for a CPU and
+// the host's steal time. They are left empty where the host doesn't expose
+// them.
+func (tr *TaskRunner) setCpuWaitStats(cs *cstructs.CpuStats, pid int) {
+	if pid != 0 {
+		if stats, err := schedstat.Read(pid); err == nil {
+			cs.WaitTime = uint64(stats.WaitTime)
+			cs.Measured = append(cs.Measured, "Wait Time")
+		}
+	}
+
+	if steal, err := schedstat.HostSteal(); err == nil {
+		cs.StealTime = uint64(steal)
+		cs.Measured = append(cs.Measured, "Steal Time")
+	}
+}
+
+// connectionStats returns the TCP sockets of the network namespace of the
+// process. It returns nil if the process doesn't have a network namespace of
+// its own.
+func connectionStats(pid int) *cstructs.ConnectionStats {
+	if pid == 0 {
+		return nil
+	}
+
+	counts, err := tcpconn.Read(pid, maxConnectionEntries)
+	if err != nil {
+		return nil
+	}
+
+	return &cstructs.ConnectionStats{
+		States:    counts.States,
+		Truncated: counts.Truncated,
+	}
+}
+
+// cgroupConfig returns the cgroup configuration of the task's main process
+// given its PID. The configuration is only read once per PID, so limits
+// changed while the process runs aren't reflected in its stats.
+func (tr *TaskRunner) cgroupConfig(pid int) (*cgutil.Config, error) {
+	tr.statsCgroupLock.Lock()
+	defer tr.statsCgroupLock.Unlock()
+	if tr.statsCgroup != nil && tr.statsCgroupPID == pid {
+		return tr.statsCgroup, nil
+	}
+
+	cgroup, err := cgutil.ReadConfig(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.statsCgroup = cgroup
+	tr.statsCgroupPID = pid
+	return cgroup, nil
+}
+
+// cumulativeStats adds the cgroup counters of the current epoch to the totals
+// of the previous epochs. Must be called with resourceUsageLock held.
+func (tr *TaskRunner) cumulativeStats(epoch uint64, counters *cgutil.Counters) *cstructs.TaskCumulativeStats {
+	cs := &cstructs.TaskCumulativeStats{}
+	for _, m := range counters.Measured {
+		switch m {
+		case cgutil.CounterCpuTime:
+			cs.CpuTime = tr.cumulativeCpuTime.Update(epoch, counters.CpuTime)
+		case cgutil.CounterBytesWritten:
+			cs.BytesWritten = tr.cumulativeBytesWritten.Update(epoch, counters.BytesWritten)
+		default:
+			continue
+		}
+		cs.Measured = append(cs.Measured, m)
+	}
+	return cs
+}
+
+// DebugStats forces a stats collection of the running task and returns the
+// sample along with the time spent in each stage of the collection. The
+// sample replaces the latest stats of the task.
+func (tr *TaskRunner) DebugStats(ctx context.Context) (*cstructs.TaskStatsDebug, error) {
+	handle := tr.getDriverHandle()
+	if handle == nil {
+		return nil, ErrTaskNotRunning
+	}
+
+	debug := &cstructs.TaskStatsDebug{}
+	stage := func(name string, start time.Time, err error) {
+		debug.Stages = append(debug.Stages, &cstructs.StatsStageTiming{
+			Name:     name,
+			Duration: time.Since(start),
+		})
+		if err != nil {
+			debug.Errors = append(debug.Errors, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	// Read a single sample from the driver
+	start := time.Now()
+	ru, err := tr.driverStatsSample(ctx, handle)
+	stage("driver", start, err)
+	if ru == nil {
+		return debug, nil
+	}
+
+	start = time.Now()
+	var cgroup *cgutil.Config
+	pid, err := tr.PID()
+	if err == nil {
+		cgroup, err = tr.cgroupConfig(pid)
+	}
+	stage("cgroup", start, err)
+
+	start = time.Now()
+	tr.updateStats(ru, pid, cgroup)
+	stage("aggregation", start, nil)
+
+	debug.Sample = ru
+	return debug, nil
+}
+
+// StatsDiff reads two stats samples from the driver, window apart, and
+// returns the change between them.
+func (tr *TaskRunner) StatsDiff(ctx context.Context, window time.Duration) (*cstructs.TaskStatsDiff, error) {
+	handle := tr.getDriverHandle()
+	if handle == nil {
+		return nil, ErrTaskNotRunning
+	}
+
+	first, err := tr.driverStatsSample(ctx, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-time.After(window):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	second, err := tr.driverStatsSample(ctx, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return cstructs.NewTaskStatsDiff(first, second), nil
+}
+
+// driverStatsSample reads a single stats sample from the driver.
+func (tr *TaskRunner) driverStatsSample(ctx context.Context, handle *DriverHandle) (*cstructs.TaskResourceUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, driverStatsSampleTimeout)
+	defer cancel()
+
+	ch, err := handle.Stats(ctx, tr.clientConfig.StatsCollectionInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case ru, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("driver closed the stats channel")
+		}
+		return ru, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for a stats sample: %v", ctx.Err())
+	}
+}
+
 //TODO Remove Backwardscompat or use tr.Alloc()?
 func (tr *TaskRunner) setGaugeForMemory(ru *cstructs.TaskResourceUsage) {
 	if !tr.clientConfig.DisableTaggedMetrics {
@@ -1156,6 +1586,10 @@ func (tr *TaskRunner) setGaugeForMemory(ru *cstructs.TaskResourceUsage) {
 			float32(ru.ResourceUsage.MemoryStats.KernelUsage), tr.baseLabels)
 		metrics.SetGaugeWithLabels([]string{"client", "allocs", "memory", "kernel_max_usage"},
 			float32(ru.ResourceUsage.MemoryStats.KernelMaxUsage), tr.baseLabels)
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "memory", "reserved"},
+			float32(ru.MemoryReserved), tr.baseLabels)
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "memory", "max_limit"},
+			float32(ru.MemoryMaxLimit), tr.baseLabels)
 	}
 
 	if tr.clientConfig.BackwardsCompatibleMetrics {
@@ -1166,6 +1600,8 @@ func (tr *TaskRunner) setGaugeForMemory(ru *cstructs.TaskResourceUsage) {
 		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "memory", "max_usage"}, float32(ru.ResourceUsage.MemoryStats.MaxUsage))
 		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "memory", "kernel_usage"}, float32(ru.ResourceUsage.MemoryStats.KernelUsage))
 		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "memory", "kernel_max_usage"}, float32(ru.ResourceUsage.MemoryStats.KernelMaxUsage))
+		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "memory", "reserved"}, float32(ru.MemoryReserved))
+		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "memory", "max_limit"}, float32(ru.MemoryMaxLimit))
 	}
 }
 
@@ -1184,6 +1620,10 @@ func (tr *TaskRunner) setGaugeForCPU(ru *cstructs.TaskResourceUsage) {
 			float32(ru.ResourceUsage.CpuStats.ThrottledPeriods), tr.baseLabels)
 		metrics.SetGaugeWithLabels([]string{"client", "allocs", "cpu", "total_ticks"},
 			float32(ru.ResourceUsage.CpuStats.TotalTicks), tr.baseLabels)
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "cpu", "wait_time"},
+			float32(ru.ResourceUsage.CpuStats.WaitTime), tr.baseLabels)
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "cpu", "steal_time"},
+			float32(ru.ResourceUsage.CpuStats.StealTime), tr.baseLabels)
 	}
 
 	if tr.clientConfig.BackwardsCompatibleMetrics {
@@ -1193,13 +1633,44 @@ func (tr *TaskRunner) setGaugeForCPU(ru *cstructs.TaskResourceUsage) {
 		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "cpu", "throttled_time"}, float32(ru.ResourceUsage.CpuStats.ThrottledTime))
 		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "cpu", "throttled_periods"}, float32(ru.ResourceUsage.CpuStats.ThrottledPeriods))
 		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "cpu", "total_ticks"}, float32(ru.ResourceUsage.CpuStats.TotalTicks))
+		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "cpu", "wait_time"}, float32(ru.ResourceUsage.CpuStats.WaitTime))
+		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "cpu", "steal_time"}, float32(ru.ResourceUsage.CpuStats.StealTime))
+	}
+}
+
+func (tr *TaskRunner) setGaugeForCumulative(ru *cstructs.TaskResourceUsage) {
+	if !tr.clientConfig.DisableTaggedMetrics {
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "cpu", "cumulative_time"},
+			float32(ru.Cumulative.CpuTime), tr.baseLabels)
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "disk", "cumulative_bytes_written"},
+			float32(ru.Cumulative.BytesWritten), tr.baseLabels)
+	}
+
+	if tr.clientConfig.BackwardsCompatibleMetrics {
+		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "cpu", "cumulative_time"}, float32(ru.Cumulative.CpuTime))
+		metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "disk", "cumulative_bytes_written"}, float32(ru.Cumulative.BytesWritten))
+	}
+}
+
+func (tr *TaskRunner) setGaugeForConnections(ru *cstructs.TaskResourceUsage) {
+	for _, state := range tcpconn.States {
+		count := float32(ru.ConnectionStats.States[state])
+		name := strings.ToLower(state)
+		if !tr.clientConfig.DisableTaggedMetrics {
+			metrics.SetGaugeWithLabels([]string{"client", "allocs", "tcp", name}, count, tr.baseLabels)
+		}
+
+		if tr.clientConfig.BackwardsCompatibleMetrics {
+			metrics.SetGauge([]string{"client", "allocs", tr.alloc.Job.Name, tr.alloc.TaskGroup, tr.allocID, tr.taskName, "tcp", name}, count)
+		}
 	}
 }
 
 // emitStats emits resource usage stats of tasks to remote metrics collector
 // sinks
 func (tr *TaskRunner) emitStats(ru *cstructs.TaskResourceUsage) {
-	if !tr.clientConfig.PublishAllocationMetrics {
+	// Don't emit zeroed usage when the driver didn't return stats
+	if !tr.clientConfig.PublishAllocationMetrics || ru.DriverStatsUnavailable {
 		return
 	}
 
@@ -1210,6 +1681,14 @@ func (tr *TaskRunner) emitStats(ru *cstructs.TaskResourceUsage) {
 	if ru.ResourceUsage.CpuStats != nil {
 		tr.setGaugeForCPU(ru)
 	}
+
+	if ru.Cumulative != nil {
+		tr.setGaugeForCumulative(ru)
+	}
+
+	if ru.ConnectionStats != nil {
+		tr.setGaugeForConnections(ru)
+	}
 }
 
 // appendTaskEvent updates the task status by appending the new event.
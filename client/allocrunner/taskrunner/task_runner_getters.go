@@ -127,3 +127,18 @@ func (tr *TaskRunner) hookState(name string) *state.HookState {
 	}
 	return s
 }
+
+// setPrestartHook sets the name of the prestart hook being run. An empty name
+// marks that no hook is running.
+func (tr *TaskRunner) setPrestartHook(name string) {
+	tr.stateLock.Lock()
+	defer tr.stateLock.Unlock()
+	tr.prestartHook = name
+}
+
+// getPrestartHook returns the name of the prestart hook being run, if any.
+func (tr *TaskRunner) getPrestartHook() string {
+	tr.stateLock.RLock()
+	defer tr.stateLock.RUnlock()
+	return tr.prestartHook
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/nomad/client/allocrunner/interfaces"
 	"github.com/hashicorp/nomad/client/allocrunner/taskrunner/state"
@@ -54,6 +55,20 @@ func (tr *TaskRunner) initHooks() {
 	// Add the hook resources
 	tr.hookResources = &hookResources{}
 
+	// Skip collecting stats in the namespaces they are disabled for and back
+	// off the collection of idle tasks if enabled
+	statsHook := newStatsHook(tr, tr.clientConfig.StatsCollectionInterval, hookLogger)
+	if ns := tr.alloc.Namespace; tr.clientConfig.StatsDisabled(ns) {
+		labels := append([]metrics.Label{{Name: "namespace", Value: ns}}, tr.baseLabels...)
+		statsHook.disable(labels)
+	} else if max := tr.clientConfig.StatsAdaptiveMaxInterval; max != 0 {
+		statsHook.adaptive(max, tr.baseLabels)
+	}
+
+	// Expose the progress of the artifact downloads
+	artifactHook := newArtifactHook(tr, hookLogger)
+	tr.artifactProgress = artifactHook.progress
+
 	// Create the task directory hook. This is run first to ensure the
 	// directory path exists for other hooks.
 	tr.runnerHooks = []interfaces.TaskHook{
@@ -61,8 +76,8 @@ func (tr *TaskRunner) initHooks() {
 		newTaskDirHook(tr, hookLogger),
 		newLogMonHook(tr.logmonHookConfig, hookLogger),
 		newDispatchHook(tr.Alloc(), hookLogger),
-		newArtifactHook(tr, hookLogger),
-		newStatsHook(tr, tr.clientConfig.StatsCollectionInterval, hookLogger),
+		artifactHook,
+		statsHook,
 		newDeviceHook(tr.devicemanager, hookLogger),
 	}
 
@@ -173,15 +188,18 @@ func (tr *TaskRunner) prestart() error {
 		req.VaultToken = tr.getVaultToken()
 
 		// Time the prestart hook
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running prestart hook", "name", name, "start", start)
 		}
 
 		// Run the prestart hook
+		tr.setPrestartHook(name)
 		var resp interfaces.TaskPrestartResponse
-		if err := pre.Prestart(tr.killCtx, &req, &resp); err != nil {
+		err := pre.Prestart(tr.killCtx, &req, &resp)
+		tr.setPrestartHook("")
+		tr.lifecycleEvents.SendHook(tr.taskName, "prestart", name, start, err)
+		if err != nil {
 			tr.emitHookError(err, name)
 			return structs.WrapRecoverable(fmt.Sprintf("prestart hook %q failed: %v", name, err), err)
 		}
@@ -256,9 +274,8 @@ func (tr *TaskRunner) poststart() error {
 		}
 
 		name := post.Name()
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running poststart hook", "name", name, "start", start)
 		}
 
@@ -269,7 +286,9 @@ func (tr *TaskRunner) poststart() error {
 			TaskEnv:       tr.envBuilder.Build(),
 		}
 		var resp interfaces.TaskPoststartResponse
-		if err := post.Poststart(tr.killCtx, &req, &resp); err != nil {
+		err := post.Poststart(tr.killCtx, &req, &resp)
+		tr.lifecycleEvents.SendHook(tr.taskName, "poststart", name, start, err)
+		if err != nil {
 			tr.emitHookError(err, name)
 			merr.Errors = append(merr.Errors, fmt.Errorf("poststart hook %q failed: %v", name, err))
 		}
@@ -304,15 +323,16 @@ func (tr *TaskRunner) exited() error {
 		}
 
 		name := post.Name()
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running exited hook", "name", name, "start", start)
 		}
 
 		req := interfaces.TaskExitedRequest{}
 		var resp interfaces.TaskExitedResponse
-		if err := post.Exited(tr.killCtx, &req, &resp); err != nil {
+		err := post.Exited(tr.killCtx, &req, &resp)
+		tr.lifecycleEvents.SendHook(tr.taskName, "exited", name, start, err)
+		if err != nil {
 			tr.emitHookError(err, name)
 			merr.Errors = append(merr.Errors, fmt.Errorf("exited hook %q failed: %v", name, err))
 		}
@@ -348,9 +368,8 @@ func (tr *TaskRunner) stop() error {
 		}
 
 		name := post.Name()
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running stop hook", "name", name, "start", start)
 		}
 
@@ -363,7 +382,9 @@ func (tr *TaskRunner) stop() error {
 		}
 
 		var resp interfaces.TaskStopResponse
-		if err := post.Stop(tr.killCtx, &req, &resp); err != nil {
+		err := post.Stop(tr.killCtx, &req, &resp)
+		tr.lifecycleEvents.SendHook(tr.taskName, "stop", name, start, err)
+		if err != nil {
 			tr.emitHookError(err, name)
 			merr.Errors = append(merr.Errors, fmt.Errorf("stop hook %q failed: %v", name, err))
 		}
@@ -413,15 +434,16 @@ func (tr *TaskRunner) updateHooks() {
 		}
 
 		// Time the update hook
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running update hook", "name", name, "start", start)
 		}
 
 		// Run the update hook
 		var resp interfaces.TaskUpdateResponse
-		if err := upd.Update(tr.killCtx, &req, &resp); err != nil {
+		err := upd.Update(tr.killCtx, &req, &resp)
+		tr.lifecycleEvents.SendHook(tr.taskName, "update", name, start, err)
+		if err != nil {
 			tr.emitHookError(err, name)
 			tr.logger.Error("update hook failed", "name", name, "error", err)
 		}
@@ -457,16 +479,17 @@ func (tr *TaskRunner) preKill() {
 		name := killHook.Name()
 
 		// Time the pre kill hook
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running prekill hook", "name", name, "start", start)
 		}
 
 		// Run the pre kill hook
 		req := interfaces.TaskPreKillRequest{}
 		var resp interfaces.TaskPreKillResponse
-		if err := killHook.PreKilling(context.Background(), &req, &resp); err != nil {
+		err := killHook.PreKilling(context.Background(), &req, &resp)
+		tr.lifecycleEvents.SendHook(tr.taskName, "prekill", name, start, err)
+		if err != nil {
 			tr.emitHookError(err, name)
 			tr.logger.Error("prekill hook failed", "name", name, "error", err)
 		}
@@ -492,13 +515,13 @@ func (tr *TaskRunner) shutdownHooks() {
 		name := sh.Name()
 
 		// Time the update hook
-		var start time.Time
+		start := time.Now()
 		if tr.logger.IsTrace() {
-			start = time.Now()
 			tr.logger.Trace("running shutdown hook", "name", name, "start", start)
 		}
 
 		sh.Shutdown()
+		tr.lifecycleEvents.SendHook(tr.taskName, "shutdown", name, start, nil)
 
 		if tr.logger.IsTrace() {
 			end := time.Now()
@@ -19,8 +19,10 @@ import (
 	"github.com/hashicorp/nomad/client/consul"
 	consulapi "github.com/hashicorp/nomad/client/consul"
 	"github.com/hashicorp/nomad/client/devicemanager"
+	"github.com/hashicorp/nomad/client/lib/cgutil"
 	"github.com/hashicorp/nomad/client/pluginmanager/drivermanager"
 	cstate "github.com/hashicorp/nomad/client/state"
+	cstructs "github.com/hashicorp/nomad/client/structs"
 	ctestutil "github.com/hashicorp/nomad/client/testutil"
 	"github.com/hashicorp/nomad/client/vaultclient"
 	agentconsul "github.com/hashicorp/nomad/command/agent/consul"
@@ -1799,3 +1801,120 @@ func testWaitForTaskToStart(t *testing.T, tr *TaskRunner) {
 		require.NoError(t, err)
 	})
 }
+
+// TestTaskRunner_UpdateStats_CounterEpoch asserts resource usage samples are
+// stamped with the number of task restarts.
+func TestTaskRunner_UpdateStats_CounterEpoch(t *testing.T) {
+	t.Parallel()
+
+	alloc := mock.BatchAlloc()
+	task := alloc.Job.TaskGroups[0].Tasks[0]
+
+	conf, cleanup := testTaskRunnerConfig(t, alloc, task.Name)
+	defer cleanup()
+
+	tr, err := NewTaskRunner(conf)
+	require.NoError(t, err)
+
+	newUsage := func() *cstructs.TaskResourceUsage {
+		return &cstructs.TaskResourceUsage{
+			ResourceUsage: &cstructs.ResourceUsage{
+				MemoryStats: &cstructs.MemoryStats{},
+				CpuStats:    &cstructs.CpuStats{},
+			},
+		}
+	}
+
+	tr.UpdateStats(newUsage())
+	require.Zero(t, tr.LatestResourceUsage().CounterEpoch)
+
+	tr.EmitEvent(structs.NewTaskEvent(structs.TaskRestarting))
+	tr.UpdateStats(newUsage())
+	require.Equal(t, uint64(1), tr.LatestResourceUsage().CounterEpoch)
+}
+
+// TestTaskRunner_CgroupConfig_Cached asserts the cgroup configuration of the
+// task is only read once per PID.
+func TestTaskRunner_CgroupConfig_Cached(t *testing.T) {
+	t.Parallel()
+
+	if _, err := cgutil.ReadConfig(os.Getpid()); err != nil {
+		t.Skipf("cgroups unavailable: %v", err)
+	}
+
+	tr := &TaskRunner{}
+	first, err := tr.cgroupConfig(os.Getpid())
+	require.NoError(t, err)
+
+	second, err := tr.cgroupConfig(os.Getpid())
+	require.NoError(t, err)
+	require.True(t, first == second, "expected the cached configuration")
+
+	// A new PID reads the configuration again
+	other, err := tr.cgroupConfig(os.Getppid())
+	require.NoError(t, err)
+	require.False(t, first == other, "expected a new configuration")
+}
+
+// TestTaskRunner_UpdateStats_DriverStatsUnavailable asserts the last memory
+// and CPU stats are kept when the driver doesn't return stats in time.
+func TestTaskRunner_UpdateStats_DriverStatsUnavailable(t *testing.T) {
+	t.Parallel()
+
+	alloc := mock.BatchAlloc()
+	task := alloc.Job.TaskGroups[0].Tasks[0]
+
+	conf, cleanup := testTaskRunnerConfig(t, alloc, task.Name)
+	defer cleanup()
+
+	tr, err := NewTaskRunner(conf)
+	require.NoError(t, err)
+
+	tr.UpdateStats(&cstructs.TaskResourceUsage{
+		ResourceUsage: &cstructs.ResourceUsage{
+			MemoryStats: &cstructs.MemoryStats{RSS: 1024, Usage: 2048},
+			CpuStats:    &cstructs.CpuStats{TotalTicks: 100},
+		},
+		Timestamp: 1,
+	})
+
+	// The driver timed out
+	tr.UpdateStats(&cstructs.TaskResourceUsage{
+		ResourceUsage:          &cstructs.ResourceUsage{},
+		Timestamp:              2,
+		DriverStatsUnavailable: true,
+	})
+
+	usage := tr.LatestResourceUsage()
+	require.True(t, usage.DriverStatsUnavailable)
+	require.Equal(t, int64(2), usage.Timestamp)
+	require.Equal(t, uint64(1024), usage.ResourceUsage.MemoryStats.RSS)
+	require.Equal(t, uint64(2048), usage.ResourceUsage.MemoryStats.Usage)
+	require.Equal(t, float64(100), usage.ResourceUsage.CpuStats.TotalTicks)
+}
+
+func TestTaskRunner_UpdateStats_MemoryLimits(t *testing.T) {
+	t.Parallel()
+
+	alloc := mock.BatchAlloc()
+	task := alloc.Job.TaskGroups[0].Tasks[0]
+	task.Resources.MemoryMB = 256
+
+	conf, cleanup := testTaskRunnerConfig(t, alloc, task.Name)
+	defer cleanup()
+
+	tr, err := NewTaskRunner(conf)
+	require.NoError(t, err)
+
+	tr.UpdateStats(&cstructs.TaskResourceUsage{
+		ResourceUsage: &cstructs.ResourceUsage{
+			MemoryStats: &cstructs.MemoryStats{},
+			CpuStats:    &cstructs.CpuStats{},
+		},
+	})
+
+	// The task isn't running so no cgroup limit can be read
+	usage := tr.LatestResourceUsage()
+	require.Equal(t, uint64(256*1024*1024), usage.MemoryReserved)
+	require.Zero(t, usage.MemoryMaxLimit)
+}
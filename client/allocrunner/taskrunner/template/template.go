@@ -3,9 +3,11 @@ package template
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -701,3 +703,50 @@ func loadTemplateEnv(tmpls []*structs.Template, taskDir string, taskEnv *taskenv
 	}
 	return all, nil
 }
+
+// DestinationPath returns the host path the template is rendered to.
+func DestinationPath(tmpl *structs.Template, taskDir string, taskEnv *taskenv.TaskEnv) string {
+	return filepath.Join(taskDir, taskEnv.ReplaceEnv(tmpl.DestPath))
+}
+
+// ReadsSecrets returns whether the template calls the Vault secret or secrets
+// functions. Templates sourced from a file are inspected on the host.
+func ReadsSecrets(tmpl *structs.Template, taskDir string, taskEnv *taskenv.TaskEnv) (bool, error) {
+	contents := tmpl.EmbeddedTmpl
+	if tmpl.SourcePath != "" {
+		src := tmpl.SourcePath
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(taskDir, taskEnv.ReplaceEnv(src))
+		}
+
+		raw, err := ioutil.ReadFile(src)
+		if err != nil {
+			return false, fmt.Errorf("failed to read template source %q: %v", tmpl.SourcePath, err)
+		}
+		contents = string(raw)
+	}
+
+	left, right := tmpl.LeftDelim, tmpl.RightDelim
+	if left == "" {
+		left = "{{"
+	}
+	if right == "" {
+		right = "}}"
+	}
+
+	// Look for the functions in each action of the template
+	actions := strings.Split(contents, left)
+	for _, action := range actions[1:] {
+		if i := strings.Index(action, right); i != -1 {
+			action = action[:i]
+		}
+		if secretFuncRe.MatchString(action) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// secretFuncRe matches calls to the Vault secret and secrets functions
+var secretFuncRe = regexp.MustCompile(`(^|[\s(|])secrets?(\s|$)`)
@@ -1382,3 +1382,37 @@ func TestTaskTemplateManager_BlockedEvents(t *testing.T) {
 		t.Fatalf("bad event: %q", eventMsg)
 	}
 }
+
+func TestTaskTemplateManager_ReadsSecrets(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	d, err := ioutil.TempDir("", "ct_reads_secrets")
+	require.NoError(err)
+	defer os.RemoveAll(d)
+
+	env := taskenv.NewEmptyTaskEnv()
+	cases := []struct {
+		tmpl    *structs.Template
+		secrets bool
+	}{
+		{&structs.Template{EmbeddedTmpl: `{{ with secret "secret/foo" }}{{ .Data.value }}{{ end }}`}, true},
+		{&structs.Template{EmbeddedTmpl: `{{range secrets "secret/"}}{{.}}{{end}}`}, true},
+		{&structs.Template{EmbeddedTmpl: `{{ key "secret" }} secret`}, false},
+		{&structs.Template{EmbeddedTmpl: `[[ with secret "a" ]][[ end ]]`, LeftDelim: "[[", RightDelim: "]]"}, true},
+		{&structs.Template{EmbeddedTmpl: `{{ with secret "a" }}{{ end }}`, LeftDelim: "[[", RightDelim: "]]"}, false},
+	}
+	for _, c := range cases {
+		secrets, err := ReadsSecrets(c.tmpl, d, env)
+		require.NoError(err)
+		require.Equal(c.secrets, secrets, c.tmpl.EmbeddedTmpl)
+	}
+
+	// Templates sourced from a file are read from the task dir
+	require.NoError(ioutil.WriteFile(filepath.Join(d, "src.tmpl"), []byte(`{{ secret "a" }}`), 0644))
+	secrets, err := ReadsSecrets(&structs.Template{SourcePath: "src.tmpl"}, d, env)
+	require.NoError(err)
+	require.True(secrets)
+
+	_, err = ReadsSecrets(&structs.Template{SourcePath: "missing.tmpl"}, d, env)
+	require.Error(err)
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -28,6 +29,9 @@ import (
 	consulApi "github.com/hashicorp/nomad/client/consul"
 	"github.com/hashicorp/nomad/client/devicemanager"
 	"github.com/hashicorp/nomad/client/fingerprint"
+	"github.com/hashicorp/nomad/client/lib/execaudit"
+	"github.com/hashicorp/nomad/client/lib/streamlimit"
+	"github.com/hashicorp/nomad/client/lib/streamsession"
 	"github.com/hashicorp/nomad/client/pluginmanager"
 	"github.com/hashicorp/nomad/client/pluginmanager/drivermanager"
 	"github.com/hashicorp/nomad/client/servers"
@@ -44,6 +48,7 @@ import (
 	"github.com/hashicorp/nomad/nomad/structs"
 	nconfig "github.com/hashicorp/nomad/nomad/structs/config"
 	"github.com/hashicorp/nomad/plugins/device"
+	"github.com/hashicorp/nomad/plugins/drivers"
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/shirou/gopsutil/host"
 )
@@ -124,6 +129,20 @@ type AllocRunner interface {
 	DestroyCh() <-chan struct{}
 	ShutdownCh() <-chan struct{}
 	GetTaskEventHandler(taskName string) drivermanager.EventHandler
+	TaskPID(taskName string) (int, error)
+	TaskArtifactProgress(taskName string) (*cstructs.TaskArtifactProgress, error)
+	CancelTaskArtifactDownload(taskName string) error
+	TaskDiagnose(taskName string) (*cstructs.TaskDiagnosis, error)
+	SignalTask(taskName, signal string) error
+	TaskExec(taskName string, timeout time.Duration, cmd []string) (*drivers.ExecTaskResult, error)
+	SetTaskLogRotation(taskName string, rotation *structs.LogConfig) error
+	RotateTaskLogs(taskName, logType string) (string, error)
+	TaskRenderedTemplate(taskName, dest string) ([]byte, bool, error)
+	TaskStatsDebug(ctx context.Context, taskName string) (*cstructs.TaskStatsDebug, error)
+	TaskStatsDiff(ctx context.Context, taskName string, window time.Duration) (*cstructs.TaskStatsDiff, error)
+	DestroyAllocDir(ctx context.Context, progress func(freed int64)) error
+	CollectTask(ctx context.Context, taskName string, progress func(freed int64)) error
+	LifecycleEvents() *cstructs.AllocLifecycleBroadcaster
 }
 
 // Client is used to implement the client interaction with Nomad. Clients
@@ -189,6 +208,10 @@ type Client struct {
 	// allocUpdates stores allocations that need to be synced to the server.
 	allocUpdates chan *structs.Allocation
 
+	// allocDecisions sends the decisions made while reconciling allocations
+	// with the servers
+	allocDecisions *cstructs.AllocDecisionBroadcaster
+
 	// consulService is Nomad's custom Consul client for managing services
 	// and checks.
 	consulService consulApi.ConsulServiceAPI
@@ -227,6 +250,19 @@ type Client struct {
 	endpoints     rpcEndpoints
 	streamingRpcs *structs.StreamingRpcRegistry
 
+	// streamLimiter caps the number of concurrent streaming RPCs. Every
+	// streaming RPC must hold a slot while it streams.
+	streamLimiter *streamlimit.Limiter
+
+	// logStreamLimiter caps the number of concurrent log streams per task
+	logStreamLimiter *streamlimit.KeyedLimiter
+
+	// streamSessions tracks the active streaming RPC sessions
+	streamSessions *streamsession.Registry
+
+	// execAudit records the commands executed in tasks
+	execAudit *execaudit.Log
+
 	// pluginManagers is the set of PluginManagers registered by the client
 	pluginManagers *pluginmanager.PluginGroup
 
@@ -253,6 +289,10 @@ var (
 	// configured servers. This is used to trigger Consul discovery if
 	// enabled.
 	noServersErr = errors.New("no servers")
+
+	// allocLookupTimeoutErr is returned when the allocations can't be looked
+	// up within the configured AllocLookupTimeout.
+	allocLookupTimeoutErr = errors.New("client busy looking up allocations, retry later")
 )
 
 // NewClient is used to create a new client from the given configuration
@@ -286,6 +326,9 @@ func NewClient(cfg *config.Config, consulCatalog consul.CatalogAPI, consulServic
 		connPool:             pool.NewPool(logger, clientRPCCache, clientMaxStreams, tlsWrap),
 		tlsWrap:              tlsWrap,
 		streamingRpcs:        structs.NewStreamingRpcRegistry(),
+		streamLimiter:        streamlimit.NewLimiter(cfg.MaxConcurrentStreams, cfg.StreamQueueTimeout),
+		logStreamLimiter:     streamlimit.NewKeyedLimiter(cfg.MaxLogStreamsPerTask),
+		streamSessions:       streamsession.NewRegistry(),
 		logger:               logger,
 		rpcLogger:            logger.Named("rpc"),
 		allocs:               make(map[string]AllocRunner),
@@ -296,6 +339,7 @@ func NewClient(cfg *config.Config, consulCatalog consul.CatalogAPI, consulServic
 		triggerEmitNodeEvent: make(chan *structs.NodeEvent, 8),
 		fpInitialized:        make(chan struct{}),
 		invalidAllocs:        make(map[string]struct{}),
+		allocDecisions:       cstructs.NewAllocDecisionBroadcaster(),
 	}
 
 	c.batchNodeUpdates = newBatchNodeUpdates(
@@ -384,6 +428,7 @@ func NewClient(cfg *config.Config, consulCatalog consul.CatalogAPI, consulServic
 	// Add the garbage collector
 	gcConfig := &GCConfig{
 		MaxAllocs:           cfg.GCMaxAllocs,
+		MinAllocRetention:   cfg.GCMinAllocRetention,
 		DiskUsageThreshold:  cfg.GCDiskUsageThreshold,
 		InodeUsageThreshold: cfg.GCInodeUsageThreshold,
 		Interval:            cfg.GCInterval,
@@ -525,6 +570,13 @@ func (c *Client) init() error {
 	}
 
 	c.logger.Info("using alloc directory", "alloc_dir", c.config.AllocDir)
+
+	// Open the exec audit log last so it isn't left open if a step above fails
+	execAudit, err := execaudit.Open(filepath.Join(c.config.StateDir, "exec-audit.log"))
+	if err != nil {
+		return err
+	}
+	c.execAudit = execAudit
 	return nil
 }
 
@@ -670,6 +722,9 @@ func (c *Client) Shutdown() error {
 
 	// One final save state
 	c.saveState()
+	if err := c.execAudit.Close(); err != nil {
+		c.logger.Error("error closing exec audit log", "error", err)
+	}
 	return c.stateDB.Close()
 }
 
@@ -697,6 +752,30 @@ func (c *Client) CollectAllocation(allocID string) bool {
 	return c.garbageCollector.Collect(allocID)
 }
 
+// CollectAllocationContext garbage collects a single allocation on a node,
+// calling progress with the number of bytes freed so far. Returns true if the
+// alloc was found. A canceled collection returns the context's error and
+// leaves the alloc marked for collection.
+func (c *Client) CollectAllocationContext(ctx context.Context, allocID string, progress func(freed int64)) (bool, error) {
+	return c.garbageCollector.CollectContext(ctx, allocID, progress)
+}
+
+// AllocMarkedForCollection returns true if the allocation is terminal and
+// marked for garbage collection.
+func (c *Client) AllocMarkedForCollection(allocID string) bool {
+	return c.garbageCollector.IsMarked(allocID)
+}
+
+// WouldCollectAllocs maps the allocations marked for collection to the reason
+// a garbage collection would collect them if the garbage collector's config
+// was changed by override. The reason is empty for the allocations that would
+// be kept. The live config is not modified.
+func (c *Client) WouldCollectAllocs(override func(config *GCConfig)) (map[string]string, error) {
+	config := c.garbageCollector.Config()
+	override(&config)
+	return c.garbageCollector.WouldCollect(&config)
+}
+
 // CollectAllAllocs garbage collects all allocations on a node in the terminal
 // state
 func (c *Client) CollectAllAllocs() {
@@ -722,6 +801,39 @@ func (c *Client) getAllocRunner(allocID string) (AllocRunner, error) {
 	return ar, nil
 }
 
+// lookupAllocRunner returns the alloc runner like getAllocRunner but gives up
+// with a retryable error if the allocations can't be accessed within the
+// configured AllocLookupTimeout. Without a timeout it waits indefinitely.
+func (c *Client) lookupAllocRunner(allocID string) (AllocRunner, error) {
+	timeout := c.config.AllocLookupTimeout
+	if timeout <= 0 {
+		return c.getAllocRunner(allocID)
+	}
+
+	type result struct {
+		ar  AllocRunner
+		err error
+	}
+
+	// The lookup completes in the background if it times out
+	resultCh := make(chan result, 1)
+	go func() {
+		ar, err := c.getAllocRunner(allocID)
+		resultCh <- result{ar, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-resultCh:
+		return r.ar, r.err
+	case <-timer.C:
+		metrics.IncrCounter([]string{"client", "allocs", "lookup_timeout"}, 1)
+		return nil, allocLookupTimeoutErr
+	}
+}
+
 // StatsReporter exposes the various APIs related resource usage of a Nomad
 // client
 func (c *Client) StatsReporter() ClientStatsReporter {
@@ -729,7 +841,7 @@ func (c *Client) StatsReporter() ClientStatsReporter {
 }
 
 func (c *Client) GetAllocStats(allocID string) (interfaces.AllocStatsReporter, error) {
-	ar, err := c.getAllocRunner(allocID)
+	ar, err := c.lookupAllocRunner(allocID)
 	if err != nil {
 		return nil, err
 	}
@@ -958,6 +1070,7 @@ func (c *Client) restoreState() error {
 			PrevAllocMigrator:   prevAllocMigrator,
 			DeviceManager:       c.devicemanager,
 			DriverManager:       c.drivermanager,
+			Decisions:           c.allocDecisions,
 		}
 		c.configLock.RUnlock()
 
@@ -995,6 +1108,13 @@ func (c *Client) restoreState() error {
 
 func (c *Client) handleInvalidAllocs(alloc *structs.Allocation, err error) {
 	c.invalidAllocs[alloc.ID] = struct{}{}
+	c.allocDecisions.Send(&cstructs.AllocDecision{
+		AllocID:  alloc.ID,
+		Decision: cstructs.AllocDecisionReject,
+		Reason:   fmt.Sprintf("failed to run allocation: %v", err),
+		Update:   cstructs.NewAllocServerUpdate(alloc),
+	})
+
 	// Mark alloc as failed so server can handle this
 	failed := makeFailedAlloc(alloc, err)
 	select {
@@ -2040,6 +2160,11 @@ func (c *Client) removeAlloc(allocID string) {
 
 	// Stop tracking alloc runner as it's been GC'd by the server
 	delete(c.allocs, allocID)
+	c.allocDecisions.Send(&cstructs.AllocDecision{
+		AllocID:  allocID,
+		Decision: cstructs.AllocDecisionRemove,
+		Reason:   "allocation removed by the servers",
+	})
 
 	// Ensure the GC has a reference and then collect. Collecting through the GC
 	// applies rate limiting
@@ -2062,10 +2187,37 @@ func (c *Client) updateAlloc(update *structs.Allocation) {
 		c.logger.Error("error persisting updated alloc locally", "error", err, "alloc_id", update.ID)
 	}
 
+	decision, reason := updateDecision(ar.Alloc(), update)
+	c.allocDecisions.Send(&cstructs.AllocDecision{
+		AllocID:  update.ID,
+		Decision: decision,
+		Reason:   reason,
+		Update:   cstructs.NewAllocServerUpdate(update),
+	})
+
 	// Update alloc runner
 	ar.Update(update)
 }
 
+// updateDecision returns the decision to apply an update of an allocation
+// from the servers and its reason.
+func updateDecision(existing, update *structs.Allocation) (string, string) {
+	switch {
+	case update.ServerTerminalStatus() && !existing.ServerTerminalStatus():
+		reason := fmt.Sprintf("server marked desired=%s", update.DesiredStatus)
+		if update.DesiredDescription != "" {
+			reason = fmt.Sprintf("%s: %s", reason, update.DesiredDescription)
+		}
+		return cstructs.AllocDecisionStop, reason
+	case update.DesiredTransition.ShouldMigrate() && !existing.DesiredTransition.ShouldMigrate():
+		return cstructs.AllocDecisionMigrate, "node draining: server marked the allocation for migration"
+	case update.Job != nil && existing.Job != nil && update.Job.Version != existing.Job.Version:
+		return cstructs.AllocDecisionUpdate, fmt.Sprintf("job updated from version %d to %d", existing.Job.Version, update.Job.Version)
+	default:
+		return cstructs.AllocDecisionUpdate, "allocation updated by the servers"
+	}
+}
+
 // addAlloc is invoked when we should add an allocation
 func (c *Client) addAlloc(alloc *structs.Allocation, migrateToken string) error {
 	c.allocLock.Lock()
@@ -2122,6 +2274,7 @@ func (c *Client) addAlloc(alloc *structs.Allocation, migrateToken string) error
 		PrevAllocMigrator:   prevAllocMigrator,
 		DeviceManager:       c.devicemanager,
 		DriverManager:       c.drivermanager,
+		Decisions:           c.allocDecisions,
 	}
 	c.configLock.RUnlock()
 
@@ -2133,6 +2286,17 @@ func (c *Client) addAlloc(alloc *structs.Allocation, migrateToken string) error
 	// Store the alloc runner.
 	c.allocs[alloc.ID] = ar
 
+	reason := "allocation placed by the servers"
+	if alloc.PreviousAllocation != "" {
+		reason = fmt.Sprintf("%s replacing allocation %s", reason, alloc.PreviousAllocation)
+	}
+	c.allocDecisions.Send(&cstructs.AllocDecision{
+		AllocID:  alloc.ID,
+		Decision: cstructs.AllocDecisionRun,
+		Reason:   reason,
+		Update:   cstructs.NewAllocServerUpdate(alloc),
+	})
+
 	go ar.Run()
 	return nil
 }
@@ -2493,6 +2657,8 @@ func (c *Client) setGaugeForDiskStats(nodeID string, hStats *stats.HostStats) {
 			metrics.SetGaugeWithLabels([]string{"client", "host", "disk", "available"}, float32(disk.Available), labels)
 			metrics.SetGaugeWithLabels([]string{"client", "host", "disk", "used_percent"}, float32(disk.UsedPercent), labels)
 			metrics.SetGaugeWithLabels([]string{"client", "host", "disk", "inodes_percent"}, float32(disk.InodesUsedPercent), labels)
+			metrics.SetGaugeWithLabels([]string{"client", "host", "disk", "inodes_used"}, float32(disk.InodesUsed), labels)
+			metrics.SetGaugeWithLabels([]string{"client", "host", "disk", "inodes_total"}, float32(disk.InodesTotal), labels)
 		}
 
 		if c.config.BackwardsCompatibleMetrics {
@@ -2501,6 +2667,8 @@ func (c *Client) setGaugeForDiskStats(nodeID string, hStats *stats.HostStats) {
 			metrics.SetGauge([]string{"client", "host", "disk", nodeID, disk.Device, "available"}, float32(disk.Available))
 			metrics.SetGauge([]string{"client", "host", "disk", nodeID, disk.Device, "used_percent"}, float32(disk.UsedPercent))
 			metrics.SetGauge([]string{"client", "host", "disk", nodeID, disk.Device, "inodes_percent"}, float32(disk.InodesUsedPercent))
+			metrics.SetGauge([]string{"client", "host", "disk", nodeID, disk.Device, "inodes_used"}, float32(disk.InodesUsed))
+			metrics.SetGauge([]string{"client", "host", "disk", nodeID, disk.Device, "inodes_total"}, float32(disk.InodesTotal))
 		}
 	}
 }
@@ -1,11 +1,20 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
 	"time"
 
 	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/nomad/client/lib/execaudit"
 	"github.com/hashicorp/nomad/client/structs"
+	"github.com/hashicorp/nomad/helper"
 	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/ugorji/go/codec"
 )
 
 // ClientStats endpoint is used for retrieving stats about a client
@@ -13,6 +22,12 @@ type ClientStats struct {
 	c *Client
 }
 
+func NewClientStatsEndpoint(c *Client) *ClientStats {
+	s := &ClientStats{c}
+	s.c.streamingRpcs.Register("ClientStats.StatsStream", s.statsStream)
+	return s
+}
+
 // Stats is used to retrieve the Clients stats.
 func (s *ClientStats) Stats(args *nstructs.NodeSpecificRequest, reply *structs.ClientStatsResponse) error {
 	defer metrics.MeasureSince([]string{"client", "client_stats", "stats"}, time.Now())
@@ -28,3 +43,231 @@ func (s *ClientStats) Stats(args *nstructs.NodeSpecificRequest, reply *structs.C
 	reply.HostStats = clientStats.LatestHostStats()
 	return nil
 }
+
+// ListStreams is used to list the active streaming sessions of the client. It
+// requires a management token.
+func (s *ClientStats) ListStreams(args *nstructs.NodeSpecificRequest, reply *structs.ClientStreamsResponse) error {
+	defer metrics.MeasureSince([]string{"client", "client_stats", "list_streams"}, time.Now())
+
+	// Check management permissions
+	if aclObj, err := s.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.IsManagement() {
+		return nstructs.ErrPermissionDenied
+	}
+
+	now := time.Now()
+	sessions := s.c.streamSessions.List()
+	reply.Streams = make([]*structs.StreamSession, 0, len(sessions))
+	for _, session := range sessions {
+		reply.Streams = append(reply.Streams, &structs.StreamSession{
+			ID:           session.ID,
+			Method:       session.Method,
+			AllocID:      session.AllocID,
+			Task:         session.Task,
+			AccessorID:   session.AccessorID,
+			StartedAt:    session.StartedAt,
+			Age:          now.Sub(session.StartedAt),
+			BytesRead:    session.BytesRead,
+			BytesWritten: session.BytesWritten,
+		})
+	}
+	return nil
+}
+
+// ExecAudit is used to query the log of the commands executed in the tasks of
+// the client. It requires a management token.
+func (s *ClientStats) ExecAudit(args *structs.ClientExecAuditRequest, reply *structs.ClientExecAuditResponse) error {
+	defer metrics.MeasureSince([]string{"client", "client_stats", "exec_audit"}, time.Now())
+
+	// Check management permissions
+	if aclObj, err := s.c.ResolveToken(args.AuthToken); err != nil {
+		return err
+	} else if aclObj != nil && !aclObj.IsManagement() {
+		return nstructs.ErrPermissionDenied
+	}
+
+	if args.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+
+	entries, err := s.c.execAudit.Query(&execaudit.Filter{
+		AllocID: args.AllocID,
+		Task:    args.Task,
+		Since:   args.Since,
+		Limit:   args.Limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	reply.Entries = entries
+	return nil
+}
+
+// statsStream streams the host stats and the stats of the allocations of the
+// client on an interval. Each interval a host frame is sent followed by a
+// frame per allocation.
+func (s *ClientStats) statsStream(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "client_stats", "stats_stream"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req structs.ClientStatsStreamRequest
+	decoder := codec.NewDecoder(conn, nstructs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, nstructs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check node read permissions
+	if aclObj, err := s.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil && !aclObj.AllowNodeRead() {
+		handleStreamResultError(nstructs.ErrPermissionDenied, nil, encoder)
+		return
+	}
+
+	s.c.setStreamTarget(conn, "", "", req.QueryOptions.AuthToken)
+
+	interval := req.Interval
+	if interval < 0 {
+		handleStreamResultError(errors.New("interval must not be negative"), helper.Int64ToPtr(400), encoder)
+		return
+	} else if interval == 0 {
+		interval = s.c.config.StatsCollectionInterval
+	}
+
+	// Wait for a stream slot. Node wide streams are queued with the default
+	// namespace.
+	release, err := s.c.acquireStream(conn, nstructs.DefaultNamespace)
+	if err != nil {
+		handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	buf := new(bytes.Buffer)
+	frameCodec := codec.NewEncoder(buf, nstructs.JsonHandle)
+	send := func(frame *structs.ClientStatsFrame) error {
+		if err := frameCodec.Encode(frame); err != nil {
+			return err
+		}
+		frameCodec.Reset(buf)
+
+		resp := structs.StreamErrWrapper{Payload: buf.Bytes()}
+		err := encoder.Encode(resp)
+		buf.Reset()
+		if err != nil {
+			return err
+		}
+		encoder.Reset(conn)
+		return nil
+	}
+
+	var streamErr error
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+OUTER:
+	for {
+		if err := s.sendStats(&req, send); err != nil {
+			streamErr = err
+			break OUTER
+		}
+
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case <-ctx.Done():
+			break OUTER
+		case <-s.c.shutdownCh:
+			break OUTER
+		case <-ticker.C:
+		}
+	}
+
+	if streamErr != nil {
+		handleStreamResultError(streamErr, helper.Int64ToPtr(500), encoder)
+		return
+	}
+}
+
+// sendStats sends a host frame followed by a frame for each allocation
+// selected by the request, each stamped with the time its stats were
+// collected at. Allocations without stats, such as those in namespaces whose
+// stats collection is disabled, are skipped.
+func (s *ClientStats) sendStats(req *structs.ClientStatsStreamRequest, send func(*structs.ClientStatsFrame) error) error {
+	clientStats := s.c.StatsReporter()
+	host := &structs.ClientStatsFrame{
+		Type:      structs.StatsFrameHost,
+		HostStats: clientStats.LatestHostStats(),
+	}
+	if host.HostStats != nil {
+		host.Timestamp = host.HostStats.Timestamp
+		host.Monotonic = host.HostStats.Monotonic
+	}
+	if err := send(host); err != nil {
+		return err
+	}
+
+	runners := s.c.getAllocRunners()
+	allocIDs := req.AllocIDs
+	if len(allocIDs) == 0 {
+		for allocID := range runners {
+			allocIDs = append(allocIDs, allocID)
+		}
+		sort.Strings(allocIDs)
+	}
+
+	for _, allocID := range allocIDs {
+		ar, ok := runners[allocID]
+		if !ok || s.c.config.StatsDisabled(ar.Alloc().Namespace) {
+			continue
+		}
+
+		stats, err := ar.StatsReporter().LatestAllocStats("")
+		if err != nil {
+			s.c.logger.Debug("failed to get alloc stats", "alloc_id", allocID, "error", err)
+			continue
+		}
+		stripCumulative(stats)
+
+		frame := &structs.ClientStatsFrame{
+			Type:       structs.StatsFrameAlloc,
+			AllocID:    allocID,
+			AllocStats: stats,
+			Timestamp:  stats.Timestamp,
+			Monotonic:  stats.Monotonic,
+		}
+		if err := send(frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,14 +1,22 @@
 package client
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/nomad/acl"
 	"github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/nomad/mock"
 	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/testutil"
 	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
 )
 
 func TestClientStats_Stats(t *testing.T) {
@@ -84,3 +92,393 @@ func TestClientStats_Stats_ACL(t *testing.T) {
 		require.NotNil(resp.HostStats)
 	}
 }
+
+// startStatsStream starts a stats stream on the client, returning a channel of
+// the received messages.
+func startStatsStream(t *testing.T, client *Client, req *structs.ClientStatsStreamRequest) (<-chan *structs.StreamErrWrapper, <-chan error, func()) {
+	handler, err := client.StreamingRpcHandler("ClientStats.StatsStream")
+	require.Nil(t, err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+
+	errCh := make(chan error, 1)
+	streamMsg := make(chan *structs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, nstructs.MsgpackHandle)
+		for {
+			var msg structs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+				return
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	encoder := codec.NewEncoder(p1, nstructs.MsgpackHandle)
+	require.Nil(t, encoder.Encode(req))
+
+	return streamMsg, errCh, func() {
+		p1.Close()
+		p2.Close()
+	}
+}
+
+func TestClientStats_StatsStream(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	other := mock.Alloc()
+	other.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	other.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(other, ""))
+
+	// Only stream the stats of the first alloc
+	req := &structs.ClientStatsStreamRequest{
+		Interval:     100 * time.Millisecond,
+		AllocIDs:     []string{a.ID},
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	streamMsg, errCh, stop := startStatsStream(t, client, req)
+	defer stop()
+
+	hostFrames, allocFrames := 0, 0
+	var lastHost, lastAlloc int64
+	timeout := time.After(10 * time.Second)
+	for hostFrames < 2 || allocFrames < 2 {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout, received %d host and %d alloc frames", hostFrames, allocFrames)
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var frame structs.ClientStatsFrame
+			require.NoError(json.Unmarshal(msg.Payload, &frame))
+
+			// Frames are stamped with the time their stats were collected
+			switch frame.Type {
+			case structs.StatsFrameHost:
+				require.NotNil(frame.HostStats)
+				require.Equal(frame.HostStats.Timestamp, frame.Timestamp)
+				require.Equal(frame.HostStats.Monotonic, frame.Monotonic)
+				require.True(frame.Monotonic >= lastHost)
+				lastHost = frame.Monotonic
+				hostFrames++
+			case structs.StatsFrameAlloc:
+				require.Equal(a.ID, frame.AllocID)
+				require.NotNil(frame.AllocStats)
+				require.Equal(frame.AllocStats.Timestamp, frame.Timestamp)
+				require.Equal(frame.AllocStats.Monotonic, frame.Monotonic)
+				require.True(frame.Monotonic >= lastAlloc)
+				lastAlloc = frame.Monotonic
+				allocFrames++
+			default:
+				t.Fatalf("unexpected frame type %q", frame.Type)
+			}
+		}
+	}
+}
+
+func TestClientStats_StatsStream_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, _ := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         mock.CreatePolicyAndToken(t, server.State(), 1005, "invalid", mock.NodePolicy(acl.PolicyDeny)).SecretID,
+			ExpectedError: nstructs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:  "good token",
+			Token: mock.CreatePolicyAndToken(t, server.State(), 1007, "valid", mock.NodePolicy(acl.PolicyRead)).SecretID,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			req := &structs.ClientStatsStreamRequest{
+				QueryOptions: nstructs.QueryOptions{
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+			streamMsg, errCh, stop := startStatsStream(t, client, req)
+			defer stop()
+
+			select {
+			case <-time.After(10 * time.Second):
+				t.Fatal("timeout")
+			case err := <-errCh:
+				t.Fatal(err)
+			case msg := <-streamMsg:
+				if c.ExpectedError == "" {
+					require.Nil(msg.Error)
+					var frame structs.ClientStatsFrame
+					require.NoError(json.Unmarshal(msg.Payload, &frame))
+					require.Equal(structs.StatsFrameHost, frame.Type)
+				} else {
+					require.NotNil(msg.Error)
+					require.Contains(msg.Error.Error(), c.ExpectedError)
+				}
+			}
+		})
+	}
+}
+
+func TestClientStats_ListStreams(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	req := &nstructs.NodeSpecificRequest{}
+	var resp structs.ClientStreamsResponse
+	require.Nil(client.ClientRPC("ClientStats.ListStreams", &req, &resp))
+	require.Empty(resp.Streams)
+
+	// Open a stream and wait for its first frame
+	streamReq := &structs.ClientStatsStreamRequest{
+		QueryOptions: nstructs.QueryOptions{Region: "global"},
+	}
+	streamMsg, errCh, stop := startStatsStream(t, client, streamReq)
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout")
+	case err := <-errCh:
+		t.Fatal(err)
+	case <-streamMsg:
+	}
+
+	resp = structs.ClientStreamsResponse{}
+	require.Nil(client.ClientRPC("ClientStats.ListStreams", &req, &resp))
+	require.Len(resp.Streams, 1)
+	stream := resp.Streams[0]
+	require.NotEmpty(stream.ID)
+	require.Equal("ClientStats.StatsStream", stream.Method)
+	require.NotZero(stream.BytesRead)
+	require.NotZero(stream.BytesWritten)
+	require.False(stream.StartedAt.IsZero())
+
+	// Closing the stream ends the session
+	stop()
+	testutil.WaitForResult(func() (bool, error) {
+		resp = structs.ClientStreamsResponse{}
+		if err := client.ClientRPC("ClientStats.ListStreams", &req, &resp); err != nil {
+			return false, err
+		}
+		if len(resp.Streams) != 0 {
+			return false, fmt.Errorf("expected no streams, got %d", len(resp.Streams))
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestClientStats_ListStreams_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Try request with a node token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "node", mock.NodePolicy(acl.PolicyWrite))
+		req := &nstructs.NodeSpecificRequest{}
+		req.AuthToken = token.SecretID
+
+		var resp structs.ClientStreamsResponse
+		err := client.ClientRPC("ClientStats.ListStreams", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Open a stream with a node read token
+	token := mock.CreatePolicyAndToken(t, server.State(), 1007, "valid", mock.NodePolicy(acl.PolicyRead))
+	streamReq := &structs.ClientStatsStreamRequest{
+		QueryOptions: nstructs.QueryOptions{
+			Region:    "global",
+			AuthToken: token.SecretID,
+		},
+	}
+	streamMsg, errCh, stop := startStatsStream(t, client, streamReq)
+	defer stop()
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout")
+	case err := <-errCh:
+		t.Fatal(err)
+	case <-streamMsg:
+	}
+
+	// Try request with a management token and expect the stream's accessor
+	{
+		req := &nstructs.NodeSpecificRequest{}
+		req.AuthToken = root.SecretID
+
+		var resp structs.ClientStreamsResponse
+		require.Nil(client.ClientRPC("ClientStats.ListStreams", &req, &resp))
+		require.Len(resp.Streams, 1)
+		require.Equal(token.AccessorID, resp.Streams[0].AccessorID)
+	}
+}
+
+func TestClientStats_ExecAudit(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	req := &structs.ClientExecAuditRequest{}
+	var resp structs.ClientExecAuditResponse
+	require.Nil(client.ClientRPC("ClientStats.ExecAudit", &req, &resp))
+	require.Empty(resp.Entries)
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(client.addAlloc(a, ""))
+
+	// Run a command once the task is running
+	execReq := &structs.AllocExecOnceRequest{AllocID: a.ID, Task: "web", Cmd: []string{"echo", "hi"}}
+	testutil.WaitForResult(func() (bool, error) {
+		var execResp structs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &execReq, &execResp)
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	resp = structs.ClientExecAuditResponse{}
+	require.Nil(client.ClientRPC("ClientStats.ExecAudit", &req, &resp))
+	require.NotEmpty(resp.Entries)
+	entry := resp.Entries[len(resp.Entries)-1]
+	require.Equal(a.ID, entry.AllocID)
+	require.Equal(a.Namespace, entry.Namespace)
+	require.Equal("web", entry.Task)
+	require.Equal([]string{"echo", "hi"}, entry.Command)
+	require.Empty(entry.AccessorID)
+	require.Empty(entry.Error)
+	require.Zero(entry.ExitCode)
+	require.False(entry.StartTime.IsZero())
+	require.False(entry.EndTime.Before(entry.StartTime))
+
+	// Filter the entries
+	req.Task = "foo"
+	resp = structs.ClientExecAuditResponse{}
+	require.Nil(client.ClientRPC("ClientStats.ExecAudit", &req, &resp))
+	require.Empty(resp.Entries)
+
+	req.Task = ""
+	req.Limit = -1
+	err := client.ClientRPC("ClientStats.ExecAudit", &req, &resp)
+	require.EqualError(err, "limit must not be negative")
+}
+
+func TestClientStats_ExecAudit_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	server, addr, root := testACLServer(t, nil)
+	defer server.Shutdown()
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{addr}
+		c.ACLEnabled = true
+	})
+	defer cleanup()
+
+	// Register a running alloc with the server so the client keeps it
+	waitTilNodeReady(client, t)
+	a := mock.Alloc()
+	a.NodeID = client.NodeID()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	state := server.State()
+	require.NoError(state.UpsertJob(1001, a.Job))
+	require.NoError(state.UpsertJobSummary(1002, mock.JobSummary(a.JobID)))
+	require.NoError(state.UpsertAllocs(1003, []*nstructs.Allocation{a}))
+
+	// Try request with a node token and expect failure
+	{
+		token := mock.CreatePolicyAndToken(t, server.State(), 1005, "node", mock.NodePolicy(acl.PolicyWrite))
+		req := &structs.ClientExecAuditRequest{}
+		req.AuthToken = token.SecretID
+
+		var resp structs.ClientExecAuditResponse
+		err := client.ClientRPC("ClientStats.ExecAudit", &req, &resp)
+		require.NotNil(err)
+		require.EqualError(err, nstructs.ErrPermissionDenied.Error())
+	}
+
+	// Run a command with an alloc exec token
+	token := mock.CreatePolicyAndToken(t, server.State(), 1007, "valid",
+		mock.NamespacePolicy(nstructs.DefaultNamespace, "", []string{acl.NamespaceCapabilityAllocExec}))
+	execReq := &structs.AllocExecOnceRequest{AllocID: a.ID, Task: "web", Cmd: []string{"echo", "hi"}}
+	execReq.AuthToken = token.SecretID
+	execReq.Namespace = nstructs.DefaultNamespace
+	testutil.WaitForResult(func() (bool, error) {
+		var execResp structs.AllocExecOnceResponse
+		err := client.ClientRPC("Allocations.ExecOnce", &execReq, &execResp)
+		return err == nil, err
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Try request with a management token and expect the command's accessor
+	{
+		req := &structs.ClientExecAuditRequest{AllocID: a.ID}
+		req.AuthToken = root.SecretID
+
+		var resp structs.ClientExecAuditResponse
+		require.Nil(client.ClientRPC("ClientStats.ExecAudit", &req, &resp))
+		require.NotEmpty(resp.Entries)
+		require.Equal(token.AccessorID, resp.Entries[len(resp.Entries)-1].AccessorID)
+	}
+}
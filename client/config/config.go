@@ -178,6 +178,48 @@ type Config struct {
 	// before garbage collection is triggered.
 	GCMaxAllocs int
 
+	// GCMinAllocRetention is the minimum time terminal allocations are kept
+	// before being collected to stay below GCMaxAllocs. Collections caused by
+	// disk or inode usage ignore it.
+	GCMinAllocRetention time.Duration
+
+	// MaxConcurrentStreams is the maximum number of streaming RPCs served
+	// concurrently. Zero means no limit.
+	MaxConcurrentStreams int
+
+	// StreamQueueTimeout is how long a stream waits for a slot once
+	// MaxConcurrentStreams is reached. Zero rejects streams immediately.
+	StreamQueueTimeout time.Duration
+
+	// MaxLogStreamsPerTask is the maximum number of log streams served
+	// concurrently for a single task. Zero means no limit.
+	MaxLogStreamsPerTask int
+
+	// AllocLookupTimeout is how long alloc endpoints wait to look up an
+	// allocation before returning a retryable error. Zero waits indefinitely.
+	AllocLookupTimeout time.Duration
+
+	// MemoryUsageSemantics chooses the metric reported as the memory Usage of
+	// tasks. See the MemoryUsage constants in client/structs.
+	MemoryUsageSemantics string
+
+	// CpuAccounting chooses the convention of the CPU Percent of tasks. See
+	// the CpuAccounting constants in client/structs.
+	CpuAccounting string
+
+	// DisableStatsNamespaces are the namespaces whose allocations don't have
+	// their resource usage collected.
+	DisableStatsNamespaces []string
+
+	// StatsAdaptiveMaxInterval enables backing off the stats collection of
+	// idle tasks up to the given interval. Zero disables it.
+	StatsAdaptiveMaxInterval time.Duration
+
+	// CollectConnectionStats enables counting the TCP sockets of tasks with
+	// their own network namespace on every stats collection. Disabled by
+	// default as it parses the namespace's whole connection table.
+	CollectConnectionStats bool
+
 	// LogLevel is the level of the logs to putout
 	LogLevel string
 
@@ -225,6 +267,7 @@ func (c *Config) Copy() *Config {
 	*nc = *c
 	nc.Node = nc.Node.Copy()
 	nc.Servers = helper.CopySliceString(nc.Servers)
+	nc.DisableStatsNamespaces = helper.CopySliceString(nc.DisableStatsNamespaces)
 	nc.Options = helper.CopyMapStringString(nc.Options)
 	nc.ConsulConfig = c.ConsulConfig.Copy()
 	nc.VaultConfig = c.VaultConfig.Copy()
@@ -379,3 +422,14 @@ func (c *Config) NomadPluginConfig() *base.AgentConfig {
 		},
 	}
 }
+
+// StatsDisabled returns whether the resource usage of the allocations of the
+// namespace isn't collected.
+func (c *Config) StatsDisabled(namespace string) bool {
+	for _, ns := range c.DisableStatsNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
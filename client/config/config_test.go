@@ -34,3 +34,18 @@ func TestConfigReadDefault(t *testing.T) {
 		t.Errorf("Expected %s, found %s", expected, actual)
 	}
 }
+
+func TestConfigStatsDisabled(t *testing.T) {
+	config := Config{}
+	if config.StatsDisabled("default") {
+		t.Errorf("Expected stats to be enabled by default")
+	}
+
+	config.DisableStatsNamespaces = []string{"batch"}
+	if !config.StatsDisabled("batch") {
+		t.Errorf("Expected stats to be disabled for batch")
+	}
+	if config.StatsDisabled("default") {
+		t.Errorf("Expected stats to be enabled for default")
+	}
+}
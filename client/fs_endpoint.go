@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -32,6 +33,8 @@ var (
 	taskNotPresentErr    = fmt.Errorf("must provide task name")
 	logTypeNotPresentErr = fmt.Errorf("must provide log type (stdout/stderr)")
 	invalidOrigin        = fmt.Errorf("origin must be start or end")
+	invalidMaxLineLength = fmt.Errorf("max line length must not be negative")
+	invalidHeartbeat     = fmt.Errorf("heartbeat interval must be at least %v", minLogHeartbeatInterval)
 )
 
 const (
@@ -46,10 +49,23 @@ const (
 	// a closed connection without sending any additional data
 	streamHeartbeatRate = 1 * time.Second
 
+	// minLogHeartbeatInterval is the shortest interval at which followers can
+	// request heartbeats carrying the state of the log file
+	minLogHeartbeatInterval = 1 * time.Second
+
 	// streamBatchWindow is the window in which file content is batched before
 	// being flushed if the frame size has not been hit.
 	streamBatchWindow = 200 * time.Millisecond
 
+	// streamMinBatchWindow and streamMaxBatchWindow bound the batch window
+	// as it adapts to how fast the consumer reads frames.
+	streamMinBatchWindow = 50 * time.Millisecond
+	streamMaxBatchWindow = 1 * time.Second
+
+	// streamCompressThreshold is the size of frame data above which it is
+	// compressed for streams that asked for compression.
+	streamCompressThreshold = 1024
+
 	// nextLogCheckRate is the rate at which we check for a log entry greater
 	// than what we are watching for. This is to handle the case in which logs
 	// rotate faster than we can detect and we have to rely on a normal
@@ -61,6 +77,23 @@ const (
 	deleteEvent   = "file deleted"
 	truncateEvent = "file truncated"
 
+	// rotateEvent is sent when following logs moves on to the next log file.
+	// The frame holds the path and index of the new file.
+	rotateEvent = "file rotated"
+
+	// closeEvent is sent once all the logs of a task that exited have been
+	// streamed to a follower.
+	closeEvent = "close"
+
+	// heartbeatEvent is sent periodically to followers requesting it. The
+	// frame holds the path, size and modification time of the log file being
+	// written.
+	heartbeatEvent = "heartbeat"
+
+	// truncatedLineMarker replaces the end of log lines longer than the
+	// requested max line length.
+	truncatedLineMarker = "...[truncated]"
+
 	// OriginStart and OriginEnd are the available parameters for the origin
 	// argument when streaming a file. They respectively offset from the start
 	// and end of a file.
@@ -77,14 +110,19 @@ type FileSystem struct {
 func NewFileSystemEndpoint(c *Client) *FileSystem {
 	f := &FileSystem{c}
 	f.c.streamingRpcs.Register("FileSystem.Logs", f.logs)
+	f.c.streamingRpcs.Register("FileSystem.AllocLogs", f.allocLogs)
 	f.c.streamingRpcs.Register("FileSystem.Stream", f.stream)
 	return f
 }
 
+func (f *FileSystem) handleStreamResultError(err error, code *int64, encoder *codec.Encoder) {
+	handleStreamResultError(err, code, encoder)
+}
+
 // handleStreamResultError is a helper for sending an error with a potential
 // error code. The transmission of the error is ignored if the error has been
 // generated by the closing of the underlying transport.
-func (f *FileSystem) handleStreamResultError(err error, code *int64, encoder *codec.Encoder) {
+func handleStreamResultError(err error, code *int64, encoder *codec.Encoder) {
 	// Nothing to do as the conn is closed
 	if err == io.EOF || strings.Contains(err.Error(), "closed") {
 		return
@@ -168,6 +206,8 @@ func (f *FileSystem) stream(conn io.ReadWriteCloser) {
 		return
 	}
 
+	f.c.setStreamTarget(conn, req.AllocID, "", req.QueryOptions.AuthToken)
+
 	// Validate the arguments
 	if req.AllocID == "" {
 		f.handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
@@ -218,6 +258,14 @@ func (f *FileSystem) stream(conn io.ReadWriteCloser) {
 		}
 	}
 
+	// Wait for a stream slot
+	release, err := f.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		f.handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
 	frames := make(chan *sframer.StreamFrame, streamFramesBuffer)
 	errCh := make(chan error)
 	var buf bytes.Buffer
@@ -225,6 +273,10 @@ func (f *FileSystem) stream(conn io.ReadWriteCloser) {
 
 	// Create the framer
 	framer := sframer.NewStreamFramer(frames, streamHeartbeatRate, streamBatchWindow, streamFrameSize)
+	framer.SetAdaptive(streamMinBatchWindow, streamMaxBatchWindow)
+	if req.Compress && !req.PlainText {
+		framer.SetCompression(streamCompressThreshold)
+	}
 	framer.Run()
 	defer framer.Destroy()
 
@@ -345,6 +397,8 @@ func (f *FileSystem) logs(conn io.ReadWriteCloser) {
 		}
 	}
 
+	f.c.setStreamTarget(conn, req.AllocID, req.Task, req.QueryOptions.AuthToken)
+
 	// Validate the arguments
 	if req.AllocID == "" {
 		f.handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
@@ -368,6 +422,14 @@ func (f *FileSystem) logs(conn io.ReadWriteCloser) {
 		f.handleStreamResultError(invalidOrigin, helper.Int64ToPtr(400), encoder)
 		return
 	}
+	if req.MaxLineLength < 0 {
+		f.handleStreamResultError(invalidMaxLineLength, helper.Int64ToPtr(400), encoder)
+		return
+	}
+	if req.HeartbeatInterval != 0 && req.HeartbeatInterval < minLogHeartbeatInterval {
+		f.handleStreamResultError(invalidHeartbeat, helper.Int64ToPtr(400), encoder)
+		return
+	}
 
 	fs, err := f.c.GetAllocFS(req.AllocID)
 	if err != nil {
@@ -409,6 +471,52 @@ func (f *FileSystem) logs(conn io.ReadWriteCloser) {
 		return
 	}
 
+	// Following the logs of a task that already exited would block forever
+	// waiting for more output. Serve the persisted logs instead and end the
+	// stream with a close frame.
+	follow := req.Follow
+	exited := follow && req.AllowAfterExit && taskState.State == structs.TaskStateDead
+	if exited {
+		follow = false
+	}
+
+	// Seek to the log file written at the start time
+	if !req.StartTime.IsZero() {
+		entries, err := fs.List(filepath.Join(allocdir.SharedAllocName, allocdir.LogDirName))
+		if err != nil {
+			f.handleStreamResultError(fmt.Errorf("failed to list entries: %v", err), helper.Int64ToPtr(500), encoder)
+			return
+		}
+
+		offset, err := logOffsetAt(entries, req.StartTime, req.Task, req.LogType)
+		if err != nil {
+			code := helper.Int64ToPtr(500)
+			if _, ok := err.(notFoundErr); ok {
+				code = helper.Int64ToPtr(404)
+			}
+
+			f.handleStreamResultError(err, code, encoder)
+			return
+		}
+		req.Offset, req.Origin = offset, "start"
+	}
+
+	// Limit the number of streams reading the task's logs
+	releaseTask, err := f.acquireLogStream(req.AllocID, req.Task)
+	if err != nil {
+		f.handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer releaseTask()
+
+	// Wait for a stream slot
+	release, err := f.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		f.handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -417,7 +525,7 @@ func (f *FileSystem) logs(conn io.ReadWriteCloser) {
 
 	// Start streaming
 	go func() {
-		if err := f.logsImpl(ctx, req.Follow, req.PlainText,
+		if err := f.logsImpl(ctx, follow, req.PlainText,
 			req.Offset, req.Origin, req.Task, req.LogType, fs, frames); err != nil {
 			select {
 			case errCh <- err:
@@ -444,14 +552,90 @@ func (f *FileSystem) logs(conn io.ReadWriteCloser) {
 		}
 	}()
 
-	var streamErr error
+	var truncator *lineTruncator
+	if req.MaxLineLength > 0 {
+		truncator = &lineTruncator{max: req.MaxLineLength, delim: '\n'}
+		if req.Delimiter != nil {
+			truncator.delim = *req.Delimiter
+		}
+	}
+
+	var stripper *ansiStripper
+	if req.StripANSI {
+		stripper = &ansiStripper{}
+	}
+
 	buf := new(bytes.Buffer)
 	frameCodec := codec.NewEncoder(buf, structs.JsonHandle)
+	sendFrame := func(frame *sframer.StreamFrame) error {
+		if stripper != nil && len(frame.Data) > 0 {
+			frame.Data = stripper.Strip(frame.Data)
+
+			// Skip frames that only held escape sequences
+			if len(frame.Data) == 0 && frame.FileEvent == "" {
+				return nil
+			}
+		}
+
+		if truncator != nil && len(frame.Data) > 0 {
+			frame.Data = truncator.Truncate(frame.Data)
+
+			// Skip frames that only held the rest of a truncated line
+			if len(frame.Data) == 0 && frame.FileEvent == "" {
+				return nil
+			}
+		}
+
+		var resp cstructs.StreamErrWrapper
+		if req.PlainText {
+			resp.Payload = frame.Data
+		} else {
+			// Compress once the lines have been rewritten above
+			if req.Compress {
+				frame.Compress(streamCompressThreshold)
+			}
+
+			if err := frameCodec.Encode(frame); err != nil {
+				return err
+			}
+			frameCodec.Reset(buf)
+
+			resp.Payload = buf.Bytes()
+			buf.Reset()
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+		encoder.Reset(conn)
+		return nil
+	}
+
+	// Periodically let followers know the state of the log file so they can
+	// tell a quiet task from a stuck stream
+	var heartbeatCh <-chan time.Time
+	if follow && !req.PlainText && req.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(req.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeatCh = ticker.C
+	}
+
+	var streamErr error
 OUTER:
 	for {
 		select {
 		case streamErr = <-errCh:
 			break OUTER
+		case <-heartbeatCh:
+			frame, err := logHeartbeat(fs, req.Task, req.LogType)
+			if err != nil {
+				// The logs may not have been written yet
+				continue
+			}
+
+			if streamErr = sendFrame(frame); streamErr != nil {
+				break OUTER
+			}
 		case frame, ok := <-frames:
 			if !ok {
 				// framer may have been closed when an error
@@ -463,28 +647,411 @@ OUTER:
 					// No error, continue on
 				}
 
+				// Let the follower know no more logs will be written
+				if streamErr == nil && exited && !req.PlainText {
+					streamErr = sendFrame(&sframer.StreamFrame{FileEvent: closeEvent})
+				}
+
 				break OUTER
 			}
 
-			var resp cstructs.StreamErrWrapper
-			if req.PlainText {
-				resp.Payload = frame.Data
+			if streamErr = sendFrame(frame); streamErr != nil {
+				break OUTER
+			}
+		}
+	}
+
+	if streamErr != nil {
+		// If error has a Code, use it
+		var code int64 = 500
+		if codedErr, ok := streamErr.(interface{ Code() int }); ok {
+			code = int64(codedErr.Code())
+		}
+		f.handleStreamResultError(streamErr, &code, encoder)
+		return
+	}
+}
+
+// logHeartbeat returns a heartbeat frame holding the size and modification
+// time of the latest log file of the task.
+func logHeartbeat(fs allocdir.AllocDirFS, task, logType string) (*sframer.StreamFrame, error) {
+	logPath := filepath.Join(allocdir.SharedAllocName, allocdir.LogDirName)
+	entries, err := fs.List(logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	logEntry, _, _, err := findClosest(entries, math.MaxInt64, 0, task, logType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sframer.StreamFrame{
+		File:      filepath.Join(logPath, logEntry.Name),
+		FileEvent: heartbeatEvent,
+		FileSize:  logEntry.Size,
+		ModTime:   logEntry.ModTime.UnixNano(),
+	}, nil
+}
+
+// lineTruncator truncates streamed lines longer than max bytes and replaces
+// the rest of the line with truncatedLineMarker. It keeps its position in the
+// current line between calls since lines may span several frames. Lines are
+// separated by delim, and the carriage return of a CRLF line ending is kept
+// and not counted when it is in the same frame as the newline.
+type lineTruncator struct {
+	max   int
+	delim byte
+
+	// col is the length of the current line seen so far
+	col int
+}
+
+// Truncate returns the data with the lines longer than max truncated.
+func (t *lineTruncator) Truncate(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		line := data
+		end := bytes.IndexByte(data, t.delim)
+		if end != -1 {
+			line = data[:end]
+		}
+
+		cr := false
+		if end != -1 && t.delim == '\n' && len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+			cr = true
+		}
+
+		// Keep what still fits in the current line and mark the line the
+		// first time it overflows
+		if keep := t.max - t.col; keep > 0 {
+			if keep > len(line) {
+				keep = len(line)
+			}
+			out = append(out, line[:keep]...)
+		}
+		if t.col <= t.max && t.col+len(line) > t.max {
+			out = append(out, truncatedLineMarker...)
+		}
+		t.col += len(line)
+
+		if end == -1 {
+			break
+		}
+		if cr {
+			out = append(out, '\r')
+		}
+		out = append(out, t.delim)
+		t.col = 0
+		data = data[end+1:]
+	}
+
+	return out
+}
+
+// ansiState is the position of an ansiStripper within an escape sequence.
+type ansiState int
+
+const (
+	ansiText ansiState = iota
+	ansiEscape
+	ansiCSI
+	ansiOSC
+	ansiOSCEscape
+)
+
+// ansiStripper removes ANSI escape sequences from streamed logs. It keeps its
+// position within an escape sequence between calls since sequences may be
+// split across frames. CSI sequences end at their final byte, OSC sequences
+// at a BEL or string terminator and other escapes after the byte following
+// the ESC.
+type ansiStripper struct {
+	state ansiState
+}
+
+// Strip returns the data with the escape sequences removed.
+func (s *ansiStripper) Strip(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		switch s.state {
+		case ansiText:
+			if b == 0x1b {
+				s.state = ansiEscape
 			} else {
-				if err = frameCodec.Encode(frame); err != nil {
-					streamErr = err
-					break OUTER
+				out = append(out, b)
+			}
+		case ansiEscape:
+			switch b {
+			case '[':
+				s.state = ansiCSI
+			case ']':
+				s.state = ansiOSC
+			default:
+				s.state = ansiText
+			}
+		case ansiCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = ansiText
+			}
+		case ansiOSC:
+			switch b {
+			case 0x07:
+				s.state = ansiText
+			case 0x1b:
+				s.state = ansiOSCEscape
+			}
+		case ansiOSCEscape:
+			if b == '\\' {
+				s.state = ansiText
+			} else if b != 0x1b {
+				s.state = ansiOSC
+			}
+		}
+	}
+
+	return out
+}
+
+// allocLogs is used to stream the logs of several tasks of an allocation at
+// once. Each frame is tagged with the task and log type it was read from. Logs
+// don't carry timestamps so frames are sent in the order they were read,
+// which follows the order the logs were written when following them.
+func (f *FileSystem) allocLogs(conn io.ReadWriteCloser) {
+	defer metrics.MeasureSince([]string{"client", "file_system", "alloc_logs"}, time.Now())
+	defer conn.Close()
+
+	// Decode the arguments
+	var req cstructs.FsAllocLogsRequest
+	decoder := codec.NewDecoder(conn, structs.MsgpackHandle)
+	encoder := codec.NewEncoder(conn, structs.MsgpackHandle)
+
+	if err := decoder.Decode(&req); err != nil {
+		f.handleStreamResultError(err, helper.Int64ToPtr(500), encoder)
+		return
+	}
+
+	// Check read permissions once for all the tasks
+	if aclObj, err := f.c.ResolveToken(req.QueryOptions.AuthToken); err != nil {
+		f.handleStreamResultError(err, nil, encoder)
+		return
+	} else if aclObj != nil {
+		readfs := aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityReadFS)
+		logs := aclObj.AllowNsOp(req.QueryOptions.Namespace, acl.NamespaceCapabilityReadLogs)
+		if !readfs && !logs {
+			f.handleStreamResultError(structs.ErrPermissionDenied, nil, encoder)
+			return
+		}
+	}
+
+	f.c.setStreamTarget(conn, req.AllocID, "", req.QueryOptions.AuthToken)
+
+	// Validate the arguments
+	if req.AllocID == "" {
+		f.handleStreamResultError(allocIDNotPresentErr, helper.Int64ToPtr(400), encoder)
+		return
+	}
+	logTypes := req.LogTypes
+	if len(logTypes) == 0 {
+		logTypes = []string{"stdout", "stderr"}
+	}
+	for _, logType := range logTypes {
+		switch logType {
+		case "stdout", "stderr":
+		default:
+			f.handleStreamResultError(logTypeNotPresentErr, helper.Int64ToPtr(400), encoder)
+			return
+		}
+	}
+	switch req.Origin {
+	case "start", "end":
+	case "":
+		req.Origin = "start"
+	default:
+		f.handleStreamResultError(invalidOrigin, helper.Int64ToPtr(400), encoder)
+		return
+	}
+
+	fs, err := f.c.GetAllocFS(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if structs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		f.handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	allocState, err := f.c.GetAllocState(req.AllocID)
+	if err != nil {
+		code := helper.Int64ToPtr(500)
+		if structs.IsErrUnknownAllocation(err) {
+			code = helper.Int64ToPtr(404)
+		}
+
+		f.handleStreamResultError(err, code, encoder)
+		return
+	}
+
+	// Pick the tasks, skipping the ones that haven't started when none were
+	// named
+	tasks := req.Tasks
+	if len(tasks) == 0 {
+		for name, state := range allocState.TaskStates {
+			if !state.StartedAt.IsZero() {
+				tasks = append(tasks, name)
+			}
+		}
+		sort.Strings(tasks)
+
+		if len(tasks) == 0 {
+			f.handleStreamResultError(fmt.Errorf("no task started yet. No logs available"), helper.Int64ToPtr(404), encoder)
+			return
+		}
+	}
+	for _, task := range tasks {
+		taskState := allocState.TaskStates[task]
+		if taskState == nil {
+			f.handleStreamResultError(fmt.Errorf("unknown task name %q", task), helper.Int64ToPtr(400), encoder)
+			return
+		}
+		if taskState.StartedAt.IsZero() {
+			f.handleStreamResultError(fmt.Errorf("task %q not started yet. No logs available", task), helper.Int64ToPtr(404), encoder)
+			return
+		}
+	}
+
+	// Limit the number of streams reading each task's logs
+	for _, task := range tasks {
+		releaseTask, err := f.acquireLogStream(req.AllocID, task)
+		if err != nil {
+			f.handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+			return
+		}
+		defer releaseTask()
+	}
+
+	// Wait for a stream slot
+	release, err := f.c.acquireStream(conn, req.QueryOptions.Namespace)
+	if err != nil {
+		f.handleStreamResultError(err, helper.Int64ToPtr(429), encoder)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	merged := make(chan *cstructs.AllocLogFrame, streamFramesBuffer)
+	errCh := make(chan error)
+
+	// Stream each log and tag its frames. Heartbeats are sent once for the
+	// whole stream below.
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		for _, logType := range logTypes {
+			wg.Add(1)
+			frames := make(chan *sframer.StreamFrame, streamFramesBuffer)
+			go func(task, logType string) {
+				if err := f.logsImpl(ctx, req.Follow, false,
+					req.Offset, req.Origin, task, logType, fs, frames); err != nil {
+					select {
+					case errCh <- err:
+					case <-ctx.Done():
+					}
 				}
-				frameCodec.Reset(buf)
+			}(task, logType)
 
-				resp.Payload = buf.Bytes()
-				buf.Reset()
+			go func(task, logType string) {
+				defer wg.Done()
+				for frame := range frames {
+					if frame.IsHeartbeat() {
+						continue
+					}
+
+					select {
+					case merged <- &cstructs.AllocLogFrame{Task: task, LogType: logType, StreamFrame: frame}:
+					case <-ctx.Done():
+					}
+				}
+			}(task, logType)
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	// Create a goroutine to detect the remote side closing
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					// One end of the pipe was explicitly closed, exit cleanly
+					cancel()
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+				}
+				return
 			}
+		}
+	}()
 
-			if err := encoder.Encode(resp); err != nil {
-				streamErr = err
+	heartbeat := time.NewTicker(streamHeartbeatRate)
+	defer heartbeat.Stop()
+
+	buf := new(bytes.Buffer)
+	frameCodec := codec.NewEncoder(buf, structs.JsonHandle)
+	sendFrame := func(frame *cstructs.AllocLogFrame) error {
+		if req.Compress && frame.StreamFrame != nil {
+			frame.Compress(streamCompressThreshold)
+		}
+
+		if err := frameCodec.Encode(frame); err != nil {
+			return err
+		}
+		frameCodec.Reset(buf)
+
+		resp := cstructs.StreamErrWrapper{Payload: buf.Bytes()}
+		err := encoder.Encode(resp)
+		buf.Reset()
+		if err != nil {
+			return err
+		}
+		encoder.Reset(conn)
+		return nil
+	}
+
+	var streamErr error
+OUTER:
+	for {
+		select {
+		case streamErr = <-errCh:
+			break OUTER
+		case <-ctx.Done():
+			break OUTER
+		case <-heartbeat.C:
+			if streamErr = sendFrame(&cstructs.AllocLogFrame{StreamFrame: sframer.HeartbeatStreamFrame}); streamErr != nil {
+				break OUTER
+			}
+		case frame, ok := <-merged:
+			if !ok {
+				// All the logs were streamed. Check once more for an
+				// error.
+				select {
+				case streamErr = <-errCh:
+				default:
+				}
+				break OUTER
+			}
+
+			if streamErr = sendFrame(frame); streamErr != nil {
 				break OUTER
 			}
-			encoder.Reset(conn)
 		}
 	}
 
@@ -499,6 +1066,28 @@ OUTER:
 	}
 }
 
+// acquireLogStream reserves one of the task's log streams and returns a
+// function releasing it. The number of log streams of the task is emitted as
+// a gauge.
+func (f *FileSystem) acquireLogStream(allocID, task string) (func(), error) {
+	key := allocID + "/" + task
+	labels := []metrics.Label{
+		{Name: "alloc_id", Value: allocID},
+		{Name: "task", Value: task},
+	}
+
+	release, err := f.c.logStreamLimiter.Acquire(key)
+	if err != nil {
+		return nil, fmt.Errorf("too many log streams for task %q, retry later", task)
+	}
+	metrics.SetGaugeWithLabels([]string{"client", "allocs", "log_streams"}, float32(f.c.logStreamLimiter.Count(key)), labels)
+
+	return func() {
+		release()
+		metrics.SetGaugeWithLabels([]string{"client", "allocs", "log_streams"}, float32(f.c.logStreamLimiter.Count(key)), labels)
+	}, nil
+}
+
 // logsImpl is used to stream the logs of a the given task. Output is sent on
 // the passed frames channel and the method will return on EOF if follow is not
 // true otherwise when the context is cancelled or on an error.
@@ -508,6 +1097,7 @@ func (f *FileSystem) logsImpl(ctx context.Context, follow, plain bool, offset in
 
 	// Create the framer
 	framer := sframer.NewStreamFramer(frames, streamHeartbeatRate, streamBatchWindow, streamFrameSize)
+	framer.SetAdaptive(streamMinBatchWindow, streamMaxBatchWindow)
 	framer.Run()
 	defer framer.Destroy()
 
@@ -526,6 +1116,9 @@ func (f *FileSystem) logsImpl(ctx context.Context, follow, plain bool, offset in
 		return invalidOrigin
 	}
 
+	// lastIdx is the index of the log file streamed last
+	lastIdx := int64(-1)
+
 	for {
 		// Logic for picking next file is:
 		// 1) List log files
@@ -569,6 +1162,18 @@ func (f *FileSystem) logsImpl(ctx context.Context, follow, plain bool, offset in
 		}
 
 		p := filepath.Join(logPath, logEntry.Name)
+
+		// Let followers know the logs rotated so they can segment them per
+		// file
+		if follow && !plain && lastIdx != -1 && idx != lastIdx {
+			if err := parseFramerErr(framer.SendIndexEvent(p, rotateEvent, idx)); err == syscall.EPIPE {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		lastIdx = idx
+
 		err = f.streamFile(ctx, openOffset, p, 0, fs, framer, eofCancelCh)
 
 		// Check if the context is cancelled
@@ -929,6 +1534,31 @@ func findClosest(entries []*cstructs.AllocFileInfo, desiredIdx, desiredOffset in
 	return indexes[idx].entry, indexes[idx].idx, offset, nil
 }
 
+// logOffsetAt returns the offset from the start of the logs of the first log
+// file modified at or after t. Log files are written in order so the files
+// before it only hold older lines. The offset is the start of that file, as
+// lines carry no timestamps to seek within it. The offset is the end of the
+// logs if all files are older.
+func logOffsetAt(entries []*cstructs.AllocFileInfo, t time.Time, task, logType string) (int64, error) {
+	indexes, err := logIndexes(entries, task, logType)
+	if err != nil {
+		return 0, err
+	}
+	if len(indexes) == 0 {
+		return 0, notFoundErr{taskName: task, logType: logType}
+	}
+
+	sort.Sort(indexes)
+	var offset int64
+	for _, index := range indexes {
+		if !index.entry.ModTime.Before(t) {
+			break
+		}
+		offset += index.entry.Size
+	}
+	return offset, nil
+}
+
 // parseFramerErr takes an error and returns an error. The error will
 // potentially change if it was caused by the connection being closed.
 func parseFramerErr(err error) error {
@@ -595,6 +595,212 @@ OUTER:
 	})
 }
 
+func TestFS_Stream_Compress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	expected := strings.Repeat("Hello from the other side\n", 200)
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "2s",
+		"stdout_string": expected,
+	}
+
+	// Wait for alloc to be running
+	alloc := testutil.WaitForRunning(t, s.RPC, job)[0]
+
+	// Make the request
+	req := &cstructs.FsStreamRequest{
+		AllocID:      alloc.ID,
+		Path:         "alloc/logs/web.stdout.0",
+		Compress:     true,
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	}
+
+	// Get the handler
+	handler, err := c.StreamingRpcHandler("FileSystem.Stream")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(3 * time.Second)
+	received := ""
+	compressed := false
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout")
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			if msg.Error != nil {
+				t.Fatalf("Got error: %v", msg.Error.Error())
+			}
+
+			var frame sframer.StreamFrame
+			require.NoError(codec.NewDecoderBytes(msg.Payload, structs.JsonHandle).Decode(&frame))
+			if frame.Compressed {
+				compressed = true
+			}
+			require.NoError(frame.Decompress())
+
+			// Add the payload
+			received += string(frame.Data)
+			if received == expected {
+				break OUTER
+			}
+		}
+	}
+
+	require.True(compressed)
+}
+
+func TestFS_Logs_Compress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	// The escape sequences are stripped before the frames are compressed
+	expected := strings.Repeat("Hello from the other side\n", 200)
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "2s",
+		"stdout_string": strings.Repeat("\x1b[31mHello from the other side\x1b[0m\n", 200),
+	}
+
+	// Wait for alloc to be running
+	alloc := testutil.WaitForRunning(t, s.RPC, job)[0]
+
+	// Make the request
+	req := &cstructs.FsLogsRequest{
+		AllocID:      alloc.ID,
+		Task:         job.TaskGroups[0].Tasks[0].Name,
+		LogType:      "stdout",
+		Origin:       "start",
+		StripANSI:    true,
+		Compress:     true,
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	}
+
+	// Get the handler
+	handler, err := c.StreamingRpcHandler("FileSystem.Logs")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(3 * time.Second)
+	received := ""
+	compressed := false
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout")
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			if msg.Error != nil {
+				t.Fatalf("Got error: %v", msg.Error.Error())
+			}
+
+			var frame sframer.StreamFrame
+			require.NoError(codec.NewDecoderBytes(msg.Payload, structs.JsonHandle).Decode(&frame))
+			if frame.Compressed {
+				compressed = true
+			}
+			require.NoError(frame.Decompress())
+
+			// Add the payload
+			received += string(frame.Data)
+			if received == expected {
+				break OUTER
+			}
+		}
+	}
+
+	require.True(compressed)
+}
+
 type ReadWriteCloseChecker struct {
 	io.ReadWriteCloser
 	Closed bool
@@ -1016,99 +1222,730 @@ func TestFS_Logs_ACL(t *testing.T) {
 		Token         string
 		ExpectedError string
 	}{
-		{
-			Name:          "bad token",
-			Token:         tokenBad.SecretID,
-			ExpectedError: structs.ErrPermissionDenied.Error(),
-		},
-		{
-			Name:          "good token",
-			Token:         tokenGood.SecretID,
-			ExpectedError: structs.ErrUnknownAllocationPrefix,
-		},
-		{
-			Name:          "root token",
-			Token:         root.SecretID,
-			ExpectedError: structs.ErrUnknownAllocationPrefix,
-		},
+		{
+			Name:          "bad token",
+			Token:         tokenBad.SecretID,
+			ExpectedError: structs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "good token",
+			Token:         tokenGood.SecretID,
+			ExpectedError: structs.ErrUnknownAllocationPrefix,
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: structs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			// Make the request with bad allocation id
+			req := &cstructs.FsLogsRequest{
+				AllocID: uuid.Generate(),
+				Task:    "foo",
+				LogType: "stdout",
+				Origin:  "start",
+				QueryOptions: structs.QueryOptions{
+					Namespace: structs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			// Get the handler
+			handler, err := client.StreamingRpcHandler("FileSystem.Logs")
+			require.Nil(err)
+
+			// Create a pipe
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+
+			errCh := make(chan error)
+			streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+			// Start the handler
+			go handler(p2)
+
+			// Start the decoder
+			go func() {
+				decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+				for {
+					var msg cstructs.StreamErrWrapper
+					if err := decoder.Decode(&msg); err != nil {
+						if err == io.EOF || strings.Contains(err.Error(), "closed") {
+							return
+						}
+						errCh <- fmt.Errorf("error decoding: %v", err)
+					}
+
+					streamMsg <- &msg
+				}
+			}()
+
+			// Send the request
+			encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			timeout := time.After(5 * time.Second)
+
+		OUTER:
+			for {
+				select {
+				case <-timeout:
+					t.Fatal("timeout")
+				case err := <-errCh:
+					t.Fatal(err)
+				case msg := <-streamMsg:
+					if msg.Error == nil {
+						continue
+					}
+
+					if strings.Contains(msg.Error.Error(), c.ExpectedError) {
+						break OUTER
+					} else {
+						t.Fatalf("Bad error: %v", msg.Error)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFS_AllocLogs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	// Run a main task and a sidecar
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "20s",
+		"stdout_string": "main\n",
+	}
+	sidecar := job.TaskGroups[0].Tasks[0].Copy()
+	sidecar.Name = "sidecar"
+	sidecar.Config = map[string]interface{}{
+		"run_for":       "20s",
+		"stdout_string": "sidecar\n",
+	}
+	job.TaskGroups[0].Tasks = append(job.TaskGroups[0].Tasks, sidecar)
+
+	// Wait for client to be running job
+	alloc := testutil.WaitForRunning(t, s.RPC, job)[0]
+
+	// stream sends the request and returns the received messages
+	stream := func(req *cstructs.FsAllocLogsRequest) (<-chan *cstructs.StreamErrWrapper, <-chan error, func()) {
+		handler, err := c.StreamingRpcHandler("FileSystem.AllocLogs")
+		require.NoError(err)
+
+		p1, p2 := net.Pipe()
+		errCh := make(chan error, 1)
+		streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+		go handler(p2)
+		go func() {
+			decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+			for {
+				var msg cstructs.StreamErrWrapper
+				if err := decoder.Decode(&msg); err != nil {
+					if err == io.EOF || strings.Contains(err.Error(), "closed") {
+						return
+					}
+					errCh <- fmt.Errorf("error decoding: %v", err)
+					return
+				}
+
+				streamMsg <- &msg
+			}
+		}()
+
+		encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+		require.Nil(encoder.Encode(req))
+		return streamMsg, errCh, func() {
+			p1.Close()
+			p2.Close()
+		}
+	}
+
+	// Unknown tasks are rejected
+	streamMsg, errCh, stop := stream(&cstructs.FsAllocLogsRequest{
+		AllocID:      alloc.ID,
+		Tasks:        []string{"web", "foo"},
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	})
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	case err := <-errCh:
+		t.Fatal(err)
+	case msg := <-streamMsg:
+		require.NotNil(msg.Error)
+		require.EqualValues(400, *msg.Error.Code)
+		require.Contains(msg.Error.Error(), `unknown task name "foo"`)
+	}
+	stop()
+
+	// Follow the stdout of all the tasks
+	streamMsg, errCh, stop = stream(&cstructs.FsAllocLogsRequest{
+		AllocID:      alloc.ID,
+		LogTypes:     []string{"stdout"},
+		Origin:       "start",
+		Follow:       true,
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	})
+	defer stop()
+
+	expected := map[string]string{
+		"web":     "main\n",
+		"sidecar": "sidecar\n",
+	}
+	received := make(map[string]string)
+	timeout := time.After(10 * time.Second)
+	for !reflect.DeepEqual(expected, received) {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout, received %v", received)
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var frame cstructs.AllocLogFrame
+			require.NoError(codec.NewDecoderBytes(msg.Payload, structs.JsonHandle).Decode(&frame))
+			if frame.Task == "" {
+				// Heartbeat
+				require.Empty(frame.LogType)
+				continue
+			}
+
+			require.Equal("stdout", frame.LogType)
+			received[frame.Task] += string(frame.Data)
+		}
+	}
+}
+
+func TestFS_AllocLogs_ACL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server
+	s, root := nomad.TestACLServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	client, cleanup := TestClient(t, func(c *config.Config) {
+		c.ACLEnabled = true
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	// Create a bad token
+	policyBad := mock.NamespacePolicy("other", "", []string{acl.NamespaceCapabilityReadFS})
+	tokenBad := mock.CreatePolicyAndToken(t, s.State(), 1005, "invalid", policyBad)
+
+	policyGood := mock.NamespacePolicy(structs.DefaultNamespace, "",
+		[]string{acl.NamespaceCapabilityReadLogs})
+	tokenGood := mock.CreatePolicyAndToken(t, s.State(), 1009, "valid2", policyGood)
+
+	cases := []struct {
+		Name          string
+		Token         string
+		ExpectedError string
+	}{
+		{
+			Name:          "bad token",
+			Token:         tokenBad.SecretID,
+			ExpectedError: structs.ErrPermissionDenied.Error(),
+		},
+		{
+			Name:          "good token",
+			Token:         tokenGood.SecretID,
+			ExpectedError: structs.ErrUnknownAllocationPrefix,
+		},
+		{
+			Name:          "root token",
+			Token:         root.SecretID,
+			ExpectedError: structs.ErrUnknownAllocationPrefix,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			// Make the request with bad allocation id
+			req := &cstructs.FsAllocLogsRequest{
+				AllocID: uuid.Generate(),
+				QueryOptions: structs.QueryOptions{
+					Namespace: structs.DefaultNamespace,
+					Region:    "global",
+					AuthToken: c.Token,
+				},
+			}
+
+			handler, err := client.StreamingRpcHandler("FileSystem.AllocLogs")
+			require.Nil(err)
+
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+			p1.SetDeadline(time.Now().Add(5 * time.Second))
+
+			go handler(p2)
+
+			encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+			require.Nil(encoder.Encode(req))
+
+			var msg cstructs.StreamErrWrapper
+			decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+			require.NoError(decoder.Decode(&msg))
+			require.NotNil(msg.Error)
+			require.Contains(msg.Error.Error(), c.ExpectedError)
+		})
+	}
+}
+
+func TestFS_Logs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	expected := "Hello from the other side\n"
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "2s",
+		"stdout_string": expected,
+	}
+
+	// Wait for client to be running job
+	testutil.WaitForRunning(t, s.RPC, job)
+
+	// Get the allocation ID
+	args := structs.AllocListRequest{}
+	args.Region = "global"
+	resp := structs.AllocListResponse{}
+	require.NoError(s.RPC("Alloc.List", &args, &resp))
+	require.Len(resp.Allocations, 1)
+	allocID := resp.Allocations[0].ID
+
+	// Make the request
+	req := &cstructs.FsLogsRequest{
+		AllocID:      allocID,
+		Task:         job.TaskGroups[0].Tasks[0].Name,
+		LogType:      "stdout",
+		Origin:       "start",
+		PlainText:    true,
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	}
+
+	// Get the handler
+	handler, err := c.StreamingRpcHandler("FileSystem.Logs")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(3 * time.Second)
+	received := ""
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout")
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			if msg.Error != nil {
+				t.Fatalf("Got error: %v", msg.Error.Error())
+			}
+
+			// Add the payload
+			received += string(msg.Payload)
+			if received == expected {
+				break OUTER
+			}
+		}
+	}
+}
+
+func TestFS_Logs_AllowAfterExit(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	expected := "Hello from the other side\n"
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "10ms",
+		"stdout_string": expected,
+	}
+
+	// Wait for the task to finish
+	testutil.WaitForRunning(t, s.RPC, job)
+	args := structs.AllocListRequest{}
+	args.Region = "global"
+	resp := structs.AllocListResponse{}
+	require.NoError(s.RPC("Alloc.List", &args, &resp))
+	require.Len(resp.Allocations, 1)
+	allocID := resp.Allocations[0].ID
+	task := job.TaskGroups[0].Tasks[0].Name
+
+	testutil.WaitForResult(func() (bool, error) {
+		state, err := c.GetAllocState(allocID)
+		if err != nil {
+			return false, err
+		}
+		if ts := state.TaskStates[task]; ts == nil || ts.State != structs.TaskStateDead {
+			return false, fmt.Errorf("task not dead: %#v", ts)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Make the request
+	req := &cstructs.FsLogsRequest{
+		AllocID:        allocID,
+		Task:           task,
+		LogType:        "stdout",
+		Origin:         "start",
+		Follow:         true,
+		AllowAfterExit: true,
+		QueryOptions:   structs.QueryOptions{Region: "global"},
+	}
+
+	// Get the handler
+	handler, err := c.StreamingRpcHandler("FileSystem.Logs")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(3 * time.Second)
+	received := ""
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout, received %q", received)
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			if msg.Error != nil {
+				t.Fatalf("Got error: %v", msg.Error.Error())
+			}
+
+			var frame sframer.StreamFrame
+			require.NoError(codec.NewDecoderBytes(msg.Payload, structs.JsonHandle).Decode(&frame))
+			if frame.FileEvent == closeEvent {
+				break OUTER
+			}
+			received += string(frame.Data)
+		}
+	}
+
+	// All the logs were served before the close frame
+	require.Equal(expected, received)
+}
+
+func TestFS_Logs_MaxLineLength(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	// Write a line spanning several frames
+	long := strings.Repeat("a", 3*streamFrameSize)
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "10ms",
+		"stdout_string": long + "\nshort\n",
+	}
+
+	// Wait for the task to finish
+	testutil.WaitForRunning(t, s.RPC, job)
+	args := structs.AllocListRequest{}
+	args.Region = "global"
+	resp := structs.AllocListResponse{}
+	require.NoError(s.RPC("Alloc.List", &args, &resp))
+	require.Len(resp.Allocations, 1)
+	allocID := resp.Allocations[0].ID
+	task := job.TaskGroups[0].Tasks[0].Name
+
+	testutil.WaitForResult(func() (bool, error) {
+		state, err := c.GetAllocState(allocID)
+		if err != nil {
+			return false, err
+		}
+		if ts := state.TaskStates[task]; ts == nil || ts.State != structs.TaskStateDead {
+			return false, fmt.Errorf("task not dead: %#v", ts)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Make the request
+	req := &cstructs.FsLogsRequest{
+		AllocID:        allocID,
+		Task:           task,
+		LogType:        "stdout",
+		Origin:         "start",
+		Follow:         true,
+		AllowAfterExit: true,
+		MaxLineLength:  10,
+		QueryOptions:   structs.QueryOptions{Region: "global"},
+	}
+
+	// Get the handler
+	handler, err := c.StreamingRpcHandler("FileSystem.Logs")
+	require.Nil(err)
+
+	// Create a pipe
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+
+	errCh := make(chan error)
+	streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+	// Start the handler
+	go handler(p2)
+
+	// Start the decoder
+	go func() {
+		decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+		for {
+			var msg cstructs.StreamErrWrapper
+			if err := decoder.Decode(&msg); err != nil {
+				if err == io.EOF || strings.Contains(err.Error(), "closed") {
+					return
+				}
+				errCh <- fmt.Errorf("error decoding: %v", err)
+			}
+
+			streamMsg <- &msg
+		}
+	}()
+
+	// Send the request
+	encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+	require.Nil(encoder.Encode(req))
+
+	timeout := time.After(3 * time.Second)
+	received := ""
+OUTER:
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout, received %q", received)
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			if msg.Error != nil {
+				t.Fatalf("Got error: %v", msg.Error.Error())
+			}
+
+			var frame sframer.StreamFrame
+			require.NoError(codec.NewDecoderBytes(msg.Payload, structs.JsonHandle).Decode(&frame))
+			if frame.FileEvent == closeEvent {
+				break OUTER
+			}
+			received += string(frame.Data)
+		}
+	}
+
+	// Only the long line was truncated
+	require.Equal("aaaaaaaaaa"+truncatedLineMarker+"\nshort\n", received)
+}
+
+func TestFS_lineTruncator(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cases := []struct {
+		Chunks   []string
+		Expected string
+	}{
+		{[]string{"short\n"}, "short\n"},
+		{[]string{"12345\n"}, "12345\n"},
+		{[]string{"1234567890\nab\n"}, "12345" + truncatedLineMarker + "\nab\n"},
+		{[]string{"123", "45", "678", "90\n", "ab"}, "12345" + truncatedLineMarker + "\nab"},
+		{[]string{"12345", "6\n"}, "12345" + truncatedLineMarker + "\n"},
 	}
 
 	for _, c := range cases {
-		t.Run(c.Name, func(t *testing.T) {
-			// Make the request with bad allocation id
-			req := &cstructs.FsLogsRequest{
-				AllocID: uuid.Generate(),
-				Task:    "foo",
-				LogType: "stdout",
-				Origin:  "start",
-				QueryOptions: structs.QueryOptions{
-					Namespace: structs.DefaultNamespace,
-					Region:    "global",
-					AuthToken: c.Token,
-				},
-			}
+		truncator := &lineTruncator{max: 5, delim: '\n'}
+		received := ""
+		for _, chunk := range c.Chunks {
+			received += string(truncator.Truncate([]byte(chunk)))
+		}
+		require.Equal(c.Expected, received, "chunks: %q", c.Chunks)
+	}
+}
 
-			// Get the handler
-			handler, err := client.StreamingRpcHandler("FileSystem.Logs")
-			require.Nil(err)
+func TestFS_lineTruncator_Delimiter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
 
-			// Create a pipe
-			p1, p2 := net.Pipe()
-			defer p1.Close()
-			defer p2.Close()
+	cases := []struct {
+		Delim    byte
+		Chunks   []string
+		Expected string
+	}{
+		// NUL delimited records, including one split across chunks
+		{0, []string{"1234567890\x00ab\x00"}, "12345" + truncatedLineMarker + "\x00ab\x00"},
+		{0, []string{"123", "4567\x00a\nb\x00"}, "12345" + truncatedLineMarker + "\x00a\nb\x00"},
+		{0, []string{"line1\nline2\n"}, "line1" + truncatedLineMarker},
 
-			errCh := make(chan error)
-			streamMsg := make(chan *cstructs.StreamErrWrapper)
+		// Custom separator
+		{'|', []string{"12|1234567|12"}, "12|12345" + truncatedLineMarker + "|12"},
 
-			// Start the handler
-			go handler(p2)
+		// The carriage return of a CRLF ending isn't counted
+		{'\n', []string{"12345\r\n123456\r\n"}, "12345\r\n12345" + truncatedLineMarker + "\r\n"},
+	}
 
-			// Start the decoder
-			go func() {
-				decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
-				for {
-					var msg cstructs.StreamErrWrapper
-					if err := decoder.Decode(&msg); err != nil {
-						if err == io.EOF || strings.Contains(err.Error(), "closed") {
-							return
-						}
-						errCh <- fmt.Errorf("error decoding: %v", err)
-					}
+	for _, c := range cases {
+		truncator := &lineTruncator{max: 5, delim: c.Delim}
+		received := ""
+		for _, chunk := range c.Chunks {
+			received += string(truncator.Truncate([]byte(chunk)))
+		}
+		require.Equal(c.Expected, received, "chunks: %q", c.Chunks)
+	}
+}
 
-					streamMsg <- &msg
-				}
-			}()
+func TestFS_ansiStripper(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
 
-			// Send the request
-			encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
-			require.Nil(encoder.Encode(req))
+	cases := []struct {
+		Chunks   []string
+		Expected string
+	}{
+		{[]string{"plain\n"}, "plain\n"},
+		{[]string{"\x1b[31mred\x1b[0m \x1b[1;32mgreen\x1b[m\n"}, "red green\n"},
+		{[]string{"\x1b[38;5;208morange\x1b[0m"}, "orange"},
 
-			timeout := time.After(5 * time.Second)
+		// Sequences split across chunks
+		{[]string{"a\x1b", "[31mb"}, "ab"},
+		{[]string{"a\x1b[3", "1", "mb\x1b[0", "m"}, "ab"},
 
-		OUTER:
-			for {
-				select {
-				case <-timeout:
-					t.Fatal("timeout")
-				case err := <-errCh:
-					t.Fatal(err)
-				case msg := <-streamMsg:
-					if msg.Error == nil {
-						continue
-					}
+		// OSC sequences ending with a BEL or string terminator
+		{[]string{"\x1b]0;title\x07text"}, "text"},
+		{[]string{"\x1b]0;ti", "tle\x1b", "\\text"}, "text"},
 
-					if strings.Contains(msg.Error.Error(), c.ExpectedError) {
-						break OUTER
-					} else {
-						t.Fatalf("Bad error: %v", msg.Error)
-					}
-				}
-			}
-		})
+		// Other two byte escapes
+		{[]string{"\x1b7saved\x1b8"}, "saved"},
+	}
+
+	for _, c := range cases {
+		stripper := &ansiStripper{}
+		received := ""
+		for _, chunk := range c.Chunks {
+			received += string(stripper.Strip([]byte(chunk)))
+		}
+		require.Equal(c.Expected, received, "chunks: %q", c.Chunks)
 	}
 }
 
-func TestFS_Logs(t *testing.T) {
+func TestFS_Logs_TaskStreamLimit(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
 
@@ -1119,15 +1956,14 @@ func TestFS_Logs(t *testing.T) {
 
 	c, cleanup := TestClient(t, func(c *config.Config) {
 		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+		c.MaxLogStreamsPerTask = 1
 	})
 	defer cleanup()
 
-	expected := "Hello from the other side\n"
 	job := mock.BatchJob()
 	job.TaskGroups[0].Count = 1
 	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
-		"run_for":       "2s",
-		"stdout_string": expected,
+		"run_for": "10s",
 	}
 
 	// Wait for client to be running job
@@ -1140,14 +1976,21 @@ func TestFS_Logs(t *testing.T) {
 	require.NoError(s.RPC("Alloc.List", &args, &resp))
 	require.Len(resp.Allocations, 1)
 	allocID := resp.Allocations[0].ID
+	task := job.TaskGroups[0].Tasks[0].Name
+
+	// Hold the only log stream of the task
+	release, err := c.logStreamLimiter.Acquire(allocID + "/" + task)
+	require.NoError(err)
+	defer release()
 
 	// Make the request
 	req := &cstructs.FsLogsRequest{
 		AllocID:      allocID,
-		Task:         job.TaskGroups[0].Tasks[0].Name,
+		Task:         task,
 		LogType:      "stdout",
 		Origin:       "start",
 		PlainText:    true,
+		Follow:       true,
 		QueryOptions: structs.QueryOptions{Region: "global"},
 	}
 
@@ -1186,26 +2029,16 @@ func TestFS_Logs(t *testing.T) {
 	encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
 	require.Nil(encoder.Encode(req))
 
-	timeout := time.After(3 * time.Second)
-	received := ""
-OUTER:
-	for {
-		select {
-		case <-timeout:
-			t.Fatal("timeout")
-		case err := <-errCh:
-			t.Fatal(err)
-		case msg := <-streamMsg:
-			if msg.Error != nil {
-				t.Fatalf("Got error: %v", msg.Error.Error())
-			}
-
-			// Add the payload
-			received += string(msg.Payload)
-			if received == expected {
-				break OUTER
-			}
-		}
+	select {
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout")
+	case err := <-errCh:
+		t.Fatal(err)
+	case msg := <-streamMsg:
+		require.NotNil(msg.Error)
+		require.NotNil(msg.Error.Code)
+		require.EqualValues(429, *msg.Error.Code)
+		require.Contains(msg.Error.Error(), "too many log streams")
 	}
 }
 
@@ -1308,6 +2141,121 @@ OUTER:
 	}
 }
 
+func TestFS_Logs_Heartbeat(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Start a server and client
+	s := nomad.TestServer(t, nil)
+	defer s.Shutdown()
+	testutil.WaitForLeader(t, s.RPC)
+
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.Servers = []string{s.GetConfig().RPCAddr.String()}
+	})
+	defer cleanup()
+
+	expected := "Hello from the other side\n"
+	job := mock.BatchJob()
+	job.TaskGroups[0].Count = 1
+	job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for":       "20s",
+		"stdout_string": expected,
+	}
+
+	// Wait for client to be running job
+	alloc := testutil.WaitForRunning(t, s.RPC, job)[0]
+
+	// stream starts streaming the logs with the given heartbeat interval
+	stream := func(interval time.Duration) (<-chan *cstructs.StreamErrWrapper, <-chan error, func()) {
+		req := &cstructs.FsLogsRequest{
+			AllocID:           alloc.ID,
+			Task:              job.TaskGroups[0].Tasks[0].Name,
+			LogType:           "stdout",
+			Origin:            "start",
+			Follow:            true,
+			HeartbeatInterval: interval,
+			QueryOptions:      structs.QueryOptions{Region: "global"},
+		}
+
+		handler, err := c.StreamingRpcHandler("FileSystem.Logs")
+		require.NoError(err)
+
+		p1, p2 := net.Pipe()
+		errCh := make(chan error, 1)
+		streamMsg := make(chan *cstructs.StreamErrWrapper)
+
+		go handler(p2)
+		go func() {
+			decoder := codec.NewDecoder(p1, structs.MsgpackHandle)
+			for {
+				var msg cstructs.StreamErrWrapper
+				if err := decoder.Decode(&msg); err != nil {
+					if err == io.EOF || strings.Contains(err.Error(), "closed") {
+						return
+					}
+					errCh <- fmt.Errorf("error decoding: %v", err)
+					return
+				}
+
+				streamMsg <- &msg
+			}
+		}()
+
+		encoder := codec.NewEncoder(p1, structs.MsgpackHandle)
+		require.Nil(encoder.Encode(req))
+		return streamMsg, errCh, func() {
+			p1.Close()
+			p2.Close()
+		}
+	}
+
+	// Intervals that are too short are rejected
+	streamMsg, errCh, stop := stream(10 * time.Millisecond)
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	case err := <-errCh:
+		t.Fatal(err)
+	case msg := <-streamMsg:
+		require.NotNil(msg.Error)
+		require.EqualValues(400, *msg.Error.Code)
+		require.Contains(msg.Error.Error(), "heartbeat interval")
+	}
+	stop()
+
+	streamMsg, errCh, stop = stream(minLogHeartbeatInterval)
+	defer stop()
+
+	timeout := time.After(10 * time.Second)
+	received := ""
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout, received %q", received)
+		case err := <-errCh:
+			t.Fatal(err)
+		case msg := <-streamMsg:
+			require.Nil(msg.Error)
+
+			var frame sframer.StreamFrame
+			require.NoError(codec.NewDecoderBytes(msg.Payload, structs.JsonHandle).Decode(&frame))
+			if frame.FileEvent != heartbeatEvent {
+				received += string(frame.Data)
+				continue
+			}
+
+			// The heartbeat reports the logs written so far
+			require.Equal(expected, received)
+			require.Equal(filepath.Join(allocdir.SharedAllocName, allocdir.LogDirName, "web.stdout.0"), frame.File)
+			require.Equal(int64(len(expected)), frame.FileSize)
+			require.NotZero(frame.ModTime)
+			require.Empty(frame.Data)
+			return
+		}
+	}
+}
+
 func TestFS_findClosest(t *testing.T) {
 	task := "foo"
 	entries := []*cstructs.AllocFileInfo{
@@ -1513,6 +2461,93 @@ func TestFS_findClosest(t *testing.T) {
 	}
 }
 
+func TestFS_logOffsetAt(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	now := time.Now()
+	entries := []*cstructs.AllocFileInfo{
+		{Name: "foo.stdout.2", Size: 100, ModTime: now},
+		{Name: "foo.stdout.0", Size: 100, ModTime: now.Add(-2 * time.Hour)},
+		{Name: "foo.stdout.1", Size: 100, ModTime: now.Add(-time.Hour)},
+		{Name: "foo.stderr.0", Size: 100, ModTime: now},
+	}
+
+	cases := []struct {
+		Time   time.Time
+		Offset int64
+	}{
+		{now.Add(-3 * time.Hour), 0},
+		{now.Add(-2 * time.Hour), 0},
+		{now.Add(-90 * time.Minute), 100},
+		{now.Add(-time.Minute), 200},
+		{now.Add(time.Minute), 300},
+	}
+
+	for _, c := range cases {
+		offset, err := logOffsetAt(entries, c.Time, "foo", "stdout")
+		require.NoError(err)
+		require.Equal(c.Offset, offset, "time %v", c.Time)
+	}
+
+	_, err := logOffsetAt(entries, now, "bar", "stdout")
+	require.Error(err)
+}
+
+// TestFS_logsImpl_StartTime asserts that seeking to a start time streams the
+// whole log file written around it, including the lines written before it.
+func TestFS_logsImpl_StartTime(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	c, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	ad := tempAllocDir(t)
+	defer os.RemoveAll(ad.AllocDir)
+
+	logDir := filepath.Join(ad.SharedDir, allocdir.LogDirName)
+	require.NoError(os.MkdirAll(logDir, 0777))
+
+	// The rotated file is older than the start time and the active file has
+	// a line written either side of it
+	start := time.Now().Add(-time.Minute)
+	rotated := filepath.Join(logDir, "foo.stdout.0")
+	require.NoError(ioutil.WriteFile(rotated, []byte("rotated\n"), 0666))
+	require.NoError(os.Chtimes(rotated, start.Add(-time.Hour), start.Add(-time.Hour)))
+
+	active := filepath.Join(logDir, "foo.stdout.1")
+	require.NoError(ioutil.WriteFile(active, []byte("before\nafter\n"), 0666))
+	require.NoError(os.Chtimes(active, start.Add(time.Second), start.Add(time.Second)))
+
+	entries, err := ad.List(filepath.Join(allocdir.SharedAllocName, allocdir.LogDirName))
+	require.NoError(err)
+	offset, err := logOffsetAt(entries, start, "foo", "stdout")
+	require.NoError(err)
+	require.Equal(int64(len("rotated\n")), offset)
+
+	frames := make(chan *sframer.StreamFrame, 4)
+	go func() {
+		err := c.endpoints.FileSystem.logsImpl(context.Background(), false, false,
+			offset, OriginStart, "foo", "stdout", ad, frames)
+		if err != nil {
+			t.Errorf("logs() failed: %v", err)
+		}
+	}()
+
+	expected := "before\nafter\n"
+	var received []byte
+	timeout := time.After(10 * time.Duration(testutil.TestMultiplier()) * streamBatchWindow)
+	for string(received) != expected {
+		select {
+		case frame := <-frames:
+			received = append(received, frame.Data...)
+		case <-timeout:
+			t.Fatalf("did not receive data: got %q", string(received))
+		}
+	}
+}
+
 func TestFS_streamFile_NoFile(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -1898,7 +2933,8 @@ func TestFS_logsImpl_Follow(t *testing.T) {
 				return
 			}
 
-			if frame.IsHeartbeat() {
+			// Skip heartbeats and the rotations between the log files
+			if frame.IsHeartbeat() || frame.FileEvent == rotateEvent {
 				continue
 			}
 
@@ -1935,3 +2971,62 @@ func TestFS_logsImpl_Follow(t *testing.T) {
 		t.Fatalf("did not receive data: got %q", string(received))
 	}
 }
+
+func TestFS_logsImpl_Follow_Rotate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	c, cleanup := TestClient(t, nil)
+	defer cleanup()
+
+	// Get a temp alloc dir and create the log dir
+	ad := tempAllocDir(t)
+	defer os.RemoveAll(ad.AllocDir)
+
+	logDir := filepath.Join(ad.SharedDir, allocdir.LogDirName)
+	require.NoError(os.MkdirAll(logDir, 0777))
+
+	writeToFile := func(index int, data string) {
+		logFile := fmt.Sprintf("foo.stdout.%d", index)
+		require.NoError(ioutil.WriteFile(filepath.Join(logDir, logFile), []byte(data), 0777))
+	}
+	writeToFile(0, "ab")
+
+	frames := make(chan *sframer.StreamFrame, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.endpoints.FileSystem.logsImpl(ctx, true, false, 0,
+		OriginStart, "foo", "stdout", ad, frames)
+
+	// next returns the next frame that isn't a heartbeat
+	next := func() *sframer.StreamFrame {
+		timeout := time.After(10 * time.Duration(testutil.TestMultiplier()) * streamBatchWindow)
+		for {
+			select {
+			case frame := <-frames:
+				if !frame.IsHeartbeat() {
+					return frame
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for a frame")
+			}
+		}
+	}
+
+	frame := next()
+	require.Equal("ab", string(frame.Data))
+	require.Empty(frame.FileEvent)
+
+	// Rotate the logs mid-stream
+	writeToFile(1, "cd")
+
+	frame = next()
+	require.Equal(rotateEvent, frame.FileEvent)
+	require.Equal(int64(1), frame.FileIndex)
+	require.Equal(filepath.Join(allocdir.SharedAllocName, allocdir.LogDirName, "foo.stdout.1"), frame.File)
+	require.Empty(frame.Data)
+
+	frame = next()
+	require.Equal("cd", string(frame.Data))
+	require.Empty(frame.FileEvent)
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,7 +22,13 @@ const (
 type GCConfig struct {
 	// MaxAllocs is the maximum number of allocations to track before a GC
 	// is triggered.
-	MaxAllocs           int
+	MaxAllocs int
+
+	// MinAllocRetention is the minimum time an allocation is tracked before
+	// being collected because of MaxAllocs. Disk and inode pressure ignore
+	// it.
+	MinAllocRetention time.Duration
+
 	DiskUsageThreshold  float64
 	InodeUsageThreshold float64
 	Interval            time.Duration
@@ -129,24 +137,14 @@ func (a *AllocGarbageCollector) keepUsageBelowThreshold() error {
 
 		// See if we are below thresholds for used disk space and inode usage
 		diskStats := a.statsCollector.Stats().AllocDirStats
-		reason := ""
 		logf := a.logger.Warn
 
 		liveAllocs := a.allocCounter.NumAllocs()
+		reason, diskPressure := gcReason(a.config, diskStats, liveAllocs)
 
-		switch {
-		case diskStats.UsedPercent > a.config.DiskUsageThreshold:
-			reason = fmt.Sprintf("disk usage of %.0f is over gc threshold of %.0f",
-				diskStats.UsedPercent, a.config.DiskUsageThreshold)
-		case diskStats.InodesUsedPercent > a.config.InodeUsageThreshold:
-			reason = fmt.Sprintf("inode usage of %.0f is over gc threshold of %.0f",
-				diskStats.InodesUsedPercent, a.config.InodeUsageThreshold)
-		case liveAllocs > a.config.MaxAllocs:
-			// if we're unable to gc, don't WARN until at least 2x over limit
-			if liveAllocs < (a.config.MaxAllocs * 2) {
-				logf = a.logger.Info
-			}
-			reason = fmt.Sprintf("number of allocations (%d) is over the limit (%d)", liveAllocs, a.config.MaxAllocs)
+		// if we're unable to gc, don't WARN until at least 2x over limit
+		if !diskPressure && liveAllocs < (a.config.MaxAllocs*2) {
+			logf = a.logger.Info
 		}
 
 		if reason == "" {
@@ -155,7 +153,12 @@ func (a *AllocGarbageCollector) keepUsageBelowThreshold() error {
 		}
 
 		// Collect an allocation
-		gcAlloc := a.allocRunners.Pop()
+		var gcAlloc *GCAlloc
+		if diskPressure {
+			gcAlloc = a.allocRunners.Pop()
+		} else {
+			gcAlloc = a.popRetained()
+		}
 		if gcAlloc == nil {
 			logf("garbage collection skipped because no terminal allocations", "reason", reason)
 			break
@@ -167,6 +170,81 @@ func (a *AllocGarbageCollector) keepUsageBelowThreshold() error {
 	return nil
 }
 
+// gcReason returns why allocations have to be garbage collected given the
+// thresholds of the config, or an empty reason if they don't. diskPressure is
+// false when the reason is the number of allocations, in which case only the
+// allocations retained for the minimum retention may be collected.
+func gcReason(config *GCConfig, diskStats *stats.DiskStats, liveAllocs int) (reason string, diskPressure bool) {
+	switch {
+	case diskStats.UsedPercent > config.DiskUsageThreshold:
+		return fmt.Sprintf("disk usage of %.0f is over gc threshold of %.0f",
+			diskStats.UsedPercent, config.DiskUsageThreshold), true
+	case diskStats.InodesUsedPercent > config.InodeUsageThreshold:
+		return fmt.Sprintf("inode usage of %.0f (%d of %d inodes) is over gc threshold of %.0f",
+			diskStats.InodesUsedPercent, diskStats.InodesUsed, diskStats.InodesTotal, config.InodeUsageThreshold), true
+	case liveAllocs > config.MaxAllocs:
+		return fmt.Sprintf("number of allocations (%d) is over the limit (%d)", liveAllocs, config.MaxAllocs), false
+	}
+	return "", true
+}
+
+// WouldCollect maps the allocations marked for collection to the reason a
+// garbage collection would collect them under the thresholds of the given
+// config. The reason is empty for the allocations that would be kept. Nothing
+// is collected. The disk space freed by an allocation is estimated from the
+// disk it reserves, and inode usage is assumed not to change.
+func (a *AllocGarbageCollector) WouldCollect(config *GCConfig) (map[string]string, error) {
+	if err := a.statsCollector.Collect(); err != nil {
+		return nil, err
+	}
+
+	diskStats := *a.statsCollector.Stats().AllocDirStats
+	liveAllocs := a.allocCounter.NumAllocs()
+	retainedBefore := time.Now().Add(-config.MinAllocRetention)
+	queue := a.allocRunners.sorted()
+
+	collected := make(map[string]string, len(queue))
+	for _, gcAlloc := range queue {
+		collected[gcAlloc.allocID] = ""
+	}
+
+	for len(queue) > 0 {
+		reason, diskPressure := gcReason(config, &diskStats, liveAllocs)
+		if reason == "" {
+			break
+		}
+
+		gcAlloc := queue[0]
+		if !diskPressure && !gcAlloc.timeStamp.Before(retainedBefore) {
+			break
+		}
+		queue = queue[1:]
+		collected[gcAlloc.allocID] = reason
+
+		// Account for the collected alloc
+		liveAllocs--
+		alloc := gcAlloc.allocRunner.Alloc()
+
+		// COMPAT(0.11): Remove in 0.11
+		var freed uint64
+		if alloc.AllocatedResources != nil {
+			freed = uint64(alloc.AllocatedResources.Shared.DiskMB * MB)
+		} else if alloc.Resources != nil {
+			freed = uint64(alloc.Resources.DiskMB * MB)
+		}
+		if freed > diskStats.Used {
+			freed = diskStats.Used
+		}
+		diskStats.Used -= freed
+		diskStats.Available += freed
+		if diskStats.Size > 0 {
+			diskStats.UsedPercent = float64(diskStats.Used) / float64(diskStats.Size) * 100
+		}
+	}
+
+	return collected, nil
+}
+
 // destroyAllocRunner is used to destroy an allocation runner. It will acquire a
 // lock to restrict parallelism and then destroy the alloc runner, returning
 // once the allocation has been destroyed.
@@ -193,6 +271,11 @@ func (a *AllocGarbageCollector) destroyAllocRunner(allocID string, ar AllocRunne
 	<-a.destroyCh
 }
 
+// Config returns a copy of the garbage collector's config
+func (a *AllocGarbageCollector) Config() GCConfig {
+	return *a.config
+}
+
 func (a *AllocGarbageCollector) Stop() {
 	close(a.shutdownCh)
 }
@@ -210,6 +293,60 @@ func (a *AllocGarbageCollector) Collect(allocID string) bool {
 	return true
 }
 
+// IsMarked returns true if the allocation is marked for collection.
+func (a *AllocGarbageCollector) IsMarked(allocID string) bool {
+	return a.allocRunners.Contains(allocID)
+}
+
+// CollectContext garbage collects a single allocation on a node, calling
+// progress with the number of bytes of its alloc dir freed so far. Returns true
+// if the alloc was found. If the context is canceled before the alloc dir is
+// removed, the context's error is returned and the alloc is marked for
+// collection again so the collection can be retried.
+func (a *AllocGarbageCollector) CollectContext(ctx context.Context, allocID string, progress func(freed int64)) (bool, error) {
+	gcAlloc := a.allocRunners.Remove(allocID)
+	if gcAlloc == nil {
+		a.logger.Debug("alloc was already garbage collected", "alloc_id", allocID)
+		return false, nil
+	}
+	ar := gcAlloc.allocRunner
+
+	// Acquire the destroy lock
+	select {
+	case <-a.shutdownCh:
+		a.allocRunners.restore(gcAlloc)
+		return true, fmt.Errorf("garbage collector shutting down")
+	case <-ctx.Done():
+		a.allocRunners.restore(gcAlloc)
+		return true, ctx.Err()
+	case a.destroyCh <- struct{}{}:
+	}
+	defer func() { <-a.destroyCh }()
+
+	// Remove the alloc dir of stopped allocs while reporting progress. The
+	// alloc dir of running allocs is removed when they are destroyed.
+	select {
+	case <-ar.WaitCh():
+		if err := ar.DestroyAllocDir(ctx, progress); err != nil {
+			a.logger.Info("garbage collection of allocation canceled", "alloc_id", allocID, "error", err)
+			a.allocRunners.restore(gcAlloc)
+			return true, err
+		}
+	default:
+	}
+
+	a.logger.Info("garbage collecting allocation", "alloc_id", allocID, "reason", "forced collection")
+	ar.Destroy()
+
+	select {
+	case <-ar.DestroyCh():
+	case <-a.shutdownCh:
+	}
+
+	a.logger.Debug("alloc garbage collected", "alloc_id", allocID)
+	return true, nil
+}
+
 // CollectAll garbage collects all terminated allocations on a node
 func (a *AllocGarbageCollector) CollectAll() {
 	for {
@@ -245,7 +382,7 @@ func (a *AllocGarbageCollector) MakeRoomFor(allocations []*structs.Allocation) e
 		default:
 		}
 
-		gcAlloc := a.allocRunners.Pop()
+		gcAlloc := a.popRetained()
 		if gcAlloc == nil {
 			// It's fine if we can't lower below the limit here as
 			// we'll keep trying to drop below the limit with each
@@ -334,6 +471,12 @@ func (a *AllocGarbageCollector) MakeRoomFor(allocations []*structs.Allocation) e
 	return nil
 }
 
+// popRetained pops the oldest allocation that has been tracked for longer than
+// the minimum retention.
+func (a *AllocGarbageCollector) popRetained() *GCAlloc {
+	return a.allocRunners.PopBefore(time.Now().Add(-a.config.MinAllocRetention))
+}
+
 // MarkForCollection starts tracking an allocation for Garbage Collection
 func (a *AllocGarbageCollector) MarkForCollection(allocID string, ar AllocRunner) {
 	if a.allocRunners.Push(allocID, ar) {
@@ -418,6 +561,34 @@ func (i *IndexedGCAllocPQ) Push(allocID string, ar AllocRunner) bool {
 	return true
 }
 
+// restore pushes back an alloc removed from the GC queue, keeping its place in
+// the queue. Returns false if the alloc was pushed again in the meantime.
+func (i *IndexedGCAllocPQ) restore(gcAlloc *GCAlloc) bool {
+	i.pqLock.Lock()
+	defer i.pqLock.Unlock()
+
+	if _, ok := i.index[gcAlloc.allocID]; ok {
+		return false
+	}
+	i.index[gcAlloc.allocID] = gcAlloc
+	heap.Push(&i.heap, gcAlloc)
+	return true
+}
+
+// sorted returns the allocs in the GC queue from the oldest to the newest
+// without removing them.
+func (i *IndexedGCAllocPQ) sorted() []*GCAlloc {
+	i.pqLock.Lock()
+	defer i.pqLock.Unlock()
+
+	allocs := make([]*GCAlloc, len(i.heap))
+	copy(allocs, i.heap)
+	sort.Slice(allocs, func(x, y int) bool {
+		return allocs[x].timeStamp.Before(allocs[y].timeStamp)
+	})
+	return allocs
+}
+
 func (i *IndexedGCAllocPQ) Pop() *GCAlloc {
 	i.pqLock.Lock()
 	defer i.pqLock.Unlock()
@@ -431,6 +602,21 @@ func (i *IndexedGCAllocPQ) Pop() *GCAlloc {
 	return gcAlloc
 }
 
+// PopBefore pops the oldest alloc runner if it was pushed before the given
+// time. Returns nil otherwise.
+func (i *IndexedGCAllocPQ) PopBefore(t time.Time) *GCAlloc {
+	i.pqLock.Lock()
+	defer i.pqLock.Unlock()
+
+	if len(i.heap) == 0 || !i.heap[0].timeStamp.Before(t) {
+		return nil
+	}
+
+	gcAlloc := heap.Pop(&i.heap).(*GCAlloc)
+	delete(i.index, gcAlloc.allocRunner.Alloc().ID)
+	return gcAlloc
+}
+
 // Remove alloc from GC. Returns nil if alloc doesn't exist.
 func (i *IndexedGCAllocPQ) Remove(allocID string) *GCAlloc {
 	i.pqLock.Lock()
@@ -445,6 +631,15 @@ func (i *IndexedGCAllocPQ) Remove(allocID string) *GCAlloc {
 	return nil
 }
 
+// Contains returns true if the alloc is in the GC queue.
+func (i *IndexedGCAllocPQ) Contains(allocID string) bool {
+	i.pqLock.Lock()
+	defer i.pqLock.Unlock()
+
+	_, ok := i.index[allocID]
+	return ok
+}
+
 func (i *IndexedGCAllocPQ) Length() int {
 	i.pqLock.Lock()
 	defer i.pqLock.Unlock()
@@ -1,7 +1,11 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -163,6 +167,67 @@ func TestAllocGarbageCollector_Collect(t *testing.T) {
 	}
 }
 
+func TestAllocGarbageCollector_CollectContext_Cancel(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	logger := testlog.HCLogger(t)
+	gc := NewAllocGarbageCollector(logger, &MockStatsCollector{}, &MockAllocCounter{}, gcConfig())
+
+	alloc := mock.Alloc()
+	alloc.Job.TaskGroups[0].RestartPolicy = &structs.RestartPolicy{
+		Attempts: 0,
+		Mode:     structs.RestartPolicyModeFail,
+	}
+	alloc.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	alloc.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "10ms",
+	}
+	ar1, cleanup1 := allocrunner.TestAllocRunnerFromAlloc(t, alloc)
+	defer cleanup1()
+
+	go ar1.Run()
+	select {
+	case <-ar1.WaitCh():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("alloc runner did not stop")
+	}
+	gc.MarkForCollection(ar1.Alloc().ID, ar1)
+
+	// Write files to the alloc dir
+	allocDir := ar1.GetAllocDir().AllocDir
+	for i := 0; i < 10; i++ {
+		name := filepath.Join(allocDir, fmt.Sprintf("file%d", i))
+		require.NoError(ioutil.WriteFile(name, make([]byte, 1024), 0666))
+	}
+
+	// Cancel the collection after the first file was removed
+	ctx, cancel := context.WithCancel(context.Background())
+	found, err := gc.CollectContext(ctx, ar1.Alloc().ID, func(freed int64) {
+		cancel()
+	})
+	require.True(found)
+	require.Equal(context.Canceled, err)
+
+	// The alloc is still marked for collection and its alloc dir remains
+	require.Equal(1, gc.allocRunners.Length())
+	require.False(ar1.IsDestroyed())
+	_, err = os.Stat(allocDir)
+	require.NoError(err)
+
+	// Retry the collection
+	var freed int64
+	found, err = gc.CollectContext(context.Background(), ar1.Alloc().ID, func(f int64) {
+		freed = f
+	})
+	require.True(found)
+	require.NoError(err)
+	require.True(freed >= 9*1024)
+	require.Equal(0, gc.allocRunners.Length())
+	require.True(ar1.IsDestroyed())
+	_, err = os.Stat(allocDir)
+	require.True(os.IsNotExist(err))
+}
+
 func TestAllocGarbageCollector_CollectAll(t *testing.T) {
 	t.Parallel()
 	logger := testlog.HCLogger(t)
@@ -563,3 +628,168 @@ func TestAllocGarbageCollector_UsedPercentThreshold(t *testing.T) {
 		t.Fatalf("gcAlloc: %v", gcAlloc)
 	}
 }
+
+func TestAllocGarbageCollector_MinAllocRetention(t *testing.T) {
+	t.Parallel()
+	logger := testlog.HCLogger(t)
+	statsCollector := &MockStatsCollector{}
+	conf := gcConfig()
+	conf.MaxAllocs = 1
+	conf.MinAllocRetention = time.Hour
+	gc := NewAllocGarbageCollector(logger, statsCollector, &MockAllocCounter{allocs: 2}, conf)
+
+	ar1, cleanup1 := allocrunner.TestAllocRunnerFromAlloc(t, mock.Alloc())
+	defer cleanup1()
+	ar2, cleanup2 := allocrunner.TestAllocRunnerFromAlloc(t, mock.Alloc())
+	defer cleanup2()
+
+	go ar1.Run()
+	go ar2.Run()
+
+	gc.MarkForCollection(ar1.Alloc().ID, ar1)
+	gc.MarkForCollection(ar2.Alloc().ID, ar2)
+
+	// Exit the alloc runners
+	exitAllocRunner(ar1, ar2)
+
+	statsCollector.availableValues = []uint64{1 << 40}
+	statsCollector.usedPercents = []float64{20}
+	statsCollector.inodePercents = []float64{10}
+
+	if err := gc.keepUsageBelowThreshold(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// We shouldn't GC any of the allocs for being over max allocs since they
+	// were just marked for collection
+	if n := gc.allocRunners.Length(); n != 2 {
+		t.Fatalf("expected 2 allocs to be retained, got %d", n)
+	}
+
+	// We shouldn't make room for new allocs by collecting them either as
+	// there is enough disk space
+	if err := gc.MakeRoomFor([]*structs.Allocation{mock.Alloc()}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if n := gc.allocRunners.Length(); n != 2 {
+		t.Fatalf("expected 2 allocs to be retained, got %d", n)
+	}
+}
+
+func TestAllocGarbageCollector_MinAllocRetention_DiskPressure(t *testing.T) {
+	t.Parallel()
+	logger := testlog.HCLogger(t)
+	statsCollector := &MockStatsCollector{}
+	conf := gcConfig()
+	conf.MinAllocRetention = time.Hour
+	gc := NewAllocGarbageCollector(logger, statsCollector, &MockAllocCounter{}, conf)
+
+	ar1, cleanup1 := allocrunner.TestAllocRunnerFromAlloc(t, mock.Alloc())
+	defer cleanup1()
+	ar2, cleanup2 := allocrunner.TestAllocRunnerFromAlloc(t, mock.Alloc())
+	defer cleanup2()
+
+	go ar1.Run()
+	go ar2.Run()
+
+	gc.MarkForCollection(ar1.Alloc().ID, ar1)
+	gc.MarkForCollection(ar2.Alloc().ID, ar2)
+
+	// Exit the alloc runners
+	exitAllocRunner(ar1, ar2)
+
+	statsCollector.availableValues = []uint64{1000, 800}
+	statsCollector.usedPercents = []float64{85, 60}
+	statsCollector.inodePercents = []float64{50, 30}
+
+	if err := gc.keepUsageBelowThreshold(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Disk pressure overrides the retention so a single alloc is collected
+	if n := gc.allocRunners.Length(); n != 1 {
+		t.Fatalf("expected 1 alloc to be retained, got %d", n)
+	}
+}
+
+// fixedStatsCollector always returns the same host stats
+type fixedStatsCollector struct {
+	stats *stats.HostStats
+}
+
+func (f *fixedStatsCollector) Collect() error {
+	return nil
+}
+
+func (f *fixedStatsCollector) Stats() *stats.HostStats {
+	return f.stats
+}
+
+func TestAllocGarbageCollector_WouldCollect(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	logger := testlog.HCLogger(t)
+
+	// The disk is 85% used and each alloc reserves 15% of it
+	statsCollector := &fixedStatsCollector{
+		stats: &stats.HostStats{
+			AllocDirStats: &stats.DiskStats{
+				Size:              1000 * MB,
+				Used:              850 * MB,
+				Available:         150 * MB,
+				UsedPercent:       85,
+				InodesUsedPercent: 10,
+			},
+		},
+	}
+	gc := NewAllocGarbageCollector(logger, statsCollector, &MockAllocCounter{allocs: 3}, gcConfig())
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		alloc := mock.Alloc()
+		alloc.AllocatedResources.Shared.DiskMB = 150
+		ar, cleanup := allocrunner.TestAllocRunnerFromAlloc(t, alloc)
+		defer cleanup()
+		gc.MarkForCollection(alloc.ID, ar)
+		ids = append(ids, alloc.ID)
+	}
+
+	// With the live config only the oldest alloc is collected
+	config := gc.Config()
+	collected, err := gc.WouldCollect(&config)
+	require.NoError(err)
+	require.Len(collected, 3)
+	require.Contains(collected[ids[0]], "disk usage of 85")
+	require.Empty(collected[ids[1]])
+	require.Empty(collected[ids[2]])
+
+	// A lower disk threshold collects more allocs
+	config.DiskUsageThreshold = 60
+	collected, err = gc.WouldCollect(&config)
+	require.NoError(err)
+	require.Contains(collected[ids[0]], "disk usage of 85")
+	require.Contains(collected[ids[1]], "disk usage of 70")
+	require.Empty(collected[ids[2]])
+
+	// Allocs over the limit are only collected after their retention
+	config = gc.Config()
+	config.DiskUsageThreshold = 100
+	config.MaxAllocs = 1
+	config.MinAllocRetention = time.Hour
+	collected, err = gc.WouldCollect(&config)
+	require.NoError(err)
+	for _, id := range ids {
+		require.Empty(collected[id])
+	}
+
+	config.MinAllocRetention = 0
+	collected, err = gc.WouldCollect(&config)
+	require.NoError(err)
+	require.Contains(collected[ids[0]], "number of allocations (3)")
+	require.Contains(collected[ids[1]], "number of allocations (2)")
+	require.Empty(collected[ids[2]])
+
+	// Nothing was collected
+	require.Equal(3, gc.allocRunners.Length())
+	require.Equal(float64(80), gc.Config().DiskUsageThreshold)
+}
@@ -0,0 +1,321 @@
+// Package cgutil reads the cgroup configuration that was applied to a running
+// process. It supports both the legacy (v1) and the unified (v2) hierarchies.
+package cgutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCgroupsUnsupported is returned on platforms without cgroups.
+	ErrCgroupsUnsupported = errors.New("cgroups are not supported on this platform")
+
+	// ErrReclaimUnsupported is returned when the memory of a cgroup can't be
+	// reclaimed on demand, which requires the memory.reclaim file of the
+	// unified hierarchy.
+	ErrReclaimUnsupported = errors.New("memory reclaim requires cgroup v2 with memory.reclaim (Linux 5.19+)")
+)
+
+const (
+	// Version1 and Version2 identify the cgroup hierarchy a configuration was
+	// read from.
+	Version1 = 1
+	Version2 = 2
+)
+
+// v1LimitFiles are the limit files read from each legacy controller.
+var v1LimitFiles = map[string][]string{
+	"memory": {"memory.limit_in_bytes", "memory.soft_limit_in_bytes", "memory.memsw.limit_in_bytes"},
+	"cpu":    {"cpu.shares", "cpu.cfs_quota_us", "cpu.cfs_period_us"},
+	"pids":   {"pids.max"},
+	"cpuset": {"cpuset.cpus", "cpuset.mems"},
+}
+
+// v2LimitFiles are the limit files read from the unified hierarchy.
+var v2LimitFiles = []string{
+	"memory.max", "memory.high", "memory.low", "memory.swap.max",
+	"cpu.max", "cpu.weight",
+	"pids.max",
+	"cpuset.cpus.effective", "cpuset.mems.effective",
+}
+
+// Config is the effective cgroup configuration of a process.
+type Config struct {
+	// Version is the cgroup version the limits were read from.
+	Version int
+
+	// Paths maps each controller to the cgroup directory of the process on
+	// the host. The unified hierarchy is keyed by an empty controller name.
+	Paths map[string]string
+
+	// Limits maps each limit file (eg memory.max) to its raw content.
+	// Files that do not exist on the host are omitted.
+	Limits map[string]string
+}
+
+// v1UnlimitedMemory is the smallest memory.limit_in_bytes value treated as
+// unlimited. The kernel reports the max page aligned int64 when no limit is set.
+const v1UnlimitedMemory = 1 << 62
+
+// MemoryLimit returns the hard memory limit in bytes and whether one is set.
+func (c *Config) MemoryLimit() (uint64, bool) {
+	file := "memory.limit_in_bytes"
+	if c.Version == Version2 {
+		file = "memory.max"
+	}
+
+	raw, ok := c.Limits[file]
+	if !ok || raw == "max" {
+		return 0, false
+	}
+
+	limit, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || limit >= v1UnlimitedMemory {
+		return 0, false
+	}
+	return limit, true
+}
+
+// Counters are the cumulative usage counters of a cgroup. They start at zero
+// when the cgroup is created.
+type Counters struct {
+	// CpuTime is the CPU time consumed in nanoseconds
+	CpuTime uint64
+
+	// BytesWritten is the number of bytes written to block devices
+	BytesWritten uint64
+
+	// Measured lists the counters that could be read
+	Measured []string
+}
+
+const (
+	// The names of the counters reported in Measured
+	CounterCpuTime      = "CPU Time"
+	CounterBytesWritten = "Bytes Written"
+)
+
+// parseMemoryStatFile returns the page cache size in bytes of a v2 memory.stat
+// file.
+func parseMemoryStatFile(r io.Reader) (uint64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 || fields[0] != "file" {
+			continue
+		}
+		return strconv.ParseUint(fields[1], 10, 64)
+	}
+
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("memory.stat has no file")
+}
+
+// parseCpuStatUsage returns the usage_usec of a v2 cpu.stat file in
+// nanoseconds.
+func parseCpuStatUsage(r io.Reader) (uint64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 || fields[0] != "usage_usec" {
+			continue
+		}
+
+		usec, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return usec * 1000, nil
+	}
+
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("cpu.stat has no usage_usec")
+}
+
+// parseBytesWritten returns the bytes written to all devices from a v1
+// blkio.throttle.io_service_bytes or a v2 io.stat file.
+func parseBytesWritten(r io.Reader, version int) (uint64, error) {
+	var total uint64
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		if version == Version1 {
+			// 8:0 Write 4096
+			if len(fields) != 3 || fields[1] != "Write" {
+				continue
+			}
+			n, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			total += n
+			continue
+		}
+
+		// 8:0 rbytes=4096 wbytes=8192 rios=1 wios=2
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "wbytes=") {
+				continue
+			}
+			n, err := strconv.ParseUint(strings.TrimPrefix(f, "wbytes="), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+	}
+
+	return total, s.Err()
+}
+
+// procCgroup is an entry of /proc/<pid>/cgroup
+type procCgroup struct {
+	controllers []string
+	path        string
+}
+
+// parseProcCgroup parses the content of /proc/<pid>/cgroup. Entries of the
+// unified hierarchy have no controllers.
+func parseProcCgroup(r io.Reader) ([]procCgroup, error) {
+	var entries []procCgroup
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid cgroup entry %q", line)
+		}
+
+		var controllers []string
+		if parts[1] != "" {
+			controllers = strings.Split(parts[1], ",")
+		}
+		entries = append(entries, procCgroup{controllers: controllers, path: parts[2]})
+	}
+
+	return entries, s.Err()
+}
+
+// cgroupMount is a mounted cgroup hierarchy
+type cgroupMount struct {
+	root        string
+	mountpoint  string
+	unified     bool
+	controllers map[string]struct{}
+}
+
+// parseMountInfo parses the content of /proc/<pid>/mountinfo and returns the
+// mounted cgroup hierarchies.
+func parseMountInfo(r io.Reader) ([]*cgroupMount, error) {
+	var mounts []*cgroupMount
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+
+		// The optional fields are terminated by a single hyphen, after which
+		// come the filesystem type, source and super options.
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep == -1 || len(fields) < sep+4 {
+			continue
+		}
+
+		m := &cgroupMount{
+			root:       fields[3],
+			mountpoint: fields[4],
+		}
+		switch fields[sep+1] {
+		case "cgroup2":
+			m.unified = true
+		case "cgroup":
+			m.controllers = make(map[string]struct{})
+			for _, opt := range strings.Split(fields[sep+3], ",") {
+				m.controllers[opt] = struct{}{}
+			}
+		default:
+			continue
+		}
+		mounts = append(mounts, m)
+	}
+
+	return mounts, s.Err()
+}
+
+// findMount returns the mount serving the given controllers, or the unified
+// hierarchy if no controllers are given.
+func findMount(mounts []*cgroupMount, controllers []string) *cgroupMount {
+OUTER:
+	for _, m := range mounts {
+		if len(controllers) == 0 {
+			if m.unified {
+				return m
+			}
+			continue
+		}
+
+		if m.unified {
+			continue
+		}
+		for _, c := range controllers {
+			if _, ok := m.controllers[c]; !ok {
+				continue OUTER
+			}
+		}
+		return m
+	}
+	return nil
+}
+
+// resolve maps the cgroup entries of a process to directories on the host
+// and determines which hierarchy version holds the process' controllers.
+func resolve(entries []procCgroup, mounts []*cgroupMount) (int, map[string]string) {
+	version := Version2
+	paths := make(map[string]string, len(entries))
+	for _, e := range entries {
+		m := findMount(mounts, e.controllers)
+		if m == nil {
+			continue
+		}
+
+		rel := e.path
+		if m.root != "/" {
+			rel = strings.TrimPrefix(rel, m.root)
+		}
+		dir := filepath.Join(m.mountpoint, rel)
+
+		if len(e.controllers) == 0 {
+			paths[""] = dir
+			continue
+		}
+
+		version = Version1
+		for _, c := range e.controllers {
+			paths[c] = dir
+		}
+	}
+
+	return version, paths
+}
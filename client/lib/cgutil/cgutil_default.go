@@ -0,0 +1,21 @@
+// +build !linux
+
+package cgutil
+
+// ReadConfig returns the effective cgroup configuration of the given process.
+// Here it always returns ErrCgroupsUnsupported.
+func ReadConfig(pid int) (*Config, error) {
+	return nil, ErrCgroupsUnsupported
+}
+
+// ReadCounters returns the cumulative usage counters of the cgroups of the
+// configuration. Here no counters are measured.
+func (c *Config) ReadCounters() *Counters {
+	return &Counters{}
+}
+
+// ReclaimMemory reclaims the page cache of the cgroup. Here it always returns
+// ErrCgroupsUnsupported.
+func (c *Config) ReclaimMemory() (uint64, error) {
+	return 0, ErrCgroupsUnsupported
+}
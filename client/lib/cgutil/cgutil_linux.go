@@ -0,0 +1,179 @@
+// +build linux
+
+package cgutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ReadConfig returns the effective cgroup configuration of the given process.
+func ReadConfig(pid int) (*Config, error) {
+	cgroupFile, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer cgroupFile.Close()
+
+	entries, err := parseProcCgroup(cgroupFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mountInfo, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer mountInfo.Close()
+
+	mounts, err := parseMountInfo(mountInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	version, paths := resolve(entries, mounts)
+	c := &Config{
+		Version: version,
+		Paths:   paths,
+		Limits:  make(map[string]string),
+	}
+
+	if version == Version2 {
+		readLimits(c.Limits, paths[""], v2LimitFiles)
+		return c, nil
+	}
+
+	for controller, files := range v1LimitFiles {
+		if dir, ok := paths[controller]; ok {
+			readLimits(c.Limits, dir, files)
+		}
+	}
+	return c, nil
+}
+
+// ReadCounters returns the cumulative usage counters of the cgroups of the
+// configuration. Counters that can't be read are omitted from Measured.
+func (c *Config) ReadCounters() *Counters {
+	counters := &Counters{}
+
+	if c.Version == Version2 {
+		dir := c.Paths[""]
+		if f, err := os.Open(filepath.Join(dir, "cpu.stat")); err == nil {
+			if usage, err := parseCpuStatUsage(f); err == nil {
+				counters.CpuTime = usage
+				counters.Measured = append(counters.Measured, CounterCpuTime)
+			}
+			f.Close()
+		}
+		if f, err := os.Open(filepath.Join(dir, "io.stat")); err == nil {
+			if written, err := parseBytesWritten(f, Version2); err == nil {
+				counters.BytesWritten = written
+				counters.Measured = append(counters.Measured, CounterBytesWritten)
+			}
+			f.Close()
+		}
+		return counters
+	}
+
+	if dir, ok := c.Paths["cpuacct"]; ok {
+		if raw, err := ioutil.ReadFile(filepath.Join(dir, "cpuacct.usage")); err == nil {
+			if usage, err := strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64); err == nil {
+				counters.CpuTime = usage
+				counters.Measured = append(counters.Measured, CounterCpuTime)
+			}
+		}
+	}
+	if dir, ok := c.Paths["blkio"]; ok {
+		if f, err := os.Open(filepath.Join(dir, "blkio.throttle.io_service_bytes")); err == nil {
+			if written, err := parseBytesWritten(f, Version1); err == nil {
+				counters.BytesWritten = written
+				counters.Measured = append(counters.Measured, CounterBytesWritten)
+			}
+			f.Close()
+		}
+	}
+	return counters
+}
+
+// readLimits reads each of the files in dir and stores their content in
+// limits. Missing files are skipped.
+func readLimits(limits map[string]string, dir string, files []string) {
+	if dir == "" {
+		return
+	}
+
+	for _, f := range files {
+		raw, err := ioutil.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			continue
+		}
+		limits[f] = strings.TrimSpace(string(raw))
+	}
+}
+
+// ReclaimMemory reclaims the page cache of the cgroup and returns the number of
+// bytes its memory usage dropped by. ErrReclaimUnsupported is returned unless
+// the cgroup is in the unified hierarchy of a kernel supporting memory.reclaim.
+func (c *Config) ReclaimMemory() (uint64, error) {
+	if c.Version != Version2 {
+		return 0, ErrReclaimUnsupported
+	}
+
+	dir := c.Paths[""]
+	reclaim := filepath.Join(dir, "memory.reclaim")
+	if _, err := os.Stat(reclaim); os.IsNotExist(err) {
+		return 0, ErrReclaimUnsupported
+	} else if err != nil {
+		return 0, err
+	}
+
+	before, err := readUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return 0, err
+	}
+	cache, err := parseMemoryStatFile(f)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	// The kernel fails with EAGAIN when less than requested was reclaimed,
+	// eg as pages were dirty or in use
+	if cache > 0 {
+		err := ioutil.WriteFile(reclaim, []byte(strconv.FormatUint(cache, 10)), 0)
+		if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EAGAIN {
+			err = nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	after, err := readUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return 0, err
+	}
+	if after > before {
+		return 0, nil
+	}
+	return before - after, nil
+}
+
+// readUint reads a file holding a single unsigned integer.
+func readUint(path string) (uint64, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+}
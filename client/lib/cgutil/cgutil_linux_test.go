@@ -0,0 +1,21 @@
+// +build linux
+
+package cgutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgutil_ReadConfig(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	c, err := ReadConfig(os.Getpid())
+	require.NoError(err)
+	require.Contains([]int{Version1, Version2}, c.Version)
+	require.NotEmpty(c.Paths)
+	require.NotNil(c.Limits)
+}
@@ -0,0 +1,129 @@
+package cgutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testMountInfo = `24 1 0:22 / / rw,relatime - overlay overlay rw
+32 24 0:28 / /sys/fs/cgroup rw,relatime - tmpfs tmpfs rw,mode=755
+33 32 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,relatime shared:9 - cgroup cgroup rw,cpu,cpuacct
+36 32 0:32 /nomad /sys/fs/cgroup/memory rw,relatime - cgroup cgroup rw,memory
+40 32 0:36 / /sys/fs/cgroup/pids rw,relatime - cgroup cgroup rw,pids
+42 32 0:38 / /sys/fs/cgroup/unified rw,relatime - cgroup2 cgroup2 rw
+`
+
+func TestCgutil_parseProcCgroup(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	entries, err := parseProcCgroup(strings.NewReader("4:memory:/nomad/abc\n2:cpu,cpuacct:/abc\n0::/abc\n"))
+	require.NoError(err)
+	require.Len(entries, 3)
+	require.Equal([]string{"memory"}, entries[0].controllers)
+	require.Equal("/nomad/abc", entries[0].path)
+	require.Equal([]string{"cpu", "cpuacct"}, entries[1].controllers)
+	require.Empty(entries[2].controllers)
+
+	_, err = parseProcCgroup(strings.NewReader("garbage\n"))
+	require.Error(err)
+}
+
+func TestCgutil_MemoryLimit(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cases := []struct {
+		Config   *Config
+		Limit    uint64
+		HasLimit bool
+	}{
+		{&Config{Version: Version2, Limits: map[string]string{"memory.max": "268435456"}}, 268435456, true},
+		{&Config{Version: Version2, Limits: map[string]string{"memory.max": "max"}}, 0, false},
+		{&Config{Version: Version1, Limits: map[string]string{"memory.limit_in_bytes": "268435456"}}, 268435456, true},
+		{&Config{Version: Version1, Limits: map[string]string{"memory.limit_in_bytes": "9223372036854771712"}}, 0, false},
+		{&Config{Version: Version1, Limits: map[string]string{}}, 0, false},
+	}
+
+	for _, c := range cases {
+		limit, ok := c.Config.MemoryLimit()
+		require.Equal(c.Limit, limit)
+		require.Equal(c.HasLimit, ok)
+	}
+}
+
+func TestCgutil_resolve_V1(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mounts, err := parseMountInfo(strings.NewReader(testMountInfo))
+	require.NoError(err)
+	require.Len(mounts, 4)
+
+	entries, err := parseProcCgroup(strings.NewReader("4:memory:/nomad/abc\n2:cpu,cpuacct:/abc\n1:pids:/abc\n0::/abc\n"))
+	require.NoError(err)
+
+	version, paths := resolve(entries, mounts)
+	require.Equal(Version1, version)
+	require.Equal("/sys/fs/cgroup/memory/abc", paths["memory"])
+	require.Equal("/sys/fs/cgroup/cpu,cpuacct/abc", paths["cpu"])
+	require.Equal("/sys/fs/cgroup/cpu,cpuacct/abc", paths["cpuacct"])
+	require.Equal("/sys/fs/cgroup/pids/abc", paths["pids"])
+	require.Equal("/sys/fs/cgroup/unified/abc", paths[""])
+}
+
+func TestCgutil_resolve_V2(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mounts, err := parseMountInfo(strings.NewReader("30 24 0:26 / /sys/fs/cgroup rw - cgroup2 cgroup2 rw,nsdelegate\n"))
+	require.NoError(err)
+
+	entries, err := parseProcCgroup(strings.NewReader("0::/nomad.slice/abc.scope\n"))
+	require.NoError(err)
+
+	version, paths := resolve(entries, mounts)
+	require.Equal(Version2, version)
+	require.Equal(map[string]string{"": "/sys/fs/cgroup/nomad.slice/abc.scope"}, paths)
+}
+
+func TestCgutil_parseCpuStatUsage(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	usage, err := parseCpuStatUsage(strings.NewReader("usage_usec 1500\nuser_usec 1000\nsystem_usec 500\n"))
+	require.NoError(err)
+	require.Equal(uint64(1500000), usage)
+
+	_, err = parseCpuStatUsage(strings.NewReader("user_usec 1000\n"))
+	require.Error(err)
+}
+
+func TestCgutil_parseMemoryStatFile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cache, err := parseMemoryStatFile(strings.NewReader("anon 4096\nfile 8192\nkernel_stack 1024\n"))
+	require.NoError(err)
+	require.Equal(uint64(8192), cache)
+
+	_, err = parseMemoryStatFile(strings.NewReader("anon 4096\n"))
+	require.Error(err)
+}
+
+func TestCgutil_parseBytesWritten(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	v1 := "8:0 Read 100\n8:0 Write 4096\n8:16 Write 1024\n8:0 Total 5220\nTotal 5220\n"
+	written, err := parseBytesWritten(strings.NewReader(v1), Version1)
+	require.NoError(err)
+	require.Equal(uint64(5120), written)
+
+	v2 := "8:0 rbytes=100 wbytes=4096 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=0 wbytes=1024 rios=0 wios=1\n"
+	written, err = parseBytesWritten(strings.NewReader(v2), Version2)
+	require.NoError(err)
+	require.Equal(uint64(5120), written)
+}
@@ -0,0 +1,276 @@
+// Package execaudit records the commands executed in tasks by operators to an
+// append-only log on the client.
+package execaudit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the size in bytes after which the log is rotated.
+	// Only the previous log file is kept, so at most twice the size is used.
+	DefaultMaxSize = 16 * 1024 * 1024
+
+	// readChunkSize is the size of the chunks the log is read backwards in
+	readChunkSize = 64 * 1024
+)
+
+// Entry is a command executed in a task
+type Entry struct {
+	// AccessorID is the accessor of the token that ran the command. It is
+	// empty if ACLs are disabled.
+	AccessorID string
+
+	// AllocID, Namespace and Task are the task the command ran in
+	AllocID   string
+	Namespace string
+	Task      string
+
+	// Command is the command and its arguments
+	Command []string
+
+	// StartTime and EndTime are when the command started and exited
+	StartTime time.Time
+	EndTime   time.Time
+
+	// ExitCode and Signal are the exit code of the command and the signal
+	// that killed it, if any
+	ExitCode int
+	Signal   int
+
+	// Error is set if the command could not be run
+	Error string `json:",omitempty"`
+}
+
+// Filter selects the entries returned by Query
+type Filter struct {
+	// AllocID and Task only return the commands run in the allocation or
+	// task when set
+	AllocID string
+	Task    string
+
+	// Since only returns the commands started at or after the time when set
+	Since time.Time
+
+	// Limit only returns the given number of most recent entries when set
+	Limit int
+}
+
+func (f *Filter) matches(e *Entry) bool {
+	if f.AllocID != "" && f.AllocID != e.AllocID {
+		return false
+	}
+	if f.Task != "" && f.Task != e.Task {
+		return false
+	}
+	if !f.Since.IsZero() && e.StartTime.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only log of entries stored as a JSON object per line. Once
+// the file reaches maxSize it is rotated to the path suffixed with ".1",
+// replacing the previous rotated file.
+type Log struct {
+	path    string
+	maxSize int64
+
+	// l protects f and size
+	l    sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens the log at the path, creating it if it doesn't exist.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, maxSize: DefaultMaxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the log file for appending. Must be called with l held.
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open exec audit log: %v", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat exec audit log: %v", err)
+	}
+
+	l.f = f
+	l.size = fi.Size()
+	return nil
+}
+
+// rotatedPath returns the path of the previous log file
+func (l *Log) rotatedPath() string {
+	return l.path + ".1"
+}
+
+// Append writes the entry to the log and syncs it to disk.
+func (l *Log) Append(e *Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.l.Lock()
+	defer l.l.Unlock()
+	if l.f == nil {
+		return fmt.Errorf("exec audit log is closed")
+	}
+
+	if _, err := l.f.Write(line); err != nil {
+		return fmt.Errorf("failed to write exec audit log: %v", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+
+	l.size += int64(len(line))
+	if l.maxSize > 0 && l.size >= l.maxSize {
+		return l.rotate()
+	}
+	return nil
+}
+
+// rotate moves the log file to the rotated path and opens a new one. Must be
+// called with l held.
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("failed to close exec audit log: %v", err)
+	}
+	l.f = nil
+
+	// Keep appending to the current file if it can't be moved
+	renameErr := os.Rename(l.path, l.rotatedPath())
+	if err := l.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to rotate exec audit log: %v", renameErr)
+	}
+	return nil
+}
+
+// Query returns the entries matching the filter, oldest first. The log is
+// read backwards, newest first, so queries with a Limit stop once enough
+// entries are found. Lines that can't be decoded, such as one partially
+// written when the client crashed, are skipped.
+func (l *Log) Query(filter *Filter) ([]*Entry, error) {
+	// Open both files together so a concurrent rotation isn't read twice
+	l.l.Lock()
+	current, err := os.Open(l.path)
+	if err != nil {
+		l.l.Unlock()
+		return nil, fmt.Errorf("failed to open exec audit log: %v", err)
+	}
+	defer current.Close()
+
+	rotated, err := os.Open(l.rotatedPath())
+	if err == nil {
+		defer rotated.Close()
+	} else if !os.IsNotExist(err) {
+		l.l.Unlock()
+		return nil, fmt.Errorf("failed to open exec audit log: %v", err)
+	}
+	l.l.Unlock()
+
+	var entries []*Entry
+	limited := func() bool {
+		return filter.Limit > 0 && len(entries) >= filter.Limit
+	}
+	collect := func(line []byte) bool {
+		var e Entry
+		if json.Unmarshal(line, &e) == nil && filter.matches(&e) {
+			entries = append(entries, &e)
+		}
+		return !limited()
+	}
+
+	if err := readReverse(current, collect); err != nil {
+		return nil, err
+	}
+	if rotated != nil && !limited() {
+		if err := readReverse(rotated, collect); err != nil {
+			return nil, err
+		}
+	}
+
+	// Return the entries oldest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// readReverse calls fn with each non-empty line of the file, without its
+// newline, starting from the last one. It stops once fn returns false.
+func readReverse(f *os.File, fn func(line []byte) bool) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat exec audit log: %v", err)
+	}
+
+	// buf holds the start of the file that hasn't been split into lines yet
+	var buf []byte
+	for pos := fi.Size(); pos > 0; {
+		n := int64(readChunkSize)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+
+		chunk := make([]byte, n, n+int64(len(buf)))
+		if _, err := f.ReadAt(chunk, pos); err != nil {
+			return fmt.Errorf("failed to read exec audit log: %v", err)
+		}
+		buf = append(chunk, buf...)
+
+		// Every line after a newline is complete
+		for {
+			i := bytes.LastIndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+
+			line := buf[i+1:]
+			buf = buf[:i]
+			if len(line) > 0 && !fn(line) {
+				return nil
+			}
+		}
+	}
+
+	// The first line of the file
+	if len(buf) > 0 {
+		fn(buf)
+	}
+	return nil
+}
+
+// Close closes the log. Entries can't be appended once closed.
+func (l *Log) Close() error {
+	l.l.Lock()
+	defer l.l.Unlock()
+	if l.f == nil {
+		return nil
+	}
+
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
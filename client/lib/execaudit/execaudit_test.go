@@ -0,0 +1,154 @@
+package execaudit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_AppendQuery(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "execaudit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "exec-audit.log")
+	l, err := Open(path)
+	require.NoError(err)
+
+	start := time.Now().Round(0)
+	entries := []*Entry{
+		{AccessorID: "a", AllocID: "alloc1", Task: "web", Command: []string{"ls"}, StartTime: start},
+		{AccessorID: "b", AllocID: "alloc1", Task: "db", Command: []string{"ps"}, StartTime: start.Add(time.Second), ExitCode: 1},
+		{AccessorID: "a", AllocID: "alloc2", Task: "web", Command: []string{"cat", "x"}, StartTime: start.Add(2 * time.Second), Error: "boom"},
+	}
+	for _, e := range entries {
+		require.NoError(l.Append(e))
+	}
+
+	all, err := l.Query(&Filter{})
+	require.NoError(err)
+	require.Len(all, 3)
+	for i, e := range all {
+		require.Equal(entries[i].Command, e.Command)
+		require.True(entries[i].StartTime.Equal(e.StartTime))
+	}
+
+	found, err := l.Query(&Filter{AllocID: "alloc1"})
+	require.NoError(err)
+	require.Len(found, 2)
+
+	found, err = l.Query(&Filter{Task: "web"})
+	require.NoError(err)
+	require.Len(found, 2)
+	require.Equal("boom", found[1].Error)
+
+	found, err = l.Query(&Filter{Since: start.Add(time.Second)})
+	require.NoError(err)
+	require.Len(found, 2)
+	require.Equal("b", found[0].AccessorID)
+
+	// The most recent entries are kept
+	found, err = l.Query(&Filter{Limit: 1})
+	require.NoError(err)
+	require.Len(found, 1)
+	require.Equal("alloc2", found[0].AllocID)
+
+	// Entries survive reopening the log
+	require.NoError(l.Close())
+	require.Error(l.Append(entries[0]))
+
+	l, err = Open(path)
+	require.NoError(err)
+	defer l.Close()
+	require.NoError(l.Append(entries[0]))
+
+	all, err = l.Query(&Filter{})
+	require.NoError(err)
+	require.Len(all, 4)
+}
+
+func TestLog_Query_PartialLine(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "execaudit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "exec-audit.log")
+	l, err := Open(path)
+	require.NoError(err)
+	defer l.Close()
+
+	require.NoError(l.Append(&Entry{AllocID: "alloc1", Command: []string{"ls"}}))
+
+	// Simulate a write interrupted by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(err)
+	_, err = f.WriteString(`{"AllocID":"allo`)
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	all, err := l.Query(&Filter{})
+	require.NoError(err)
+	require.Len(all, 1)
+	require.Equal("alloc1", all[0].AllocID)
+}
+
+func TestLog_Rotate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "execaudit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "exec-audit.log")
+	l, err := Open(path)
+	require.NoError(err)
+	defer l.Close()
+
+	// Write enough entries to span several read chunks and rotations
+	l.maxSize = 4 * readChunkSize
+	arg := strings.Repeat("x", 1000)
+	for i := 0; i < 1000; i++ {
+		require.NoError(l.Append(&Entry{AllocID: fmt.Sprintf("alloc%d", i), Command: []string{"echo", arg}}))
+	}
+
+	// Files are rotated once the entry reaching the max size is written
+	for _, p := range []string{path, path + ".1"} {
+		fi, err := os.Stat(p)
+		require.NoError(err)
+		require.True(fi.Size() < l.maxSize+2000, "%s is %d bytes", p, fi.Size())
+	}
+
+	// Only the entries of the two files are kept, oldest first
+	all, err := l.Query(&Filter{})
+	require.NoError(err)
+	require.NotEmpty(all)
+	require.True(len(all) < 1000)
+	for i, e := range all {
+		require.Equal(fmt.Sprintf("alloc%d", 1000-len(all)+i), e.AllocID)
+	}
+
+	// Limited queries return the most recent entries
+	found, err := l.Query(&Filter{Limit: 3})
+	require.NoError(err)
+	require.Len(found, 3)
+	require.Equal("alloc997", found[0].AllocID)
+	require.Equal("alloc999", found[2].AllocID)
+
+	// The rotated file is read once the current one is exhausted
+	found, err = l.Query(&Filter{AllocID: all[0].AllocID})
+	require.NoError(err)
+	require.Len(found, 1)
+}
@@ -0,0 +1,128 @@
+// Package procfd lists the open file descriptors of a process.
+package procfd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupported is returned on platforms where the file descriptors of
+	// a process can't be listed.
+	ErrUnsupported = errors.New("listing file descriptors is not supported on this platform")
+)
+
+const (
+	// The types of file descriptors
+	TypeFile      = "file"
+	TypeSocket    = "socket"
+	TypePipe      = "pipe"
+	TypeAnonInode = "anon_inode"
+)
+
+// FD is an open file descriptor.
+type FD struct {
+	// Num is the file descriptor number
+	Num int
+
+	// Path is the target of the file descriptor. For sockets and pipes it
+	// includes the inode, eg socket:[1234].
+	Path string
+
+	// Type is the type of the file descriptor
+	Type string
+
+	// Flags are the names of the flags the file was opened with
+	Flags []string
+}
+
+// fdType returns the type of a file descriptor from its link target.
+func fdType(target string) string {
+	for _, t := range []string{TypeSocket, TypePipe, TypeAnonInode} {
+		if strings.HasPrefix(target, t+":") {
+			return t
+		}
+	}
+	return TypeFile
+}
+
+// flagNames maps the open flags to their names
+var flagNames = []struct {
+	flag uint64
+	name string
+}{
+	{0x400, "O_APPEND"},
+	{0x800, "O_NONBLOCK"},
+	{0x1000, "O_DSYNC"},
+	{0x2000, "O_ASYNC"},
+	{0x4000, "O_DIRECT"},
+	{0x10000, "O_DIRECTORY"},
+	{0x20000, "O_NOFOLLOW"},
+	{0x40000, "O_NOATIME"},
+	{0x80000, "O_CLOEXEC"},
+	{0x101000, "O_SYNC"},
+	{0x200000, "O_PATH"},
+}
+
+// parseFlags parses the flags of a /proc/<pid>/fdinfo/<fd> file and returns
+// their names. The access mode is always first.
+func parseFlags(r io.Reader) ([]string, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 2)
+		if len(parts) != 2 || parts[0] != "flags" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 8, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		switch flags & 0x3 {
+		case 0x0:
+			names = append(names, "O_RDONLY")
+		case 0x1:
+			names = append(names, "O_WRONLY")
+		default:
+			names = append(names, "O_RDWR")
+		}
+		for _, f := range flagNames {
+			if flags&f.flag == f.flag {
+				names = append(names, f.name)
+			}
+		}
+		return names, nil
+	}
+
+	return nil, s.Err()
+}
+
+// Diff returns the file descriptors opened and closed between two listings.
+// A descriptor whose target changed is reported as closed and opened.
+func Diff(prev, cur []*FD) (opened, closed []*FD) {
+	prevByNum := make(map[int]*FD, len(prev))
+	for _, fd := range prev {
+		prevByNum[fd.Num] = fd
+	}
+	curByNum := make(map[int]*FD, len(cur))
+	for _, fd := range cur {
+		curByNum[fd.Num] = fd
+	}
+
+	for _, fd := range prev {
+		if c, ok := curByNum[fd.Num]; !ok || c.Path != fd.Path {
+			closed = append(closed, fd)
+		}
+	}
+	for _, fd := range cur {
+		if p, ok := prevByNum[fd.Num]; !ok || p.Path != fd.Path {
+			opened = append(opened, fd)
+		}
+	}
+	return opened, closed
+}
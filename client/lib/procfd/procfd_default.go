@@ -0,0 +1,9 @@
+// +build !linux
+
+package procfd
+
+// List returns the open file descriptors of the process. Here it always
+// returns ErrUnsupported.
+func List(pid int) ([]*FD, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,51 @@
+// +build linux
+
+package procfd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// List returns the open file descriptors of the process sorted by number.
+// Descriptors closed while listing are skipped.
+func List(pid int) ([]*FD, error) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := make([]*FD, 0, len(entries))
+	for _, e := range entries {
+		num, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+
+		fd := &FD{
+			Num:  num,
+			Path: target,
+			Type: fdType(target),
+		}
+
+		if info, err := os.Open(fmt.Sprintf("/proc/%d/fdinfo/%d", pid, num)); err == nil {
+			fd.Flags, _ = parseFlags(info)
+			info.Close()
+		}
+
+		fds = append(fds, fd)
+	}
+
+	sort.Slice(fds, func(i, j int) bool { return fds[i].Num < fds[j].Num })
+	return fds, nil
+}
@@ -0,0 +1,35 @@
+// +build linux
+
+package procfd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcFD_List(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	f, err := ioutil.TempFile("", "procfd")
+	require.NoError(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	fds, err := List(os.Getpid())
+	require.NoError(err)
+
+	var found *FD
+	for _, fd := range fds {
+		if fd.Num == int(f.Fd()) {
+			found = fd
+		}
+	}
+	require.NotNil(found)
+	require.Equal(f.Name(), found.Path)
+	require.Equal(TypeFile, found.Type)
+	require.Contains(found.Flags, "O_RDWR")
+}
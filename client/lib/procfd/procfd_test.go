@@ -0,0 +1,54 @@
+package procfd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcFD_fdType(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Equal(TypeSocket, fdType("socket:[1234]"))
+	require.Equal(TypePipe, fdType("pipe:[1234]"))
+	require.Equal(TypeAnonInode, fdType("anon_inode:[eventfd]"))
+	require.Equal(TypeFile, fdType("/var/log/app.log"))
+}
+
+func TestProcFD_parseFlags(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	flags, err := parseFlags(strings.NewReader("pos:\t0\nflags:\t02102002\nmnt_id:\t24\n"))
+	require.NoError(err)
+	require.Equal([]string{"O_RDWR", "O_APPEND", "O_CLOEXEC"}, flags)
+
+	flags, err = parseFlags(strings.NewReader("flags:\t0100000\n"))
+	require.NoError(err)
+	require.Equal([]string{"O_RDONLY"}, flags)
+
+	_, err = parseFlags(strings.NewReader("flags:\tgarbage\n"))
+	require.Error(err)
+}
+
+func TestProcFD_Diff(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	prev := []*FD{
+		{Num: 0, Path: "/dev/null"},
+		{Num: 3, Path: "/tmp/a"},
+		{Num: 4, Path: "socket:[1]"},
+	}
+	cur := []*FD{
+		{Num: 0, Path: "/dev/null"},
+		{Num: 4, Path: "socket:[2]"},
+		{Num: 5, Path: "/tmp/b"},
+	}
+
+	opened, closed := Diff(prev, cur)
+	require.Equal([]*FD{cur[1], cur[2]}, opened)
+	require.Equal([]*FD{prev[1], prev[2]}, closed)
+}
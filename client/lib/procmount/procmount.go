@@ -0,0 +1,93 @@
+// Package procmount lists the mounts of a process's mount namespace.
+package procmount
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupported is returned on platforms where the mounts of a process
+	// can't be listed.
+	ErrUnsupported = errors.New("listing mounts is not supported on this platform")
+)
+
+// Mount is an entry of a mount table.
+type Mount struct {
+	// Source is the device or remote filesystem that is mounted
+	Source string
+
+	// Target is the mount point relative to the process's root
+	Target string
+
+	// Root is the directory of the filesystem that is mounted at Target. It
+	// is not / for bind mounts of a subdirectory.
+	Root string
+
+	// FSType is the type of the filesystem, eg ext4 or tmpfs
+	FSType string
+
+	// Options are the per mount options, eg rw or nosuid
+	Options []string
+
+	// SuperOptions are the per filesystem options
+	SuperOptions []string
+}
+
+// Parse parses the mount table in the format of /proc/<pid>/mountinfo.
+func Parse(r io.Reader) ([]*Mount, error) {
+	var mounts []*Mount
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+
+		// The optional fields are terminated by a single hyphen, after which
+		// come the filesystem type, source and super options.
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep == -1 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("invalid mountinfo line %q", s.Text())
+		}
+
+		mounts = append(mounts, &Mount{
+			Source:       unescape(fields[sep+2]),
+			Target:       unescape(fields[4]),
+			Root:         unescape(fields[3]),
+			FSType:       fields[sep+1],
+			Options:      strings.Split(fields[5], ","),
+			SuperOptions: strings.Split(fields[sep+3], ","),
+		})
+	}
+
+	return mounts, s.Err()
+}
+
+// unescape replaces the octal escapes the kernel uses for whitespace and
+// backslashes in paths, eg \040 for a space.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
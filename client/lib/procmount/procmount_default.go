@@ -0,0 +1,9 @@
+// +build !linux
+
+package procmount
+
+// List returns the mounts of the process's mount namespace. Here it always
+// returns ErrUnsupported.
+func List(pid int) ([]*Mount, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,20 @@
+// +build linux
+
+package procmount
+
+import (
+	"fmt"
+	"os"
+)
+
+// List returns the mounts of the process's mount namespace in the order they
+// were mounted.
+func List(pid int) ([]*Mount, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/mountinfo", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
@@ -0,0 +1,27 @@
+// +build linux
+
+package procmount
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcMount_List(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mounts, err := List(os.Getpid())
+	require.NoError(err)
+
+	var root *Mount
+	for _, m := range mounts {
+		if m.Target == "/" {
+			root = m
+		}
+	}
+	require.NotNil(root)
+	require.NotEmpty(root.FSType)
+}
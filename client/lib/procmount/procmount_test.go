@@ -0,0 +1,41 @@
+package procmount
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcMount_Parse(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	input := `24 1 0:22 / / rw,relatime - overlay overlay rw,lowerdir=/l
+36 24 8:1 /srv/data /alloc/data\040dir ro,nosuid shared:9 master:2 - ext4 /dev/sda1 rw,errors=remount-ro
+`
+	mounts, err := Parse(strings.NewReader(input))
+	require.NoError(err)
+	require.Len(mounts, 2)
+
+	require.Equal(&Mount{
+		Source:       "overlay",
+		Target:       "/",
+		Root:         "/",
+		FSType:       "overlay",
+		Options:      []string{"rw", "relatime"},
+		SuperOptions: []string{"rw", "lowerdir=/l"},
+	}, mounts[0])
+
+	require.Equal(&Mount{
+		Source:       "/dev/sda1",
+		Target:       "/alloc/data dir",
+		Root:         "/srv/data",
+		FSType:       "ext4",
+		Options:      []string{"ro", "nosuid"},
+		SuperOptions: []string{"rw", "errors=remount-ro"},
+	}, mounts[1])
+
+	_, err = Parse(strings.NewReader("garbage\n"))
+	require.Error(err)
+}
@@ -0,0 +1,262 @@
+// Package procsnap reads a snapshot of a process's status, scheduling, IO and
+// limits from procfs.
+package procsnap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupported is returned on platforms where processes can't be
+	// inspected.
+	ErrUnsupported = errors.New("process snapshots are not supported on this platform")
+
+	// ErrProcessGone is returned when the process exited before or while its
+	// snapshot was read.
+	ErrProcessGone = errors.New("process no longer exists")
+)
+
+// Snapshot is the state of a process at the time it was read.
+type Snapshot struct {
+	PID    int
+	Status *Status
+	Stat   *Stat
+
+	// IO is nil if the IO counters of the process can't be read, eg when
+	// lacking the permission to trace it
+	IO *IO
+
+	Limits []*Limit
+}
+
+// Status holds the fields of /proc/<pid>/status. Memory sizes are in bytes.
+type Status struct {
+	Name                     string
+	State                    string
+	PPid                     int
+	Threads                  int
+	VmPeak                   uint64
+	VmSize                   uint64
+	VmHWM                    uint64
+	VmRSS                    uint64
+	VmSwap                   uint64
+	VoluntaryCtxtSwitches    uint64
+	NonvoluntaryCtxtSwitches uint64
+}
+
+// Stat holds the fields of /proc/<pid>/stat. Times are in clock ticks.
+type Stat struct {
+	State       string
+	PPid        int
+	MinorFaults uint64
+	MajorFaults uint64
+	UserTime    uint64
+	SystemTime  uint64
+	NumThreads  int
+	StartTime   uint64
+}
+
+// IO holds the counters of /proc/<pid>/io.
+type IO struct {
+	ReadChars           uint64
+	WriteChars          uint64
+	ReadSyscalls        uint64
+	WriteSyscalls       uint64
+	ReadBytes           uint64
+	WriteBytes          uint64
+	CancelledWriteBytes uint64
+}
+
+// Limit is a resource limit of /proc/<pid>/limits. Soft and Hard are either a
+// number or "unlimited".
+type Limit struct {
+	Name  string
+	Soft  string
+	Hard  string
+	Units string
+}
+
+// ParseStatus parses the format of /proc/<pid>/status.
+func ParseStatus(r io.Reader) (*Status, error) {
+	status := &Status{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], strings.TrimSpace(parts[1])
+
+		var err error
+		switch key {
+		case "Name":
+			status.Name = value
+		case "State":
+			status.State = value
+		case "PPid":
+			status.PPid, err = strconv.Atoi(value)
+		case "Threads":
+			status.Threads, err = strconv.Atoi(value)
+		case "VmPeak":
+			status.VmPeak, err = parseKB(value)
+		case "VmSize":
+			status.VmSize, err = parseKB(value)
+		case "VmHWM":
+			status.VmHWM, err = parseKB(value)
+		case "VmRSS":
+			status.VmRSS, err = parseKB(value)
+		case "VmSwap":
+			status.VmSwap, err = parseKB(value)
+		case "voluntary_ctxt_switches":
+			status.VoluntaryCtxtSwitches, err = strconv.ParseUint(value, 10, 64)
+		case "nonvoluntary_ctxt_switches":
+			status.NonvoluntaryCtxtSwitches, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid status field %q: %v", key, err)
+		}
+	}
+
+	return status, s.Err()
+}
+
+// parseKB parses a size such as "1024 kB" into bytes.
+func parseKB(s string) (uint64, error) {
+	v, err := strconv.ParseUint(strings.TrimSuffix(s, " kB"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * 1024, nil
+}
+
+// ParseStat parses the format of /proc/<pid>/stat.
+func ParseStat(r io.Reader) (*Stat, error) {
+	raw, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The command is in parentheses and may hold spaces and parentheses so
+	// the fields start after the last closing parenthesis
+	end := strings.LastIndexByte(raw, ')')
+	if end == -1 {
+		return nil, fmt.Errorf("invalid stat %q", raw)
+	}
+	fields := strings.Fields(raw[end+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("invalid stat %q", raw)
+	}
+
+	// Fields are numbered from the state, which is the third field of the
+	// file
+	field := func(n int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = strconv.ParseUint(fields[n-3], 10, 64)
+		return v
+	}
+
+	stat := &Stat{
+		State:       fields[0],
+		PPid:        int(field(4)),
+		MinorFaults: field(10),
+		MajorFaults: field(12),
+		UserTime:    field(14),
+		SystemTime:  field(15),
+		NumThreads:  int(field(20)),
+		StartTime:   field(22),
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid stat %q: %v", raw, err)
+	}
+	return stat, nil
+}
+
+// ParseIO parses the format of /proc/<pid>/io.
+func ParseIO(r io.Reader) (*IO, error) {
+	counters := &IO{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid io line %q", s.Text())
+		}
+
+		v, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid io line %q: %v", s.Text(), err)
+		}
+
+		switch parts[0] {
+		case "rchar":
+			counters.ReadChars = v
+		case "wchar":
+			counters.WriteChars = v
+		case "syscr":
+			counters.ReadSyscalls = v
+		case "syscw":
+			counters.WriteSyscalls = v
+		case "read_bytes":
+			counters.ReadBytes = v
+		case "write_bytes":
+			counters.WriteBytes = v
+		case "cancelled_write_bytes":
+			counters.CancelledWriteBytes = v
+		}
+	}
+
+	return counters, s.Err()
+}
+
+// ParseLimits parses the format of /proc/<pid>/limits. The columns are
+// aligned with spaces and limit names hold spaces too, so the columns are
+// located using the header.
+func ParseLimits(r io.Reader) ([]*Limit, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() {
+		return nil, s.Err()
+	}
+
+	header := s.Text()
+	soft := strings.Index(header, "Soft Limit")
+	hard := strings.Index(header, "Hard Limit")
+	units := strings.Index(header, "Units")
+	if soft == -1 || hard < soft || units < hard {
+		return nil, fmt.Errorf("invalid limits header %q", header)
+	}
+
+	var limits []*Limit
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) < units {
+			return nil, fmt.Errorf("invalid limits line %q", line)
+		}
+
+		limits = append(limits, &Limit{
+			Name:  strings.TrimSpace(line[:soft]),
+			Soft:  strings.TrimSpace(line[soft:hard]),
+			Hard:  strings.TrimSpace(line[hard:units]),
+			Units: strings.TrimSpace(line[units:]),
+		})
+	}
+
+	return limits, s.Err()
+}
+
+func readAll(r io.Reader) (string, error) {
+	var b strings.Builder
+	if _, err := io.Copy(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
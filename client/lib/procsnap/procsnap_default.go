@@ -0,0 +1,15 @@
+// +build !linux
+
+package procsnap
+
+// Read returns a snapshot of the process. Here it always returns
+// ErrUnsupported.
+func Read(pid int) (*Snapshot, error) {
+	return nil, ErrUnsupported
+}
+
+// Descendants returns the PIDs of the process's descendants. Here it always
+// returns ErrUnsupported.
+func Descendants(pid int) ([]int, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,115 @@
+// +build linux
+
+package procsnap
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"syscall"
+)
+
+// Read returns a snapshot of the process. ErrProcessGone is returned if the
+// process exits before all of its files were read.
+func Read(pid int) (*Snapshot, error) {
+	snap := &Snapshot{PID: pid}
+
+	if err := readFile(pid, "status", func(r io.Reader) (err error) {
+		snap.Status, err = ParseStatus(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := readFile(pid, "stat", func(r io.Reader) (err error) {
+		snap.Stat, err = ParseStat(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	// Reading the IO counters requires the permission to trace the process
+	if err := readFile(pid, "io", func(r io.Reader) (err error) {
+		snap.IO, err = ParseIO(r)
+		return err
+	}); err != nil && !os.IsPermission(err) {
+		return nil, err
+	}
+
+	if err := readFile(pid, "limits", func(r io.Reader) (err error) {
+		snap.Limits, err = ParseLimits(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// readFile parses a file of the process's procfs directory.
+func readFile(pid int, name string, parse func(io.Reader) error) error {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/%s", pid, name))
+	if err != nil {
+		return gone(err)
+	}
+	defer f.Close()
+
+	return gone(parse(f))
+}
+
+// gone returns ErrProcessGone if the error denotes the process exited. The
+// procfs directory disappears or its files fail with ESRCH once the process
+// is reaped.
+func gone(err error) error {
+	if err == nil {
+		return nil
+	}
+	if os.IsNotExist(err) {
+		return ErrProcessGone
+	}
+	if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.ESRCH {
+		return ErrProcessGone
+	}
+	return err
+}
+
+// Descendants returns the PIDs of the process's descendants sorted by PID.
+// Processes exiting while the process table is scanned are skipped.
+func Descendants(pid int) ([]int, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	for _, e := range entries {
+		child, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+
+		var stat *Stat
+		if err := readFile(child, "stat", func(r io.Reader) (err error) {
+			stat, err = ParseStat(r)
+			return err
+		}); err != nil {
+			continue
+		}
+		children[stat.PPid] = append(children[stat.PPid], child)
+	}
+
+	var pids []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		pids = append(pids, next)
+		queue = append(queue, children[next]...)
+	}
+
+	sort.Ints(pids)
+	return pids, nil
+}
@@ -0,0 +1,50 @@
+// +build linux
+
+package procsnap
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcSnap_Read(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	snap, err := Read(os.Getpid())
+	require.NoError(err)
+	require.Equal(os.Getpid(), snap.PID)
+	require.Equal(os.Getppid(), snap.Status.PPid)
+	require.Equal(os.Getppid(), snap.Stat.PPid)
+	require.NotZero(snap.Status.VmPeak)
+	require.NotEmpty(snap.Limits)
+}
+
+func TestProcSnap_Read_Gone(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Run a process to completion so its PID is no longer in use
+	cmd := exec.Command("true")
+	require.NoError(cmd.Run())
+
+	_, err := Read(cmd.Process.Pid)
+	require.Equal(ErrProcessGone, err)
+}
+
+func TestProcSnap_Descendants(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(cmd.Start())
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	pids, err := Descendants(os.Getpid())
+	require.NoError(err)
+	require.Contains(pids, cmd.Process.Pid)
+}
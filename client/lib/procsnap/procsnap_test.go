@@ -0,0 +1,111 @@
+package procsnap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcSnap_ParseStatus(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	input := `Name:	redis-server
+State:	S (sleeping)
+PPid:	1
+Threads:	4
+VmPeak:	   52000 kB
+VmSize:	   51000 kB
+VmHWM:	    8000 kB
+VmRSS:	    7000 kB
+voluntary_ctxt_switches:	150
+nonvoluntary_ctxt_switches:	12
+`
+	status, err := ParseStatus(strings.NewReader(input))
+	require.NoError(err)
+	require.Equal(&Status{
+		Name:                     "redis-server",
+		State:                    "S (sleeping)",
+		PPid:                     1,
+		Threads:                  4,
+		VmPeak:                   52000 * 1024,
+		VmSize:                   51000 * 1024,
+		VmHWM:                    8000 * 1024,
+		VmRSS:                    7000 * 1024,
+		VoluntaryCtxtSwitches:    150,
+		NonvoluntaryCtxtSwitches: 12,
+	}, status)
+
+	_, err = ParseStatus(strings.NewReader("VmPeak:	lots kB\n"))
+	require.Error(err)
+}
+
+func TestProcSnap_ParseStat(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// The command holds a space and a parenthesis
+	input := "42 (my (app) x) S 1 42 42 0 -1 4194560 300 0 2 0 150 25 0 0 20 0 3 0 9000 52000000 1750 18446744073709551615\n"
+	stat, err := ParseStat(strings.NewReader(input))
+	require.NoError(err)
+	require.Equal(&Stat{
+		State:       "S",
+		PPid:        1,
+		MinorFaults: 300,
+		MajorFaults: 2,
+		UserTime:    150,
+		SystemTime:  25,
+		NumThreads:  3,
+		StartTime:   9000,
+	}, stat)
+
+	_, err = ParseStat(strings.NewReader("42 (app) S 1\n"))
+	require.Error(err)
+}
+
+func TestProcSnap_ParseIO(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	input := `rchar: 1000
+wchar: 2000
+syscr: 10
+syscw: 20
+read_bytes: 4096
+write_bytes: 8192
+cancelled_write_bytes: 0
+`
+	counters, err := ParseIO(strings.NewReader(input))
+	require.NoError(err)
+	require.Equal(&IO{
+		ReadChars:     1000,
+		WriteChars:    2000,
+		ReadSyscalls:  10,
+		WriteSyscalls: 20,
+		ReadBytes:     4096,
+		WriteBytes:    8192,
+	}, counters)
+
+	_, err = ParseIO(strings.NewReader("garbage\n"))
+	require.Error(err)
+}
+
+func TestProcSnap_ParseLimits(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	input := `Limit                     Soft Limit           Hard Limit           Units
+Max cpu time              unlimited            unlimited            seconds
+Max open files            1024                 524288               files
+`
+	limits, err := ParseLimits(strings.NewReader(input))
+	require.NoError(err)
+	require.Equal([]*Limit{
+		{Name: "Max cpu time", Soft: "unlimited", Hard: "unlimited", Units: "seconds"},
+		{Name: "Max open files", Soft: "1024", Hard: "524288", Units: "files"},
+	}, limits)
+
+	_, err = ParseLimits(strings.NewReader("garbage\n"))
+	require.Error(err)
+}
@@ -0,0 +1,122 @@
+// Package profiler samples the stacks of a running process with perf and
+// returns them in the collapsed format consumed by flamegraph tooling: one
+// line per unique stack with the frames separated by semicolons, root first,
+// followed by the number of samples.
+package profiler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// TypeCPU samples the stacks of the process while it is on CPU
+	TypeCPU = "cpu"
+
+	// TypeOffCPU samples the stacks of the process when it is switched off
+	// CPU
+	TypeOffCPU = "off-cpu"
+
+	// MaxDuration is the maximum duration of a profile
+	MaxDuration = 5 * time.Minute
+)
+
+var (
+	// ErrUnsupported is returned on platforms where profiling is not
+	// supported.
+	ErrUnsupported = errors.New("profiling is not supported on this platform")
+
+	// ErrPerfNotFound is returned if perf is not installed on the node
+	ErrPerfNotFound = errors.New("perf is not installed on the node")
+)
+
+// Validate returns an error if the profile type or duration is invalid.
+func Validate(profileType string, duration time.Duration) error {
+	switch profileType {
+	case TypeCPU, TypeOffCPU:
+	default:
+		return fmt.Errorf("unknown profile type %q; must be %q or %q", profileType, TypeCPU, TypeOffCPU)
+	}
+
+	if duration <= 0 || duration > MaxDuration {
+		return fmt.Errorf("profile duration must be greater than 0 and at most %v", MaxDuration)
+	}
+
+	return nil
+}
+
+// Collapse reads the output of perf script and returns the collapsed stacks
+// sorted by stack.
+func Collapse(r io.Reader) ([]byte, error) {
+	counts := make(map[string]int)
+	var comm string
+	var frames []string
+
+	flush := func() {
+		if comm == "" {
+			return
+		}
+
+		// perf lists the leaf first
+		stack := make([]string, 0, len(frames)+1)
+		stack = append(stack, comm)
+		for i := len(frames) - 1; i >= 0; i-- {
+			stack = append(stack, frames[i])
+		}
+		counts[strings.Join(stack, ";")]++
+
+		comm = ""
+		frames = frames[:0]
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		// Sample headers are not indented; frames are
+		if line[0] != ' ' && line[0] != '\t' {
+			flush()
+			if fields := strings.Fields(line); len(fields) > 0 {
+				comm = fields[0]
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		sym := fields[1]
+		if i := strings.LastIndex(sym, "+0x"); i > 0 {
+			sym = sym[:i]
+		}
+		frames = append(frames, strings.Replace(sym, ";", ":", -1))
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	stacks := make([]string, 0, len(counts))
+	for stack := range counts {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	var buf bytes.Buffer
+	for _, stack := range stacks {
+		fmt.Fprintf(&buf, "%s %d\n", stack, counts[stack])
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package profiler
+
+import (
+	"context"
+	"time"
+)
+
+// Run is not supported on this platform.
+func Run(ctx context.Context, pid int, profileType string, duration time.Duration) ([]byte, error) {
+	return nil, ErrUnsupported
+}
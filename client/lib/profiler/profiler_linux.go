@@ -0,0 +1,58 @@
+// +build linux
+
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Run profiles the process with the given pid for the duration and returns
+// the collapsed stacks. perf must be installed on the node.
+func Run(ctx context.Context, pid int, profileType string, duration time.Duration) ([]byte, error) {
+	if err := Validate(profileType, duration); err != nil {
+		return nil, err
+	}
+
+	perf, err := exec.LookPath("perf")
+	if err != nil {
+		return nil, ErrPerfNotFound
+	}
+
+	dir, err := ioutil.TempDir("", "nomad-profile")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "perf.data")
+
+	args := []string{"record", "-o", data, "-g", "-p", strconv.Itoa(pid)}
+	switch profileType {
+	case TypeCPU:
+		args = append(args, "-F", "99")
+	case TypeOffCPU:
+		args = append(args, "-e", "sched:sched_switch")
+	}
+	args = append(args, "--", "sleep", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+
+	if out, err := exec.CommandContext(ctx, perf, args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("perf record failed: %v: %s", err, bytes.TrimSpace(out))
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, perf, "script", "-i", data)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("perf script failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return Collapse(bytes.NewReader(out))
+}
@@ -0,0 +1,48 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPerfScript = `redis-server  1234 [000] 100.000001:     10101 cycles:ppp: 
+	    55d1 dictFind+0x21 (/usr/bin/redis-server)
+	    55d2 processCommand+0x1a0 (/usr/bin/redis-server)
+	    55d3 main+0x10 (/usr/bin/redis-server)
+
+redis-server  1234 [001] 100.010001:     10101 cycles:ppp: 
+	    55d1 dictFind+0x30 (/usr/bin/redis-server)
+	    55d2 processCommand+0x1a0 (/usr/bin/redis-server)
+	    55d3 main+0x10 (/usr/bin/redis-server)
+
+redis-server  1234 [001] 100.020001:     10101 cycles:ppp: 
+	    7f00 [unknown] ([unknown])
+	    55d3 main+0x10 (/usr/bin/redis-server)
+`
+
+func TestProfiler_Collapse(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	out, err := Collapse(strings.NewReader(testPerfScript))
+	require.NoError(err)
+	require.Equal("redis-server;main;[unknown] 1\nredis-server;main;processCommand;dictFind 2\n", string(out))
+
+	out, err = Collapse(strings.NewReader(""))
+	require.NoError(err)
+	require.Empty(out)
+}
+
+func TestProfiler_Validate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.NoError(Validate(TypeCPU, time.Second))
+	require.NoError(Validate(TypeOffCPU, MaxDuration))
+	require.Error(Validate("heap", time.Second))
+	require.Error(Validate(TypeCPU, 0))
+	require.Error(Validate(TypeCPU, MaxDuration+time.Second))
+}
@@ -0,0 +1,56 @@
+// Package schedstat reads how long processes wait for a CPU.
+package schedstat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned on platforms where scheduler statistics
+	// can't be read.
+	ErrUnsupported = errors.New("reading scheduler statistics is not supported on this platform")
+)
+
+// Stats are the scheduler statistics of a process
+type Stats struct {
+	// RunTime is the time spent running on a CPU
+	RunTime time.Duration
+
+	// WaitTime is the time spent runnable, waiting on a run queue
+	WaitTime time.Duration
+
+	// Timeslices is the number of timeslices run on a CPU
+	Timeslices uint64
+}
+
+// Parse parses the content of /proc/<pid>/schedstat.
+func Parse(r io.Reader) (*Stats, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(raw))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid schedstat %q", raw)
+	}
+
+	values := make([]uint64, len(fields))
+	for i, f := range fields {
+		if values[i], err = strconv.ParseUint(f, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid schedstat field %q: %v", f, err)
+		}
+	}
+
+	return &Stats{
+		RunTime:    time.Duration(values[0]),
+		WaitTime:   time.Duration(values[1]),
+		Timeslices: values[2],
+	}, nil
+}
@@ -0,0 +1,17 @@
+// +build !linux
+
+package schedstat
+
+import "time"
+
+// Read returns the scheduler statistics of the given process. Here it always
+// returns ErrUnsupported.
+func Read(pid int) (*Stats, error) {
+	return nil, ErrUnsupported
+}
+
+// HostSteal returns the steal time of the host. Here it always returns
+// ErrUnsupported.
+func HostSteal() (time.Duration, error) {
+	return 0, ErrUnsupported
+}
@@ -0,0 +1,36 @@
+// +build linux
+
+package schedstat
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// Read returns the scheduler statistics of the given process.
+func Read(pid int) (*Stats, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/schedstat", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// HostSteal returns the time the hypervisor ran other guests while the CPUs
+// of the host were waiting, summed over all CPUs.
+func HostSteal() (time.Duration, error) {
+	times, err := cpu.Times(false)
+	if err != nil {
+		return 0, err
+	}
+	if len(times) == 0 {
+		return 0, fmt.Errorf("no cpu times reported")
+	}
+
+	return time.Duration(times[0].Steal * float64(time.Second)), nil
+}
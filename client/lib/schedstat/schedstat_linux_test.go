@@ -0,0 +1,22 @@
+// +build linux
+
+package schedstat
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedstat_Read(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	stats, err := Read(os.Getpid())
+	require.NoError(err)
+	require.NotZero(stats.RunTime)
+
+	_, err = HostSteal()
+	require.NoError(err)
+}
@@ -0,0 +1,26 @@
+package schedstat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedstat_Parse(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	stats, err := Parse(strings.NewReader("123456789 2000 42\n"))
+	require.NoError(err)
+	require.Equal(123456789*time.Nanosecond, stats.RunTime)
+	require.Equal(2*time.Microsecond, stats.WaitTime)
+	require.Equal(uint64(42), stats.Timeslices)
+
+	_, err = Parse(strings.NewReader("1 2\n"))
+	require.Error(err)
+
+	_, err = Parse(strings.NewReader("1 -2 3\n"))
+	require.Error(err)
+}
@@ -2,9 +2,13 @@ package framer
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"sync"
 	"time"
+
+	metrics "github.com/armon/go-metrics"
 )
 
 var (
@@ -27,11 +31,25 @@ type StreamFrame struct {
 	// FileEvent is the last file event that occurred that could cause the
 	// streams position to change or end
 	FileEvent string `json:",omitempty"`
+
+	// FileIndex is the index of the log file the stream moved to when the
+	// logs rotated
+	FileIndex int64 `json:",omitempty"`
+
+	// FileSize and ModTime are the size and modification time (UnixNano) of
+	// the log file being written, sent in heartbeats to followers
+	FileSize int64 `json:",omitempty"`
+	ModTime  int64 `json:",omitempty"`
+
+	// Compressed is true when Data is gzip compressed. It is only set by
+	// framers that had compression enabled.
+	Compressed bool `json:",omitempty"`
 }
 
 // IsHeartbeat returns if the frame is a heartbeat frame
 func (s *StreamFrame) IsHeartbeat() bool {
-	return s.Offset == 0 && len(s.Data) == 0 && s.File == "" && s.FileEvent == ""
+	return s.Offset == 0 && len(s.Data) == 0 && s.File == "" && s.FileEvent == "" && s.FileIndex == 0 &&
+		s.FileSize == 0 && s.ModTime == 0 && !s.Compressed
 }
 
 func (s *StreamFrame) Clear() {
@@ -39,6 +57,10 @@ func (s *StreamFrame) Clear() {
 	s.Data = nil
 	s.File = ""
 	s.FileEvent = ""
+	s.FileIndex = 0
+	s.FileSize = 0
+	s.ModTime = 0
+	s.Compressed = false
 }
 
 func (s *StreamFrame) IsCleared() bool {
@@ -50,6 +72,12 @@ func (s *StreamFrame) IsCleared() bool {
 		return false
 	} else if s.FileEvent != "" {
 		return false
+	} else if s.FileIndex != 0 {
+		return false
+	} else if s.FileSize != 0 || s.ModTime != 0 {
+		return false
+	} else if s.Compressed {
+		return false
 	} else {
 		return true
 	}
@@ -63,6 +91,53 @@ func (s *StreamFrame) Copy() *StreamFrame {
 	return n
 }
 
+// Compress gzip compresses the data of the frame in place if it is larger than
+// threshold bytes. The data is left as is if compressing it does not make it
+// smaller.
+func (s *StreamFrame) Compress(threshold int) {
+	if s.Compressed || len(s.Data) <= threshold {
+		return
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(s.Data); err != nil {
+		return
+	}
+	if err := w.Close(); err != nil {
+		return
+	}
+	if buf.Len() >= len(s.Data) {
+		return
+	}
+
+	s.Data = buf.Bytes()
+	s.Compressed = true
+}
+
+// Decompress inflates the data of a compressed frame in place. It is a no-op
+// for uncompressed frames.
+func (s *StreamFrame) Decompress() error {
+	if !s.Compressed {
+		return nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(s.Data))
+	if err != nil {
+		return fmt.Errorf("failed to decompress frame: %v", err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to decompress frame: %v", err)
+	}
+
+	s.Data = data
+	s.Compressed = false
+	return nil
+}
+
 // StreamFramer is used to buffer and send frames as well as heartbeat.
 type StreamFramer struct {
 	// out is where frames are sent and is closed when no more frames will
@@ -94,6 +169,24 @@ type StreamFramer struct {
 
 	// Captures whether the framer is running
 	running bool
+
+	// adaptive is true when the batch window is adjusted to the throughput
+	// of the consumer, between minWindow and maxWindow.
+	adaptive             bool
+	minWindow, maxWindow time.Duration
+
+	// window is the current batch window and lastFlush the time the last
+	// frame was sent. They are only used in adaptive mode.
+	window    time.Duration
+	lastFlush time.Time
+
+	// compressThreshold is the size of frame data above which the data is
+	// gzip compressed. Zero disables compression.
+	compressThreshold int
+
+	// sent is true when a frame was sent since the last heartbeat tick, in
+	// which case no heartbeat is needed.
+	sent bool
 }
 
 // NewStreamFramer creates a new stream framer that will output StreamFrames to
@@ -110,6 +203,7 @@ func NewStreamFramer(out chan<- *StreamFrame,
 		frameSize:  frameSize,
 		heartbeat:  heartbeat,
 		flusher:    flusher,
+		window:     batchWindow,
 		f:          new(StreamFrame),
 		data:       bytes.NewBuffer(make([]byte, 0, 2*frameSize)),
 		shutdownCh: make(chan struct{}),
@@ -117,6 +211,52 @@ func NewStreamFramer(out chan<- *StreamFrame,
 	}
 }
 
+// SetAdaptive enables adaptive batching. The batch window starts at the window
+// the framer was created with and is halved each time the consumer is ready to
+// receive a frame, down to minWindow, and doubled each time it lags behind, up
+// to maxWindow. It must be called before Run.
+func (s *StreamFramer) SetAdaptive(minWindow, maxWindow time.Duration) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.running {
+		return
+	}
+
+	s.adaptive = true
+	s.minWindow = minWindow
+	s.maxWindow = maxWindow
+	if s.window < minWindow {
+		s.window = minWindow
+	} else if s.window > maxWindow {
+		s.window = maxWindow
+	}
+
+	// Tick at the smallest window so the current window can be honored
+	s.flusher.Stop()
+	s.flusher = time.NewTicker(minWindow)
+}
+
+// SetCompression enables gzip compression of the data of frames larger than
+// threshold bytes. Compressed frames have Compressed set and must be inflated
+// with Decompress by the consumer, so compression must only be enabled when
+// the consumer asked for it. It must be called before Run.
+func (s *StreamFramer) SetCompression(threshold int) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.running {
+		return
+	}
+
+	s.compressThreshold = threshold
+}
+
+// BatchWindow returns the current batch window.
+func (s *StreamFramer) BatchWindow() time.Duration {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.window
+}
+
 // Destroy is used to cleanup the StreamFramer and flush any pending frames
 func (s *StreamFramer) Destroy() {
 	s.l.Lock()
@@ -185,10 +325,25 @@ OUTER:
 				continue
 			}
 
+			// Skip if the adaptive batch window has not elapsed yet
+			if s.adaptive && time.Since(s.lastFlush) < s.window {
+				s.l.Unlock()
+				continue
+			}
+
 			// Read the data for the frame, and send it
 			s.send()
 			s.l.Unlock()
 		case <-s.heartbeat.C:
+			// Only heartbeat if the stream was idle since the last tick
+			s.l.Lock()
+			sent := s.sent
+			s.sent = false
+			s.l.Unlock()
+			if sent {
+				continue
+			}
+
 			// Send a heartbeat frame
 			select {
 			case s.out <- HeartbeatStreamFrame:
@@ -206,7 +361,7 @@ OUTER:
 		if len(s.f.Data) > 0 {
 			// Cannot select on shutdownCh as it's already closed
 			// Cannot select on exitCh as it's only closed after this exits
-			s.out <- s.f.Copy()
+			s.out <- s.compress(s.f.Copy())
 		}
 	}
 	s.l.Unlock()
@@ -222,11 +377,66 @@ func (s *StreamFramer) send() {
 	}
 
 	s.f.Data = s.readData()
-	select {
-	case s.out <- s.f.Copy():
+	if s.sendFrame(s.f.Copy()) {
 		s.f.Clear()
+	}
+}
+
+// sendFrame sends the frame on the output channel and returns false if the
+// framer exited before it could be sent. In adaptive mode the batch window is
+// adjusted based on whether the consumer was ready to receive the frame. Must
+// be called with the lock held.
+func (s *StreamFramer) sendFrame(frame *StreamFrame) bool {
+	frame = s.compress(frame)
+
+	if s.adaptive {
+		select {
+		case s.out <- frame:
+			s.adapt(false)
+			s.sent = true
+			return true
+		default:
+		}
+	}
+
+	select {
+	case s.out <- frame:
+		if s.adaptive {
+			s.adapt(true)
+		}
+		s.sent = true
+		return true
 	case <-s.exitCh:
+		return false
+	}
+}
+
+// compress gzip compresses the data of the frame if compression is enabled.
+// Must be called with the lock held.
+func (s *StreamFramer) compress(frame *StreamFrame) *StreamFrame {
+	if s.compressThreshold > 0 {
+		frame.Compress(s.compressThreshold)
 	}
+	return frame
+}
+
+// adapt shrinks the batch window when the consumer keeps up and grows it when
+// the consumer is lagging. Must be called with the lock held.
+func (s *StreamFramer) adapt(lagging bool) {
+	if lagging {
+		s.window *= 2
+		if s.window > s.maxWindow {
+			s.window = s.maxWindow
+		}
+	} else {
+		s.window /= 2
+		if s.window < s.minWindow {
+			s.window = s.minWindow
+		}
+	}
+
+	s.lastFlush = time.Now()
+	metrics.SetGauge([]string{"client", "streaming", "batch_window"}, float32(s.window.Seconds()*1000))
 }
 
 // readData is a helper which reads the buffered data returning up to the frame
@@ -294,9 +504,7 @@ func (s *StreamFramer) Send(file, fileEvent string, data []byte, offset int64) e
 
 		// Create a new frame to send it
 		s.f.Data = s.readData()
-		select {
-		case s.out <- s.f.Copy():
-		case <-s.exitCh:
+		if !s.sendFrame(s.f.Copy()) {
 			return nil
 		}
 
@@ -306,3 +514,21 @@ func (s *StreamFramer) Send(file, fileEvent string, data []byte, offset int64) e
 
 	return nil
 }
+
+// SendIndexEvent flushes the pending frame and sends a frame holding only the
+// file event and the index of the file it applies to. An error is returned if
+// the run routine hasn't run or encountered an error.
+func (s *StreamFramer) SendIndexEvent(file, fileEvent string, index int64) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if !s.running {
+		return fmt.Errorf("StreamFramer not running")
+	}
+
+	if !s.f.IsCleared() {
+		s.send()
+	}
+
+	s.sendFrame(&StreamFrame{File: file, FileEvent: fileEvent, FileIndex: index})
+	return nil
+}
@@ -2,6 +2,7 @@ package framer
 
 import (
 	"bytes"
+	"fmt"
 	"reflect"
 	"strconv"
 	"testing"
@@ -258,3 +259,187 @@ func TestStreamFramer_Order(t *testing.T) {
 		t.Fatal("out channel should be closed")
 	}
 }
+
+// This test checks that the adaptive batch window shrinks when the consumer
+// keeps up with the frames.
+func TestStreamFramer_Adaptive_FastConsumer(t *testing.T) {
+	minWindow, maxWindow := 10*time.Millisecond, 1*time.Second
+
+	frames := make(chan *StreamFrame)
+	sf := NewStreamFramer(frames, 100*time.Millisecond, 500*time.Millisecond, 1)
+	sf.SetAdaptive(minWindow, maxWindow)
+	sf.Run()
+	defer sf.Destroy()
+
+	// Start a reader that is always ready
+	go func() {
+		for range frames {
+		}
+	}()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if err := sf.Send("foo", "", []byte{0xa}, 0); err != nil {
+			return false, err
+		}
+		if w := sf.BatchWindow(); w != minWindow {
+			return false, fmt.Errorf("expected window %v; got %v", minWindow, w)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+// This test checks that the adaptive batch window grows when the consumer
+// lags behind the frames.
+func TestStreamFramer_Adaptive_SlowConsumer(t *testing.T) {
+	minWindow, maxWindow := 10*time.Millisecond, 200*time.Millisecond
+
+	frames := make(chan *StreamFrame)
+	sf := NewStreamFramer(frames, 100*time.Millisecond, 50*time.Millisecond, 1)
+	sf.SetAdaptive(minWindow, maxWindow)
+	sf.Run()
+	defer sf.Destroy()
+
+	// Start a reader that is slower than the writer
+	go func() {
+		for range frames {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	// Start a writer that fills every frame
+	go func() {
+		for {
+			if err := sf.Send("foo", "", []byte{0xa}, 0); err != nil {
+				return
+			}
+		}
+	}()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if w := sf.BatchWindow(); w != maxWindow {
+			return false, fmt.Errorf("expected window %v; got %v", maxWindow, w)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+// This test checks that frame data above the threshold is compressed and
+// can be inflated back, while smaller frames are sent as is.
+func TestStreamFramer_Compression(t *testing.T) {
+	data := bytes.Repeat([]byte("compress me "), 100)
+
+	frames := make(chan *StreamFrame, 10)
+	sf := NewStreamFramer(frames, 1*time.Second, 1*time.Second, len(data))
+	sf.SetCompression(64)
+	sf.Run()
+	defer sf.Destroy()
+
+	if err := sf.Send("foo", "", data, 0); err != nil {
+		t.Fatalf("Send() failed %v", err)
+	}
+
+	frame := <-frames
+	if !frame.Compressed {
+		t.Fatalf("expected compressed frame: %#v", frame)
+	}
+	if len(frame.Data) >= len(data) {
+		t.Fatalf("expected compressed data to be smaller: %d >= %d", len(frame.Data), len(data))
+	}
+	if err := frame.Decompress(); err != nil {
+		t.Fatalf("Decompress() failed %v", err)
+	}
+	if frame.Compressed || !bytes.Equal(frame.Data, data) {
+		t.Fatalf("bad frame after decompression: %#v", frame)
+	}
+
+	// Data below the threshold is not compressed
+	small := []byte("small")
+	frames2 := make(chan *StreamFrame, 10)
+	sf2 := NewStreamFramer(frames2, 1*time.Second, 1*time.Second, len(small))
+	sf2.SetCompression(64)
+	sf2.Run()
+	defer sf2.Destroy()
+
+	if err := sf2.Send("foo", "", small, 0); err != nil {
+		t.Fatalf("Send() failed %v", err)
+	}
+
+	frame = <-frames2
+	if frame.Compressed || !bytes.Equal(frame.Data, small) {
+		t.Fatalf("expected uncompressed frame: %#v", frame)
+	}
+	if err := frame.Decompress(); err != nil {
+		t.Fatalf("Decompress() failed %v", err)
+	}
+	if !bytes.Equal(frame.Data, small) {
+		t.Fatalf("bad frame data: %q", frame.Data)
+	}
+}
+
+// This test checks that frames are not compressed unless compression was
+// enabled.
+func TestStreamFramer_Compression_Disabled(t *testing.T) {
+	data := bytes.Repeat([]byte("compress me "), 100)
+
+	frames := make(chan *StreamFrame, 10)
+	sf := NewStreamFramer(frames, 1*time.Second, 1*time.Second, len(data))
+	sf.Run()
+	defer sf.Destroy()
+
+	if err := sf.Send("foo", "", data, 0); err != nil {
+		t.Fatalf("Send() failed %v", err)
+	}
+
+	frame := <-frames
+	if frame.Compressed || !bytes.Equal(frame.Data, data) {
+		t.Fatalf("expected uncompressed frame: %#v", frame)
+	}
+}
+
+// This test checks that heartbeats are only sent while no data is streamed.
+func TestStreamFramer_Heartbeat_Idle(t *testing.T) {
+	hRate := 50 * time.Millisecond
+	frames := make(chan *StreamFrame, 10)
+	sf := NewStreamFramer(frames, hRate, 10*time.Millisecond, 1)
+	sf.Run()
+	defer sf.Destroy()
+
+	// Keep the stream busy for several heartbeat intervals
+	busy := time.After(6 * hRate)
+	ticker := time.NewTicker(hRate / 5)
+	defer ticker.Stop()
+BUSY:
+	for {
+		select {
+		case <-busy:
+			break BUSY
+		case <-ticker.C:
+			if err := sf.Send("foo", "", []byte{0xa}, 0); err != nil {
+				t.Fatalf("Send() failed %v", err)
+			}
+		case frame := <-frames:
+			if frame.IsHeartbeat() {
+				t.Fatalf("unexpected heartbeat while streaming data")
+			}
+		}
+	}
+	ticker.Stop()
+
+	// Once idle, heartbeats are sent at the heartbeat rate
+	heartbeats := 0
+	timeout := time.After(10 * time.Duration(testutil.TestMultiplier()) * hRate)
+	for heartbeats < 3 {
+		select {
+		case frame := <-frames:
+			if frame.IsHeartbeat() {
+				heartbeats++
+			}
+		case <-timeout:
+			t.Fatalf("expected 3 heartbeats; got %d", heartbeats)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package streamlimit
+
+import "sync"
+
+// KeyedLimiter caps the number of concurrent streams sharing a key, such as
+// the log streams of a single task. Streams beyond the cap are rejected with
+// ErrLimitReached.
+type KeyedLimiter struct {
+	// max is the maximum number of concurrent streams per key. If zero,
+	// streams are not limited.
+	max int
+
+	// counts is the number of active streams of each key
+	counts map[string]int
+
+	lock sync.Mutex
+}
+
+// NewKeyedLimiter returns a limiter allowing max concurrent streams per key.
+func NewKeyedLimiter(max int) *KeyedLimiter {
+	return &KeyedLimiter{
+		max:    max,
+		counts: make(map[string]int),
+	}
+}
+
+// Acquire reserves a stream for the key and returns a function that must be
+// called to release it. ErrLimitReached is returned if the key already has the
+// maximum number of streams.
+func (l *KeyedLimiter) Acquire(key string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.max > 0 && l.counts[key] >= l.max {
+		return nil, ErrLimitReached
+	}
+	l.counts[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			if l.counts[key] <= 1 {
+				delete(l.counts, key)
+			} else {
+				l.counts[key]--
+			}
+		})
+	}, nil
+}
+
+// Count returns the number of active streams of the key.
+func (l *KeyedLimiter) Count(key string) int {
+	if l == nil {
+		return 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.counts[key]
+}
@@ -0,0 +1,50 @@
+package streamlimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedLimiter_Unlimited(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewKeyedLimiter(0)
+	for i := 0; i < 10; i++ {
+		_, err := l.Acquire("web")
+		require.NoError(err)
+	}
+	require.Equal(10, l.Count("web"))
+}
+
+func TestKeyedLimiter_Reject(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewKeyedLimiter(2)
+	release1, err := l.Acquire("web")
+	require.NoError(err)
+	_, err = l.Acquire("web")
+	require.NoError(err)
+
+	// The cap applies per key
+	_, err = l.Acquire("web")
+	require.Equal(ErrLimitReached, err)
+	releaseDB, err := l.Acquire("db")
+	require.NoError(err)
+	require.Equal(2, l.Count("web"))
+	require.Equal(1, l.Count("db"))
+
+	// Releasing twice only frees one stream
+	release1()
+	release1()
+	require.Equal(1, l.Count("web"))
+	_, err = l.Acquire("web")
+	require.NoError(err)
+	_, err = l.Acquire("web")
+	require.Equal(ErrLimitReached, err)
+
+	releaseDB()
+	require.Equal(0, l.Count("db"))
+}
@@ -0,0 +1,188 @@
+// Package streamlimit caps the number of concurrent streaming RPCs served by a
+// client. Streams waiting for a slot are queued per namespace and admitted in
+// round robin order so that a single namespace can not monopolize the slots.
+package streamlimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+var (
+	// ErrLimitReached is returned when no stream slot is available and the
+	// stream could not be queued or timed out waiting for a slot. The caller
+	// may retry later.
+	ErrLimitReached = errors.New("too many concurrent streams, retry later")
+)
+
+// Limiter limits the number of concurrent streams.
+type Limiter struct {
+	// max is the maximum number of concurrent streams. If zero, streams are
+	// not limited.
+	max int
+
+	// queueTimeout is how long a stream waits for a slot when the limit is
+	// reached. If zero, streams are rejected immediately.
+	queueTimeout time.Duration
+
+	// active is the number of streams holding a slot
+	active int
+
+	// waiting is the total number of queued streams
+	waiting int
+
+	// queues holds the waiters of each namespace in arrival order
+	queues map[string][]chan struct{}
+
+	// order is the round robin order of the namespaces that have waiters
+	order []string
+
+	lock sync.Mutex
+}
+
+// NewLimiter returns a limiter allowing max concurrent streams. Streams wait
+// up to queueTimeout for a slot once the limit is reached.
+func NewLimiter(max int, queueTimeout time.Duration) *Limiter {
+	return &Limiter{
+		max:          max,
+		queueTimeout: queueTimeout,
+		queues:       make(map[string][]chan struct{}),
+	}
+}
+
+// Acquire blocks until a slot is available for a stream of the given
+// namespace and returns a function that must be called to release it.
+// ErrLimitReached is returned if no slot could be acquired in time.
+func (l *Limiter) Acquire(ctx context.Context, namespace string) (func(), error) {
+	if l == nil || l.max <= 0 {
+		return func() {}, nil
+	}
+
+	l.lock.Lock()
+	if l.active < l.max && l.waiting == 0 {
+		l.active++
+		l.emitLocked()
+		l.lock.Unlock()
+		return l.releaseFn(), nil
+	}
+
+	if l.queueTimeout <= 0 {
+		l.lock.Unlock()
+		metrics.IncrCounter([]string{"client", "streams", "rejected"}, 1)
+		return nil, ErrLimitReached
+	}
+
+	// Queue the stream
+	ch := make(chan struct{})
+	if len(l.queues[namespace]) == 0 {
+		l.order = append(l.order, namespace)
+	}
+	l.queues[namespace] = append(l.queues[namespace], ch)
+	l.waiting++
+	l.emitLocked()
+	l.lock.Unlock()
+
+	start := time.Now()
+	defer metrics.MeasureSince([]string{"client", "streams", "wait_time"}, start)
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ch:
+		return l.releaseFn(), nil
+	case <-timer.C:
+		err = ErrLimitReached
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.lock.Lock()
+	removed := l.removeLocked(namespace, ch)
+	l.lock.Unlock()
+
+	// The slot was handed over while giving up; pass it on.
+	if !removed {
+		l.release()
+	}
+
+	metrics.IncrCounter([]string{"client", "streams", "rejected"}, 1)
+	return nil, err
+}
+
+// releaseFn returns a function releasing a slot exactly once
+func (l *Limiter) releaseFn() func() {
+	var once sync.Once
+	return func() {
+		once.Do(l.release)
+	}
+}
+
+// release hands the slot to the next queued stream or frees it
+func (l *Limiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.order) == 0 {
+		l.active--
+		l.emitLocked()
+		return
+	}
+
+	// Pick the oldest waiter of the next namespace and move the namespace to
+	// the back of the line if it still has waiters.
+	namespace := l.order[0]
+	l.order = l.order[1:]
+	queue := l.queues[namespace]
+	ch := queue[0]
+	if len(queue) == 1 {
+		delete(l.queues, namespace)
+	} else {
+		l.queues[namespace] = queue[1:]
+		l.order = append(l.order, namespace)
+	}
+	l.waiting--
+	l.emitLocked()
+
+	close(ch)
+}
+
+// removeLocked removes a waiter from its queue. It returns false if the
+// waiter was not queued anymore, meaning it has been handed a slot.
+func (l *Limiter) removeLocked(namespace string, ch chan struct{}) bool {
+	queue := l.queues[namespace]
+	for i, c := range queue {
+		if c != ch {
+			continue
+		}
+
+		queue = append(queue[:i], queue[i+1:]...)
+		if len(queue) == 0 {
+			delete(l.queues, namespace)
+			for j, ns := range l.order {
+				if ns == namespace {
+					l.order = append(l.order[:j], l.order[j+1:]...)
+					break
+				}
+			}
+		} else {
+			l.queues[namespace] = queue
+		}
+		l.waiting--
+		l.emitLocked()
+		return true
+	}
+
+	return false
+}
+
+// emitLocked emits the number of active and queued streams
+func (l *Limiter) emitLocked() {
+	metrics.SetGauge([]string{"client", "streams", "active"}, float32(l.active))
+	metrics.SetGauge([]string{"client", "streams", "queue_depth"}, float32(l.waiting))
+}
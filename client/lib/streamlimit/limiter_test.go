@@ -0,0 +1,136 @@
+package streamlimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Unlimited(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewLimiter(0, 0)
+	for i := 0; i < 10; i++ {
+		_, err := l.Acquire(context.Background(), "default")
+		require.NoError(err)
+	}
+}
+
+func TestLimiter_Reject(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewLimiter(1, 0)
+	release, err := l.Acquire(context.Background(), "default")
+	require.NoError(err)
+
+	_, err = l.Acquire(context.Background(), "default")
+	require.Equal(ErrLimitReached, err)
+
+	// Releasing twice only frees one slot
+	release()
+	release()
+	release, err = l.Acquire(context.Background(), "default")
+	require.NoError(err)
+	_, err = l.Acquire(context.Background(), "default")
+	require.Equal(ErrLimitReached, err)
+	release()
+}
+
+func TestLimiter_QueueTimeout(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewLimiter(1, 50*time.Millisecond)
+	release, err := l.Acquire(context.Background(), "default")
+	require.NoError(err)
+	defer release()
+
+	start := time.Now()
+	_, err = l.Acquire(context.Background(), "default")
+	require.Equal(ErrLimitReached, err)
+	require.True(time.Since(start) >= 50*time.Millisecond)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	require.Zero(l.waiting)
+	require.Empty(l.order)
+}
+
+func TestLimiter_FairQueue(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewLimiter(1, 10*time.Second)
+	release, err := l.Acquire(context.Background(), "default")
+	require.NoError(err)
+
+	// Queue three streams of a busy namespace followed by one of another
+	admitted := make(chan string, 4)
+	queue := func(namespace string) {
+		l.lock.Lock()
+		waiting := l.waiting
+		l.lock.Unlock()
+
+		go func() {
+			release, err := l.Acquire(context.Background(), namespace)
+			if err != nil {
+				admitted <- err.Error()
+				return
+			}
+			admitted <- namespace
+			release()
+		}()
+
+		// Wait for the stream to be queued to make the order deterministic
+		for {
+			l.lock.Lock()
+			queued := l.waiting > waiting
+			l.lock.Unlock()
+			if queued {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue("busy")
+	queue("busy")
+	queue("busy")
+	queue("other")
+
+	release()
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		select {
+		case ns := <-admitted:
+			order = append(order, ns)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout; admitted %v", order)
+		}
+	}
+	require.Equal([]string{"busy", "other", "busy", "busy"}, order)
+}
+
+func TestLimiter_Cancel(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewLimiter(1, 10*time.Second)
+	release, err := l.Acquire(context.Background(), "default")
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = l.Acquire(ctx, "default")
+	require.Equal(context.Canceled, err)
+
+	// The cancelled stream does not hold on to the slot
+	release()
+	release, err = l.Acquire(context.Background(), "default")
+	require.NoError(err)
+	release()
+}
@@ -0,0 +1,138 @@
+// Package streamsession tracks the streaming RPC sessions served by a client.
+package streamsession
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/nomad/helper/uuid"
+)
+
+// Info describes a streaming session
+type Info struct {
+	// ID uniquely identifies the session
+	ID string
+
+	// Method is the streaming RPC method served
+	Method string
+
+	// AllocID and Task are the target of the stream, if any
+	AllocID string
+	Task    string
+
+	// AccessorID is the accessor of the token that opened the stream
+	AccessorID string
+
+	// StartedAt is when the session started
+	StartedAt time.Time
+
+	// BytesRead and BytesWritten are the bytes received and sent
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// Conn wraps the connection of a streaming session, counting the bytes
+// transferred.
+type Conn struct {
+	io.ReadWriteCloser
+
+	// read and written are accessed atomically
+	read    int64
+	written int64
+
+	info Info
+	lock sync.Mutex
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+// SetTarget sets the allocation and task the session streams
+func (c *Conn) SetTarget(allocID, task string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.info.AllocID = allocID
+	c.info.Task = task
+}
+
+// SetAccessor sets the accessor of the token that opened the session
+func (c *Conn) SetAccessor(accessorID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.info.AccessorID = accessorID
+}
+
+// Info returns a snapshot of the session
+func (c *Conn) Info() *Info {
+	c.lock.Lock()
+	info := c.info
+	c.lock.Unlock()
+
+	info.BytesRead = atomic.LoadInt64(&c.read)
+	info.BytesWritten = atomic.LoadInt64(&c.written)
+	return &info
+}
+
+// Registry holds the active streaming sessions
+type Registry struct {
+	sessions map[string]*Conn
+	lock     sync.Mutex
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		sessions: make(map[string]*Conn),
+	}
+}
+
+// Track registers a session serving method over conn. The returned Conn must
+// be used in place of conn and the returned function called once the session
+// ends to unregister it.
+func (r *Registry) Track(method string, conn io.ReadWriteCloser) (*Conn, func()) {
+	c := &Conn{
+		ReadWriteCloser: conn,
+		info: Info{
+			ID:        uuid.Generate(),
+			Method:    method,
+			StartedAt: time.Now(),
+		},
+	}
+
+	r.lock.Lock()
+	r.sessions[c.info.ID] = c
+	r.lock.Unlock()
+
+	return c, func() {
+		r.lock.Lock()
+		delete(r.sessions, c.info.ID)
+		r.lock.Unlock()
+	}
+}
+
+// List returns the active sessions, oldest first
+func (r *Registry) List() []*Info {
+	r.lock.Lock()
+	infos := make([]*Info, 0, len(r.sessions))
+	for _, c := range r.sessions {
+		infos = append(infos, c.Info())
+	}
+	r.lock.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt.Before(infos[j].StartedAt)
+	})
+	return infos
+}
@@ -0,0 +1,61 @@
+package streamsession
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bufConn is an in memory connection
+type bufConn struct {
+	bytes.Buffer
+}
+
+func (*bufConn) Close() error { return nil }
+
+func TestRegistry_Track(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	r := NewRegistry()
+	require.Empty(r.List())
+
+	conn := &bufConn{}
+	conn.WriteString("request")
+
+	c, done := r.Track("FileSystem.Logs", conn)
+	c.SetTarget("alloc", "web")
+	c.SetAccessor("accessor")
+
+	read, err := ioutil.ReadAll(c)
+	require.NoError(err)
+	require.Equal("request", string(read))
+	_, err = c.Write([]byte("response!"))
+	require.NoError(err)
+
+	other, otherDone := r.Track("Allocations.Checks", &bufConn{})
+	defer otherDone()
+
+	infos := r.List()
+	require.Len(infos, 2)
+	info := infos[0]
+	if info.ID != c.Info().ID {
+		info = infos[1]
+	}
+	require.Equal("FileSystem.Logs", info.Method)
+	require.Equal("alloc", info.AllocID)
+	require.Equal("web", info.Task)
+	require.Equal("accessor", info.AccessorID)
+	require.EqualValues(7, info.BytesRead)
+	require.EqualValues(9, info.BytesWritten)
+	require.NotEmpty(info.ID)
+	require.False(info.StartedAt.IsZero())
+	require.Contains([]string{infos[0].ID, infos[1].ID}, other.Info().ID)
+
+	done()
+	infos = r.List()
+	require.Len(infos, 1)
+	require.Equal("Allocations.Checks", infos[0].Method)
+}
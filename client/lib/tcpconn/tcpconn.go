@@ -0,0 +1,84 @@
+// Package tcpconn counts the TCP sockets of a network namespace by state.
+package tcpconn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupported is returned on platforms where sockets can't be
+	// counted.
+	ErrUnsupported = errors.New("counting tcp connections is not supported on this platform")
+
+	// ErrSharedNetns is returned when the process doesn't have a network
+	// namespace of its own, so its sockets can't be told apart from the ones
+	// of the host.
+	ErrSharedNetns = errors.New("process shares the network namespace of the client")
+)
+
+// States are the names of the TCP states, in the order of their codes in
+// /proc/net/tcp starting at 1.
+var States = []string{
+	"ESTABLISHED",
+	"SYN_SENT",
+	"SYN_RECV",
+	"FIN_WAIT1",
+	"FIN_WAIT2",
+	"TIME_WAIT",
+	"CLOSE",
+	"CLOSE_WAIT",
+	"LAST_ACK",
+	"LISTEN",
+	"CLOSING",
+}
+
+// Counts are the number of sockets in each state.
+type Counts struct {
+	States map[string]uint64
+
+	// Truncated is set when the tables held more sockets than were counted
+	Truncated bool
+}
+
+// NewCounts returns empty counts.
+func NewCounts() *Counts {
+	return &Counts{States: make(map[string]uint64)}
+}
+
+// Parse counts the sockets of a /proc/net/tcp or /proc/net/tcp6 table,
+// reading at most max of them. It returns the number of sockets read.
+func Parse(r io.Reader, max int, counts *Counts) (int, error) {
+	s := bufio.NewScanner(r)
+
+	// Skip the header
+	if !s.Scan() {
+		return 0, s.Err()
+	}
+
+	n := 0
+	for s.Scan() {
+		if n == max {
+			counts.Truncated = true
+			break
+		}
+
+		fields := strings.Fields(s.Text())
+		if len(fields) < 4 {
+			return n, fmt.Errorf("invalid socket line %q", s.Text())
+		}
+
+		code, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil || code == 0 || int(code) > len(States) {
+			return n, fmt.Errorf("invalid socket state %q", fields[3])
+		}
+		counts.States[States[code-1]]++
+		n++
+	}
+
+	return n, s.Err()
+}
@@ -0,0 +1,45 @@
+// +build linux
+
+package tcpconn
+
+import (
+	"fmt"
+	"os"
+)
+
+// Read counts the TCP sockets of the network namespace of the process,
+// reading at most max of them. ErrSharedNetns is returned if the process is in
+// the network namespace of the client.
+func Read(pid int, max int) (*Counts, error) {
+	netns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return nil, err
+	}
+	own, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		return nil, err
+	}
+	if netns == own {
+		return nil, ErrSharedNetns
+	}
+
+	counts := NewCounts()
+	for _, table := range []string{"tcp", "tcp6"} {
+		f, err := os.Open(fmt.Sprintf("/proc/%d/net/%s", pid, table))
+		if os.IsNotExist(err) {
+			// IPv6 may be disabled
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		n, err := Parse(f, max, counts)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		max -= n
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,18 @@
+// +build linux
+
+package tcpconn
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPConn_Read_SharedNetns(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	_, err := Read(os.Getpid(), 10)
+	require.Equal(ErrSharedNetns, err)
+}
@@ -0,0 +1,45 @@
+package tcpconn
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTable = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:D2A8 01 00000000:00000000 00:00000000 00000000     0        0 12346 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:1F90 0100007F:D2AA 06 00000000:00000000 03:00000D4A 00000000     0        0 0 3 0000000000000000
+   3: 0100007F:1F90 0100007F:D2AC 06 00000000:00000000 03:00000D4A 00000000     0        0 0 3 0000000000000000
+`
+
+func TestTCPConn_Parse(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	counts := NewCounts()
+	n, err := Parse(strings.NewReader(testTable), 10, counts)
+	require.NoError(err)
+	require.Equal(4, n)
+	require.False(counts.Truncated)
+	require.Equal(map[string]uint64{
+		"LISTEN":      1,
+		"ESTABLISHED": 1,
+		"TIME_WAIT":   2,
+	}, counts.States)
+
+	// Tables are counted until the limit is reached
+	counts = NewCounts()
+	n, err = Parse(strings.NewReader(testTable), 2, counts)
+	require.NoError(err)
+	require.Equal(2, n)
+	require.True(counts.Truncated)
+	require.Equal(map[string]uint64{
+		"LISTEN":      1,
+		"ESTABLISHED": 1,
+	}, counts.States)
+
+	_, err = Parse(strings.NewReader("header\n   0: 00000000:1F90 00000000:0000 ZZ\n"), 10, NewCounts())
+	require.Error(err)
+}
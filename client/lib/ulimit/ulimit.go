@@ -0,0 +1,104 @@
+// Package ulimit reads the resource limits applied to a process.
+package ulimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrUnsupported is returned on platforms where the resource limits of a
+	// process can't be read.
+	ErrUnsupported = errors.New("reading resource limits is not supported on this platform")
+)
+
+// Unlimited is the value of a limit that isn't set
+const Unlimited = "unlimited"
+
+// names maps the limit descriptions of /proc/<pid>/limits to the names used
+// by ulimit and setrlimit.
+var names = map[string]string{
+	"Max cpu time":          "cpu",
+	"Max file size":         "fsize",
+	"Max data size":         "data",
+	"Max stack size":        "stack",
+	"Max core file size":    "core",
+	"Max resident set":      "rss",
+	"Max processes":         "nproc",
+	"Max open files":        "nofile",
+	"Max locked memory":     "memlock",
+	"Max address space":     "as",
+	"Max file locks":        "locks",
+	"Max pending signals":   "sigpending",
+	"Max msgqueue size":     "msgqueue",
+	"Max nice priority":     "nice",
+	"Max realtime priority": "rtprio",
+	"Max realtime timeout":  "rttime",
+}
+
+// Limit is a resource limit of a process.
+type Limit struct {
+	// Soft and Hard are the soft and hard limits. They are either a number
+	// or Unlimited.
+	Soft string
+	Hard string
+
+	// Units is the unit of the limit, eg files or bytes. It is empty for
+	// unitless limits.
+	Units string
+}
+
+// Parse parses the content of /proc/<pid>/limits and returns the limits keyed
+// by their ulimit name, eg nofile. Unknown limits are keyed by their
+// description.
+func Parse(r io.Reader) (map[string]*Limit, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("missing limits header")
+	}
+
+	// The columns are aligned on the header
+	header := s.Text()
+	softIdx := strings.Index(header, "Soft Limit")
+	hardIdx := strings.Index(header, "Hard Limit")
+	unitsIdx := strings.Index(header, "Units")
+	if softIdx == -1 || hardIdx < softIdx || unitsIdx < hardIdx {
+		return nil, fmt.Errorf("invalid limits header %q", header)
+	}
+
+	limits := make(map[string]*Limit)
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) < hardIdx {
+			return nil, fmt.Errorf("invalid limit %q", line)
+		}
+
+		desc := strings.TrimSpace(line[:softIdx])
+		limit := &Limit{
+			Soft: strings.TrimSpace(line[softIdx:hardIdx]),
+		}
+		if len(line) > unitsIdx {
+			limit.Hard = strings.TrimSpace(line[hardIdx:unitsIdx])
+			limit.Units = strings.TrimSpace(line[unitsIdx:])
+		} else {
+			limit.Hard = strings.TrimSpace(line[hardIdx:])
+		}
+
+		name, ok := names[desc]
+		if !ok {
+			name = desc
+		}
+		limits[name] = limit
+	}
+
+	return limits, s.Err()
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package ulimit
+
+// Read returns the resource limits of the given process. Here it always
+// returns ErrUnsupported.
+func Read(pid int) (map[string]*Limit, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,19 @@
+// +build linux
+
+package ulimit
+
+import (
+	"fmt"
+	"os"
+)
+
+// Read returns the resource limits of the given process.
+func Read(pid int) (map[string]*Limit, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
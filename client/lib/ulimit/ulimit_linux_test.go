@@ -0,0 +1,20 @@
+// +build linux
+
+package ulimit
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUlimit_Read(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	limits, err := Read(os.Getpid())
+	require.NoError(err)
+	require.Contains(limits, "nofile")
+	require.Equal("files", limits["nofile"].Units)
+}
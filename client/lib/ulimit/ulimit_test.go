@@ -0,0 +1,33 @@
+package ulimit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testLimits = `Limit                     Soft Limit           Hard Limit           Units     
+Max cpu time              unlimited            unlimited            seconds   
+Max processes             63448                63448                processes 
+Max open files            1024                 524288               files     
+Max nice priority         0                    0                    
+Max realtime timeout      unlimited            unlimited            us        
+`
+
+func TestUlimit_Parse(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	limits, err := Parse(strings.NewReader(testLimits))
+	require.NoError(err)
+	require.Len(limits, 5)
+	require.Equal(&Limit{Soft: Unlimited, Hard: Unlimited, Units: "seconds"}, limits["cpu"])
+	require.Equal(&Limit{Soft: "63448", Hard: "63448", Units: "processes"}, limits["nproc"])
+	require.Equal(&Limit{Soft: "1024", Hard: "524288", Units: "files"}, limits["nofile"])
+	require.Equal(&Limit{Soft: "0", Hard: "0"}, limits["nice"])
+	require.Equal(&Limit{Soft: Unlimited, Hard: Unlimited, Units: "us"}, limits["rttime"])
+
+	_, err = Parse(strings.NewReader("garbage\n"))
+	require.Error(err)
+}
@@ -29,3 +29,14 @@ func (c *logmonClient) Stop() error {
 	_, err := c.client.Stop(context.Background(), req)
 	return err
 }
+
+func (c *logmonClient) Rotate(logType string) (string, error) {
+	req := &proto.RotateRequest{
+		LogType: logType,
+	}
+	resp, err := c.client.Rotate(context.Background(), req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Path, nil
+}
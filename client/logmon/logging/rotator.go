@@ -35,8 +35,9 @@ const (
 
 // FileRotator writes bytes to a rotated set of files
 type FileRotator struct {
-	MaxFiles int   // MaxFiles is the maximum number of rotated files allowed in a path
-	FileSize int64 // FileSize is the size a rotated file is allowed to grow
+	MaxFiles   int          // MaxFiles is the maximum number of rotated files allowed in a path
+	FileSize   int64        // FileSize is the size a rotated file is allowed to grow
+	limitsLock sync.RWMutex // limitsLock guards MaxFiles and FileSize once the rotator is running
 
 	path             string // path is the path on the file system where the rotated set of files are opened
 	baseFileName     string // baseFileName is the base file name of the rotated files
@@ -47,6 +48,7 @@ type FileRotator struct {
 	currentWr   int64    // currentWr is the number of bytes written to the current file
 	bufw        *bufio.Writer
 	bufLock     sync.Mutex
+	writeLock   sync.Mutex // writeLock serializes writes and forced rotations
 
 	flushTicker *time.Ticker
 	logger      hclog.Logger
@@ -85,13 +87,17 @@ func NewFileRotator(path string, baseFile string, maxFiles int,
 // Write writes a byte array to a file and rotates the file if it's size becomes
 // equal to the maximum size the user has defined.
 func (f *FileRotator) Write(p []byte) (n int, err error) {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
 	n = 0
 	var forceRotate bool
+	_, fileSize := f.limits()
 
 	for n < len(p) {
 		// Check if we still have space in the current file, otherwise close and
 		// open the next file
-		if forceRotate || f.currentWr >= f.FileSize {
+		if forceRotate || f.currentWr >= fileSize {
 			forceRotate = false
 			f.flushBuffer()
 			f.currentFile.Close()
@@ -102,7 +108,7 @@ func (f *FileRotator) Write(p []byte) (n int, err error) {
 		}
 		// Calculate the remaining size on this file and how much we have left
 		// to write
-		remainingSpace := f.FileSize - f.currentWr
+		remainingSpace := fileSize - f.currentWr
 		remainingToWrite := int64(len(p[n:]))
 
 		// Check if we are near the end of the file. If we are we attempt to
@@ -118,7 +124,7 @@ func (f *FileRotator) Write(p []byte) (n int, err error) {
 			} else if idx >= 0 {
 				// We found a new line but don't have space so just force rotate
 				forceRotate = true
-			} else if remainingToWrite > f.FileSize || f.FileSize-lineScanLimit < 0 {
+			} else if remainingToWrite > fileSize || fileSize-lineScanLimit < 0 {
 				// There is no new line remaining but there is no point in
 				// rotating since the remaining data will not even fit in the
 				// next file either so just fill this one up.
@@ -160,12 +166,13 @@ func (f *FileRotator) Write(p []byte) (n int, err error) {
 // nextFile opens the next file and purges older files if the number of rotated
 // files is larger than the maximum files configured by the user
 func (f *FileRotator) nextFile() error {
+	maxFiles, fileSize := f.limits()
 	nextFileIdx := f.logFileIdx
 	for {
 		nextFileIdx += 1
 		logFileName := filepath.Join(f.path, fmt.Sprintf("%s.%d", f.baseFileName, nextFileIdx))
 		if fi, err := os.Stat(logFileName); err == nil {
-			if fi.IsDir() || fi.Size() >= f.FileSize {
+			if fi.IsDir() || fi.Size() >= fileSize {
 				continue
 			}
 		}
@@ -178,7 +185,7 @@ func (f *FileRotator) nextFile() error {
 	// Purge old files if we have more files than MaxFiles
 	f.closedLock.Lock()
 	defer f.closedLock.Unlock()
-	if f.logFileIdx-f.oldestLogFileIdx >= f.MaxFiles && !f.closed {
+	if f.logFileIdx-f.oldestLogFileIdx >= maxFiles && !f.closed {
 		select {
 		case f.purgeCh <- struct{}{}:
 		default:
@@ -187,6 +194,62 @@ func (f *FileRotator) nextFile() error {
 	return nil
 }
 
+// Rotate closes the current file and opens the next one even if the current
+// file has not reached the maximum size. It returns the path of the file that
+// was rotated out, or an empty path if the current file is empty.
+func (f *FileRotator) Rotate() (string, error) {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	f.closedLock.Lock()
+	closed := f.closed
+	f.closedLock.Unlock()
+	if closed {
+		return "", fmt.Errorf("rotator for %q is closed", f.baseFileName)
+	}
+
+	if err := f.flushBuffer(); err != nil {
+		return "", err
+	}
+	if f.currentWr == 0 {
+		return "", nil
+	}
+
+	rotated := f.currentFile.Name()
+	f.currentFile.Close()
+	if err := f.nextFile(); err != nil {
+		f.logger.Error("error creating next file", "err", err)
+		return "", err
+	}
+	return rotated, nil
+}
+
+// SetLimits updates the number of rotated files to keep and the size at which
+// files are rotated. The new limits apply to subsequent writes; if fewer files
+// are allowed the excess ones are purged.
+func (f *FileRotator) SetLimits(maxFiles int, fileSize int64) {
+	f.limitsLock.Lock()
+	f.MaxFiles = maxFiles
+	f.FileSize = fileSize
+	f.limitsLock.Unlock()
+
+	f.closedLock.Lock()
+	defer f.closedLock.Unlock()
+	if !f.closed {
+		select {
+		case f.purgeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// limits returns the current number of rotated files to keep and their size
+func (f *FileRotator) limits() (int, int64) {
+	f.limitsLock.RLock()
+	defer f.limitsLock.RUnlock()
+	return f.MaxFiles, f.FileSize
+}
+
 // lastFile finds out the rotated file with the largest index in a path.
 func (f *FileRotator) lastFile() error {
 	finfos, err := ioutil.ReadDir(f.path)
@@ -285,14 +348,15 @@ func (f *FileRotator) purgeOldFiles() {
 
 			// Not continuing to delete files if the number of files is not more
 			// than MaxFiles
-			if len(fIndexes) <= f.MaxFiles {
+			maxFiles, _ := f.limits()
+			if len(fIndexes) <= maxFiles {
 				continue
 			}
 
 			// Sorting the file indexes so that we can purge the older files and keep
 			// only the number of files as configured by the user
 			sort.Sort(sort.IntSlice(fIndexes))
-			toDelete := fIndexes[0 : len(fIndexes)-f.MaxFiles]
+			toDelete := fIndexes[0 : len(fIndexes)-maxFiles]
 			for _, fIndex := range toDelete {
 				fname := filepath.Join(f.path, fmt.Sprintf("%s.%d", f.baseFileName, fIndex))
 				err := os.RemoveAll(fname)
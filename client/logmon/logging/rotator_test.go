@@ -349,6 +349,105 @@ func TestFileRotator_PurgeOldFiles(t *testing.T) {
 	})
 }
 
+func TestFileRotator_SetLimits(t *testing.T) {
+	t.Parallel()
+	var path string
+	var err error
+	if path, err = ioutil.TempDir("", pathPrefix); err != nil {
+		t.Fatalf("test setup err: %v", err)
+	}
+	defer os.RemoveAll(path)
+
+	fr, err := NewFileRotator(path, baseFileName, 5, 2, testlog.HCLogger(t))
+	if err != nil {
+		t.Fatalf("test setup err: %v", err)
+	}
+
+	str := "abcdeghijklmn"
+	if _, err := fr.Write([]byte(str)); err != nil {
+		t.Fatalf("got error while writing: %v", err)
+	}
+
+	waitForFiles := func(expected int) {
+		var lastErr error
+		testutil.WaitForResult(func() (bool, error) {
+			f, err := ioutil.ReadDir(path)
+			if err != nil {
+				lastErr = fmt.Errorf("test error: %v", err)
+				return false, nil
+			}
+
+			if len(f) != expected {
+				lastErr = fmt.Errorf("expected number of files: %v, got: %v", expected, len(f))
+				return false, nil
+			}
+
+			return true, nil
+		}, func(err error) {
+			t.Fatalf("%v", lastErr)
+		})
+	}
+	waitForFiles(5)
+
+	// Lowering the number of files purges the excess ones
+	fr.SetLimits(2, 2)
+	waitForFiles(2)
+}
+
+func TestFileRotator_Rotate(t *testing.T) {
+	t.Parallel()
+	var path string
+	var err error
+	if path, err = ioutil.TempDir("", pathPrefix); err != nil {
+		t.Fatalf("test setup err: %v", err)
+	}
+	defer os.RemoveAll(path)
+
+	fr, err := NewFileRotator(path, baseFileName, 5, 1024, testlog.HCLogger(t))
+	if err != nil {
+		t.Fatalf("test setup err: %v", err)
+	}
+	defer fr.Close()
+
+	// Nothing is rotated while the current file is empty
+	rotated, err := fr.Rotate()
+	if err != nil {
+		t.Fatalf("got error while rotating: %v", err)
+	}
+	if rotated != "" {
+		t.Fatalf("expected no rotated file, got %q", rotated)
+	}
+
+	str := "abcdefgh"
+	if _, err := fr.Write([]byte(str)); err != nil {
+		t.Fatalf("got error while writing: %v", err)
+	}
+
+	rotated, err = fr.Rotate()
+	if err != nil {
+		t.Fatalf("got error while rotating: %v", err)
+	}
+	if expected := filepath.Join(path, baseFileName+".0"); rotated != expected {
+		t.Fatalf("expected rotated file %q, got %q", expected, rotated)
+	}
+
+	// The rotated file holds the output and new writes go to the next file
+	if b, err := ioutil.ReadFile(rotated); err != nil {
+		t.Fatalf("failed to read rotated file: %v", err)
+	} else if string(b) != str {
+		t.Fatalf("expected %q in rotated file, got %q", str, b)
+	}
+	if _, err := fr.Write([]byte("ijk")); err != nil {
+		t.Fatalf("got error while writing: %v", err)
+	}
+	fr.flushBuffer()
+	if b, err := ioutil.ReadFile(filepath.Join(path, baseFileName+".1")); err != nil {
+		t.Fatalf("failed to read current file: %v", err)
+	} else if string(b) != "ijk" {
+		t.Fatalf("expected %q in current file, got %q", "ijk", b)
+	}
+}
+
 func BenchmarkRotator(b *testing.B) {
 	kb := 1024
 	for _, inputSize := range []int{kb, 2 * kb, 4 * kb, 8 * kb, 16 * kb, 32 * kb, 64 * kb, 128 * kb, 256 * kb} {
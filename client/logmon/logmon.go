@@ -45,6 +45,10 @@ type LogConfig struct {
 type LogMon interface {
 	Start(*LogConfig) error
 	Stop() error
+
+	// Rotate forces the rotation of the stdout or stderr log and returns the
+	// path of the rotated file.
+	Rotate(logType string) (string, error)
 }
 
 func NewLogMon(logger hclog.Logger) LogMon {
@@ -75,7 +79,9 @@ func (l *logmonImpl) Start(cfg *LogConfig) error {
 		return l.start(cfg)
 	}
 
-	// if the TaskLogger has been created and is currently running, noop
+	// if the TaskLogger has been created and is currently running, apply
+	// any change to the rotation settings
+	l.tl.SetRotation(cfg.MaxFiles, cfg.MaxFileSizeMB)
 	return nil
 }
 
@@ -97,6 +103,15 @@ func (l *logmonImpl) Stop() error {
 	return nil
 }
 
+func (l *logmonImpl) Rotate(logType string) (string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.tl == nil {
+		return "", fmt.Errorf("logmon has not been started")
+	}
+	return l.tl.Rotate(logType)
+}
+
 type TaskLogger struct {
 	config *LogConfig
 
@@ -119,6 +134,37 @@ func (tl *TaskLogger) IsRunning() bool {
 	return false
 }
 
+// SetRotation updates the rotation settings of both the stdout and stderr
+// rotators without interrupting log collection.
+func (tl *TaskLogger) SetRotation(maxFiles, maxFileSizeMB int) {
+	logFileSize := int64(maxFileSizeMB * 1024 * 1024)
+	if tl.lro != nil {
+		tl.lro.rotatorWriter.SetLimits(maxFiles, logFileSize)
+	}
+	if tl.lre != nil {
+		tl.lre.rotatorWriter.SetLimits(maxFiles, logFileSize)
+	}
+}
+
+// Rotate forces the rotation of the stdout or stderr log and returns the path
+// of the rotated file. The path is empty if the log was empty.
+func (tl *TaskLogger) Rotate(logType string) (string, error) {
+	var wrapper *logRotatorWrapper
+	switch logType {
+	case "stdout":
+		wrapper = tl.lro
+	case "stderr":
+		wrapper = tl.lre
+	default:
+		return "", fmt.Errorf("unknown log type %q", logType)
+	}
+
+	if wrapper == nil {
+		return "", fmt.Errorf("%s log is not being collected", logType)
+	}
+	return wrapper.rotatorWriter.Rotate()
+}
+
 func (tl *TaskLogger) Close() {
 	var wg sync.WaitGroup
 	if tl.lro != nil {
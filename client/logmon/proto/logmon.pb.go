@@ -40,7 +40,7 @@ func (m *StartRequest) Reset()         { *m = StartRequest{} }
 func (m *StartRequest) String() string { return proto.CompactTextString(m) }
 func (*StartRequest) ProtoMessage()    {}
 func (*StartRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_logmon_c8f5fe5f286cd193, []int{0}
+	return fileDescriptor_logmon_e40830c0da3b26e7, []int{0}
 }
 func (m *StartRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StartRequest.Unmarshal(m, b)
@@ -119,7 +119,7 @@ func (m *StartResponse) Reset()         { *m = StartResponse{} }
 func (m *StartResponse) String() string { return proto.CompactTextString(m) }
 func (*StartResponse) ProtoMessage()    {}
 func (*StartResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_logmon_c8f5fe5f286cd193, []int{1}
+	return fileDescriptor_logmon_e40830c0da3b26e7, []int{1}
 }
 func (m *StartResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StartResponse.Unmarshal(m, b)
@@ -149,7 +149,7 @@ func (m *StopRequest) Reset()         { *m = StopRequest{} }
 func (m *StopRequest) String() string { return proto.CompactTextString(m) }
 func (*StopRequest) ProtoMessage()    {}
 func (*StopRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_logmon_c8f5fe5f286cd193, []int{2}
+	return fileDescriptor_logmon_e40830c0da3b26e7, []int{2}
 }
 func (m *StopRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StopRequest.Unmarshal(m, b)
@@ -179,7 +179,7 @@ func (m *StopResponse) Reset()         { *m = StopResponse{} }
 func (m *StopResponse) String() string { return proto.CompactTextString(m) }
 func (*StopResponse) ProtoMessage()    {}
 func (*StopResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_logmon_c8f5fe5f286cd193, []int{3}
+	return fileDescriptor_logmon_e40830c0da3b26e7, []int{3}
 }
 func (m *StopResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StopResponse.Unmarshal(m, b)
@@ -199,11 +199,89 @@ func (m *StopResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StopResponse proto.InternalMessageInfo
 
+type RotateRequest struct {
+	LogType              string   `protobuf:"bytes,1,opt,name=log_type,json=logType,proto3" json:"log_type,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RotateRequest) Reset()         { *m = RotateRequest{} }
+func (m *RotateRequest) String() string { return proto.CompactTextString(m) }
+func (*RotateRequest) ProtoMessage()    {}
+func (*RotateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_logmon_e40830c0da3b26e7, []int{4}
+}
+func (m *RotateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RotateRequest.Unmarshal(m, b)
+}
+func (m *RotateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RotateRequest.Marshal(b, m, deterministic)
+}
+func (dst *RotateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RotateRequest.Merge(dst, src)
+}
+func (m *RotateRequest) XXX_Size() int {
+	return xxx_messageInfo_RotateRequest.Size(m)
+}
+func (m *RotateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RotateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RotateRequest proto.InternalMessageInfo
+
+func (m *RotateRequest) GetLogType() string {
+	if m != nil {
+		return m.LogType
+	}
+	return ""
+}
+
+type RotateResponse struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RotateResponse) Reset()         { *m = RotateResponse{} }
+func (m *RotateResponse) String() string { return proto.CompactTextString(m) }
+func (*RotateResponse) ProtoMessage()    {}
+func (*RotateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_logmon_e40830c0da3b26e7, []int{5}
+}
+func (m *RotateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RotateResponse.Unmarshal(m, b)
+}
+func (m *RotateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RotateResponse.Marshal(b, m, deterministic)
+}
+func (dst *RotateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RotateResponse.Merge(dst, src)
+}
+func (m *RotateResponse) XXX_Size() int {
+	return xxx_messageInfo_RotateResponse.Size(m)
+}
+func (m *RotateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RotateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RotateResponse proto.InternalMessageInfo
+
+func (m *RotateResponse) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*StartRequest)(nil), "hashicorp.nomad.client.logmon.proto.StartRequest")
 	proto.RegisterType((*StartResponse)(nil), "hashicorp.nomad.client.logmon.proto.StartResponse")
 	proto.RegisterType((*StopRequest)(nil), "hashicorp.nomad.client.logmon.proto.StopRequest")
 	proto.RegisterType((*StopResponse)(nil), "hashicorp.nomad.client.logmon.proto.StopResponse")
+	proto.RegisterType((*RotateRequest)(nil), "hashicorp.nomad.client.logmon.proto.RotateRequest")
+	proto.RegisterType((*RotateResponse)(nil), "hashicorp.nomad.client.logmon.proto.RotateResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -220,6 +298,7 @@ const _ = grpc.SupportPackageIsVersion4
 type LogMonClient interface {
 	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*StartResponse, error)
 	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error)
+	Rotate(ctx context.Context, in *RotateRequest, opts ...grpc.CallOption) (*RotateResponse, error)
 }
 
 type logMonClient struct {
@@ -248,10 +327,20 @@ func (c *logMonClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *logMonClient) Rotate(ctx context.Context, in *RotateRequest, opts ...grpc.CallOption) (*RotateResponse, error) {
+	out := new(RotateResponse)
+	err := c.cc.Invoke(ctx, "/hashicorp.nomad.client.logmon.proto.LogMon/Rotate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LogMonServer is the server API for LogMon service.
 type LogMonServer interface {
 	Start(context.Context, *StartRequest) (*StartResponse, error)
 	Stop(context.Context, *StopRequest) (*StopResponse, error)
+	Rotate(context.Context, *RotateRequest) (*RotateResponse, error)
 }
 
 func RegisterLogMonServer(s *grpc.Server, srv LogMonServer) {
@@ -294,6 +383,24 @@ func _LogMon_Stop_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LogMon_Rotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogMonServer).Rotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hashicorp.nomad.client.logmon.proto.LogMon/Rotate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogMonServer).Rotate(ctx, req.(*RotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _LogMon_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "hashicorp.nomad.client.logmon.proto.LogMon",
 	HandlerType: (*LogMonServer)(nil),
@@ -306,35 +413,43 @@ var _LogMon_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Stop",
 			Handler:    _LogMon_Stop_Handler,
 		},
+		{
+			MethodName: "Rotate",
+			Handler:    _LogMon_Rotate_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "client/logmon/proto/logmon.proto",
 }
 
 func init() {
-	proto.RegisterFile("client/logmon/proto/logmon.proto", fileDescriptor_logmon_c8f5fe5f286cd193)
-}
-
-var fileDescriptor_logmon_c8f5fe5f286cd193 = []byte{
-	// 320 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x90, 0x31, 0x6f, 0xc2, 0x30,
-	0x10, 0x85, 0x1b, 0x0a, 0xa1, 0x1c, 0x0d, 0x45, 0x5e, 0x1a, 0xd1, 0xa1, 0x28, 0x1d, 0xca, 0x14,
-	0x0a, 0xfd, 0x07, 0x55, 0xd5, 0xa9, 0x74, 0x80, 0xad, 0x4b, 0x64, 0xc0, 0x09, 0x96, 0x62, 0x5f,
-	0x6a, 0x1b, 0x09, 0xb1, 0xf6, 0xd7, 0xf6, 0x5f, 0x54, 0x71, 0x4c, 0x94, 0x11, 0x26, 0xeb, 0xee,
-	0x7d, 0x4f, 0xf7, 0xfc, 0x60, 0xbc, 0xc9, 0x39, 0x93, 0x66, 0x9a, 0x63, 0x26, 0x50, 0x4e, 0x0b,
-	0x85, 0x06, 0xdd, 0x10, 0xdb, 0x81, 0x3c, 0xed, 0xa8, 0xde, 0xf1, 0x0d, 0xaa, 0x22, 0x96, 0x28,
-	0xe8, 0x36, 0xae, 0x1c, 0x71, 0x13, 0x8a, 0x7e, 0x5b, 0x70, 0xbb, 0x32, 0x54, 0x99, 0x25, 0xfb,
-	0xd9, 0x33, 0x6d, 0xc8, 0x3d, 0x74, 0x73, 0xcc, 0x92, 0x2d, 0x57, 0xa1, 0x37, 0xf6, 0x26, 0xbd,
-	0xa5, 0x9f, 0x63, 0xf6, 0xce, 0x15, 0x99, 0xc0, 0x50, 0x9b, 0x2d, 0xee, 0x4d, 0x92, 0xf2, 0x9c,
-	0x25, 0x92, 0x0a, 0x16, 0xb6, 0x2c, 0x31, 0xa8, 0xf6, 0x1f, 0x3c, 0x67, 0x5f, 0x54, 0x30, 0x47,
-	0x32, 0xa5, 0x1a, 0xe4, 0x75, 0x4d, 0x32, 0xa5, 0x6a, 0xf2, 0x01, 0x7a, 0x82, 0x1e, 0x2c, 0xa6,
-	0xc3, 0xf6, 0xd8, 0x9b, 0x04, 0xcb, 0x1b, 0x41, 0x0f, 0xa5, 0xae, 0xc9, 0x33, 0x0c, 0x4f, 0x62,
-	0xa2, 0xf9, 0x91, 0x25, 0x62, 0x1d, 0x76, 0x2c, 0x13, 0x38, 0x66, 0xc5, 0x8f, 0x6c, 0xb1, 0x26,
-	0x8f, 0xd0, 0xaf, 0x93, 0xa5, 0x18, 0xfa, 0xf6, 0x14, 0x9c, 0x42, 0xa5, 0xe8, 0x80, 0x2a, 0x50,
-	0x8a, 0x61, 0xb7, 0x06, 0x6c, 0x96, 0x14, 0xa3, 0x3b, 0x08, 0x5c, 0x09, 0xba, 0x40, 0xa9, 0x59,
-	0x14, 0x40, 0x7f, 0x65, 0xb0, 0x70, 0xa5, 0x44, 0x83, 0xb2, 0xa4, 0x72, 0xac, 0xe4, 0xf9, 0x9f,
-	0x07, 0xfe, 0x27, 0x66, 0x0b, 0x94, 0xa4, 0x80, 0x8e, 0xb5, 0x92, 0x59, 0x7c, 0x46, 0xdf, 0x71,
-	0xb3, 0xeb, 0xd1, 0xfc, 0x12, 0x8b, 0x4b, 0x76, 0x45, 0x04, 0xb4, 0xcb, 0x30, 0xe4, 0xe5, 0x4c,
-	0x77, 0xfd, 0x8d, 0xd1, 0xec, 0x02, 0xc7, 0xe9, 0xdc, 0x5b, 0xf7, 0xbb, 0x63, 0xf7, 0x6b, 0xdf,
-	0x3e, 0xaf, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff, 0xd8, 0xb4, 0x87, 0xfc, 0x7a, 0x02, 0x00, 0x00,
+	proto.RegisterFile("client/logmon/proto/logmon.proto", fileDescriptor_logmon_e40830c0da3b26e7)
+}
+
+var fileDescriptor_logmon_e40830c0da3b26e7 = []byte{
+	// 374 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x91, 0x31, 0xaf, 0xda, 0x30,
+	0x14, 0x85, 0x0b, 0x85, 0x00, 0x17, 0x42, 0x91, 0x97, 0xa6, 0x74, 0x28, 0x4a, 0x2b, 0x15, 0x75,
+	0x08, 0x05, 0xfe, 0x41, 0x55, 0x75, 0x2a, 0x1d, 0x42, 0xa7, 0x2e, 0x91, 0x01, 0x27, 0x58, 0x8a,
+	0x73, 0x5d, 0xdb, 0x48, 0xc0, 0xfa, 0xfe, 0xe7, 0xfb, 0x2d, 0x4f, 0x71, 0x9c, 0x88, 0xb7, 0xc1,
+	0x94, 0x5c, 0x9f, 0xef, 0xe8, 0x1e, 0x1f, 0xc3, 0x6c, 0x9f, 0x73, 0x56, 0x98, 0x45, 0x8e, 0x99,
+	0xc0, 0x62, 0x21, 0x15, 0x1a, 0x74, 0x43, 0x64, 0x07, 0xf2, 0xf9, 0x48, 0xf5, 0x91, 0xef, 0x51,
+	0xc9, 0xa8, 0x40, 0x41, 0x0f, 0x51, 0xe5, 0x88, 0x6e, 0xa1, 0xf0, 0xa9, 0x0d, 0xa3, 0xad, 0xa1,
+	0xca, 0xc4, 0xec, 0xff, 0x89, 0x69, 0x43, 0xde, 0x43, 0x2f, 0xc7, 0x2c, 0x39, 0x70, 0x15, 0xb4,
+	0x66, 0xad, 0xf9, 0x20, 0xf6, 0x72, 0xcc, 0x7e, 0x72, 0x45, 0xe6, 0x30, 0xd1, 0xe6, 0x80, 0x27,
+	0x93, 0xa4, 0x3c, 0x67, 0x49, 0x41, 0x05, 0x0b, 0xda, 0x96, 0x18, 0x57, 0xe7, 0xbf, 0x78, 0xce,
+	0xfe, 0x50, 0xc1, 0x1c, 0xc9, 0x94, 0xba, 0x21, 0xdf, 0x36, 0x24, 0x53, 0xaa, 0x21, 0x3f, 0xc2,
+	0x40, 0xd0, 0xb3, 0xc5, 0x74, 0xd0, 0x99, 0xb5, 0xe6, 0x7e, 0xdc, 0x17, 0xf4, 0x5c, 0xea, 0x9a,
+	0x7c, 0x85, 0x49, 0x2d, 0x26, 0x9a, 0x5f, 0x59, 0x22, 0x76, 0x41, 0xd7, 0x32, 0xbe, 0x63, 0xb6,
+	0xfc, 0xca, 0x36, 0x3b, 0xf2, 0x09, 0x86, 0x4d, 0xb2, 0x14, 0x03, 0xcf, 0xae, 0x82, 0x3a, 0x54,
+	0x8a, 0x0e, 0xa8, 0x02, 0xa5, 0x18, 0xf4, 0x1a, 0xc0, 0x66, 0x49, 0x31, 0x7c, 0x07, 0xbe, 0x2b,
+	0x41, 0x4b, 0x2c, 0x34, 0x0b, 0x7d, 0x18, 0x6e, 0x0d, 0x4a, 0x57, 0x4a, 0x38, 0x86, 0x51, 0x35,
+	0x3a, 0xf9, 0x1b, 0xf8, 0x31, 0x1a, 0x6a, 0x58, 0xdd, 0xda, 0x07, 0xe8, 0x97, 0xad, 0x99, 0x8b,
+	0x64, 0xae, 0xb6, 0xb2, 0xc5, 0xbf, 0x17, 0xc9, 0xc2, 0x2f, 0x30, 0xae, 0xd9, 0xca, 0x4d, 0x08,
+	0x74, 0x24, 0x35, 0x47, 0x07, 0xda, 0xff, 0xd5, 0x73, 0x1b, 0xbc, 0xdf, 0x98, 0x6d, 0xb0, 0x20,
+	0x12, 0xba, 0x36, 0x0c, 0x59, 0x46, 0x77, 0xbc, 0x60, 0x74, 0xfb, 0x7a, 0xd3, 0xd5, 0x23, 0x16,
+	0x77, 0x99, 0x37, 0x44, 0x40, 0xa7, 0xbc, 0x1e, 0xf9, 0x7e, 0xa7, 0xbb, 0x29, 0x66, 0xba, 0x7c,
+	0xc0, 0xd1, 0xac, 0xd3, 0xe0, 0x55, 0x8d, 0x90, 0xfb, 0xe2, 0xbe, 0xaa, 0x7a, 0xba, 0x7e, 0xc8,
+	0x53, 0x2f, 0xfd, 0xd1, 0xfb, 0xd7, 0xb5, 0xca, 0xce, 0xb3, 0x9f, 0xf5, 0xcb, 0x00, 0x51, 0xb4,
+	0x27, 0x9d, 0x41, 0x03, 0x00, 0x00,
 }
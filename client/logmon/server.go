@@ -34,3 +34,11 @@ func (s *logmonServer) Start(ctx context.Context, req *proto.StartRequest) (*pro
 func (s *logmonServer) Stop(ctx context.Context, req *proto.StopRequest) (*proto.StopResponse, error) {
 	return &proto.StopResponse{}, s.impl.Stop()
 }
+
+func (s *logmonServer) Rotate(ctx context.Context, req *proto.RotateRequest) (*proto.RotateResponse, error) {
+	path, err := s.impl.Rotate(req.LogType)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.RotateResponse{Path: path}, nil
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
@@ -10,6 +11,7 @@ import (
 
 	metrics "github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/lib"
+	"github.com/hashicorp/nomad/client/lib/streamsession"
 	"github.com/hashicorp/nomad/client/servers"
 	inmem "github.com/hashicorp/nomad/helper/codec"
 	"github.com/hashicorp/nomad/helper/pool"
@@ -39,9 +41,68 @@ func (c *Client) ClientRPC(method string, args interface{}, reply interface{}) e
 }
 
 // StreamingRpcHandler is used to make a local, client only streaming RPC
-// call.
+// call. The sessions of the returned handler are tracked.
 func (c *Client) StreamingRpcHandler(method string) (structs.StreamingRpcHandler, error) {
-	return c.streamingRpcs.GetHandler(method)
+	handler, err := c.streamingRpcs.GetHandler(method)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(conn io.ReadWriteCloser) {
+		tracked, done := c.streamSessions.Track(method, conn)
+		defer done()
+		handler(tracked)
+	}, nil
+}
+
+// acquireStream waits for a stream slot for the namespace. It gives up if the
+// remote side closes conn while the stream is queued so abandoned streams
+// don't hold their place in the queue.
+func (c *Client) acquireStream(conn io.ReadWriteCloser, namespace string) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create a goroutine to detect the remote side closing. It only exits
+	// once conn is closed, cancelling the already finished wait.
+	go func() {
+		for {
+			if _, err := conn.Read(nil); err != nil {
+				if err == io.EOF || err == io.ErrClosedPipe {
+					cancel()
+				}
+				return
+			}
+		}
+	}()
+
+	return c.streamLimiter.Acquire(ctx, namespace)
+}
+
+// setStreamTarget records the allocation and task streamed over conn and the
+// accessor of the token that opened the stream. It is a no-op for streams
+// that aren't tracked.
+func (c *Client) setStreamTarget(conn io.ReadWriteCloser, allocID, task, secretID string) {
+	session, ok := conn.(*streamsession.Conn)
+	if !ok {
+		return
+	}
+
+	session.SetTarget(allocID, task)
+	if accessor := c.tokenAccessor(secretID); accessor != "" {
+		session.SetAccessor(accessor)
+	}
+}
+
+// tokenAccessor returns the accessor of the token. It is empty if ACLs are
+// disabled or the token can't be resolved.
+func (c *Client) tokenAccessor(secretID string) string {
+	if !c.config.ACLEnabled {
+		return ""
+	}
+	if token, err := c.resolveTokenValue(secretID); err == nil && token != nil {
+		return token.AccessorID
+	}
+	return ""
 }
 
 // RPC is used to forward an RPC call to a nomad server, or fail if no servers.
@@ -215,9 +276,9 @@ func (c *Client) streamingRpcConn(server *servers.Server, method string) (net.Co
 // setupClientRpc is used to setup the Client's RPC endpoints
 func (c *Client) setupClientRpc() {
 	// Initialize the RPC handlers
-	c.endpoints.ClientStats = &ClientStats{c}
+	c.endpoints.ClientStats = NewClientStatsEndpoint(c)
 	c.endpoints.FileSystem = NewFileSystemEndpoint(c)
-	c.endpoints.Allocations = &Allocations{c}
+	c.endpoints.Allocations = NewAllocationsEndpoint(c)
 
 	// Create the RPC Server
 	c.rpcServer = rpc.NewServer()
@@ -344,7 +405,7 @@ func (c *Client) handleStreamingConn(conn net.Conn) {
 	}
 
 	ack := structs.StreamingRpcAck{}
-	handler, err := c.streamingRpcs.GetHandler(header.Method)
+	handler, err := c.StreamingRpcHandler(header.Method)
 	if err != nil {
 		c.rpcLogger.Error("streaming RPC error", "addr", conn.RemoteAddr(), "error", err)
 		metrics.IncrCounter([]string{"client", "streaming_rpc", "request_error"}, 1)
@@ -1,15 +1,22 @@
 package client
 
 import (
+	"context"
 	"errors"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/lib/streamlimit"
+	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/nomad"
+	"github.com/hashicorp/nomad/nomad/mock"
 	"github.com/hashicorp/nomad/nomad/structs"
 	sconfig "github.com/hashicorp/nomad/nomad/structs/config"
 	"github.com/hashicorp/nomad/testutil"
 	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
 )
 
 func TestRpc_streamingRpcConn_badEndpoint(t *testing.T) {
@@ -113,3 +120,125 @@ func TestRpc_streamingRpcConn_badEndpoint_TLS(t *testing.T) {
 	require.NotNil(err)
 	require.Contains(err.Error(), "Unknown rpc method: \"Bogus\"")
 }
+
+// TestRpc_StreamingRpcs_StreamLimit asserts the streaming RPCs take a stream
+// slot before streaming.
+func TestRpc_StreamingRpcs_StreamLimit(t *testing.T) {
+	t.Parallel()
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.MaxConcurrentStreams = 1
+	})
+	defer cleanup()
+
+	a := mock.Alloc()
+	a.Job.TaskGroups[0].Tasks[0].Driver = "mock_driver"
+	a.Job.TaskGroups[0].Tasks[0].Config = map[string]interface{}{
+		"run_for": "20s",
+	}
+	require.Nil(t, c.addAlloc(a, ""))
+
+	testutil.WaitForResult(func() (bool, error) {
+		ar, err := c.getAllocRunner(a.ID)
+		if err != nil {
+			return false, err
+		}
+		if ts := ar.AllocState().TaskStates["web"]; ts == nil || ts.State != structs.TaskStateRunning {
+			return false, errors.New("task not running")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Hold the only stream slot
+	release, err := c.streamLimiter.Acquire(context.Background(), structs.DefaultNamespace)
+	require.NoError(t, err)
+	defer release()
+
+	cases := map[string]interface{}{
+		"Allocations.Checks":           &cstructs.AllocChecksRequest{AllocID: a.ID},
+		"Allocations.GarbageCollect":   &structs.AllocSpecificRequest{AllocID: a.ID},
+		"Allocations.LifecycleEvents":  &structs.AllocSpecificRequest{AllocID: a.ID},
+		"Allocations.Decisions":        &structs.AllocSpecificRequest{AllocID: a.ID},
+		"Allocations.ArtifactProgress": &cstructs.AllocArtifactProgressRequest{AllocID: a.ID, Task: "web"},
+		"Allocations.StatsStream":      &cstructs.AllocStatsRequest{AllocID: a.ID},
+		"ClientStats.StatsStream":      &cstructs.ClientStatsStreamRequest{},
+		"FileSystem.Logs": &cstructs.FsLogsRequest{
+			AllocID: a.ID,
+			Task:    "web",
+			LogType: "stdout",
+			Origin:  "start",
+		},
+		"FileSystem.AllocLogs": &cstructs.FsAllocLogsRequest{AllocID: a.ID},
+		"FileSystem.Stream": &cstructs.FsStreamRequest{
+			AllocID: a.ID,
+			Path:    "alloc/logs/web.stdout.0",
+		},
+	}
+
+	for method, req := range cases {
+		t.Run(method, func(t *testing.T) {
+			handler, err := c.StreamingRpcHandler(method)
+			require.NoError(t, err)
+
+			p1, p2 := net.Pipe()
+			defer p1.Close()
+			defer p2.Close()
+			go handler(p2)
+
+			require.NoError(t, codec.NewEncoder(p1, structs.MsgpackHandle).Encode(req))
+
+			var msg cstructs.StreamErrWrapper
+			require.NoError(t, codec.NewDecoder(p1, structs.MsgpackHandle).Decode(&msg))
+			require.NotNil(t, msg.Error)
+			require.Equal(t, streamlimit.ErrLimitReached.Error(), msg.Error.Message)
+			require.EqualValues(t, 429, *msg.Error.Code)
+		})
+	}
+}
+
+// TestRpc_StreamingRpcs_StreamQueue_Disconnect asserts a queued stream stops
+// waiting for a slot once the remote side disconnects.
+func TestRpc_StreamingRpcs_StreamQueue_Disconnect(t *testing.T) {
+	t.Parallel()
+	c, cleanup := TestClient(t, func(c *config.Config) {
+		c.MaxConcurrentStreams = 1
+		c.StreamQueueTimeout = time.Hour
+	})
+	defer cleanup()
+
+	// Hold the only stream slot
+	release, err := c.streamLimiter.Acquire(context.Background(), structs.DefaultNamespace)
+	require.NoError(t, err)
+	defer release()
+
+	handler, err := c.StreamingRpcHandler("ClientStats.StatsStream")
+	require.NoError(t, err)
+
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+
+	done := make(chan struct{})
+	go func() {
+		handler(p2)
+		close(done)
+	}()
+
+	req := &cstructs.ClientStatsStreamRequest{}
+	require.NoError(t, codec.NewEncoder(p1, structs.MsgpackHandle).Encode(req))
+
+	// The stream is queued until the remote side disconnects
+	select {
+	case <-done:
+		t.Fatal("stream was not queued")
+	case <-time.After(100 * time.Millisecond):
+	}
+	p1.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued stream kept waiting after the disconnect")
+	}
+	require.Empty(t, c.streamSessions.List())
+}
@@ -24,9 +24,20 @@ type HostStats struct {
 	DeviceStats      []*DeviceGroupStats
 	Uptime           uint64
 	Timestamp        int64
+	Monotonic        int64
 	CPUTicksConsumed float64
 }
 
+// processStart is the reference monotonic readings of samples are taken from
+var processStart = time.Now()
+
+// Monotonic returns the monotonic clock reading of t in nanoseconds since the
+// client process started. Unlike the wall clock it doesn't jump when the
+// clock is adjusted.
+func Monotonic(t time.Time) int64 {
+	return t.Sub(processStart).Nanoseconds()
+}
+
 // MemoryStats represents stats related to virtual memory usage
 type MemoryStats struct {
 	Total     uint64
@@ -53,6 +64,11 @@ type DiskStats struct {
 	Available         uint64
 	UsedPercent       float64
 	InodesUsedPercent float64
+
+	// InodesUsed and InodesTotal are zero on filesystems without inode
+	// accounting.
+	InodesUsed  uint64
+	InodesTotal uint64
 }
 
 // DeviceGroupStats represents stats related to device group
@@ -112,7 +128,8 @@ func (h *HostStatsCollector) Collect() error {
 // collectLocked collects stats related to resource usage of the host but should
 // be called with the lock held.
 func (h *HostStatsCollector) collectLocked() error {
-	hs := &HostStats{Timestamp: time.Now().UTC().UnixNano()}
+	now := time.Now()
+	hs := &HostStats{Timestamp: now.UTC().UnixNano(), Monotonic: Monotonic(now)}
 
 	// Determine up-time
 	uptime, err := host.Uptime()
@@ -233,6 +250,8 @@ func (h *HostStatsCollector) toDiskStats(usage *disk.UsageStat, partitionStat *d
 		Available:         usage.Free,
 		UsedPercent:       usage.UsedPercent,
 		InodesUsedPercent: usage.InodesUsedPercent,
+		InodesUsed:        usage.InodesUsed,
+		InodesTotal:       usage.InodesTotal,
 	}
 	if math.IsNaN(ds.UsedPercent) {
 		ds.UsedPercent = 0.0
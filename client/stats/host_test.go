@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
 )
 
 func TestHostCpuStatsCalculator_Nan(t *testing.T) {
@@ -30,3 +31,24 @@ func TestHostCpuStatsCalculator_Nan(t *testing.T) {
 		t.Errorf("total: Expected: %f, Got %f", 0.0, total)
 	}
 }
+
+func TestHostStatsCollector_toDiskStats_Inodes(t *testing.T) {
+	var h HostStatsCollector
+
+	// Filesystems without inode accounting report no inodes
+	ds := h.toDiskStats(&disk.UsageStat{Total: 100, Used: 50, UsedPercent: 50}, nil)
+	if ds.InodesUsed != 0 || ds.InodesTotal != 0 || ds.InodesUsedPercent != 0 {
+		t.Errorf("expected no inode usage, got %#v", ds)
+	}
+
+	ds = h.toDiskStats(&disk.UsageStat{InodesUsed: 10, InodesTotal: 40, InodesUsedPercent: 25}, nil)
+	if ds.InodesUsed != 10 {
+		t.Errorf("inodes used: Expected: %d, Got %d", 10, ds.InodesUsed)
+	}
+	if ds.InodesTotal != 40 {
+		t.Errorf("inodes total: Expected: %d, Got %d", 40, ds.InodesTotal)
+	}
+	if ds.InodesUsedPercent != 25 {
+		t.Errorf("inodes percent: Expected: %f, Got %f", 25.0, ds.InodesUsedPercent)
+	}
+}
@@ -0,0 +1,149 @@
+package structs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+const (
+	// AllocDecisionRun, AllocDecisionUpdate, AllocDecisionStop,
+	// AllocDecisionMigrate, AllocDecisionRemove, AllocDecisionReject and
+	// AllocDecisionUnhealthy are the decisions the client makes while
+	// reconciling its allocations with the servers
+	AllocDecisionRun       = "run"
+	AllocDecisionUpdate    = "update"
+	AllocDecisionStop      = "stop"
+	AllocDecisionMigrate   = "migrate"
+	AllocDecisionRemove    = "remove"
+	AllocDecisionReject    = "reject"
+	AllocDecisionUnhealthy = "unhealthy"
+
+	// decisionListenerCap is the number of decisions buffered for each
+	// listener before decisions are dropped.
+	decisionListenerCap = 64
+)
+
+// AllocDecision is a decision the client made about an allocation, along with
+// the server update that triggered it.
+type AllocDecision struct {
+	// Timestamp is when the decision was made (UnixNano)
+	Timestamp int64
+
+	// AllocID is the allocation the decision is about
+	AllocID string
+
+	// Decision is one of the AllocDecision constants
+	Decision string
+
+	// Reason is a human readable explanation of the decision
+	Reason string
+
+	// Update is the server update of the allocation that triggered the
+	// decision. It is nil for decisions made by the client alone, eg when
+	// the allocation failed its health checks.
+	Update *AllocServerUpdate
+}
+
+// AllocServerUpdate is the part of a server update of an allocation that
+// drives the client's decisions.
+type AllocServerUpdate struct {
+	AllocModifyIndex   uint64
+	DesiredStatus      string
+	DesiredDescription string
+	DesiredTransition  structs.DesiredTransition
+	DeploymentID       string
+	JobVersion         uint64
+	JobModifyIndex     uint64
+}
+
+// NewAllocServerUpdate returns the update of the allocation received from
+// the servers. The allocation is nil if the servers only sent its ID.
+func NewAllocServerUpdate(alloc *structs.Allocation) *AllocServerUpdate {
+	if alloc == nil {
+		return nil
+	}
+
+	u := &AllocServerUpdate{
+		AllocModifyIndex:   alloc.AllocModifyIndex,
+		DesiredStatus:      alloc.DesiredStatus,
+		DesiredDescription: alloc.DesiredDescription,
+		DesiredTransition:  alloc.DesiredTransition,
+		DeploymentID:       alloc.DeploymentID,
+	}
+	if alloc.Job != nil {
+		u.JobVersion = alloc.Job.Version
+		u.JobModifyIndex = alloc.Job.JobModifyIndex
+	}
+	return u
+}
+
+type decisionListener struct {
+	allocID string
+	ch      chan *AllocDecision
+}
+
+// AllocDecisionBroadcaster sends the decisions of the client to the listeners
+// of the allocation they are about. Sends never block: decisions are dropped
+// for listeners that fall behind. A nil broadcaster discards all decisions.
+type AllocDecisionBroadcaster struct {
+	mu sync.Mutex
+
+	// listeners is a map of unique ids to listeners
+	listeners map[int]*decisionListener
+
+	// nextId is the next id to assign in listener map
+	nextId int
+}
+
+// NewAllocDecisionBroadcaster returns a new AllocDecisionBroadcaster.
+func NewAllocDecisionBroadcaster() *AllocDecisionBroadcaster {
+	return &AllocDecisionBroadcaster{
+		listeners: make(map[int]*decisionListener),
+	}
+}
+
+// Send broadcasts the decision, stamping it with the current time.
+func (b *AllocDecisionBroadcaster) Send(d *AllocDecision) {
+	if b == nil {
+		return
+	}
+
+	d.Timestamp = time.Now().UnixNano()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, l := range b.listeners {
+		if l.allocID != d.AllocID {
+			continue
+		}
+
+		select {
+		case l.ch <- d:
+		default:
+		}
+	}
+}
+
+// Listen returns a channel receiving the decisions about the allocation made
+// from now on and a function to stop listening, which closes the channel.
+func (b *AllocDecisionBroadcaster) Listen(allocID string) (<-chan *AllocDecision, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextId
+	b.nextId++
+	ch := make(chan *AllocDecision, decisionListenerCap)
+	b.listeners[id] = &decisionListener{allocID: allocID, ch: ch}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.listeners, id)
+			close(ch)
+		})
+	}
+}
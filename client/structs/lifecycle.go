@@ -0,0 +1,126 @@
+package structs
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// LifecycleEventHook and LifecycleEventState are the types of alloc
+	// runner lifecycle events
+	LifecycleEventHook  = "hook"
+	LifecycleEventState = "state"
+
+	// lifecycleListenerCap is the number of events buffered for each
+	// listener before events are dropped.
+	lifecycleListenerCap = 64
+)
+
+// AllocLifecycleEvent is an event of the lifecycle of an alloc runner or one
+// of its task runners.
+type AllocLifecycleEvent struct {
+	// Timestamp is when the event occurred (UnixNano)
+	Timestamp int64
+
+	// Task is the task the event is about. It is empty for events of the
+	// alloc runner.
+	Task string
+
+	// Type is either LifecycleEventHook or LifecycleEventState
+	Type string
+
+	// Stage is the stage the hook ran in, eg prerun or prestart
+	Stage string
+
+	// Hook is the name of the hook that ran
+	Hook string
+
+	// Duration is the time the hook took to run
+	Duration time.Duration
+
+	// Error is the error returned by the hook, if any
+	Error string
+
+	// State is the new state of the task, or client status of the
+	// allocation, for state events
+	State string
+}
+
+// AllocLifecycleBroadcaster sends the lifecycle events of an alloc runner to
+// all listeners. Sends never block: events are dropped for listeners that
+// fall behind. A nil broadcaster discards all events.
+type AllocLifecycleBroadcaster struct {
+	mu sync.Mutex
+
+	// listeners is a map of unique ids to listener chans
+	listeners map[int]chan *AllocLifecycleEvent
+
+	// nextId is the next id to assign in listener map
+	nextId int
+}
+
+// NewAllocLifecycleBroadcaster returns a new AllocLifecycleBroadcaster.
+func NewAllocLifecycleBroadcaster() *AllocLifecycleBroadcaster {
+	return &AllocLifecycleBroadcaster{
+		listeners: make(map[int]chan *AllocLifecycleEvent),
+	}
+}
+
+// Send broadcasts the event, stamping it with the current time.
+func (b *AllocLifecycleBroadcaster) Send(e *AllocLifecycleEvent) {
+	if b == nil {
+		return
+	}
+
+	e.Timestamp = time.Now().UnixNano()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, l := range b.listeners {
+		select {
+		case l <- e:
+		default:
+		}
+	}
+}
+
+// SendHook broadcasts that a hook ran in the given stage since start.
+func (b *AllocLifecycleBroadcaster) SendHook(task, stage, hook string, start time.Time, err error) {
+	if b == nil {
+		return
+	}
+
+	e := &AllocLifecycleEvent{
+		Task:     task,
+		Type:     LifecycleEventHook,
+		Stage:    stage,
+		Hook:     hook,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	b.Send(e)
+}
+
+// Listen returns a channel receiving the events sent from now on and a
+// function to stop listening, which closes the channel.
+func (b *AllocLifecycleBroadcaster) Listen() (<-chan *AllocLifecycleEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextId
+	b.nextId++
+	ch := make(chan *AllocLifecycleEvent, lifecycleListenerCap)
+	b.listeners[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.listeners, id)
+			close(ch)
+		})
+	}
+}
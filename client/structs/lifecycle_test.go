@@ -0,0 +1,51 @@
+package structs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocLifecycleBroadcaster(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	b := NewAllocLifecycleBroadcaster()
+
+	// Events sent before listening aren't received
+	b.Send(&AllocLifecycleEvent{Type: LifecycleEventState, State: "pending"})
+
+	events, stop := b.Listen()
+	b.SendHook("web", "prestart", "task_dir", time.Now(), fmt.Errorf("boom"))
+
+	select {
+	case e := <-events:
+		require.Equal(LifecycleEventHook, e.Type)
+		require.Equal("web", e.Task)
+		require.Equal("prestart", e.Stage)
+		require.Equal("task_dir", e.Hook)
+		require.Equal("boom", e.Error)
+		require.NotZero(e.Timestamp)
+	case <-time.After(time.Second):
+		t.Fatalf("no event received")
+	}
+
+	// Sends never block on a listener that falls behind
+	for i := 0; i < 2*lifecycleListenerCap; i++ {
+		b.Send(&AllocLifecycleEvent{Type: LifecycleEventState})
+	}
+	require.Len(events, lifecycleListenerCap)
+
+	// Stopping closes the channel and may be called more than once
+	stop()
+	stop()
+	for range events {
+	}
+
+	// A nil broadcaster discards events
+	var nilB *AllocLifecycleBroadcaster
+	nilB.Send(&AllocLifecycleEvent{})
+	nilB.SendHook("web", "prestart", "task_dir", time.Now(), nil)
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"time"
 
+	"github.com/hashicorp/nomad/client/lib/execaudit"
+	sframer "github.com/hashicorp/nomad/client/lib/streamframer"
 	"github.com/hashicorp/nomad/client/stats"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/plugins/device"
@@ -34,6 +36,111 @@ type ClientStatsResponse struct {
 	structs.QueryMeta
 }
 
+const (
+	// StatsFrameHost is the type of stats frames carrying host stats
+	StatsFrameHost = "host"
+
+	// StatsFrameAlloc is the type of stats frames carrying the stats of an
+	// allocation
+	StatsFrameAlloc = "alloc"
+)
+
+// ClientStatsStreamRequest is used to stream the stats of a node and of its
+// allocations.
+type ClientStatsStreamRequest struct {
+	// Interval is the interval at which stats are sent. It defaults to the
+	// client's stats collection interval.
+	Interval time.Duration
+
+	// AllocIDs optionally restricts the allocations whose stats are sent.
+	// The stats of all allocations are sent if it is empty.
+	AllocIDs []string
+
+	structs.QueryOptions
+}
+
+// ClientStreamsResponse is used to return the active streaming sessions of a
+// client.
+type ClientStreamsResponse struct {
+	Streams []*StreamSession
+	structs.QueryMeta
+}
+
+// StreamSession is an active streaming RPC session of a client
+type StreamSession struct {
+	// ID uniquely identifies the session
+	ID string
+
+	// Method is the streaming RPC method served, eg FileSystem.Logs
+	Method string
+
+	// AllocID and Task are the target of the stream, if any
+	AllocID string
+	Task    string
+
+	// AccessorID is the accessor of the token that opened the stream. It is
+	// empty if ACLs are disabled.
+	AccessorID string
+
+	// StartedAt is when the session started and Age how long ago
+	StartedAt time.Time
+	Age       time.Duration
+
+	// BytesRead and BytesWritten are the bytes received and sent
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// ClientExecAuditRequest is used to query the log of the commands executed
+// in the tasks of a client.
+type ClientExecAuditRequest struct {
+	// AllocID and Task optionally restrict the entries to the commands run
+	// in the allocation or task
+	AllocID string
+	Task    string
+
+	// Since optionally restricts the entries to the commands started at or
+	// after the time
+	Since time.Time
+
+	// Limit optionally restricts the entries to the given number of most
+	// recent ones
+	Limit int
+
+	structs.QueryOptions
+}
+
+// ClientExecAuditResponse is used to return the commands executed in the
+// tasks of a client, oldest first. The log is rotated by size so only the
+// most recent commands are kept.
+type ClientExecAuditResponse struct {
+	Entries []*execaudit.Entry
+	structs.QueryMeta
+}
+
+// ClientStatsFrame is a frame of a node stats stream. Depending on its Type
+// it carries either the host stats or the stats of a single allocation.
+type ClientStatsFrame struct {
+	// Type is the type of the frame
+	Type string
+
+	// HostStats is set for host frames
+	HostStats *stats.HostStats
+
+	// AllocID and AllocStats are set for allocation frames
+	AllocID    string
+	AllocStats *AllocResourceUsage
+
+	// Timestamp is the wall-clock time the frame's stats were collected at
+	// (UnixNano). Monotonic is the monotonic clock reading when they were
+	// collected, in nanoseconds since the client process started. Unlike
+	// Timestamp it does not
+	// jump when the clock is adjusted, so intervals between samples should
+	// be computed from it.
+	Timestamp int64
+	Monotonic int64
+}
+
 // AllocFileInfo holds information about a file inside the AllocDir
 type AllocFileInfo struct {
 	Name     string
@@ -105,6 +212,10 @@ type FsStreamRequest struct {
 	// Follow follows the file.
 	Follow bool
 
+	// Compress gzip compresses the data of large frames, which then have
+	// Compressed set. It is ignored when PlainText is set.
+	Compress bool
+
 	structs.QueryOptions
 }
 
@@ -132,9 +243,81 @@ type FsLogsRequest struct {
 	// Follow follows logs.
 	Follow bool
 
+	// AllowAfterExit makes following the logs of a task that already exited
+	// stream all the persisted logs and end with a close frame.
+	AllowAfterExit bool
+
+	// MaxLineLength truncates streamed lines longer than the given number of
+	// bytes, replacing the rest of the line with a marker. Zero disables it.
+	MaxLineLength int
+
+	// Delimiter is the byte separating the log records that MaxLineLength
+	// applies to. It defaults to a newline.
+	Delimiter *byte
+
+	// StartTime starts streaming at the first log file written at or after
+	// the given time, overriding Offset and Origin. Logs don't carry per line
+	// timestamps so the files' modification times are used, and the lines of
+	// that file written before the time are streamed too.
+	StartTime time.Time
+
+	// StripANSI removes ANSI escape sequences, such as color codes, from the
+	// streamed logs. The log files aren't modified.
+	StripANSI bool
+
+	// HeartbeatInterval is the interval at which followers are sent frames
+	// holding the size and modification time of the log file being written,
+	// so they can tell a quiet task from a stuck stream. Zero disables them.
+	HeartbeatInterval time.Duration
+
+	// Compress gzip compresses the data of large frames, which then have
+	// Compressed set. It is ignored when PlainText is set.
+	Compress bool
+
 	structs.QueryOptions
 }
 
+// FsAllocLogsRequest is used to stream the logs of several tasks of an
+// allocation at once.
+type FsAllocLogsRequest struct {
+	// AllocID is the allocation to stream logs from
+	AllocID string
+
+	// Tasks are the tasks to stream logs from. All the started tasks of the
+	// allocation are streamed if empty.
+	Tasks []string
+
+	// LogTypes are the streams, "stdout" and "stderr", to stream. Both are
+	// streamed if empty.
+	LogTypes []string
+
+	// Offset is the offset to start streaming each log at.
+	Offset int64
+
+	// Origin can either be "start" or "end" and determines where the offset is
+	// applied.
+	Origin string
+
+	// Follow follows logs.
+	Follow bool
+
+	// Compress gzip compresses the data of large frames, which then have
+	// Compressed set.
+	Compress bool
+
+	structs.QueryOptions
+}
+
+// AllocLogFrame is a frame of the logs of one of the tasks of an allocation.
+// Frames with neither a task nor a log type are heartbeats.
+type AllocLogFrame struct {
+	// Task and LogType are the log the frame was read from
+	Task    string `json:",omitempty"`
+	LogType string `json:",omitempty"`
+
+	*sframer.StreamFrame
+}
+
 // StreamErrWrapper is used to serialize output of a stream of a file or logs.
 type StreamErrWrapper struct {
 	// Error stores any error that may have occurred.
@@ -153,6 +336,13 @@ type AllocStatsRequest struct {
 	// Task is an optional filter to only request stats for the task.
 	Task string
 
+	// Cumulative requests the totals consumed by each task since it started
+	Cumulative bool
+
+	// Interval is the interval at which stats are sent when streaming them.
+	// It defaults to one second and is raised to 100ms if lower.
+	Interval time.Duration
+
 	structs.QueryOptions
 }
 
@@ -163,6 +353,786 @@ type AllocStatsResponse struct {
 	structs.QueryMeta
 }
 
+// AllocCgroupConfigRequest is used to request the cgroup configuration the
+// client applied to the tasks of an allocation, potentially filtering by task
+type AllocCgroupConfigRequest struct {
+	// AllocID is the allocation to retrieve the cgroup configuration for
+	AllocID string
+
+	// Task is an optional filter to only request the configuration of the
+	// task.
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocCgroupConfigResponse is used to return the cgroup configuration of the
+// running tasks of an allocation.
+type AllocCgroupConfigResponse struct {
+	// Tasks maps task names to their cgroup configuration
+	Tasks map[string]*TaskCgroupConfig
+	structs.QueryMeta
+}
+
+// TaskCgroupConfig is the effective cgroup configuration of a task's main
+// process.
+type TaskCgroupConfig struct {
+	// Version is the cgroup version (1 or 2) the limits were read from
+	Version int
+
+	// Paths maps each controller to the task's cgroup directory on the host.
+	// On the unified hierarchy the controller is empty.
+	Paths map[string]string
+
+	// Limits maps the limit files (eg memory.max, cpu.max, pids.max) to their
+	// raw values.
+	Limits map[string]string
+}
+
+// AllocUlimitsRequest is used to request the resource limits applied to the
+// tasks of an allocation, potentially filtering by task
+type AllocUlimitsRequest struct {
+	// AllocID is the allocation to retrieve the resource limits for
+	AllocID string
+
+	// Task is an optional filter to only request the limits of the task.
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocUlimitsResponse is used to return the resource limits of the running
+// tasks of an allocation.
+type AllocUlimitsResponse struct {
+	// Tasks maps task names to their resource limits keyed by ulimit name,
+	// eg nofile or nproc.
+	Tasks map[string]map[string]*TaskUlimit
+	structs.QueryMeta
+}
+
+// TaskUlimit is a resource limit applied to a task's main process.
+type TaskUlimit struct {
+	// Soft and Hard are the soft and hard limits. They are either a number
+	// or "unlimited".
+	Soft string
+	Hard string
+
+	// Units is the unit of the limit, eg files or bytes
+	Units string
+}
+
+// AllocStatsDebugRequest is used to force a stats collection of the tasks of
+// an allocation, potentially filtering by task
+type AllocStatsDebugRequest struct {
+	// AllocID is the allocation to collect stats for
+	AllocID string
+
+	// Task is an optional filter to only collect the stats of the task.
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocStatsDebugResponse is used to return the forced stats collection of
+// the running tasks of an allocation.
+type AllocStatsDebugResponse struct {
+	// Tasks maps task names to their stats collection
+	Tasks map[string]*TaskStatsDebug
+	structs.QueryMeta
+}
+
+// TaskStatsDebug is the result of a forced stats collection of a task.
+type TaskStatsDebug struct {
+	// Sample is the collected sample. It is nil if the driver didn't return
+	// one.
+	Sample *TaskResourceUsage
+
+	// Stages is the time spent in each stage of the collection
+	Stages []*StatsStageTiming
+
+	// Errors are the errors encountered during the collection that are
+	// otherwise only logged or ignored.
+	Errors []string
+}
+
+// StatsStageTiming is the time spent in a stage of a stats collection.
+type StatsStageTiming struct {
+	// Name is the stage: driver, cgroup or aggregation
+	Name string
+
+	// Duration is the time spent in the stage
+	Duration time.Duration
+}
+
+// AllocStatsDiffRequest is used to request the change in the stats of the
+// tasks of an allocation over a window, potentially filtering by task
+type AllocStatsDiffRequest struct {
+	// AllocID is the allocation to diff the stats of
+	AllocID string
+
+	// Task is an optional filter to only diff the stats of the task.
+	Task string
+
+	// Window is the time to wait between the two samples. It defaults to one
+	// second if unset.
+	Window time.Duration
+
+	structs.QueryOptions
+}
+
+// AllocStatsDiffResponse is used to return the change in the stats of the
+// running tasks of an allocation.
+type AllocStatsDiffResponse struct {
+	// Tasks maps task names to the change in their stats
+	Tasks map[string]*TaskStatsDiff
+	structs.QueryMeta
+}
+
+// TaskStatsDiff is the change in the stats of a task between two samples.
+// Rates are per second.
+type TaskStatsDiff struct {
+	// Window is the time elapsed between the two samples
+	Window time.Duration
+
+	CpuTotalTicks        float64
+	CpuTotalTicksRate    float64
+	CpuThrottledPeriods  uint64
+	CpuThrottledTime     uint64
+	CpuThrottledTimeRate float64
+
+	MemoryRSS     int64
+	MemoryRSSRate float64
+	MemoryCache   int64
+	MemorySwap    int64
+}
+
+// NewTaskStatsDiff returns the change in stats from the first to the second
+// sample. Counters that went backwards, eg because the task restarted, are
+// reported as unchanged.
+func NewTaskStatsDiff(first, second *TaskResourceUsage) *TaskStatsDiff {
+	diff := &TaskStatsDiff{
+		Window: time.Duration(second.Timestamp - first.Timestamp),
+	}
+
+	rate := func(delta float64) float64 {
+		if diff.Window <= 0 {
+			return 0
+		}
+		return delta / diff.Window.Seconds()
+	}
+	counter := func(a, b uint64) uint64 {
+		if b < a {
+			return 0
+		}
+		return b - a
+	}
+
+	if c1, c2 := first.ResourceUsage.CpuStats, second.ResourceUsage.CpuStats; c1 != nil && c2 != nil {
+		if c2.TotalTicks > c1.TotalTicks {
+			diff.CpuTotalTicks = c2.TotalTicks - c1.TotalTicks
+		}
+		diff.CpuTotalTicksRate = rate(diff.CpuTotalTicks)
+		diff.CpuThrottledPeriods = counter(c1.ThrottledPeriods, c2.ThrottledPeriods)
+		diff.CpuThrottledTime = counter(c1.ThrottledTime, c2.ThrottledTime)
+		diff.CpuThrottledTimeRate = rate(float64(diff.CpuThrottledTime))
+	}
+
+	if m1, m2 := first.ResourceUsage.MemoryStats, second.ResourceUsage.MemoryStats; m1 != nil && m2 != nil {
+		diff.MemoryRSS = int64(m2.RSS) - int64(m1.RSS)
+		diff.MemoryRSSRate = rate(float64(diff.MemoryRSS))
+		diff.MemoryCache = int64(m2.Cache) - int64(m1.Cache)
+		diff.MemorySwap = int64(m2.Swap) - int64(m1.Swap)
+	}
+
+	return diff
+}
+
+// AllocSetLogRotationRequest is used to update the log rotation settings of
+// a running task
+type AllocSetLogRotationRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to update the log rotation of
+	Task string
+
+	// MaxFiles is the max number of rotated files to retain
+	MaxFiles int
+
+	// MaxFileSizeMB is the size in MB at which log files are rotated
+	MaxFileSizeMB int
+
+	structs.QueryOptions
+}
+
+// AllocRotateLogsRequest is used to force the rotation of a running task's log
+type AllocRotateLogsRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to rotate the log of
+	Task string
+
+	// LogType indicates whether the "stderr" or "stdout" log is rotated
+	LogType string
+
+	structs.QueryOptions
+}
+
+// AllocRotateLogsResponse is used to return the path of a rotated log file.
+type AllocRotateLogsResponse struct {
+	// Path is the path of the rotated file relative to the allocation
+	// directory. It is empty if the log was empty and nothing was rotated.
+	Path string
+
+	structs.QueryMeta
+}
+
+// AllocRenderedTemplateRequest is used to read the rendered content of a
+// task's template
+type AllocRenderedTemplateRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task that rendered the template
+	Task string
+
+	// Path is the destination of the template as specified in the job
+	Path string
+
+	structs.QueryOptions
+}
+
+// AllocRenderedTemplateResponse is used to return the rendered content of a
+// task's template.
+type AllocRenderedTemplateResponse struct {
+	// Content is the rendered content of the template. It is empty if the
+	// content was redacted.
+	Content []byte
+
+	// Redacted is true if the template reads Vault secrets and the request
+	// was not made with a management token.
+	Redacted bool
+
+	structs.QueryMeta
+}
+
+// AllocChecksRequest is the initial request for streaming the status of the
+// checks registered for an allocation's services.
+type AllocChecksRequest struct {
+	// AllocID is the allocation to stream check results for
+	AllocID string
+
+	structs.QueryOptions
+}
+
+// CheckStatus is the status of a service check. It is streamed when a check
+// is first seen and whenever its status changes.
+type CheckStatus struct {
+	// Task is the task that registered the service
+	Task string
+
+	// ServiceID and ServiceName identify the service the check belongs to
+	ServiceID   string
+	ServiceName string
+
+	// CheckID and CheckName identify the check
+	CheckID   string
+	CheckName string
+
+	// Status is the Consul status of the check (passing, warning, critical)
+	Status string
+
+	// Output is the output of the last check run
+	Output string
+
+	// Timestamp is when the status was observed (UnixNano)
+	Timestamp int64
+}
+
+// AllocProfileRequest is the initial request for profiling a running task.
+// The collapsed stacks are streamed back once the profile completes.
+type AllocProfileRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to profile
+	Task string
+
+	// Type is the type of profile, either "cpu" or "off-cpu"
+	Type string
+
+	// Duration is how long the task is sampled for
+	Duration time.Duration
+
+	structs.QueryOptions
+}
+
+// AllocFDsRequest is used to list or stream the open file descriptors of a
+// task's main process
+type AllocFDsRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to inspect
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocFDsResponse is used to return the open file descriptors of a task's
+// main process.
+type AllocFDsResponse struct {
+	FDs []*FileDescriptor
+	structs.QueryMeta
+}
+
+// AllocDropCachesRequest is used to reclaim the page cache of a task's cgroup
+type AllocDropCachesRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task whose page cache is reclaimed
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocDropCachesResponse is used to return how much memory was reclaimed
+type AllocDropCachesResponse struct {
+	// Reclaimed is the number of bytes the memory usage of the task's cgroup
+	// dropped by
+	Reclaimed uint64
+
+	structs.QueryMeta
+}
+
+// AllocSignalRequest is used to send a signal to the tasks of an allocation
+type AllocSignalRequest struct {
+	// AllocID is the allocation to signal
+	AllocID string
+
+	// Task is an optional task to signal. If empty every running task of
+	// the allocation is signaled.
+	Task string
+
+	// Signal is the name of the signal to send, eg SIGHUP
+	Signal string
+
+	structs.QueryOptions
+}
+
+// AllocTaskDiagnoseRequest is used to explain why a task is not running
+type AllocTaskDiagnoseRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to diagnose
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocTaskDiagnoseResponse is used to return the diagnosis of a task
+type AllocTaskDiagnoseResponse struct {
+	Diagnosis *TaskDiagnosis
+
+	structs.QueryMeta
+}
+
+const (
+	// TaskDiagnosisRunning, TaskDiagnosisPending,
+	// TaskDiagnosisDownloadingArtifacts, TaskDiagnosisPrestartHook,
+	// TaskDiagnosisRestarting, TaskDiagnosisFailed, TaskDiagnosisOOMKilled,
+	// TaskDiagnosisKilled and TaskDiagnosisCompleted are the reasons a task
+	// may be in its current state
+	TaskDiagnosisRunning              = "running"
+	TaskDiagnosisPending              = "pending"
+	TaskDiagnosisDownloadingArtifacts = "downloading-artifacts"
+	TaskDiagnosisPrestartHook         = "prestart-hook"
+	TaskDiagnosisRestarting           = "restarting"
+	TaskDiagnosisFailed               = "failed"
+	TaskDiagnosisOOMKilled            = "oom-killed"
+	TaskDiagnosisKilled               = "killed"
+	TaskDiagnosisCompleted            = "completed"
+)
+
+// TaskDiagnosis explains the state of a task from its events and the state of
+// its hooks.
+type TaskDiagnosis struct {
+	// State is the state of the task
+	State string
+
+	// Reason is one of the TaskDiagnosis constants
+	Reason string
+
+	// Explanation is a human readable explanation of the state
+	Explanation string
+
+	// Hook is the prestart hook the task is waiting on, if any
+	Hook string
+
+	// Artifacts is the progress of the artifact downloads when the task is
+	// downloading them
+	Artifacts *TaskArtifactProgress
+
+	// Events are the recent events of the task backing the explanation,
+	// oldest first
+	Events []*structs.TaskEvent
+}
+
+// AllocExecOnceRequest is used to run a command in a task and capture its
+// output
+type AllocExecOnceRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to run the command in
+	Task string
+
+	// Cmd is the command to run followed by its arguments
+	Cmd []string
+
+	// Timeout is the maximum runtime of the command. It defaults to 30
+	// seconds if unset.
+	Timeout time.Duration
+
+	// MaxOutputBytes is the maximum number of bytes of each of stdout and
+	// stderr returned. It defaults to 64KiB if unset.
+	MaxOutputBytes int
+
+	structs.QueryOptions
+}
+
+// AllocExecOnceResponse is used to return the output and exit code of a
+// command run in a task
+type AllocExecOnceResponse struct {
+	// Stdout and Stderr are the captured output of the command. A driver may
+	// capture both streams to Stdout.
+	Stdout []byte
+	Stderr []byte
+
+	// StdoutTruncated and StderrTruncated are set if the output exceeded the
+	// requested size and was truncated
+	StdoutTruncated bool
+	StderrTruncated bool
+
+	// ExitCode and Signal are the exit code of the command and the signal
+	// that terminated it, if any
+	ExitCode int
+	Signal   int
+
+	// Duration is how long the command ran for
+	Duration time.Duration
+
+	structs.QueryMeta
+}
+
+// AllocArtifactProgressRequest is used to stream the progress of the artifact
+// downloads of a task.
+type AllocArtifactProgressRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task downloading artifacts
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocCancelArtifactRequest is used to abort the artifact download of a
+// starting task
+type AllocCancelArtifactRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task downloading artifacts
+	Task string
+
+	structs.QueryOptions
+}
+
+// TaskArtifactProgress is the progress of the artifact downloads of a task
+type TaskArtifactProgress struct {
+	// Artifact is the source of the artifact being downloaded
+	Artifact string
+
+	// Index is the index of the artifact being downloaded among the Count
+	// artifacts of the task
+	Index int
+	Count int
+
+	// Downloaded is the number of bytes of the artifact downloaded so far and
+	// Total its size, which is -1 when unknown. Bytes are only reported for
+	// HTTP downloads.
+	Downloaded int64
+	Total      int64
+
+	// BytesPerSecond is the average download speed of the artifact
+	BytesPerSecond float64
+
+	// Done is set once the artifacts are downloaded or a download failed, in
+	// which case Error is set.
+	Done  bool
+	Error string
+}
+
+// FileDescriptor is an open file descriptor of a process
+type FileDescriptor struct {
+	// FD is the file descriptor number
+	FD int
+
+	// Path is the target of the descriptor. Sockets and pipes are reported
+	// with their inode, eg socket:[1234].
+	Path string
+
+	// Type is one of file, socket, pipe or anon_inode
+	Type string
+
+	// Flags are the names of the flags the file was opened with
+	Flags []string
+}
+
+// AllocMountsRequest is used to list the mounts of a task's mount namespace
+type AllocMountsRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to inspect
+	Task string
+
+	structs.QueryOptions
+}
+
+// AllocMountsResponse is used to return the mounts of a task's mount
+// namespace.
+type AllocMountsResponse struct {
+	Mounts []*MountEntry
+	structs.QueryMeta
+}
+
+// MountEntry is a mount as seen by a task's main process
+type MountEntry struct {
+	// Source is the device or remote filesystem that is mounted
+	Source string
+
+	// Target is the mount point within the task
+	Target string
+
+	// Root is the directory of the source filesystem mounted at Target
+	Root string
+
+	// FSType is the type of the filesystem
+	FSType string
+
+	// Options are the mount options
+	Options []string
+
+	// SuperOptions are the filesystem specific options
+	SuperOptions []string
+}
+
+// AllocProcSnapshotRequest is used to snapshot the procfs metrics of a
+// task's processes
+type AllocProcSnapshotRequest struct {
+	// AllocID is the allocation the task belongs to
+	AllocID string
+
+	// Task is the task to inspect
+	Task string
+
+	// AllPIDs includes the descendants of the task's main process
+	AllPIDs bool
+
+	structs.QueryOptions
+}
+
+// AllocProcSnapshotResponse is used to return the procfs metrics of a task's
+// processes.
+type AllocProcSnapshotResponse struct {
+	// Processes starts with the task's main process followed by its
+	// descendants if requested. Descendants that exited while being read are
+	// omitted.
+	Processes []*ProcSnapshot
+	structs.QueryMeta
+}
+
+// ProcSnapshot is the state of a process read from /proc/<pid>/status, stat,
+// io and limits. Memory sizes are in bytes and times in clock ticks.
+type ProcSnapshot struct {
+	PID     int
+	PPid    int
+	Name    string
+	State   string
+	Threads int
+
+	VmPeak uint64
+	VmSize uint64
+	VmHWM  uint64
+	VmRSS  uint64
+	VmSwap uint64
+
+	VoluntaryCtxtSwitches    uint64
+	NonvoluntaryCtxtSwitches uint64
+
+	MinorFaults uint64
+	MajorFaults uint64
+	UserTime    uint64
+	SystemTime  uint64
+	StartTime   uint64
+
+	// IO is nil if the client isn't allowed to read the IO counters
+	IO *ProcIO
+
+	Limits []*ProcLimit
+}
+
+// ProcIO holds the IO counters of a process
+type ProcIO struct {
+	ReadChars           uint64
+	WriteChars          uint64
+	ReadSyscalls        uint64
+	WriteSyscalls       uint64
+	ReadBytes           uint64
+	WriteBytes          uint64
+	CancelledWriteBytes uint64
+}
+
+// ProcLimit is a resource limit of a process. Soft and Hard are either a
+// number or "unlimited".
+type ProcLimit struct {
+	Name  string
+	Soft  string
+	Hard  string
+	Units string
+}
+
+// AllocGCProgress is streamed while an allocation is garbage collected
+type AllocGCProgress struct {
+	// BytesFreed is the size of the files of the alloc dir removed so far
+	BytesFreed int64
+
+	// Done is true once the allocation has been garbage collected
+	Done bool
+}
+
+const (
+	// AllocGCResultCollected is the result of an allocation that was garbage
+	// collected
+	AllocGCResultCollected = "collected"
+
+	// AllocGCResultSkipped is the result of an allocation that is not eligible
+	// for garbage collection, either because it is still running or because
+	// it was already garbage collected
+	AllocGCResultSkipped = "skipped"
+
+	// AllocGCResultError is the result of an allocation that could not be
+	// garbage collected
+	AllocGCResultError = "error"
+)
+
+// AllocGarbageCollectRequest is used to garbage collect an allocation or one
+// of its tasks on a client
+type AllocGarbageCollectRequest struct {
+	// AllocID is the allocation to garbage collect
+	AllocID string
+
+	// Task is an optional dead task to garbage collect instead of the whole
+	// allocation. Only its task dir, log files and local state are removed.
+	Task string
+
+	// DryRun reports what would be reclaimed without collecting anything
+	DryRun bool
+
+	structs.QueryOptions
+}
+
+// AllocGarbageCollectResponse is used to return what was, or would be,
+// reclaimed by garbage collecting an allocation or a task.
+type AllocGarbageCollectResponse struct {
+	// Terminal is true if the allocation is marked for collection or, when a
+	// task is given, if the task is dead. It is only set for dry runs and
+	// task collections.
+	Terminal bool
+
+	// Reclaimed is the number of bytes freed, or that would be freed on a
+	// dry run. It is not computed when collecting a whole allocation.
+	Reclaimed int64
+
+	structs.WriteMeta
+}
+
+// AllocGarbageCollectManyRequest is used to garbage collect multiple
+// allocations on a client
+type AllocGarbageCollectManyRequest struct {
+	// AllocIDs are the allocations to garbage collect
+	AllocIDs []string
+
+	structs.QueryOptions
+}
+
+// AllocGarbageCollectManyResponse is used to return the result of garbage
+// collecting each requested allocation
+type AllocGarbageCollectManyResponse struct {
+	// Results maps alloc IDs to the result of garbage collecting them
+	Results map[string]*AllocGCResult
+	structs.QueryMeta
+}
+
+// AllocWouldGCRequest is used to evaluate which allocations would be garbage
+// collected under hypothetical thresholds. Unset thresholds default to the
+// client's configuration.
+type AllocWouldGCRequest struct {
+	DiskUsageThreshold  *float64
+	InodeUsageThreshold *float64
+	MaxAllocs           *int
+	MinAllocRetention   *time.Duration
+
+	structs.QueryOptions
+}
+
+// AllocWouldGCResponse is used to return whether each allocation of the
+// client would be garbage collected.
+type AllocWouldGCResponse struct {
+	// Allocs maps alloc IDs to whether they would be garbage collected
+	Allocs map[string]*AllocWouldGCResult
+	structs.QueryMeta
+}
+
+// AllocWouldGCResult is whether an allocation would be garbage collected
+type AllocWouldGCResult struct {
+	// Eligible is true if the allocation is terminal and marked for
+	// collection
+	Eligible bool
+
+	// Collected is true if the allocation would be collected
+	Collected bool
+
+	// Reason is why the allocation would be collected
+	Reason string
+}
+
+// AllocGCResult is the result of garbage collecting a single allocation
+type AllocGCResult struct {
+	// Result is one of collected, skipped or error
+	Result string
+
+	// Error is set if the allocation could not be garbage collected
+	Error string
+}
+
+// FDEvent is streamed when a file descriptor is opened or closed
+type FDEvent struct {
+	// Op is either "open" or "close"
+	Op string
+
+	// FD is the file descriptor that was opened or closed
+	FD *FileDescriptor
+
+	// Timestamp is when the change was observed (UnixNano)
+	Timestamp int64
+}
+
 // MemoryStats holds memory usage related stats
 type MemoryStats struct {
 	RSS            uint64
@@ -173,10 +1143,72 @@ type MemoryStats struct {
 	KernelUsage    uint64
 	KernelMaxUsage uint64
 
+	// RawUsage is the usage reported by the driver before the client's
+	// memory usage semantics were applied to Usage.
+	RawUsage uint64
+
 	// A list of fields whose values were actually sampled
 	Measured []string
 }
 
+const (
+	// MemoryUsageRaw reports the usage as measured by the driver
+	MemoryUsageRaw = "raw"
+
+	// MemoryUsageCacheExcluded reports the usage minus the page cache
+	MemoryUsageCacheExcluded = "cache-excluded"
+
+	// MemoryUsageWorkingSet reports the memory that can't be reclaimed
+	// without swapping: the RSS plus the kernel memory.
+	MemoryUsageWorkingSet = "working-set"
+)
+
+// ValidMemoryUsageSemantics returns whether the memory usage semantics are
+// known. Empty semantics default to raw.
+func ValidMemoryUsageSemantics(semantics string) bool {
+	switch semantics {
+	case "", MemoryUsageRaw, MemoryUsageCacheExcluded, MemoryUsageWorkingSet:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetUsageSemantics stores the measured usage in RawUsage and sets Usage to
+// the metric chosen by the semantics. Usage is left as measured if the fields
+// the metric is derived from were not sampled.
+func (ms *MemoryStats) SetUsageSemantics(semantics string) {
+	ms.RawUsage = ms.Usage
+
+	measured := make(map[string]struct{}, len(ms.Measured))
+	for _, m := range ms.Measured {
+		measured[m] = struct{}{}
+	}
+	if _, ok := measured["Usage"]; !ok {
+		return
+	}
+
+	switch semantics {
+	case MemoryUsageCacheExcluded:
+		if _, ok := measured["Cache"]; !ok {
+			return
+		}
+		if ms.Cache < ms.Usage {
+			ms.Usage -= ms.Cache
+		} else {
+			ms.Usage = 0
+		}
+	case MemoryUsageWorkingSet:
+		if _, ok := measured["RSS"]; !ok {
+			return
+		}
+		ms.Usage = ms.RSS
+		if _, ok := measured["Kernel Usage"]; ok {
+			ms.Usage += ms.KernelUsage
+		}
+	}
+}
+
 func (ms *MemoryStats) Add(other *MemoryStats) {
 	if other == nil {
 		return
@@ -189,6 +1221,7 @@ func (ms *MemoryStats) Add(other *MemoryStats) {
 	ms.MaxUsage += other.MaxUsage
 	ms.KernelUsage += other.KernelUsage
 	ms.KernelMaxUsage += other.KernelMaxUsage
+	ms.RawUsage += other.RawUsage
 	ms.Measured = joinStringSet(ms.Measured, other.Measured)
 }
 
@@ -201,6 +1234,18 @@ type CpuStats struct {
 	ThrottledTime    uint64
 	Percent          float64
 
+	// RawPercent is the percent of a single core used, as reported by the
+	// driver before the client's CPU accounting was applied to Percent.
+	RawPercent float64
+
+	// WaitTime is the total time the task's main process was runnable but
+	// waiting for a CPU, in nanoseconds.
+	WaitTime uint64
+
+	// StealTime is the total time the hypervisor ran other guests while the
+	// host's CPUs were waiting, in nanoseconds. It is host wide.
+	StealTime uint64
+
 	// A list of fields whose values were actually sampled
 	Measured []string
 }
@@ -216,9 +1261,54 @@ func (cs *CpuStats) Add(other *CpuStats) {
 	cs.ThrottledPeriods += other.ThrottledPeriods
 	cs.ThrottledTime += other.ThrottledTime
 	cs.Percent += other.Percent
+	cs.RawPercent += other.RawPercent
+	cs.WaitTime += other.WaitTime
+	if other.StealTime > cs.StealTime {
+		// Steal time is host wide and must not be summed
+		cs.StealTime = other.StealTime
+	}
 	cs.Measured = joinStringSet(cs.Measured, other.Measured)
 }
 
+const (
+	// CpuAccountingCorePercent reports the percent of a single core used, so
+	// a task using two cores reports 200.
+	CpuAccountingCorePercent = "core-percent"
+
+	// CpuAccountingHostPercent reports the percent of all the host's cores
+	// used, so it never exceeds 100.
+	CpuAccountingHostPercent = "host-percent"
+
+	// CpuAccountingCores reports the number of cores used
+	CpuAccountingCores = "cores"
+)
+
+// ValidCpuAccounting returns whether the CPU accounting is known. Empty
+// accounting defaults to core-percent.
+func ValidCpuAccounting(accounting string) bool {
+	switch accounting {
+	case "", CpuAccountingCorePercent, CpuAccountingHostPercent, CpuAccountingCores:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetAccounting stores the measured percent in RawPercent and converts
+// Percent to the accounting given the number of cores of the host.
+func (cs *CpuStats) SetAccounting(accounting string, cores int) {
+	cs.RawPercent = cs.Percent
+
+	switch accounting {
+	case CpuAccountingHostPercent:
+		if cores > 0 {
+			cs.Percent /= float64(cores)
+		}
+	case CpuAccountingCores:
+		cs.Percent /= 100
+	}
+}
+
 // ResourceUsage holds information related to cpu and memory stats
 type ResourceUsage struct {
 	MemoryStats *MemoryStats
@@ -238,6 +1328,82 @@ type TaskResourceUsage struct {
 	ResourceUsage *ResourceUsage
 	Timestamp     int64 // UnixNano
 	Pids          map[string]*ResourceUsage
+
+	// Monotonic is the monotonic clock reading when the client collected
+	// the sample, in nanoseconds since the client process started
+	Monotonic int64
+
+	// CounterEpoch is incremented each time the task is restarted, which
+	// resets cumulative counters such as CPU ticks. Consumers computing rates
+	// must reset their baseline when it changes.
+	CounterEpoch uint64
+
+	// MemoryReserved is the memory reserved for the task in bytes
+	MemoryReserved uint64
+
+	// MemoryMaxLimit is the hard memory limit applied to the task's cgroup
+	// in bytes. It is zero if no limit could be read.
+	MemoryMaxLimit uint64
+
+	// DriverStatsUnavailable is set when the driver didn't return stats in
+	// time. The memory and CPU stats are then the ones of the last sample the
+	// driver returned, or unset if there is none.
+	DriverStatsUnavailable bool
+
+	// Cumulative are the totals consumed by the task since it started. They
+	// are only returned when requested.
+	Cumulative *TaskCumulativeStats
+
+	// ConnectionStats are the TCP sockets of the task's network namespace.
+	// They are only set for tasks with a network namespace of their own
+	// when the client collects connection stats.
+	ConnectionStats *ConnectionStats
+}
+
+// ConnectionStats are the counts of the TCP sockets of a network namespace by
+// state, eg ESTABLISHED or TIME_WAIT.
+type ConnectionStats struct {
+	States map[string]uint64
+
+	// Truncated is set when the namespace held more sockets than were
+	// counted
+	Truncated bool
+}
+
+// TaskCumulativeStats are the totals consumed by a task since it first
+// started, including the usage of the runs before each restart.
+type TaskCumulativeStats struct {
+	// CpuTime is the CPU time consumed in nanoseconds
+	CpuTime uint64
+
+	// BytesWritten is the number of bytes written to block devices
+	BytesWritten uint64
+
+	// A list of fields whose values were actually sampled
+	Measured []string
+}
+
+// CumulativeCounter totals a counter that resets to zero, such as the cgroup
+// counters of a task which start over on each restart.
+type CumulativeCounter struct {
+	// base is the total of the previous epochs
+	base uint64
+
+	// last is the last value of the current epoch
+	last  uint64
+	epoch uint64
+}
+
+// Update records the value of the counter in the given epoch and returns the
+// total across all epochs. A value lower than the previous one is treated as
+// a reset within the epoch.
+func (c *CumulativeCounter) Update(epoch, value uint64) uint64 {
+	if epoch != c.epoch || value < c.last {
+		c.base += c.last
+		c.epoch = epoch
+	}
+	c.last = value
+	return c.base + c.last
 }
 
 // AllocResourceUsage holds the aggregated task resource usage of the
@@ -249,8 +1415,22 @@ type AllocResourceUsage struct {
 	// Tasks contains the resource usage of each task
 	Tasks map[string]*TaskResourceUsage
 
-	// The max timestamp of all the Tasks
+	// The max timestamp and monotonic reading of all the Tasks
 	Timestamp int64
+	Monotonic int64
+
+	// CounterEpoch is the sum of the tasks' CounterEpoch. It changes whenever
+	// any task of the allocation restarts.
+	CounterEpoch uint64
+
+	// MemoryReserved and MemoryMaxLimit are the sums of the tasks' memory
+	// reservation and hard limit in bytes.
+	MemoryReserved uint64
+	MemoryMaxLimit uint64
+
+	// DriverStatsUnavailable is set if the stats of any task are missing
+	// the driver's stats.
+	DriverStatsUnavailable bool
 }
 
 // joinStringSet takes two slices of strings and joins them
@@ -305,3 +1485,7 @@ const CheckBufSize = 4 * 1024
 // DriverStatsNotImplemented is the error to be returned if a driver doesn't
 // implement stats.
 var DriverStatsNotImplemented = errors.New("stats not implemented for driver")
+
+// StatsDisabledErrPrefix prefixes the error returned when the stats of an
+// allocation are requested but aren't collected for its namespace.
+const StatsDisabledErrPrefix = "stats collection is disabled for namespace"
@@ -0,0 +1,130 @@
+package structs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStats_SetUsageSemantics(t *testing.T) {
+	t.Parallel()
+
+	measured := []string{"RSS", "Cache", "Usage", "Kernel Usage"}
+	cases := []struct {
+		Semantics string
+		Measured  []string
+		Expected  uint64
+	}{
+		{"", measured, 1000},
+		{MemoryUsageRaw, measured, 1000},
+		{MemoryUsageCacheExcluded, measured, 700},
+		{MemoryUsageWorkingSet, measured, 650},
+		{MemoryUsageCacheExcluded, []string{"RSS", "Usage"}, 1000},
+		{MemoryUsageWorkingSet, []string{"RSS", "Usage"}, 600},
+		{MemoryUsageWorkingSet, []string{"RSS"}, 1000},
+	}
+
+	for _, c := range cases {
+		ms := &MemoryStats{
+			RSS:         600,
+			Cache:       300,
+			Usage:       1000,
+			KernelUsage: 50,
+			Measured:    c.Measured,
+		}
+		ms.SetUsageSemantics(c.Semantics)
+		require.Equal(t, c.Expected, ms.Usage, "semantics %q measuring %v", c.Semantics, c.Measured)
+		require.EqualValues(t, 1000, ms.RawUsage)
+	}
+
+	require.True(t, ValidMemoryUsageSemantics(""))
+	require.True(t, ValidMemoryUsageSemantics(MemoryUsageWorkingSet))
+	require.False(t, ValidMemoryUsageSemantics("rss"))
+}
+
+func TestCpuStats_SetAccounting(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		Accounting string
+		Expected   float64
+	}{
+		{"", 200},
+		{CpuAccountingCorePercent, 200},
+		{CpuAccountingHostPercent, 50},
+		{CpuAccountingCores, 2},
+	}
+
+	for _, c := range cases {
+		cs := &CpuStats{Percent: 200}
+		cs.SetAccounting(c.Accounting, 4)
+		require.Equal(t, c.Expected, cs.Percent, "accounting %q", c.Accounting)
+		require.EqualValues(t, 200, cs.RawPercent)
+	}
+
+	require.True(t, ValidCpuAccounting(""))
+	require.True(t, ValidCpuAccounting(CpuAccountingCores))
+	require.False(t, ValidCpuAccounting("percent"))
+}
+
+func TestNewTaskStatsDiff(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	now := time.Now()
+	first := &TaskResourceUsage{
+		ResourceUsage: &ResourceUsage{
+			CpuStats:    &CpuStats{TotalTicks: 1000, ThrottledTime: 500},
+			MemoryStats: &MemoryStats{RSS: 4096, Cache: 1024},
+		},
+		Timestamp: now.UnixNano(),
+	}
+	second := &TaskResourceUsage{
+		ResourceUsage: &ResourceUsage{
+			CpuStats:    &CpuStats{TotalTicks: 3000, ThrottledTime: 100},
+			MemoryStats: &MemoryStats{RSS: 2048, Cache: 2048},
+		},
+		Timestamp: now.Add(2 * time.Second).UnixNano(),
+	}
+
+	diff := NewTaskStatsDiff(first, second)
+	require.Equal(2*time.Second, diff.Window)
+	require.Equal(2000.0, diff.CpuTotalTicks)
+	require.Equal(1000.0, diff.CpuTotalTicksRate)
+
+	// Counters that went backwards are unchanged
+	require.Zero(diff.CpuThrottledTime)
+
+	require.Equal(int64(-2048), diff.MemoryRSS)
+	require.Equal(-1024.0, diff.MemoryRSSRate)
+	require.Equal(int64(1024), diff.MemoryCache)
+}
+
+func TestCpuStats_Add_WaitStats(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cs := &CpuStats{WaitTime: 100, StealTime: 1000}
+	cs.Add(&CpuStats{WaitTime: 50, StealTime: 1200})
+
+	// Wait time is per task while steal time is host wide
+	require.Equal(uint64(150), cs.WaitTime)
+	require.Equal(uint64(1200), cs.StealTime)
+}
+
+func TestCumulativeCounter_Update(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	var c CumulativeCounter
+	require.Equal(uint64(10), c.Update(0, 10))
+	require.Equal(uint64(25), c.Update(0, 25))
+
+	// A restart starts the counter over
+	require.Equal(uint64(30), c.Update(1, 5))
+	require.Equal(uint64(35), c.Update(1, 10))
+
+	// A reset within an epoch keeps the previous total
+	require.Equal(uint64(37), c.Update(1, 2))
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/hashicorp/nomad/client"
 	clientconfig "github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/state"
+	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/command/agent/consul"
 	"github.com/hashicorp/nomad/helper/pluginutils/loader"
 	"github.com/hashicorp/nomad/helper/uuid"
@@ -518,6 +519,28 @@ func convertClientConfig(agentConfig *Config) (*clientconfig.Config, error) {
 	conf.GCDiskUsageThreshold = agentConfig.Client.GCDiskUsageThreshold
 	conf.GCInodeUsageThreshold = agentConfig.Client.GCInodeUsageThreshold
 	conf.GCMaxAllocs = agentConfig.Client.GCMaxAllocs
+	conf.GCMinAllocRetention = agentConfig.Client.GCMinAllocRetention
+
+	// Set the streaming RPC limits
+	conf.MaxConcurrentStreams = agentConfig.Client.MaxConcurrentStreams
+	conf.StreamQueueTimeout = agentConfig.Client.StreamQueueTimeout
+	conf.MaxLogStreamsPerTask = agentConfig.Client.MaxLogStreamsPerTask
+	conf.AllocLookupTimeout = agentConfig.Client.AllocLookupTimeout
+
+	if !cstructs.ValidMemoryUsageSemantics(agentConfig.Client.MemoryUsageSemantics) {
+		return nil, fmt.Errorf("unknown memory_usage_semantics %q", agentConfig.Client.MemoryUsageSemantics)
+	}
+	conf.MemoryUsageSemantics = agentConfig.Client.MemoryUsageSemantics
+	if !cstructs.ValidCpuAccounting(agentConfig.Client.CpuAccounting) {
+		return nil, fmt.Errorf("unknown cpu_accounting %q", agentConfig.Client.CpuAccounting)
+	}
+	conf.CpuAccounting = agentConfig.Client.CpuAccounting
+	conf.DisableStatsNamespaces = agentConfig.Client.DisableStatsNamespaces
+	if max := agentConfig.Client.StatsAdaptiveMaxInterval; max != 0 && max < conf.StatsCollectionInterval {
+		return nil, fmt.Errorf("stats_adaptive_max_interval %s must not be lower than the collection interval %s", max, conf.StatsCollectionInterval)
+	}
+	conf.StatsAdaptiveMaxInterval = agentConfig.Client.StatsAdaptiveMaxInterval
+	conf.CollectConnectionStats = agentConfig.Client.CollectConnectionStats
 	if agentConfig.Client.NoHostUUID != nil {
 		conf.NoHostUUID = *agentConfig.Client.NoHostUUID
 	} else {
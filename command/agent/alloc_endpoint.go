@@ -2,12 +2,19 @@ package agent
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/snappy"
+	"github.com/hashicorp/nomad/client/lib/tcpconn"
 	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/nomad/structs"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
 const (
@@ -154,7 +161,12 @@ func (s *HTTPServer) allocGC(allocID string, resp http.ResponseWriter, req *http
 	var reply structs.GenericResponse
 	var rpcErr error
 	if useLocalClient {
-		rpcErr = s.agent.Client().ClientRPC("Allocations.GarbageCollect", &args, &reply)
+		localArgs := cstructs.AllocGarbageCollectRequest{
+			AllocID:      args.AllocID,
+			QueryOptions: args.QueryOptions,
+		}
+		var localReply cstructs.AllocGarbageCollectResponse
+		rpcErr = s.agent.Client().ClientRPC("Allocations.GarbageCollect", &localArgs, &localReply)
 	} else if useClientRPC {
 		rpcErr = s.agent.Client().RPC("ClientAllocations.GarbageCollect", &args, &reply)
 	} else if useServerRPC {
@@ -193,10 +205,21 @@ func (s *HTTPServer) allocStats(allocID string, resp http.ResponseWriter, req *h
 
 	// Build the request and parse the ACL token
 	task := req.URL.Query().Get("task")
+	format := req.URL.Query().Get("format")
+	if format != "" && format != "prometheus" {
+		return nil, CodedError(400, fmt.Sprintf("unsupported stats format %q", format))
+	}
 	args := cstructs.AllocStatsRequest{
 		AllocID: allocID,
 		Task:    task,
 	}
+	if req.URL.Query().Get("cumulative") != "" {
+		cumulative, err := strconv.ParseBool(req.URL.Query().Get("cumulative"))
+		if err != nil {
+			return nil, CodedError(400, fmt.Sprintf("Failed to parse cumulative field: %v", err))
+		}
+		args.Cumulative = cumulative
+	}
 	s.parse(resp, req, &args.QueryOptions.Region, &args.QueryOptions)
 
 	// Determine the handler to use
@@ -221,5 +244,93 @@ func (s *HTTPServer) allocStats(allocID string, resp http.ResponseWriter, req *h
 		}
 	}
 
+	if rpcErr == nil && format == "prometheus" {
+		resp.Header().Set("Content-Type", string(expfmt.FmtText))
+		return nil, writeAllocStatsPrometheus(resp, allocID, reply.Stats)
+	}
+
 	return reply.Stats, rpcErr
 }
+
+// writeAllocStatsPrometheus renders the per task resource usage of an
+// allocation in the Prometheus text exposition format. Metric names match the
+// ones published by the client's telemetry.
+func writeAllocStatsPrometheus(w io.Writer, allocID string, usage *cstructs.AllocResourceUsage) error {
+	if usage == nil {
+		return nil
+	}
+
+	tasks := make([]string, 0, len(usage.Tasks))
+	for name := range usage.Tasks {
+		tasks = append(tasks, name)
+	}
+	sort.Strings(tasks)
+
+	families := make(map[string]*dto.MetricFamily)
+	var names []string
+	gauge := func(name, task string, value float64) {
+		name = "nomad_client_allocs_" + name
+		family, ok := families[name]
+		if !ok {
+			family = &dto.MetricFamily{
+				Name: proto.String(name),
+				Type: dto.MetricType_GAUGE.Enum(),
+			}
+			families[name] = family
+			names = append(names, name)
+		}
+		family.Metric = append(family.Metric, &dto.Metric{
+			Label: []*dto.LabelPair{
+				{Name: proto.String("alloc_id"), Value: proto.String(allocID)},
+				{Name: proto.String("task"), Value: proto.String(task)},
+			},
+			Gauge: &dto.Gauge{Value: proto.Float64(value)},
+		})
+	}
+
+	for _, task := range tasks {
+		if cs := usage.Tasks[task].Cumulative; cs != nil {
+			gauge("cpu_cumulative_time", task, float64(cs.CpuTime))
+			gauge("disk_cumulative_bytes_written", task, float64(cs.BytesWritten))
+		}
+
+		if conns := usage.Tasks[task].ConnectionStats; conns != nil {
+			for _, state := range tcpconn.States {
+				gauge("tcp_"+strings.ToLower(state), task, float64(conns.States[state]))
+			}
+		}
+
+		ru := usage.Tasks[task].ResourceUsage
+		if ru == nil {
+			continue
+		}
+
+		if ms := ru.MemoryStats; ms != nil {
+			gauge("memory_rss", task, float64(ms.RSS))
+			gauge("memory_cache", task, float64(ms.Cache))
+			gauge("memory_swap", task, float64(ms.Swap))
+			gauge("memory_usage", task, float64(ms.Usage))
+			gauge("memory_max_usage", task, float64(ms.MaxUsage))
+			gauge("memory_kernel_usage", task, float64(ms.KernelUsage))
+			gauge("memory_kernel_max_usage", task, float64(ms.KernelMaxUsage))
+		}
+
+		if cs := ru.CpuStats; cs != nil {
+			gauge("cpu_total_percent", task, cs.Percent)
+			gauge("cpu_system", task, cs.SystemMode)
+			gauge("cpu_user", task, cs.UserMode)
+			gauge("cpu_throttled_time", task, float64(cs.ThrottledTime))
+			gauge("cpu_throttled_periods", task, float64(cs.ThrottledPeriods))
+			gauge("cpu_total_ticks", task, cs.TotalTicks)
+			gauge("cpu_wait_time", task, float64(cs.WaitTime))
+			gauge("cpu_steal_time", task, float64(cs.StealTime))
+		}
+	}
+
+	for _, name := range names {
+		if _, err := expfmt.MetricFamilyToText(w, families[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
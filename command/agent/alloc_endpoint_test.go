@@ -2,6 +2,7 @@ package agent
 
 import (
 	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,6 +16,7 @@ import (
 	"github.com/golang/snappy"
 	"github.com/hashicorp/nomad/acl"
 	"github.com/hashicorp/nomad/client/allocdir"
+	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/helper"
 	"github.com/hashicorp/nomad/helper/uuid"
 	"github.com/hashicorp/nomad/nomad/mock"
@@ -779,3 +781,51 @@ func TestHTTP_AllocAllGC_ACL(t *testing.T) {
 		}
 	})
 }
+
+func TestHTTP_AllocStats_Prometheus(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	usage := &cstructs.AllocResourceUsage{
+		Tasks: map[string]*cstructs.TaskResourceUsage{
+			"web": {
+				ResourceUsage: &cstructs.ResourceUsage{
+					MemoryStats: &cstructs.MemoryStats{RSS: 1024},
+					CpuStats:    &cstructs.CpuStats{Percent: 12.5},
+				},
+				ConnectionStats: &cstructs.ConnectionStats{
+					States: map[string]uint64{"ESTABLISHED": 3},
+				},
+			},
+			"sidecar": {
+				ResourceUsage: &cstructs.ResourceUsage{
+					MemoryStats: &cstructs.MemoryStats{RSS: 2048},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(writeAllocStatsPrometheus(&buf, "abc", usage))
+	out := buf.String()
+
+	require.Equal(1, strings.Count(out, "# TYPE nomad_client_allocs_memory_rss gauge"))
+	require.Contains(out, `nomad_client_allocs_memory_rss{alloc_id="abc",task="web"} 1024`)
+	require.Contains(out, `nomad_client_allocs_memory_rss{alloc_id="abc",task="sidecar"} 2048`)
+	require.Contains(out, `nomad_client_allocs_cpu_total_percent{alloc_id="abc",task="web"} 12.5`)
+	require.NotContains(out, `nomad_client_allocs_cpu_total_percent{alloc_id="abc",task="sidecar"}`)
+	require.Contains(out, `nomad_client_allocs_tcp_established{alloc_id="abc",task="web"} 3`)
+	require.Contains(out, `nomad_client_allocs_tcp_time_wait{alloc_id="abc",task="web"} 0`)
+	require.NotContains(out, `nomad_client_allocs_tcp_established{alloc_id="abc",task="sidecar"}`)
+
+	// Unknown formats are rejected before making the RPC
+	httpTest(t, nil, func(s *TestAgent) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/v1/client/allocation/%s/stats?format=xml", uuid.Generate()), nil)
+		require.Nil(err)
+
+		respW := httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		require.NotNil(err)
+		require.Contains(err.Error(), "unsupported stats format")
+	})
+}
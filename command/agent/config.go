@@ -223,6 +223,46 @@ type ClientConfig struct {
 	// before garbage collection is triggered.
 	GCMaxAllocs int `mapstructure:"gc_max_allocs"`
 
+	// GCMinAllocRetention is the minimum time terminal allocations are kept
+	// before being garbage collected because of gc_max_allocs.
+	GCMinAllocRetention time.Duration `mapstructure:"gc_min_alloc_retention"`
+
+	// MaxConcurrentStreams is the maximum number of streaming RPCs, such as
+	// log streams, the client serves concurrently. Zero means no limit.
+	MaxConcurrentStreams int `mapstructure:"max_concurrent_streams"`
+
+	// StreamQueueTimeout is how long a stream waits for a slot once
+	// MaxConcurrentStreams is reached. Zero rejects streams immediately.
+	StreamQueueTimeout time.Duration `mapstructure:"stream_queue_timeout"`
+
+	// MaxLogStreamsPerTask is the maximum number of log streams the client
+	// serves concurrently for a single task. Zero means no limit.
+	MaxLogStreamsPerTask int `mapstructure:"max_log_streams_per_task"`
+
+	// AllocLookupTimeout is how long alloc endpoints wait to look up an
+	// allocation when the client is busy. Zero waits indefinitely.
+	AllocLookupTimeout time.Duration `mapstructure:"alloc_lookup_timeout"`
+
+	// MemoryUsageSemantics chooses the metric reported as the memory usage of
+	// tasks: raw, cache-excluded or working-set.
+	MemoryUsageSemantics string `mapstructure:"memory_usage_semantics"`
+
+	// CpuAccounting chooses the convention of the CPU percent of tasks:
+	// core-percent, host-percent or cores.
+	CpuAccounting string `mapstructure:"cpu_accounting"`
+
+	// DisableStatsNamespaces are the namespaces whose allocations don't have
+	// their resource usage collected.
+	DisableStatsNamespaces []string `mapstructure:"disable_stats_namespaces"`
+
+	// StatsAdaptiveMaxInterval is the interval up to which the stats
+	// collection of idle tasks backs off. Zero disables backing off.
+	StatsAdaptiveMaxInterval time.Duration `mapstructure:"stats_adaptive_max_interval"`
+
+	// CollectConnectionStats enables counting the TCP sockets of tasks with
+	// their own network namespace on every stats collection.
+	CollectConnectionStats bool `mapstructure:"collect_connection_stats"`
+
 	// NoHostUUID disables using the host's UUID and will force generation of a
 	// random UUID.
 	NoHostUUID *bool `mapstructure:"no_host_uuid"`
@@ -1199,6 +1239,36 @@ func (a *ClientConfig) Merge(b *ClientConfig) *ClientConfig {
 	if b.GCMaxAllocs != 0 {
 		result.GCMaxAllocs = b.GCMaxAllocs
 	}
+	if b.GCMinAllocRetention != 0 {
+		result.GCMinAllocRetention = b.GCMinAllocRetention
+	}
+	if b.MaxConcurrentStreams != 0 {
+		result.MaxConcurrentStreams = b.MaxConcurrentStreams
+	}
+	if b.StreamQueueTimeout != 0 {
+		result.StreamQueueTimeout = b.StreamQueueTimeout
+	}
+	if b.MaxLogStreamsPerTask != 0 {
+		result.MaxLogStreamsPerTask = b.MaxLogStreamsPerTask
+	}
+	if b.AllocLookupTimeout != 0 {
+		result.AllocLookupTimeout = b.AllocLookupTimeout
+	}
+	if b.MemoryUsageSemantics != "" {
+		result.MemoryUsageSemantics = b.MemoryUsageSemantics
+	}
+	if b.CpuAccounting != "" {
+		result.CpuAccounting = b.CpuAccounting
+	}
+	if len(b.DisableStatsNamespaces) != 0 {
+		result.DisableStatsNamespaces = b.DisableStatsNamespaces
+	}
+	if b.StatsAdaptiveMaxInterval != 0 {
+		result.StatsAdaptiveMaxInterval = b.StatsAdaptiveMaxInterval
+	}
+	if b.CollectConnectionStats {
+		result.CollectConnectionStats = true
+	}
 	// NoHostUUID defaults to true, merge if false
 	if b.NoHostUUID != nil {
 		result.NoHostUUID = b.NoHostUUID
@@ -380,6 +380,16 @@ func parseClient(result **ClientConfig, list *ast.ObjectList) error {
 		"gc_inode_usage_threshold",
 		"gc_parallel_destroys",
 		"gc_max_allocs",
+		"gc_min_alloc_retention",
+		"max_concurrent_streams",
+		"stream_queue_timeout",
+		"max_log_streams_per_task",
+		"alloc_lookup_timeout",
+		"memory_usage_semantics",
+		"cpu_accounting",
+		"disable_stats_namespaces",
+		"stats_adaptive_max_interval",
+		"collect_connection_stats",
 		"no_host_uuid",
 		"server_join",
 	}
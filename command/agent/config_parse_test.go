@@ -79,12 +79,22 @@ func TestConfig_Parse(t *testing.T) {
 						DiskMB:        10,
 						ReservedPorts: "1,100,10-12",
 					},
-					GCInterval:            6 * time.Second,
-					GCParallelDestroys:    6,
-					GCDiskUsageThreshold:  82,
-					GCInodeUsageThreshold: 91,
-					GCMaxAllocs:           50,
-					NoHostUUID:            helper.BoolToPtr(false),
+					GCInterval:               6 * time.Second,
+					GCParallelDestroys:       6,
+					GCDiskUsageThreshold:     82,
+					GCInodeUsageThreshold:    91,
+					GCMaxAllocs:              50,
+					GCMinAllocRetention:      30 * time.Minute,
+					MaxConcurrentStreams:     20,
+					StreamQueueTimeout:       15 * time.Second,
+					MaxLogStreamsPerTask:     4,
+					AllocLookupTimeout:       2 * time.Second,
+					MemoryUsageSemantics:     "working-set",
+					CpuAccounting:            "cores",
+					DisableStatsNamespaces:   []string{"batch"},
+					StatsAdaptiveMaxInterval: 30 * time.Second,
+					CollectConnectionStats:   true,
+					NoHostUUID:               helper.BoolToPtr(false),
 				},
 				Server: &ServerConfig{
 					Enabled:                true,
@@ -300,12 +310,22 @@ func TestConfig_Parse(t *testing.T) {
 						DiskMB:        10,
 						ReservedPorts: "1,100,10-12",
 					},
-					GCInterval:            6 * time.Second,
-					GCParallelDestroys:    6,
-					GCDiskUsageThreshold:  82,
-					GCInodeUsageThreshold: 91,
-					GCMaxAllocs:           50,
-					NoHostUUID:            helper.BoolToPtr(false),
+					GCInterval:               6 * time.Second,
+					GCParallelDestroys:       6,
+					GCDiskUsageThreshold:     82,
+					GCInodeUsageThreshold:    91,
+					GCMaxAllocs:              50,
+					GCMinAllocRetention:      30 * time.Minute,
+					MaxConcurrentStreams:     20,
+					StreamQueueTimeout:       15 * time.Second,
+					MaxLogStreamsPerTask:     4,
+					AllocLookupTimeout:       2 * time.Second,
+					MemoryUsageSemantics:     "working-set",
+					CpuAccounting:            "cores",
+					DisableStatsNamespaces:   []string{"batch"},
+					StatsAdaptiveMaxInterval: 30 * time.Second,
+					CollectConnectionStats:   true,
+					NoHostUUID:               helper.BoolToPtr(false),
 				},
 				Server: &ServerConfig{
 					Enabled:                true,
@@ -257,10 +257,20 @@ func TestConfig_Merge(t *testing.T) {
 				DiskMB:        15,
 				ReservedPorts: "2,10-30,55",
 			},
-			GCInterval:            6 * time.Second,
-			GCParallelDestroys:    6,
-			GCDiskUsageThreshold:  71,
-			GCInodeUsageThreshold: 86,
+			GCInterval:               6 * time.Second,
+			GCParallelDestroys:       6,
+			GCDiskUsageThreshold:     71,
+			GCInodeUsageThreshold:    86,
+			GCMinAllocRetention:      30 * time.Minute,
+			MaxConcurrentStreams:     20,
+			StreamQueueTimeout:       15 * time.Second,
+			MaxLogStreamsPerTask:     4,
+			AllocLookupTimeout:       2 * time.Second,
+			MemoryUsageSemantics:     "working-set",
+			CpuAccounting:            "cores",
+			DisableStatsNamespaces:   []string{"batch"},
+			StatsAdaptiveMaxInterval: 30 * time.Second,
+			CollectConnectionStats:   true,
 		},
 		Server: &ServerConfig{
 			Enabled:                true,
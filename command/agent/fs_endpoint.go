@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/pkg/ioutils"
 	cstructs "github.com/hashicorp/nomad/client/structs"
@@ -197,6 +198,8 @@ func (s *HTTPServer) FileCatRequest(resp http.ResponseWriter, req *http.Request)
 // * offset: The offset to start streaming data at, defaults to zero.
 // * origin: Either "start" or "end" and defines from where the offset is
 //           applied. Defaults to "start".
+// * compress: A boolean of whether to gzip the data of large frames, which
+//           then have Compressed set.
 func (s *HTTPServer) Stream(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	var allocID, path string
 
@@ -228,13 +231,22 @@ func (s *HTTPServer) Stream(resp http.ResponseWriter, req *http.Request) (interf
 		return nil, invalidOrigin
 	}
 
+	var compress bool
+	if compressStr := q.Get("compress"); compressStr != "" {
+		var err error
+		if compress, err = strconv.ParseBool(compressStr); err != nil {
+			return nil, fmt.Errorf("error parsing compress: %v", err)
+		}
+	}
+
 	// Create the request arguments
 	fsReq := &cstructs.FsStreamRequest{
-		AllocID: allocID,
-		Path:    path,
-		Origin:  origin,
-		Offset:  offset,
-		Follow:  true,
+		AllocID:  allocID,
+		Path:     path,
+		Origin:   origin,
+		Offset:   offset,
+		Follow:   true,
+		Compress: compress,
 	}
 	s.parse(resp, req, &fsReq.QueryOptions.Region, &fsReq.QueryOptions)
 
@@ -246,12 +258,27 @@ func (s *HTTPServer) Stream(resp http.ResponseWriter, req *http.Request) (interf
 // * task: task name to stream logs for.
 // * type: stdout/stderr to stream.
 // * follow: A boolean of whether to follow the logs.
+// * allow_after_exit: A boolean of whether following the logs of an exited
+//           task streams its persisted logs and closes.
+// * max_line_length: The length after which streamed lines are truncated.
+// * delimiter: The byte separating the records max_line_length truncates,
+//           as a character or an escape sequence such as \x00. Defaults to a
+//           newline. It only affects truncation, not how data is framed.
 // * offset: The offset to start streaming data at, defaults to zero.
 // * origin: Either "start" or "end" and defines from where the offset is
 //           applied. Defaults to "start".
+// * start_time: An RFC3339 time to start streaming at, overriding the offset.
+//           Logs don't carry timestamps, so streaming starts at the beginning
+//           of the first log file last written at or after the time and may
+//           include older lines of that file.
+// * strip_ansi: A boolean of whether to remove ANSI escape sequences.
+// * heartbeat_interval: A duration at which followers are sent the state of
+//           the log file.
+// * compress: A boolean of whether to gzip the data of large frames, which
+//           then have Compressed set. Ignored when plain is set.
 func (s *HTTPServer) Logs(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	var allocID, task, logType string
-	var plain, follow bool
+	var plain, follow, allowAfterExit, stripANSI, compress bool
 	var err error
 
 	q := req.URL.Query()
@@ -269,12 +296,30 @@ func (s *HTTPServer) Logs(resp http.ResponseWriter, req *http.Request) (interfac
 		}
 	}
 
+	if allowStr := q.Get("allow_after_exit"); allowStr != "" {
+		if allowAfterExit, err = strconv.ParseBool(allowStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse allow_after_exit field to boolean: %v", err)
+		}
+	}
+
 	if plainStr := q.Get("plain"); plainStr != "" {
 		if plain, err = strconv.ParseBool(plainStr); err != nil {
 			return nil, fmt.Errorf("Failed to parse plain field to boolean: %v", err)
 		}
 	}
 
+	if stripStr := q.Get("strip_ansi"); stripStr != "" {
+		if stripANSI, err = strconv.ParseBool(stripStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse strip_ansi field to boolean: %v", err)
+		}
+	}
+
+	if compressStr := q.Get("compress"); compressStr != "" {
+		if compress, err = strconv.ParseBool(compressStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse compress field to boolean: %v", err)
+		}
+	}
+
 	logType = q.Get("type")
 	switch logType {
 	case "stdout", "stderr":
@@ -300,15 +345,52 @@ func (s *HTTPServer) Logs(resp http.ResponseWriter, req *http.Request) (interfac
 		return nil, invalidOrigin
 	}
 
+	var maxLineLength int
+	if maxStr := q.Get("max_line_length"); maxStr != "" {
+		if maxLineLength, err = strconv.Atoi(maxStr); err != nil {
+			return nil, fmt.Errorf("error parsing max_line_length: %v", err)
+		}
+	}
+
+	var delimiter *byte
+	if delimStr := q.Get("delimiter"); delimStr != "" {
+		delim, err := parseLogDelimiter(delimStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing delimiter: %v", err)
+		}
+		delimiter = &delim
+	}
+
+	var startTime time.Time
+	if startStr := q.Get("start_time"); startStr != "" {
+		if startTime, err = time.Parse(time.RFC3339, startStr); err != nil {
+			return nil, fmt.Errorf("error parsing start_time: %v", err)
+		}
+	}
+
+	var heartbeatInterval time.Duration
+	if intervalStr := q.Get("heartbeat_interval"); intervalStr != "" {
+		if heartbeatInterval, err = time.ParseDuration(intervalStr); err != nil {
+			return nil, fmt.Errorf("error parsing heartbeat_interval: %v", err)
+		}
+	}
+
 	// Create the request arguments
 	fsReq := &cstructs.FsLogsRequest{
-		AllocID:   allocID,
-		Task:      task,
-		LogType:   logType,
-		Offset:    offset,
-		Origin:    origin,
-		PlainText: plain,
-		Follow:    follow,
+		AllocID:           allocID,
+		Task:              task,
+		LogType:           logType,
+		Offset:            offset,
+		Origin:            origin,
+		PlainText:         plain,
+		Follow:            follow,
+		AllowAfterExit:    allowAfterExit,
+		MaxLineLength:     maxLineLength,
+		Delimiter:         delimiter,
+		StartTime:         startTime,
+		StripANSI:         stripANSI,
+		HeartbeatInterval: heartbeatInterval,
+		Compress:          compress,
 	}
 	s.parse(resp, req, &fsReq.QueryOptions.Region, &fsReq.QueryOptions)
 
@@ -406,3 +488,20 @@ func (s *HTTPServer) fsStreamImpl(resp http.ResponseWriter,
 	}
 	return nil, codedErr
 }
+
+// parseLogDelimiter parses a log record delimiter given either as a single
+// byte or as an escape sequence such as \x00 or \t.
+func parseLogDelimiter(s string) (byte, error) {
+	if len(s) == 1 {
+		return s[0], nil
+	}
+
+	value, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+	if err != nil {
+		return 0, err
+	}
+	if tail != "" || multibyte || value > 0xff {
+		return 0, fmt.Errorf("delimiter %q must be a single byte", s)
+	}
+	return byte(value), nil
+}
@@ -1,7 +1,9 @@
 package agent
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/nomad/api"
 	cstructs "github.com/hashicorp/nomad/client/structs"
 	"github.com/hashicorp/nomad/nomad/mock"
 	"github.com/hashicorp/nomad/nomad/structs"
@@ -389,6 +392,64 @@ func TestHTTP_FS_Stream(t *testing.T) {
 	})
 }
 
+func TestHTTP_FS_Stream_Compress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	httpTest(t, nil, func(s *TestAgent) {
+		stdout := strings.Repeat("Hello from the other side\n", 200)
+		a := mockFSAlloc(s.client.NodeID(), map[string]interface{}{
+			"run_for":       "2s",
+			"stdout_string": stdout,
+		})
+		addAllocToClient(s, a, terminalClientAlloc)
+
+		path := fmt.Sprintf("/v1/client/fs/stream/%s?path=alloc/logs/web.stdout.0&compress=true", a.ID)
+
+		p, _ := io.Pipe()
+		defer p.Close()
+
+		req, err := http.NewRequest("GET", path, p)
+		require.Nil(err)
+		respW := httptest.NewRecorder()
+		go func() {
+			_, err := s.Server.Stream(respW, req)
+			require.Nil(err)
+		}()
+
+		// The frames decode to the file's content once decompressed
+		var out []byte
+		testutil.WaitForResult(func() (bool, error) {
+			output, err := ioutil.ReadAll(respW.Body)
+			if err != nil {
+				return false, err
+			}
+			out = append(out, output...)
+
+			var data []byte
+			compressed := false
+			dec := json.NewDecoder(bytes.NewReader(out))
+			for dec.More() {
+				var frame api.StreamFrame
+				if err := dec.Decode(&frame); err != nil {
+					return false, err
+				}
+				compressed = compressed || frame.Compressed
+				if err := frame.Decompress(); err != nil {
+					return false, err
+				}
+				data = append(data, frame.Data...)
+			}
+
+			if string(data) != stdout {
+				return false, fmt.Errorf("got %d bytes of %d", len(data), len(stdout))
+			}
+			return compressed, fmt.Errorf("no frame was compressed")
+		}, func(err error) {
+			t.Fatal(err)
+		})
+	})
+}
+
 func TestHTTP_FS_Logs(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -427,6 +488,64 @@ func TestHTTP_FS_Logs(t *testing.T) {
 	})
 }
 
+func TestHTTP_FS_Logs_Compress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	httpTest(t, nil, func(s *TestAgent) {
+		stdout := strings.Repeat("Hello from the other side\n", 200)
+		a := mockFSAlloc(s.client.NodeID(), map[string]interface{}{
+			"run_for":       "2s",
+			"stdout_string": stdout,
+		})
+		addAllocToClient(s, a, terminalClientAlloc)
+
+		path := fmt.Sprintf("/v1/client/fs/logs/%s?type=stdout&task=web&compress=true", a.ID)
+
+		p, _ := io.Pipe()
+		defer p.Close()
+
+		req, err := http.NewRequest("GET", path, p)
+		require.Nil(err)
+		respW := httptest.NewRecorder()
+		go func() {
+			_, err := s.Server.Logs(respW, req)
+			require.Nil(err)
+		}()
+
+		// The frames decode to the logs once decompressed
+		var out []byte
+		testutil.WaitForResult(func() (bool, error) {
+			output, err := ioutil.ReadAll(respW.Body)
+			if err != nil {
+				return false, err
+			}
+			out = append(out, output...)
+
+			var data []byte
+			compressed := false
+			dec := json.NewDecoder(bytes.NewReader(out))
+			for dec.More() {
+				var frame api.StreamFrame
+				if err := dec.Decode(&frame); err != nil {
+					return false, err
+				}
+				compressed = compressed || frame.Compressed
+				if err := frame.Decompress(); err != nil {
+					return false, err
+				}
+				data = append(data, frame.Data...)
+			}
+
+			if string(data) != stdout {
+				return false, fmt.Errorf("got %d bytes of %d", len(data), len(stdout))
+			}
+			return compressed, fmt.Errorf("no frame was compressed")
+		}, func(err error) {
+			t.Fatal(err)
+		})
+	})
+}
+
 func TestHTTP_FS_Logs_Follow(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -471,3 +590,27 @@ func TestHTTP_FS_Logs_Follow(t *testing.T) {
 		p.Close()
 	})
 }
+
+func TestHTTP_FS_parseLogDelimiter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cases := map[string]byte{
+		"|":    '|',
+		"\x00": 0,
+		`\x00`: 0,
+		`\000`: 0,
+		`\t`:   '\t',
+		`\n`:   '\n',
+	}
+	for input, expected := range cases {
+		delim, err := parseLogDelimiter(input)
+		require.NoError(err, "input %q", input)
+		require.Equal(expected, delim, "input %q", input)
+	}
+
+	for _, input := range []string{"ab", "é", `\x0`} {
+		_, err := parseLogDelimiter(input)
+		require.Error(err, "input %q", input)
+	}
+}
@@ -67,6 +67,16 @@ client {
 	gc_disk_usage_threshold = 82
 	gc_inode_usage_threshold = 91
 	gc_max_allocs = 50
+	gc_min_alloc_retention = "30m"
+	max_concurrent_streams = 20
+	stream_queue_timeout = "15s"
+	max_log_streams_per_task = 4
+	alloc_lookup_timeout = "2s"
+	memory_usage_semantics = "working-set"
+	cpu_accounting = "cores"
+	disable_stats_namespaces = ["batch"]
+	stats_adaptive_max_interval = "30s"
+	collect_connection_stats = true
 	no_host_uuid = false
 }
 server {
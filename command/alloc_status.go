@@ -591,6 +591,10 @@ func (c *AllocStatusCommand) outputVerboseResourceUsage(task string, resourceUsa
 				measuredStats = append(measuredStats, fmt.Sprintf("%v", cpuStats.ThrottledPeriods))
 			case "Throttled Time":
 				measuredStats = append(measuredStats, fmt.Sprintf("%v", cpuStats.ThrottledTime))
+			case "Wait Time":
+				measuredStats = append(measuredStats, fmt.Sprintf("%v", cpuStats.WaitTime))
+			case "Steal Time":
+				measuredStats = append(measuredStats, fmt.Sprintf("%v", cpuStats.StealTime))
 			case "User Mode":
 				percent := strconv.FormatFloat(cpuStats.UserMode, 'f', 2, 64)
 				measuredStats = append(measuredStats, fmt.Sprintf("%v%%", percent))
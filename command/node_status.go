@@ -604,7 +604,7 @@ func (c *NodeStatusCommand) printMemoryStats(hostStats *api.HostStats) {
 func (c *NodeStatusCommand) printDiskStats(hostStats *api.HostStats) {
 	l := len(hostStats.DiskStats)
 	for i, diskStat := range hostStats.DiskStats {
-		diskStatsAttr := make([]string, 7)
+		diskStatsAttr := make([]string, 9)
 		diskStatsAttr[0] = fmt.Sprintf("Device|%s", diskStat.Device)
 		diskStatsAttr[1] = fmt.Sprintf("MountPoint|%s", diskStat.Mountpoint)
 		diskStatsAttr[2] = fmt.Sprintf("Size|%s", humanize.IBytes(diskStat.Size))
@@ -612,6 +612,8 @@ func (c *NodeStatusCommand) printDiskStats(hostStats *api.HostStats) {
 		diskStatsAttr[4] = fmt.Sprintf("Available|%s", humanize.IBytes(diskStat.Available))
 		diskStatsAttr[5] = fmt.Sprintf("Used Percent|%v%%", humanize.FormatFloat(floatFormat, diskStat.UsedPercent))
 		diskStatsAttr[6] = fmt.Sprintf("Inodes Percent|%v%%", humanize.FormatFloat(floatFormat, diskStat.InodesUsedPercent))
+		diskStatsAttr[7] = fmt.Sprintf("Inodes Used|%d", diskStat.InodesUsed)
+		diskStatsAttr[8] = fmt.Sprintf("Inodes Total|%d", diskStat.InodesTotal)
 		c.Ui.Output(formatKV(diskStatsAttr))
 		if i+1 < l {
 			c.Ui.Output("")
@@ -1141,6 +1141,7 @@ func (d *Driver) InspectTask(taskID string) (*drivers.TaskStatus, error) {
 		CompletedAt: container.State.FinishedAt,
 		DriverAttributes: map[string]string{
 			"container_id": container.ID,
+			"pid":          strconv.Itoa(container.State.Pid),
 		},
 		NetworkOverride: h.net,
 		ExitResult:      h.ExitResult(),
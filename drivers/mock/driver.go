@@ -495,20 +495,27 @@ func (d *Driver) DestroyTask(taskID string, force bool) error {
 }
 
 func (d *Driver) InspectTask(taskID string) (*drivers.TaskStatus, error) {
-	panic("not implemented")
+	handle, ok := d.tasks.Get(taskID)
+	if !ok {
+		return nil, drivers.ErrTaskNotFound
+	}
+
+	return handle.TaskStatus(), nil
 }
 
 func (d *Driver) TaskStats(ctx context.Context, taskID string, interval time.Duration) (<-chan *drivers.TaskResourceUsage, error) {
 	ch := make(chan *drivers.TaskResourceUsage)
-	go d.handleStats(ctx, ch)
+	go d.handleStats(ctx, ch, interval)
 	return ch, nil
 }
 
-func (d *Driver) handleStats(ctx context.Context, ch chan<- *drivers.TaskResourceUsage) {
+func (d *Driver) handleStats(ctx context.Context, ch chan<- *drivers.TaskResourceUsage, interval time.Duration) {
 	timer := time.NewTimer(0)
 	for {
 		select {
 		case <-timer.C:
+			timer.Reset(interval)
+
 			// Generate random value for the memory usage
 			s := &drivers.TaskResourceUsage{
 				ResourceUsage: &drivers.ResourceUsage{
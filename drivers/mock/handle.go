@@ -3,6 +3,8 @@ package mock
 import (
 	"context"
 	"io"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -51,13 +53,16 @@ func (h *taskHandle) TaskStatus() *drivers.TaskStatus {
 	defer h.stateLock.RUnlock()
 
 	return &drivers.TaskStatus{
-		ID:               h.taskConfig.ID,
-		Name:             h.taskConfig.Name,
-		State:            h.procState,
-		StartedAt:        h.startedAt,
-		CompletedAt:      h.completedAt,
-		ExitResult:       h.exitResult,
-		DriverAttributes: map[string]string{},
+		ID:          h.taskConfig.ID,
+		Name:        h.taskConfig.Name,
+		State:       h.procState,
+		StartedAt:   h.startedAt,
+		CompletedAt: h.completedAt,
+		ExitResult:  h.exitResult,
+		DriverAttributes: map[string]string{
+			// Mock tasks run inside the plugin process
+			"pid": strconv.Itoa(os.Getpid()),
+		},
 	}
 }
 